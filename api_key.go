@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/open-ness/edgecontroller/uuid"
+)
+
+// APIKeyPrefix prefixes every API key, so that API keys can be told apart
+// from tokens and recognized by secret scanners.
+const APIKeyPrefix = "cceak_"
+
+// MaxAPIKeyNameLength is the maximum length of an API key name.
+const MaxAPIKeyNameLength = 64
+
+// APIKeyActor returns the actor recorded for the changes made with an API key.
+func APIKeyActor(id string) string {
+	return "apikey:" + id
+}
+
+// APIKey is a long-lived credential for automation. The key itself is only
+// returned when it is created, only its SHA-256 hash is stored.
+//
+// A key grants its role on the paths matching its scopes, or on all paths if
+// it has no scopes. A scope is a path whose segments may be "*" and matches
+// the paths it is a prefix of, e.g. "/nodes/*/apps" matches
+// "/nodes/{node_id}/apps" and "/nodes/{node_id}/apps/{app_id}".
+type APIKey struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Role       string   `json:"role"`
+	Scopes     []string `json:"scopes,omitempty"`
+	Expiry     string   `json:"expiry,omitempty"`
+	CreatedBy  string   `json:"created_by"`
+	SecretHash string   `json:"secret_hash"`
+}
+
+// GetTableName returns the name of the persistence table.
+func (*APIKey) GetTableName() string {
+	return "api_keys"
+}
+
+// GetID gets the ID.
+func (k *APIKey) GetID() string {
+	return k.ID
+}
+
+// SetID sets the ID.
+func (k *APIKey) SetID(id string) {
+	k.ID = id
+}
+
+// FilterFields returns the filterable fields for this model.
+func (*APIKey) FilterFields() []string {
+	return []string{
+		"name",
+		"role",
+		"created_by",
+	}
+}
+
+// Validate validates the model.
+func (k *APIKey) Validate() error {
+	if !uuid.IsValid(k.ID) {
+		return errors.New("id not a valid uuid")
+	}
+	if k.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	if len(k.Name) > MaxAPIKeyNameLength {
+		return fmt.Errorf("name must be at most %d characters", MaxAPIKeyNameLength)
+	}
+	if roleRank(k.Role) < 0 {
+		return fmt.Errorf("role must be one of %s", strings.Join(Roles, ", "))
+	}
+	for i, s := range k.Scopes {
+		if !strings.HasPrefix(s, "/") || strings.HasSuffix(s, "/") || strings.Contains(s, "//") {
+			return fmt.Errorf("scopes[%d] must be an absolute path without trailing slash", i)
+		}
+	}
+	if k.Expiry != "" {
+		if _, err := time.Parse(time.RFC3339, k.Expiry); err != nil {
+			return errors.New("expiry not a valid RFC 3339 timestamp")
+		}
+	}
+	if k.SecretHash == "" {
+		return errors.New("secret_hash cannot be empty")
+	}
+
+	return nil
+}
+
+// GenerateSecret sets the secret hash to the hash of a new random secret and
+// returns the API key, which is the prefix, the ID and the secret.
+func (k *APIKey) GenerateSecret() (string, error) {
+	secret, err := generateSecret()
+	if err != nil {
+		return "", err
+	}
+	k.SecretHash = hashSecret(secret)
+
+	return APIKeyPrefix + k.ID + "." + secret, nil
+}
+
+// generateSecret returns a new random secret, base64-encoded without padding.
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// ParseAPIKey returns the ID and secret of an API key. ok is false if key is
+// not an API key.
+func ParseAPIKey(key string) (id, secret string, ok bool) {
+	return parseSecretToken(APIKeyPrefix, key)
+}
+
+// parseSecretToken returns the ID and secret of a token made of prefix, an ID
+// and a secret separated by a dot.
+func parseSecretToken(prefix, token string) (id, secret string, ok bool) {
+	if !strings.HasPrefix(token, prefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(token, prefix), ".", 2)
+	if len(parts) != 2 || !uuid.IsValid(parts[0]) || parts[1] == "" {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// CheckSecret returns whether secret is the secret of the key. The hashes are
+// compared in constant time.
+func (k *APIKey) CheckSecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(k.SecretHash), []byte(hashSecret(secret))) == 1
+}
+
+// Expired returns whether the key has expired at t.
+func (k *APIKey) Expired(t time.Time) bool {
+	if k.Expiry == "" {
+		return false
+	}
+	expiry, err := time.Parse(time.RFC3339, k.Expiry)
+	return err != nil || !t.Before(expiry)
+}
+
+// InScope returns whether path matches any of the scopes of the key.
+func (k *APIKey) InScope(path string) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+
+	segments := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	for _, scope := range k.Scopes {
+		if scopeMatches(strings.Split(scope, "/"), segments) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func scopeMatches(scope, segments []string) bool {
+	if len(segments) < len(scope) {
+		return false
+	}
+	for i, s := range scope {
+		if s != "*" && s != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Redacted returns a copy of the API key without the secret hash.
+func (k *APIKey) Redacted() Persistable {
+	redacted := *k
+	redacted.SecretHash = ""
+	return &redacted
+}
+
+func (k *APIKey) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+APIKey[
+    ID: %s
+    Name: %s
+    Role: %s
+    Scopes: %v
+    Expiry: %s
+    CreatedBy: %s
+]`),
+		k.ID,
+		k.Name,
+		k.Role,
+		k.Scopes,
+		k.Expiry,
+		k.CreatedBy)
+}
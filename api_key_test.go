@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+)
+
+var _ = Describe("Entities: APIKey", func() {
+	var (
+		k   *cce.APIKey
+		key string
+	)
+
+	BeforeEach(func() {
+		k = &cce.APIKey{
+			ID:        "3c6a2d1e-8f4b-4e7a-9c5d-2b1a0f9e8d7c",
+			Name:      "ci",
+			Role:      cce.RoleOperator,
+			Scopes:    []string{"/nodes/*/apps"},
+			CreatedBy: "admin",
+		}
+		var err error
+		key, err = k.GenerateSecret()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Describe("GetTableName", func() {
+		It(`Should return "api_keys"`, func() {
+			Expect(k.GetTableName()).To(Equal("api_keys"))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("Should not return an error for a valid API key", func() {
+			Expect(k.Validate()).To(Succeed())
+		})
+
+		DescribeTable("Invalid API keys",
+			func(modify func(k *cce.APIKey), expectedErr string) {
+				modify(k)
+				Expect(k.Validate()).To(MatchError(expectedErr))
+			},
+			Entry("Invalid ID", func(k *cce.APIKey) { k.ID = "123" }, "id not a valid uuid"),
+			Entry("No name", func(k *cce.APIKey) { k.Name = "" }, "name cannot be empty"),
+			Entry("Long name", func(k *cce.APIKey) { k.Name = strings.Repeat("a", 65) },
+				"name must be at most 64 characters"),
+			Entry("Unknown role", func(k *cce.APIKey) { k.Role = "root" },
+				"role must be one of read-only, operator, admin"),
+			Entry("Relative scope", func(k *cce.APIKey) { k.Scopes = []string{"/apps", "nodes"} },
+				"scopes[1] must be an absolute path without trailing slash"),
+			Entry("Invalid expiry", func(k *cce.APIKey) { k.Expiry = "next week" },
+				"expiry not a valid RFC 3339 timestamp"),
+			Entry("No secret", func(k *cce.APIKey) { k.SecretHash = "" }, "secret_hash cannot be empty"),
+		)
+	})
+
+	Describe("GenerateSecret", func() {
+		It("Should return a key that can be parsed and checked", func() {
+			Expect(key).To(HavePrefix(cce.APIKeyPrefix))
+			Expect(k.SecretHash).ToNot(ContainSubstring(key[len(cce.APIKeyPrefix)+len(k.ID)+1:]))
+
+			id, secret, ok := cce.ParseAPIKey(key)
+			Expect(ok).To(BeTrue())
+			Expect(id).To(Equal(k.ID))
+			Expect(k.CheckSecret(secret)).To(BeTrue())
+			Expect(k.CheckSecret(secret + "x")).To(BeFalse())
+		})
+	})
+
+	Describe("ParseAPIKey", func() {
+		DescribeTable("Not API keys",
+			func(key string) {
+				_, _, ok := cce.ParseAPIKey(key)
+				Expect(ok).To(BeFalse())
+			},
+			Entry("A JWT", "eyJhbGciOiJFUzM4NCJ9.eyJzdWIiOiJhZG1pbiJ9.c2ln"),
+			Entry("No secret", cce.APIKeyPrefix+"3c6a2d1e-8f4b-4e7a-9c5d-2b1a0f9e8d7c."),
+			Entry("Invalid ID", cce.APIKeyPrefix+"123.secret"),
+		)
+	})
+
+	Describe("Expired", func() {
+		It("Should never expire without an expiry", func() {
+			Expect(k.Expired(time.Now().Add(100 * 365 * 24 * time.Hour))).To(BeFalse())
+		})
+
+		It("Should expire at the expiry", func() {
+			k.Expiry = "2020-03-01T12:00:00Z"
+			expiry := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+			Expect(k.Expired(expiry.Add(-time.Second))).To(BeFalse())
+			Expect(k.Expired(expiry)).To(BeTrue())
+		})
+	})
+
+	Describe("InScope", func() {
+		DescribeTable("Paths",
+			func(scopes []string, path string, inScope bool) {
+				k.Scopes = scopes
+				Expect(k.InScope(path)).To(Equal(inScope))
+			},
+			Entry("No scopes", nil, "/users", true),
+			Entry("Matching wildcard", []string{"/nodes/*/apps"}, "/nodes/123/apps", true),
+			Entry("Subpath", []string{"/nodes/*/apps"}, "/nodes/123/apps/456", true),
+			Entry("Other subpath", []string{"/nodes/*/apps"}, "/nodes/123/interfaces", false),
+			Entry("Parent path", []string{"/nodes/*/apps"}, "/nodes/123", false),
+			Entry("Common prefix", []string{"/apps"}, "/appsx", false),
+			Entry("Any scope", []string{"/apps", "/policies"}, "/policies", true),
+		)
+	})
+
+	Describe("Redacted", func() {
+		It("Should not contain the secret hash", func() {
+			redacted := k.Redacted().(*cce.APIKey)
+			Expect(redacted.SecretHash).To(BeEmpty())
+			Expect(k.SecretHash).ToNot(BeEmpty())
+		})
+	})
+})
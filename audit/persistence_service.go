@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+// Package audit records the changes made through a cce.PersistenceService in an append-only audit trail.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/uuid"
+	"github.com/pkg/errors"
+)
+
+// ErrAppendOnly is returned when updating or deleting an audit record.
+var ErrAppendOnly = errors.New("audit records are append-only")
+
+// PersistenceService wraps a cce.PersistenceService and records every Create, BulkUpdate and Delete (including the
+// IfMatch variants) as a cce.AuditRecord in the same transaction as the change. The actor is taken from the context
+// (see cce.WithActor). Rows deleted by ON DELETE CASCADE are not recorded.
+type PersistenceService struct {
+	cce.PersistenceService
+}
+
+// Create persists a resource and records its creation.
+func (s *PersistenceService) Create(
+	ctx context.Context,
+	e cce.Persistable,
+) error {
+	if _, ok := e.(*cce.AuditRecord); ok {
+		return s.PersistenceService.Create(ctx, e)
+	}
+
+	return s.PersistenceService.WithTx(ctx, func(tx cce.PersistenceService) error {
+		if err := tx.Create(ctx, e); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, cce.AuditCreate, e.GetTableName(), e.GetID(), nil, e)
+	})
+}
+
+// BulkUpdate updates multiple resources and records their updates.
+func (s *PersistenceService) BulkUpdate(
+	ctx context.Context,
+	es []cce.Persistable,
+) error {
+	return s.PersistenceService.WithTx(ctx, func(tx cce.PersistenceService) error {
+		for _, e := range es {
+			if _, err := update(ctx, tx, e, 0); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// UpdateIfMatch updates a resource if its revision matches rev and records
+// the update.
+func (s *PersistenceService) UpdateIfMatch(
+	ctx context.Context,
+	e cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	err = s.PersistenceService.WithTx(ctx, func(tx cce.PersistenceService) error {
+		ok, err = update(ctx, tx, e, rev)
+		return err
+	})
+
+	return ok, err
+}
+
+// update updates a resource and records the update. Unless rev is zero, the
+// resource is only updated if its revision matches rev.
+func update(ctx context.Context, tx cce.PersistenceService, e cce.Persistable, rev int64) (ok bool, err error) {
+	if _, ok = e.(*cce.AuditRecord); ok {
+		return false, ErrAppendOnly
+	}
+
+	before, err := tx.Read(ctx, e.GetID(), e)
+	if err != nil || before == nil {
+		return false, err
+	}
+
+	if rev == 0 {
+		ok, err = true, tx.BulkUpdate(ctx, []cce.Persistable{e})
+	} else {
+		ok, err = tx.UpdateIfMatch(ctx, e, rev)
+	}
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return true, record(ctx, tx, cce.AuditUpdate, e.GetTableName(), e.GetID(), before, e)
+}
+
+// Delete deletes a resource and records the deletion.
+func (s *PersistenceService) Delete(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+) (ok bool, err error) {
+	return s.delete(ctx, id, zv, 0)
+}
+
+// DeleteIfMatch deletes a resource if its revision matches rev and records
+// the deletion.
+func (s *PersistenceService) DeleteIfMatch(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	return s.delete(ctx, id, zv, rev)
+}
+
+func (s *PersistenceService) delete(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	if _, ok = zv.(*cce.AuditRecord); ok {
+		return false, ErrAppendOnly
+	}
+
+	err = s.PersistenceService.WithTx(ctx, func(tx cce.PersistenceService) error {
+		var before cce.Persistable
+		if before, err = tx.Read(ctx, id, zv); err != nil || before == nil {
+			return err
+		}
+
+		if rev == 0 {
+			ok, err = tx.Delete(ctx, id, zv)
+		} else {
+			ok, err = tx.DeleteIfMatch(ctx, id, zv, rev)
+		}
+		if err != nil || !ok {
+			return err
+		}
+
+		return record(ctx, tx, cce.AuditDelete, zv.GetTableName(), id, before, nil)
+	})
+
+	return ok, err
+}
+
+// WithTx runs fn in a transaction, recording the changes made by fn.
+func (s *PersistenceService) WithTx(
+	ctx context.Context,
+	fn func(tx cce.PersistenceService) error,
+) error {
+	return s.PersistenceService.WithTx(ctx, func(tx cce.PersistenceService) error {
+		return fn(&PersistenceService{tx})
+	})
+}
+
+func record(
+	ctx context.Context,
+	tx cce.PersistenceService,
+	op string,
+	table string,
+	id string,
+	before cce.Persistable,
+	after cce.Persistable,
+) error {
+	r := &cce.AuditRecord{
+		ID:        uuid.New(),
+		Actor:     cce.ActorFromContext(ctx),
+		Timestamp: cce.AuditTimestamp(time.Now()),
+		Operation: op,
+		TableName: table,
+		EntityID:  id,
+	}
+
+	var err error
+	if r.Before, err = marshal(before); err != nil {
+		return err
+	}
+	if r.After, err = marshal(after); err != nil {
+		return err
+	}
+
+	if err = tx.Create(ctx, r); err != nil {
+		return errors.Wrap(err, "error recording audit trail")
+	}
+
+	return nil
+}
+
+// marshal marshals e, with its secrets removed if it is cce.Redactable.
+func marshal(e cce.Persistable) (json.RawMessage, error) {
+	if e == nil {
+		return json.RawMessage("null"), nil
+	}
+	if r, ok := e.(cce.Redactable); ok {
+		e = r.Redacted()
+	}
+
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling")
+	}
+
+	return bytes, nil
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/audit"
+	"github.com/open-ness/edgecontroller/memory"
+)
+
+var _ = Describe("PersistenceService", func() {
+	var (
+		ctx   = cce.WithActor(context.Background(), "admin")
+		inner *memory.PersistenceService
+		ps    *audit.PersistenceService
+		app   *cce.App
+	)
+
+	records := func() []*cce.AuditRecord {
+		es, _, err := inner.ReadPage(ctx, &cce.AuditRecord{}, cce.Query{Sort: "timestamp"})
+		Expect(err).ToNot(HaveOccurred())
+
+		var rs []*cce.AuditRecord
+		for _, e := range es {
+			rs = append(rs, e.(*cce.AuditRecord))
+		}
+		return rs
+	}
+
+	BeforeEach(func() {
+		var err error
+		inner, err = memory.NewPersistenceService("")
+		Expect(err).ToNot(HaveOccurred())
+		ps = &audit.PersistenceService{PersistenceService: inner}
+
+		app = &cce.App{ID: "app-1", Type: "container", Name: "app"}
+		Expect(ps.Create(ctx, app)).To(Succeed())
+	})
+
+	It("Should record a create", func() {
+		rs := records()
+		Expect(rs).To(HaveLen(1))
+		Expect(rs[0].Actor).To(Equal("admin"))
+		Expect(rs[0].Operation).To(Equal(cce.AuditCreate))
+		Expect(rs[0].TableName).To(Equal("apps"))
+		Expect(rs[0].EntityID).To(Equal("app-1"))
+		Expect(rs[0].Before).To(MatchJSON("null"))
+		Expect(rs[0].After).To(MatchJSON(mustMarshal(app)))
+	})
+
+	It("Should record an update with the entity before and after", func() {
+		before := *app
+		app.Name = "renamed"
+		Expect(ps.BulkUpdate(ctx, []cce.Persistable{app})).To(Succeed())
+
+		rs := records()
+		Expect(rs).To(HaveLen(2))
+		Expect(rs[1].Operation).To(Equal(cce.AuditUpdate))
+		Expect(rs[1].Before).To(MatchJSON(mustMarshal(&before)))
+		Expect(rs[1].After).To(MatchJSON(mustMarshal(app)))
+	})
+
+	It("Should not record an update if the revision does not match", func() {
+		Expect(ps.UpdateIfMatch(ctx, app, 2)).To(BeFalse())
+		Expect(records()).To(HaveLen(1))
+	})
+
+	It("Should record a delete with the system actor if there is no actor", func() {
+		Expect(ps.Delete(context.Background(), "app-1", &cce.App{})).To(BeTrue())
+
+		rs := records()
+		Expect(rs).To(HaveLen(2))
+		Expect(rs[1].Actor).To(Equal(cce.SystemActor))
+		Expect(rs[1].Operation).To(Equal(cce.AuditDelete))
+		Expect(rs[1].Before).To(MatchJSON(mustMarshal(app)))
+		Expect(rs[1].After).To(MatchJSON("null"))
+	})
+
+	It("Should not record the changes of a rolled back transaction", func() {
+		errFailed := errors.New("failed")
+		Expect(ps.WithTx(ctx, func(tx cce.PersistenceService) error {
+			Expect(tx.Delete(ctx, "app-1", &cce.App{})).To(BeTrue())
+			return errFailed
+		})).To(Equal(errFailed))
+
+		Expect(records()).To(HaveLen(1))
+	})
+
+	It("Should record the changes of a committed transaction", func() {
+		Expect(ps.WithTx(ctx, func(tx cce.PersistenceService) error {
+			return tx.Create(ctx, &cce.App{ID: "app-2"})
+		})).To(Succeed())
+
+		rs := records()
+		Expect(rs).To(HaveLen(2))
+		Expect(rs[1].EntityID).To(Equal("app-2"))
+	})
+
+	It("Should not record secrets", func() {
+		Expect(ps.Create(ctx, &cce.Webhook{ID: "webhook-1", URL: "https://noc", Secret: "0123456789abcdef"})).To(Succeed())
+
+		rs := records()
+		Expect(rs).To(HaveLen(2))
+		Expect(rs[1].After).To(MatchJSON(`{"id":"webhook-1","url":"https://noc","secret":""}`))
+	})
+
+	It("Should not update or delete audit records", func() {
+		rs := records()
+		Expect(ps.BulkUpdate(ctx, []cce.Persistable{rs[0]})).To(Equal(audit.ErrAppendOnly))
+		_, err := ps.Delete(ctx, rs[0].ID, &cce.AuditRecord{})
+		Expect(err).To(Equal(audit.ErrAppendOnly))
+	})
+})
+
+func mustMarshal(v interface{}) []byte {
+	bytes, err := json.Marshal(v)
+	Expect(err).ToNot(HaveOccurred())
+	return bytes
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditTimeFormat is the format of AuditRecord timestamps. It has a fixed width so that timestamps in UTC sort in
+// chronological order.
+const AuditTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// SystemActor is the actor of changes made without an authenticated user, e.g. by edge nodes over gRPC.
+const SystemActor = "system"
+
+// Audited operations
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditRecord records a change of an entity. Before is null for a create and After is null for a delete.
+type AuditRecord struct {
+	ID        string          `json:"id"`
+	Actor     string          `json:"actor"`
+	Timestamp string          `json:"timestamp"`
+	Operation string          `json:"operation"`
+	TableName string          `json:"table_name"`
+	EntityID  string          `json:"entity_id"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+}
+
+// GetTableName returns the name of the persistence table.
+func (*AuditRecord) GetTableName() string {
+	return "audit_records"
+}
+
+// GetID gets the ID.
+func (r *AuditRecord) GetID() string {
+	return r.ID
+}
+
+// SetID sets the ID.
+func (r *AuditRecord) SetID(id string) {
+	r.ID = id
+}
+
+// FilterFields returns the filterable fields for this model.
+func (*AuditRecord) FilterFields() []string {
+	return []string{
+		"actor",
+		"timestamp",
+		"table_name",
+		"entity_id",
+	}
+}
+
+func (r *AuditRecord) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+AuditRecord[
+    ID: %s
+    Actor: %s
+    Timestamp: %s
+    Operation: %s
+    TableName: %s
+    EntityID: %s
+]`),
+		r.ID,
+		r.Actor,
+		r.Timestamp,
+		r.Operation,
+		r.TableName,
+		r.EntityID)
+}
+
+// AuditTimestamp formats t as an AuditRecord timestamp.
+func AuditTimestamp(t time.Time) string {
+	return t.UTC().Format(AuditTimeFormat)
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor making changes, e.g. the subject of an authentication token.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by ctx or SystemActor if there is none.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+
+	return SystemActor
+}
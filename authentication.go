@@ -3,8 +3,55 @@
 
 package cce
 
+import (
+	"context"
+	"time"
+)
+
 // AuthCreds contains the username and password for a user.
 type AuthCreds struct {
 	Username string
 	Password string
 }
+
+// IdentityProvider verifies bearer tokens issued by an external identity
+// provider, e.g. for single sign-on.
+type IdentityProvider interface {
+	// Verify returns the subject of a valid token and the role it maps to.
+	Verify(ctx context.Context, token string) (subject, role string, err error)
+}
+
+// Authentication event actions
+const (
+	AuthActionLogin   = "login"
+	AuthActionRefresh = "refresh"
+	AuthActionLogout  = "logout"
+)
+
+// Authentication event outcomes
+const (
+	AuthOutcomeSuccess = "success"
+	AuthOutcomeFailure = "failure"
+	// AuthOutcomeLockedOut is the outcome of a login rejected because the
+	// user or source failed to log in too often, see LoginThrottle.
+	AuthOutcomeLockedOut = "locked_out"
+)
+
+// AuthEvent is a successful or failed attempt to start, refresh or end a
+// session.
+type AuthEvent struct {
+	Time     time.Time
+	Action   string
+	Outcome  string
+	Username string
+	// Source is the address of the client
+	Source string
+	// Reason describes why the attempt failed
+	Reason string
+}
+
+// AuthEventLogger records authentication events, e.g. for a SIEM to detect
+// attacks.
+type AuthEventLogger interface {
+	LogAuthEvent(e *AuthEvent)
+}
@@ -3,7 +3,19 @@
 
 package cce
 
-import "crypto/x509"
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// ErrRevocationUnsupported is returned by AuthorityService.CreateCRL and
+// CreateOCSPResponse when the issuing CA is managed externally and publishes
+// its revocations itself.
+var ErrRevocationUnsupported = errors.New("revocation is published by the external CA")
 
 // AuthorityService manages digital certificates.
 type AuthorityService interface {
@@ -12,4 +24,10 @@ type AuthorityService interface {
 	CAChain() ([]*x509.Certificate, error)
 	// SignCSR signs a ASN.1 DER encoded certificate signing request.
 	SignCSR(der []byte, template *x509.Certificate) (*x509.Certificate, error)
+	// CreateCRL signs a DER encoded certificate revocation list of the
+	// revoked certificates issued by the issuing CA.
+	CreateCRL(revoked []pkix.RevokedCertificate, now, nextUpdate time.Time) ([]byte, error)
+	// CreateOCSPResponse signs a DER encoded OCSP response with the status of
+	// a certificate issued by the issuing CA.
+	CreateOCSPResponse(template ocsp.Response) ([]byte, error)
 }
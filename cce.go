@@ -6,6 +6,7 @@ package cce
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 
 	"github.com/open-ness/common/proxy/progutil"
@@ -41,22 +42,35 @@ type Controller struct {
 	KubernetesClient   *k8s.Client
 	PersistenceService PersistenceService
 	AuthorityService   AuthorityService
-	TokenService       *jose.JWSTokenIssuer
-	AdminCreds         *AuthCreds
-
-	// The edge node's port that it listens on for gRPC connections from the
-	// Controller and serves Mm5-related endpoints for application and network
-	// policy configuration.
-	//
-	// If ELAPort is empty the default of 42101 is used.
-	ELAPort string
-
-	// The edge node's port that it listens on for gRPC connections from the
-	// Controller and serves Mm6-related endpoints for app deployment and
-	// lifecycle commands.
-	//
-	// If EVAPort is empty the default of 42102 is used.
-	EVAPort string
+	// ServerCAs are the root CAs of the Controller's own TLS certificates that
+	// are not the root of AuthorityService's chain, i.e. when node
+	// certificates are issued by an external CA. Nodes are given them to
+	// verify the Controller. It may be empty.
+	ServerCAs    []*x509.Certificate
+	TokenService *jose.JWSTokenIssuer
+	// IdentityProvider verifies the tokens of an external identity provider
+	// accepted in addition to those of TokenService. It may be nil.
+	IdentityProvider IdentityProvider
+	// LoginThrottle locks out users and sources failing to log in too often.
+	// It may be nil.
+	LoginThrottle *LoginThrottle
+	// AuthEvents records the authentication events. It may be nil.
+	AuthEvents AuthEventLogger
+	// AdminCreds are the credentials of the admin user, see EnsureAdminUser
+	AdminCreds *AuthCreds
+	// EventHub publishes the changes of resources to GET /events subscribers
+	EventHub *EventHub
+	// NotificationService notifies webhooks of events. It may be nil.
+	NotificationService NotificationService
+	// HeartbeatService records the heartbeats of nodes. It may be nil.
+	HeartbeatService HeartbeatService
+	// MaxPendingEnrollments is the maximum number of unknown nodes queued for
+	// approval when they request credentials. They are not queued if it is 0.
+	MaxPendingEnrollments int
+	// AcceptLegacySerials accepts nodes approved by the legacy MD5 serial of
+	// their public key, see LegacyNodeSerial. Their serial is migrated when
+	// they request or renew credentials.
+	AcceptLegacySerials bool
 
 	// EdgeNodeCreds are the transport credentials for connecting to an edge
 	// node. The server name will be overridden.
@@ -65,13 +79,36 @@ type Controller struct {
 
 // PersistenceService manages entity persistence. The methods with zv parameters take a zero-value Persistable for
 // reflectively creating new instances of the concrete type. In the case of Delete it is used to get the table name.
+//
+// Every entity has a revision that starts at 1 and is incremented on each update. The IfMatch methods only update or
+// delete the entity if its current revision equals rev, and return false otherwise.
+//
+// WithTx runs fn with a PersistenceService bound to a single transaction. The transaction is committed if fn returns
+// nil and rolled back otherwise. Calling WithTx on a transactional PersistenceService runs fn in the same transaction.
+// fn may be run again if the transaction conflicts with a concurrent one, so it should not have side effects other
+// than through tx, such as calls to nodes.
 type PersistenceService interface {
 	Create(ctx context.Context, e Persistable) error
 	Read(ctx context.Context, id string, zv Persistable) (e Persistable, err error)
+	ReadWithRevision(ctx context.Context, id string, zv Persistable) (e Persistable, rev int64, err error)
 	ReadAll(ctx context.Context, zv Persistable) (ps []Persistable, err error)
 	Filter(ctx context.Context, zv Filterable, fs []Filter) (ps []Persistable, err error)
+	ReadPage(ctx context.Context, zv Filterable, q Query) (ps []Persistable, nextCursor string, err error)
 	BulkUpdate(ctx context.Context, ps []Persistable) error
+	UpdateIfMatch(ctx context.Context, e Persistable, rev int64) (ok bool, err error)
 	Delete(ctx context.Context, id string, zv Persistable) (ok bool, err error)
+	DeleteIfMatch(ctx context.Context, id string, zv Persistable, rev int64) (ok bool, err error)
+	WithTx(ctx context.Context, fn func(tx PersistenceService) error) error
+}
+
+// ConstraintError is the error of a PersistenceService write that violates a
+// unique or foreign key. It may be wrapped by the PersistenceService.
+type ConstraintError struct {
+	Msg string
+}
+
+func (e *ConstraintError) Error() string {
+	return e.Msg
 }
 
 // Validatable can be validated.
@@ -107,6 +144,12 @@ type NodeEntity interface {
 	GetNodeID() string
 }
 
+// Redactable is a Persistable holding secrets that must not be disclosed,
+// e.g. in the audit trail.
+type Redactable interface {
+	Redacted() Persistable
+}
+
 // Filter filters queries in PersistenceService.Filter.
 type Filter struct {
 	Field string
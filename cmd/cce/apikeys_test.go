@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/open-ness/edgecontroller/swagger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("/apikeys", func() {
+	postAPIKey := func(body string) (int, []byte) {
+		By("Sending a POST /apikeys request")
+		resp, err := apiCli.Post("http://127.0.0.1:8080/apikeys", "application/json", strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return resp.StatusCode, respBody
+	}
+
+	createAPIKey := func(body string) swagger.APIKeyDetail {
+		status, respBody := postAPIKey(body)
+		Expect(status).To(Equal(http.StatusCreated))
+
+		var key swagger.APIKeyDetail
+		Expect(json.Unmarshal(respBody, &key)).To(Succeed())
+		Expect(key.Key).ToNot(BeEmpty())
+		return key
+	}
+
+	getStatus := func(key, url string) int {
+		resp, err := (&apiClient{Token: key}).Get(url)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	Describe("POST /apikeys", func() {
+		It("Should create a key limited to its role and scopes", func() {
+			key := createAPIKey(`{"name": "ci", "role": "read-only", "scopes": ["/apps"]}`)
+			Expect(key.CreatedBy).To(Equal("admin"))
+
+			By("Verifying the key is accepted in its scopes")
+			Expect(getStatus(key.Key, "http://127.0.0.1:8080/apps")).To(Equal(http.StatusOK))
+
+			By("Verifying the key is denied outside its scopes and role")
+			Expect(getStatus(key.Key, "http://127.0.0.1:8080/nodes")).To(Equal(http.StatusForbidden))
+			resp, err := (&apiClient{Token: key.Key}).Post("http://127.0.0.1:8080/apps", "application/json",
+				strings.NewReader("{}"))
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+
+			By("Verifying the key is not returned again")
+			resp, err = apiCli.Get("http://127.0.0.1:8080/apikeys/" + key.ID)
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).ToNot(ContainSubstring(key.Key))
+		})
+
+		It("Should record the key ID as the actor in the audit trail", func() {
+			key := createAPIKey(`{"name": "ci", "role": "operator"}`)
+
+			resp, err := (&apiClient{Token: key.Key}).Post("http://127.0.0.1:8080/nodes", "application/json",
+				strings.NewReader(fmt.Sprintf(`{"name": "apikey node", "location": "loc", "serial": "ak-%d"}`,
+					time.Now().UnixNano())))
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+			resp, err = apiCli.Get("http://127.0.0.1:8080/audit?actor=apikey:" + key.ID)
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			var records swagger.AuditRecordList
+			Expect(json.NewDecoder(resp.Body).Decode(&records)).To(Succeed())
+			Expect(records.Records).ToNot(BeEmpty())
+		})
+	})
+
+	Describe("DELETE /apikeys/{apikey_id}", func() {
+		It("Should revoke the key", func() {
+			key := createAPIKey(`{"name": "ci", "role": "read-only"}`)
+			Expect(getStatus(key.Key, "http://127.0.0.1:8080/apps")).To(Equal(http.StatusOK))
+
+			By("Sending a DELETE /apikeys/{apikey_id} request")
+			resp, err := apiCli.Delete("http://127.0.0.1:8080/apikeys/" + key.ID)
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			By("Verifying the key is not accepted anymore")
+			Expect(getStatus(key.Key, "http://127.0.0.1:8080/apps")).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Describe("POST /apikeys", func() {
+		DescribeTable("400 Bad Request",
+			func(body, expectedResp string) {
+				status, respBody := postAPIKey(body)
+
+				By("Verifying a 400 Bad Request response")
+				Expect(status).To(Equal(http.StatusBadRequest))
+				Expect(string(respBody)).To(Equal(expectedResp))
+			},
+			Entry("POST /apikeys without a name",
+				`{"role": "operator"}`,
+				"Validation failed: name cannot be empty"),
+			Entry("POST /apikeys with an expired expiry",
+				`{"name": "ci", "role": "operator", "expiry": "2020-01-01T00:00:00Z"}`,
+				"Validation failed: expiry must be in the future"),
+			Entry("POST /apikeys with a relative scope",
+				`{"name": "ci", "role": "operator", "scopes": ["apps"]}`,
+				"Validation failed: scopes[0] must be an absolute path without trailing slash"),
+		)
+	})
+})
@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/open-ness/edgecontroller/swagger"
+	"github.com/open-ness/edgecontroller/uuid"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("/apply", func() {
+	postApply := func(query, contentType, body string) (int, []byte) {
+		By("Sending a POST /apply request")
+		resp, err := apiCli.Post("http://127.0.0.1:8080/apply"+query, contentType, strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		By("Reading the response body")
+		respBody, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return resp.StatusCode, respBody
+	}
+
+	actions := func(body []byte) []string {
+		var plan swagger.ApplyPlan
+		Expect(json.Unmarshal(body, &plan)).To(Succeed())
+		var as []string
+		for _, s := range plan.Steps {
+			as = append(as, fmt.Sprintf("%s %s %s", s.Action, s.Resource, s.Name))
+		}
+		return as
+	}
+
+	Describe("POST /apply", func() {
+		var (
+			suffix   string
+			manifest string
+		)
+
+		BeforeEach(func() {
+			suffix = uuid.New()[:8]
+			manifest = fmt.Sprintf(`
+policies:
+  - name: policy-%[1]s
+    traffic_rules:
+      - description: test-rule-1
+        priority: 1
+        source:
+          description: test-source-1
+          ip_filter:
+            address: 223.1.1.0
+            mask: 16
+            begin_port: 2000
+            end_port: 2012
+            protocol: tcp
+        target:
+          description: test-target-1
+          action: accept
+apps:
+  - type: container
+    name: app-%[1]s
+    version: latest
+    vendor: canonical
+    description: test app
+    cores: 4
+    memory: 1024
+    ports:
+      - port: 80
+        protocol: tcp
+    source: http://www.test.com/my_test_app.tar.gz
+nodes:
+  - name: node-%[1]s
+    location: test location
+    serial: serial-%[1]s
+`, suffix)
+		})
+
+		It("Should return the plan of a dry run without applying it", func() {
+			status, body := postApply("?dryRun=true", "application/yaml", manifest)
+
+			By("Verifying a 200 OK response with the plan")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(actions(body)).To(Equal([]string{
+				"create policies policy-" + suffix,
+				"create apps app-" + suffix,
+				"create nodes node-" + suffix,
+			}))
+
+			By("Verifying nothing has been created")
+			status, body = postApply("?dryRun=true", "application/yaml", manifest)
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(actions(body)).To(ContainElement("create apps app-" + suffix))
+		})
+
+		It("Should apply a manifest and report no changes when it is applied again", func() {
+			status, body := postApply("", "application/yaml", manifest)
+
+			By("Verifying a 200 OK response with the applied plan")
+			Expect(status).To(Equal(http.StatusOK))
+			var plan swagger.ApplyPlan
+			Expect(json.Unmarshal(body, &plan)).To(Succeed())
+			Expect(plan.Steps).To(HaveLen(3))
+			for _, s := range plan.Steps {
+				Expect(s.Applied).To(BeTrue())
+				Expect(s.ID).ToNot(BeEmpty())
+			}
+
+			By("Verifying the app has been created")
+			app := getApp(plan.Steps[1].ID)
+			Expect(app.Name).To(Equal("app-" + suffix))
+
+			By("Verifying the node has been created")
+			node := getNode(plan.Steps[2].ID)
+			Expect(node.Serial).To(Equal("serial-" + suffix))
+
+			By("Applying the manifest again")
+			status, body = postApply("?dryRun=true", "application/yaml", manifest)
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(actions(body)).To(Equal([]string{
+				"none policies policy-" + suffix,
+				"none apps app-" + suffix,
+				"none nodes node-" + suffix,
+			}))
+
+			By("Changing the app")
+			status, body = postApply("?dryRun=true", "application/yaml",
+				strings.Replace(manifest, "cores: 4", "cores: 2", 1))
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(actions(body)).To(ContainElement("update apps app-" + suffix))
+		})
+	})
+
+	Describe("POST /apply", func() {
+		DescribeTable("400 Bad Request",
+			func(query, body, expectedResp string) {
+				status, respBody := postApply(query, "application/json", body)
+
+				By("Verifying a 400 Bad Request response")
+				Expect(status).To(Equal(http.StatusBadRequest))
+				Expect(string(respBody)).To(Equal(expectedResp))
+			},
+			Entry("POST /apply with an invalid dryRun",
+				"?dryRun=maybe", `{}`,
+				`Invalid query: dryRun "maybe" is not a boolean`),
+			Entry("POST /apply with an invalid prune",
+				"?prune=maybe", `{}`,
+				`Invalid query: prune "maybe" is not a boolean`),
+			Entry("POST /apply with an unknown field",
+				"", `{"applications": []}`,
+				`Invalid manifest: json: unknown field "applications"`),
+			Entry("POST /apply with an invalid app",
+				"", `{"apps": [{"name": "app-without-type"}]}`,
+				`Invalid manifest: apps[0]: type must be either "container" or "vm"`),
+			Entry("POST /apply with an unknown app ID",
+				"", `{"apps": [{"id": "0ae2e7a6-cdcb-4d3c-9b7b-4c1ca8bb7a1c", "name": "app"}]}`,
+				"Invalid manifest: apps[0]: app 0ae2e7a6-cdcb-4d3c-9b7b-4c1ca8bb7a1c not found"),
+			Entry("POST /apply with an unresolved node app",
+				"", `{"nodes": [{"name": "node", "location": "loc", "serial": "apply-ref", "apps": [{"app": "nope"}]}]}`,
+				`Invalid manifest: nodes[0].apps[0].app: app "nope" not found`),
+		)
+	})
+})
@@ -301,6 +301,83 @@ var _ = Describe("/apps", func() {
 			},
 			Entry("GET /apps"),
 		)
+
+		DescribeTable("200 OK with query",
+			func() {
+				By("Sending a GET /apps request filtered by type and limited to 1")
+				resp, err := apiCli.Get("http://127.0.0.1:8080/apps?type=vm&sort=-id&limit=1")
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				By("Verifying a 200 OK response")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				By("Reading the response body")
+				body, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+
+				var apps swagger.AppList
+
+				By("Unmarshaling the response")
+				Expect(json.Unmarshal(body, &apps)).To(Succeed())
+
+				By("Verifying a single vm app was returned")
+				Expect(apps.Apps).To(HaveLen(1))
+				Expect(apps.Apps[0].Type).To(Equal("vm"))
+				Expect(apps.Apps[0].ID).ToNot(Equal(containerAppID))
+
+				By("Fetching the remaining pages with the returned cursor")
+				ids := []string{apps.Apps[0].ID}
+				for apps.NextCursor != "" {
+					resp, err = apiCli.Get(fmt.Sprintf(
+						"http://127.0.0.1:8080/apps?type=vm&sort=-id&limit=1&cursor=%s", apps.NextCursor))
+					Expect(err).ToNot(HaveOccurred())
+					defer resp.Body.Close()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+					apps = swagger.AppList{}
+					Expect(json.NewDecoder(resp.Body).Decode(&apps)).To(Succeed())
+					Expect(apps.Apps).To(HaveLen(1))
+					Expect(apps.Apps[0].ID < ids[len(ids)-1]).To(BeTrue())
+					ids = append(ids, apps.Apps[0].ID)
+				}
+
+				By("Verifying the created vm app was on one of the pages")
+				Expect(ids).To(ContainElement(vmAppID))
+			},
+			Entry("GET /apps?type=vm&sort=-id&limit=1"),
+		)
+
+		DescribeTable("400 Bad Request",
+			func(query, expectedResp string) {
+				By("Sending a GET /apps request")
+				resp, err := apiCli.Get("http://127.0.0.1:8080/apps?" + query)
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				By("Verifying a 400 Bad Request response")
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+				By("Reading the response body")
+				body, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+
+				By("Verifying the response body")
+				Expect(string(body)).To(Equal(expectedResp))
+			},
+			Entry("GET /apps with non-numeric limit",
+				"limit=ten",
+				`Invalid query: limit "ten" is not a number`),
+			Entry("GET /apps with too large limit",
+				"limit=1001",
+				"Invalid query: limit must be in [0..1000]"),
+			Entry("GET /apps with invalid filter field",
+				"Type=vm",
+				`Invalid query: filter field "Type" is invalid`),
+			Entry("GET /apps with invalid cursor",
+				"cursor=abc",
+				"Invalid query: invalid cursor"),
+		)
 	})
 
 	Describe("GET /apps/{app_id}", func() {
@@ -595,6 +672,116 @@ var _ = Describe("/apps", func() {
 		)
 	})
 
+	Describe("PATCH /apps/{app_id} with If-Match", func() {
+		var (
+			containerAppID string
+		)
+
+		BeforeEach(func() {
+			containerAppID = postApps("container")
+		})
+
+		DescribeTable("412 Precondition Failed",
+			func() {
+				By("Sending a GET /apps/{app_id} request")
+				resp, err := apiCli.Get(
+					fmt.Sprintf("http://127.0.0.1:8080/apps/%s", containerAppID))
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				By("Verifying an ETag was returned")
+				etag := resp.Header.Get("ETag")
+				Expect(etag).To(Equal(`"1"`))
+
+				patch := func() *http.Response {
+					req, err := http.NewRequest(
+						http.MethodPatch,
+						fmt.Sprintf("http://127.0.0.1:8080/apps/%s", containerAppID),
+						strings.NewReader(fmt.Sprintf(`
+						{
+							"id": "%s",
+							"type": "container",
+							"name": "container app2",
+							"version": "latest",
+							"vendor": "smart edge",
+							"description": "my container app",
+							"cores": 4,
+							"memory": 1024,
+							"ports": [{"port": 80, "protocol": "tcp"}],
+							"source": "http://www.test.com/my_container_app.tar.gz"
+						}`, containerAppID)))
+					Expect(err).ToNot(HaveOccurred())
+					req.Header.Set("If-Match", etag)
+
+					resp, err := apiCli.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+					return resp
+				}
+
+				By("Sending a PATCH /apps/{app_id} request with the ETag")
+				resp = patch()
+				defer resp.Body.Close()
+
+				By("Verifying a 200 OK response with the next ETag")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(resp.Header.Get("ETag")).To(Equal(`"2"`))
+
+				By("Sending a PATCH /apps/{app_id} request with the stale ETag")
+				resp = patch()
+				defer resp.Body.Close()
+
+				By("Verifying a 412 Precondition Failed response")
+				Expect(resp.StatusCode).To(Equal(http.StatusPreconditionFailed))
+
+				By("Sending a DELETE /apps/{app_id} request with the stale ETag")
+				req, err := http.NewRequest(
+					http.MethodDelete,
+					fmt.Sprintf("http://127.0.0.1:8080/apps/%s", containerAppID),
+					nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("If-Match", etag)
+				resp, err = apiCli.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				By("Verifying a 412 Precondition Failed response")
+				Expect(resp.StatusCode).To(Equal(http.StatusPreconditionFailed))
+
+				By("Verifying the app was not deleted")
+				getApp(containerAppID)
+			},
+			Entry("PATCH and DELETE /apps/{app_id} with stale If-Match"),
+		)
+
+		It("Should return the next ETag without If-Match", func() {
+			By("Sending a PATCH /apps/{app_id} request without If-Match")
+			req, err := http.NewRequest(
+				http.MethodPatch,
+				fmt.Sprintf("http://127.0.0.1:8080/apps/%s", containerAppID),
+				strings.NewReader(fmt.Sprintf(`
+				{
+					"id": "%s",
+					"type": "container",
+					"name": "container app2",
+					"version": "latest",
+					"vendor": "smart edge",
+					"description": "my container app",
+					"cores": 4,
+					"memory": 1024,
+					"ports": [{"port": 80, "protocol": "tcp"}],
+					"source": "http://www.test.com/my_container_app.tar.gz"
+				}`, containerAppID)))
+			Expect(err).ToNot(HaveOccurred())
+			resp, err := apiCli.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			By("Verifying a 200 OK response with the next ETag")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("ETag")).To(Equal(`"2"`))
+		})
+	})
+
 	Describe("DELETE /apps/{app_id}", func() {
 		var (
 			containerAppID string
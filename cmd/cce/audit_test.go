@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/swagger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("/audit", func() {
+	Describe("GET /audit", func() {
+		var (
+			since          string
+			containerAppID string
+		)
+
+		BeforeEach(func() {
+			since = time.Now().UTC().Format(time.RFC3339Nano)
+			containerAppID = postApps("container")
+		})
+
+		DescribeTable("200 OK",
+			func() {
+				By("Sending a DELETE /apps/{app_id} request")
+				req, err := http.NewRequest(
+					http.MethodDelete,
+					fmt.Sprintf("http://127.0.0.1:8080/apps/%s", containerAppID),
+					nil)
+				Expect(err).ToNot(HaveOccurred())
+				resp, err := apiCli.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				By("Sending a GET /audit request")
+				resp, err = apiCli.Get(fmt.Sprintf(
+					"http://127.0.0.1:8080/audit?entity=%s&since=%s",
+					containerAppID, url.QueryEscape(since)))
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				By("Verifying a 200 OK response")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				By("Reading the response body")
+				body, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+
+				var records swagger.AuditRecordList
+
+				By("Unmarshaling the response")
+				Expect(json.Unmarshal(body, &records)).To(Succeed())
+
+				By("Verifying the create and delete were recorded")
+				Expect(records.Records).To(HaveLen(2))
+				Expect(records.Records[0].Operation).To(Equal(cce.AuditCreate))
+				Expect(records.Records[1].Operation).To(Equal(cce.AuditDelete))
+				for _, r := range records.Records {
+					Expect(r.Actor).To(Equal("admin"))
+					Expect(r.TableName).To(Equal("apps"))
+					Expect(r.EntityID).To(Equal(containerAppID))
+				}
+				Expect(records.Records[0].Before).To(MatchJSON("null"))
+				Expect(records.Records[1].After).To(MatchJSON("null"))
+			},
+			Entry("GET /audit?entity={app_id}&since={time}"),
+		)
+
+		DescribeTable("400 Bad Request",
+			func(query string) {
+				By("Sending a GET /audit request")
+				resp, err := apiCli.Get("http://127.0.0.1:8080/audit?" + query)
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				By("Verifying a 400 Bad Request response")
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			},
+			Entry("GET /audit?since=yesterday", "since=yesterday"),
+			Entry("GET /audit?limit=-1", "limit=-1"),
+		)
+	})
+})
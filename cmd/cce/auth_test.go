@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-ness/edgecontroller/swagger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("/auth", func() {
+	type tokens struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	postAuth := func(path, body string) (int, tokens) {
+		By(fmt.Sprintf("Sending a POST %s request", path))
+		resp, err := http.Post("http://127.0.0.1:8080"+path, "application/json", strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		var ts tokens
+		if resp.StatusCode == http.StatusCreated {
+			Expect(json.NewDecoder(resp.Body).Decode(&ts)).To(Succeed())
+		}
+		return resp.StatusCode, ts
+	}
+
+	login := func() tokens {
+		status, ts := postAuth("/auth", fmt.Sprintf(`{"username": "admin", "password": %q}`, adminPass))
+		Expect(status).To(Equal(http.StatusCreated))
+		Expect(ts.Token).ToNot(BeEmpty())
+		Expect(ts.RefreshToken).ToNot(BeEmpty())
+		return ts
+	}
+
+	getApps := func(token string) int {
+		resp, err := (&apiClient{Token: token}).Get("http://127.0.0.1:8080/apps")
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	refresh := func(refreshToken string) (int, tokens) {
+		return postAuth("/auth/refresh", fmt.Sprintf(`{"refresh_token": %q}`, refreshToken))
+	}
+
+	Describe("POST /auth", func() {
+		It("Should lock out a username failing to log in too often", func() {
+			body := `{"username": "lockout-test", "password": "wrong-password"}`
+			for i := 0; i < 5; i++ {
+				status, _ := postAuth("/auth", body)
+				Expect(status).To(Equal(http.StatusUnauthorized))
+			}
+
+			By("Sending a POST /auth request for the locked out username")
+			resp, err := http.Post("http://127.0.0.1:8080/auth", "application/json", strings.NewReader(body))
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			By("Verifying a 429 Too Many Requests response")
+			Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+			Expect(resp.Header.Get("Retry-After")).ToNot(BeEmpty())
+
+			By("Verifying the auth events are written to the syslog output")
+			Eventually(func() (string, error) {
+				b, err := ioutil.ReadFile(filepath.Join(telemDir, "syslog.log"))
+				return string(b), err
+			}).Should(And(
+				ContainSubstring(`outcome="failure" user="lockout-test" src="127.0.0.1"`),
+				ContainSubstring(`outcome="locked_out" user="lockout-test" src="127.0.0.1"`)))
+
+			By("Verifying other users can still log in")
+			login()
+		})
+	})
+
+	Describe("POST /auth/refresh", func() {
+		It("Should issue a new access token for a refresh token", func() {
+			ts := login()
+
+			By("Verifying the refresh token is not accepted as an access token")
+			Expect(getApps(ts.RefreshToken)).To(Equal(http.StatusUnauthorized))
+
+			status, refreshed := refresh(ts.RefreshToken)
+
+			By("Verifying a 201 Created response with an access token")
+			Expect(status).To(Equal(http.StatusCreated))
+			Expect(refreshed.RefreshToken).To(BeEmpty())
+			Expect(getApps(refreshed.Token)).To(Equal(http.StatusOK))
+		})
+
+		It("Should not accept an access token", func() {
+			status, _ := refresh(login().Token)
+
+			By("Verifying a 401 Unauthorized response")
+			Expect(status).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Describe("POST /auth/logout", func() {
+		It("Should revoke the tokens of the session", func() {
+			ts := login()
+			status, refreshed := refresh(ts.RefreshToken)
+			Expect(status).To(Equal(http.StatusCreated))
+
+			By("Sending a POST /auth/logout request")
+			resp, err := (&apiClient{Token: ts.Token}).Post("http://127.0.0.1:8080/auth/logout", "", nil)
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			By("Verifying a 204 No Content response")
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+			By("Verifying the tokens of the session are revoked")
+			Expect(getApps(ts.Token)).To(Equal(http.StatusUnauthorized))
+			Expect(getApps(refreshed.Token)).To(Equal(http.StatusUnauthorized))
+			status, _ = refresh(ts.RefreshToken)
+			Expect(status).To(Equal(http.StatusUnauthorized))
+
+			By("Verifying other sessions are not affected")
+			Expect(getApps(apiCli.Token)).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("POST /auth/keys", func() {
+		It("Should rotate the signing key and keep accepting tokens of the previous key", func() {
+			ts := login()
+
+			By("Sending a POST /auth/keys request")
+			resp, err := apiCli.Post("http://127.0.0.1:8080/auth/keys", "", nil)
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			By("Verifying a 201 Created response with the new key")
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			var key swagger.TokenKey
+			Expect(json.NewDecoder(resp.Body).Decode(&key)).To(Succeed())
+			Expect(key.Retired).To(BeEmpty())
+
+			By("Verifying the previous key is retired")
+			resp, err = apiCli.Get("http://127.0.0.1:8080/auth/keys")
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			var keys swagger.TokenKeyList
+			Expect(json.NewDecoder(resp.Body).Decode(&keys)).To(Succeed())
+			Expect(len(keys.Keys)).To(BeNumerically(">=", 2))
+			Expect(keys.Keys[0].ID).To(Equal(key.ID))
+			Expect(keys.Keys[1].Retired).ToNot(BeEmpty())
+
+			By("Verifying the token signed with the previous key is accepted")
+			Expect(getApps(ts.Token)).To(Equal(http.StatusOK))
+		})
+	})
+})
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	authpb "github.com/open-ness/edgecontroller/pb/auth"
+	"github.com/open-ness/edgecontroller/swagger"
+)
+
+var _ = Describe("Node enrollment", func() {
+	var csrPEM string
+
+	BeforeEach(func() {
+		clearGRPCTargetsTable()
+
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+		Expect(err).ToNot(HaveOccurred())
+		csrPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+	})
+
+	post := func(path, body string) *http.Response {
+		resp, err := apiCli.Post("http://127.0.0.1:8080"+path, "application/json", strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		return resp
+	}
+
+	Describe("Enrollment tokens", func() {
+		It("Should enroll a node with a token once", func() {
+			By("Sending a POST /enrollment_tokens request")
+			resp := post("/enrollment_tokens", `{"name": "Token Node", "location": "Localhost port 42101"}`)
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			var token swagger.EnrollmentTokenDetail
+			Expect(json.NewDecoder(resp.Body).Decode(&token)).To(Succeed())
+			Expect(token.Token).To(HavePrefix("cceet_"))
+			Expect(token.Expiry).ToNot(BeEmpty())
+
+			By("Requesting credentials with the token")
+			_, err := authSvcCli.RequestCredentials(context.TODO(),
+				&authpb.Identity{Csr: csrPEM, EnrollmentToken: token.Token})
+			Expect(err).ToNot(HaveOccurred())
+
+			By("Verifying the node of the token was created")
+			node := getNode(token.NodeID)
+			Expect(node.Name).To(Equal("Token Node"))
+			Expect(node.Serial).ToNot(BeEmpty())
+
+			By("Verifying the token cannot be used again")
+			clearGRPCTargetsTable()
+			_, err = authSvcCli.RequestCredentials(context.TODO(),
+				&authpb.Identity{Csr: csrPEM, EnrollmentToken: token.Token})
+			Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+		})
+
+		It("Should reject an invalid token", func() {
+			_, err := authSvcCli.RequestCredentials(context.TODO(),
+				&authpb.Identity{Csr: csrPEM, EnrollmentToken: "cceet_invalid"})
+			Expect(err).To(MatchError(ContainSubstring("invalid enrollment token")))
+			Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+		})
+	})
+
+	Describe("Pending enrollments", func() {
+		// requestEnrollment requests credentials for an unknown node and
+		// returns its pending enrollment
+		requestEnrollment := func() swagger.Enrollment {
+			_, rpcErr := authSvcCli.RequestCredentials(context.TODO(), &authpb.Identity{Csr: csrPEM})
+			Expect(status.Code(rpcErr)).To(Equal(codes.Unauthenticated))
+			Expect(rpcErr).To(MatchError(ContainSubstring("pending approval")))
+
+			By("Sending a GET /enrollments request")
+			resp, err := apiCli.Get("http://127.0.0.1:8080/enrollments?status=pending")
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var list swagger.EnrollmentList
+			Expect(json.NewDecoder(resp.Body).Decode(&list)).To(Succeed())
+
+			for _, e := range list.Enrollments {
+				if strings.Contains(rpcErr.Error(), e.ID) {
+					return e
+				}
+			}
+			Fail("pending enrollment not listed")
+			return swagger.Enrollment{}
+		}
+
+		It("Should enroll a node once its enrollment is approved", func() {
+			enrollment := requestEnrollment()
+			Expect(enrollment.PeerIP).To(Equal("127.0.0.1"))
+			Expect(enrollment.Status).To(Equal("pending"))
+
+			By("Sending a POST /enrollments/{enrollment_id}/approve request")
+			resp := post("/enrollments/"+enrollment.ID+"/approve",
+				`{"name": "Queued Node", "location": "Localhost port 42101"}`)
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			var node swagger.NodeDetail
+			Expect(json.NewDecoder(resp.Body).Decode(&node)).To(Succeed())
+			Expect(node.Serial).To(Equal(enrollment.Serial))
+
+			By("Requesting credentials again")
+			_, err := authSvcCli.RequestCredentials(context.TODO(), &authpb.Identity{Csr: csrPEM})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should refuse a node whose enrollment is rejected", func() {
+			enrollment := requestEnrollment()
+
+			By("Sending a POST /enrollments/{enrollment_id}/reject request")
+			resp := post("/enrollments/"+enrollment.ID+"/reject", "")
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			_, err := authSvcCli.RequestCredentials(context.TODO(), &authpb.Identity{Csr: csrPEM})
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+		})
+	})
+})
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	cce "github.com/open-ness/edgecontroller"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("/events", func() {
+	// readEvent reads the next event from an event stream, skipping comments
+	readEvent := func(r *bufio.Reader) (id string, e cce.Event) {
+		for {
+			line, err := r.ReadString('\n')
+			Expect(err).ToNot(HaveOccurred())
+			line = strings.TrimSuffix(line, "\n")
+
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				Expect(json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e)).To(Succeed())
+			case line == "" && id != "":
+				return id, e
+			}
+		}
+	}
+
+	Describe("GET /events", func() {
+		DescribeTable("200 OK",
+			func() {
+				By("Sending a GET /events request")
+				resp, err := apiCli.Get("http://127.0.0.1:8080/events?resource=apps")
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				By("Verifying a 200 OK event stream")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+
+				By("Creating an app")
+				appID := postApps("container")
+
+				By("Reading the create event")
+				r := bufio.NewReader(resp.Body)
+				id, e := readEvent(r)
+				Expect(e.Type).To(Equal(cce.EventCreate))
+				Expect(e.Resource).To(Equal("apps"))
+				Expect(e.ID).To(Equal(appID))
+
+				By("Resuming the stream after the create event")
+				req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:8080/events?resource=apps", nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Last-Event-ID", id)
+				resumed, err := apiCli.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				defer resumed.Body.Close()
+				Expect(resumed.StatusCode).To(Equal(http.StatusOK))
+
+				By("Deleting the app")
+				resp2, err := apiCli.Delete("http://127.0.0.1:8080/apps/" + appID)
+				Expect(err).ToNot(HaveOccurred())
+				resp2.Body.Close()
+
+				By("Reading the delete event from the resumed stream")
+				_, e = readEvent(bufio.NewReader(resumed.Body))
+				Expect(e.Type).To(Equal(cce.EventDelete))
+				Expect(e.ID).To(Equal(appID))
+			},
+			Entry("GET /events?resource=apps"),
+		)
+
+		DescribeTable("400 Bad Request",
+			func(query string) {
+				By("Sending a GET /events request")
+				resp, err := apiCli.Get("http://127.0.0.1:8080/events?" + query)
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				By("Verifying a 400 Bad Request response")
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			},
+			Entry("GET /events?resource=credentials", "resource=credentials"),
+			Entry("GET /events?node_id=123", "node_id=123"),
+			Entry("GET /events?last_event_id=abc", "last_event_id=abc"),
+		)
+	})
+})
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/open-ness/edgecontroller/swagger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("/export and /import", func() {
+	getExport := func() []byte {
+		By("Sending a GET /export request")
+		resp, err := apiCli.Get("http://127.0.0.1:8080/export")
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		By("Verifying a 200 OK response")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Disposition")).To(HavePrefix("attachment"))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return body
+	}
+
+	postImport := func(query, body string) (int, []byte) {
+		By("Sending a POST /import request")
+		resp, err := apiCli.Post("http://127.0.0.1:8080/import"+query, "application/json", strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return resp.StatusCode, respBody
+	}
+
+	Describe("GET /export", func() {
+		It("Should export the apps", func() {
+			appID := postApps("container")
+
+			var export swagger.Export
+			Expect(json.Unmarshal(getExport(), &export)).To(Succeed())
+
+			By("Verifying the archive")
+			Expect(export.Version).To(Equal(swagger.ExportVersion))
+			Expect(export.Tables).To(HaveKey("nodes"))
+			var ids []string
+			for _, raw := range export.Tables["apps"] {
+				var app swagger.AppDetail
+				Expect(json.Unmarshal(raw, &app)).To(Succeed())
+				ids = append(ids, app.ID)
+			}
+			Expect(ids).To(ContainElement(appID))
+		})
+	})
+
+	Describe("POST /import", func() {
+		It("Should resolve conflicts with the conflict policy", func() {
+			postApps("container")
+			export := string(getExport())
+
+			By("Verifying the import fails by default")
+			status, body := postImport("", export)
+			Expect(status).To(Equal(http.StatusConflict))
+			Expect(string(body)).To(ContainSubstring("already exists"))
+
+			By("Verifying existing entities are skipped")
+			status, body = postImport("?conflict=skip", export)
+			Expect(status).To(Equal(http.StatusOK))
+			var result swagger.ImportResult
+			Expect(json.Unmarshal(body, &result)).To(Succeed())
+			Expect(result.Created).To(BeEmpty())
+			Expect(result.Skipped["apps"]).To(BeNumerically(">", 0))
+
+			By("Verifying existing entities are overwritten")
+			status, body = postImport("?conflict=overwrite", export)
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(json.Unmarshal(body, &result)).To(Succeed())
+			Expect(result.Updated["apps"]).To(BeNumerically(">", 0))
+		})
+
+		DescribeTable("400 Bad Request",
+			func(query, body, expectedResp string) {
+				status, respBody := postImport(query, body)
+
+				By("Verifying a 400 Bad Request response")
+				Expect(status).To(Equal(http.StatusBadRequest))
+				Expect(string(respBody)).To(Equal(expectedResp))
+			},
+			Entry("POST /import with an invalid conflict policy",
+				"?conflict=merge", `{"version": 1}`,
+				`Invalid query: conflict "merge" must be one of fail, skip or overwrite`),
+			Entry("POST /import with an unsupported version",
+				"", `{"version": 2}`,
+				"Invalid archive: version 2 is not supported"),
+			Entry("POST /import with an unknown table",
+				"", `{"version": 1, "tables": {"audit_records": []}}`,
+				`Invalid archive: table "audit_records" is unknown`),
+			Entry("POST /import with an invalid app",
+				"", `{"version": 1, "tables": {"apps": [{"id": "123"}]}}`,
+				"Invalid archive: apps[0]: id not a valid uuid"),
+		)
+	})
+})
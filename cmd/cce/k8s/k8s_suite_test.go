@@ -11,11 +11,9 @@ import (
 
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/md5" //nolint:gosec
 	"crypto/rand"
 	"crypto/x509"
 	"database/sql"
-	"encoding/base64"
 
 	"encoding/json"
 	"encoding/pem"
@@ -45,6 +43,7 @@ import (
 	"google.golang.org/grpc/grpclog"
 
 	_ "github.com/go-sql-driver/mysql" // provides the mysql driver
+	cce "github.com/open-ness/edgecontroller"
 	cceGRPC "github.com/open-ness/edgecontroller/grpc"
 	"github.com/open-ness/edgecontroller/k8s"
 	authpb "github.com/open-ness/edgecontroller/pb/auth"
@@ -129,8 +128,6 @@ func startup() {
 		"-dsn", fmt.Sprintf("root:%s@tcp(:8083)/controller_ce", dbPass),
 		"-httpPort", "8080",
 		"-grpcPort", "8081",
-		"-elaPort", "42101",
-		"-evaPort", "42102",
 		"-syslog-path", "./temp_telemetry/syslog.out",
 		"-statsd-path", "./temp_telemetry/statsd.out",
 		"-adminPass", adminPass,
@@ -324,8 +321,7 @@ func createAndRegisterNode() *nodeConfig {
 		})
 
 	By("Pre-approving Node by serial")
-	hash := md5.Sum(certReq.RawSubjectPublicKeyInfo) //nolint:gosec
-	serial := base64.RawURLEncoding.EncodeToString(hash[:])
+	serial := cce.NodeSerial(certReq.RawSubjectPublicKeyInfo)
 	nodeID := postNodesSerial(serial)
 
 	By("Resetting the node")
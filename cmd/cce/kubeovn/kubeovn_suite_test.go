@@ -12,12 +12,10 @@ import (
 
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/md5" //nolint:gosec
 	"crypto/rand"
 	"crypto/x509"
 	"database/sql"
 
-	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
@@ -44,6 +42,7 @@ import (
 	"google.golang.org/grpc/grpclog"
 
 	_ "github.com/go-sql-driver/mysql" // provides the mysql driver
+	cce "github.com/open-ness/edgecontroller"
 	cceGRPC "github.com/open-ness/edgecontroller/grpc"
 	"github.com/open-ness/edgecontroller/k8s"
 	authpb "github.com/open-ness/edgecontroller/pb/auth"
@@ -127,8 +126,6 @@ func startup() {
 		"-dsn", fmt.Sprintf("root:%s@tcp(:8083)/controller_ce", dbPass),
 		"-httpPort", "8080",
 		"-grpcPort", "8081",
-		"-elaPort", "42101",
-		"-evaPort", "42102",
 		"-syslog-path", "./temp_telemetry/syslog.out",
 		"-statsd-path", "./temp_telemetry/statsd.out",
 		"-adminPass", adminPass,
@@ -393,8 +390,7 @@ func createAndRegisterNode() *nodeConfig {
 		})
 
 	By("Pre-approving Node by serial")
-	hash := md5.Sum(certReq.RawSubjectPublicKeyInfo) //nolint:gosec
-	serial := base64.RawURLEncoding.EncodeToString(hash[:])
+	serial := cce.NodeSerial(certReq.RawSubjectPublicKeyInfo)
 	nodeID := postNodesSerial(serial)
 
 	By("Resetting the node")
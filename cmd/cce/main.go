@@ -16,7 +16,8 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"strconv"
+	"io/ioutil"
+	"strings"
 
 	"github.com/gorilla/handlers"
 	"golang.org/x/sync/errgroup"
@@ -31,49 +32,140 @@ import (
 	logger "github.com/open-ness/common/log"
 	"github.com/open-ness/common/proxy/progutil"
 	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/audit"
+	"github.com/open-ness/edgecontroller/events"
+	"github.com/open-ness/edgecontroller/externalca"
 	"github.com/open-ness/edgecontroller/gorilla"
 	"github.com/open-ness/edgecontroller/grpc"
+	"github.com/open-ness/edgecontroller/grpc/node"
 	"github.com/open-ness/edgecontroller/http"
 	"github.com/open-ness/edgecontroller/jose"
 	"github.com/open-ness/edgecontroller/k8s"
+	"github.com/open-ness/edgecontroller/liveness"
+	"github.com/open-ness/edgecontroller/memory"
 	"github.com/open-ness/edgecontroller/mysql"
+	"github.com/open-ness/edgecontroller/oidc"
 	"github.com/open-ness/edgecontroller/pki"
+	"github.com/open-ness/edgecontroller/reconcile"
 	"github.com/open-ness/edgecontroller/telemetry"
+	"github.com/open-ness/edgecontroller/webhook"
 )
 
 const certsDir = "./certificates"
 
+// DSN schemes of the persistence backends
+const (
+	mysqlScheme  = "mysql://"
+	memoryScheme = "memory://"
+)
+
 var log = logger.DefaultLogger.WithField("pkg", "main")
 
 // CLI flags
 var (
-	dsn        string
-	adminPass  string
-	logLevel   string
-	httpPort   int
-	grpcPort   int
-	elaPort    int
-	evaPort    int
-	syslogPort int
-	statsdPort int
-	syslogOut  string
-	statsdOut  string
-	orchMode   string
-	k8sClient  k8s.Client
+	dsn         string
+	migrateOnly bool
+	migrateTo   int
+	adminPass   string
+	logLevel    string
+	httpPort    int
+	grpcPort    int
+	syslogPort  int
+	statsdPort  int
+	syslogOut   string
+	statsdOut   string
+	orchMode    string
+	eventBuffer int
+	k8sClient   k8s.Client
+
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+
+	oidcIssuer        string
+	oidcAudience      string
+	oidcUsernameClaim string
+	oidcGroupsClaim   string
+	oidcRoleMapping   string
+
+	loginMaxFailures       int
+	loginMaxSourceFailures int
+
+	maxPendingEnrollments int
+	acceptLegacySerials   bool
+
+	nodeCertValidity      time.Duration
+	tlsCertValidity       time.Duration
+	requireIntermediateCA bool
+
+	externalCASignURL   string
+	externalCAChainURL  string
+	externalCATokenFile string
+	externalCATLSCA     string
+
+	reconcileInterval time.Duration
+
+	heartbeatInterval time.Duration
+	heartbeatTimeout  time.Duration
+
+	nodeConnIdleTimeout time.Duration
 )
 
 func init() {
-	flag.StringVar(&dsn, "dsn", "", "Data source name")
-	flag.StringVar(&adminPass, "adminPass", "", "Admin user password")
+	flag.StringVar(&dsn, "dsn", "", "Data source name. A MySQL DSN, optionally prefixed with mysql://, "+
+		"memory:// for an ephemeral in-memory store or memory:///path/to/file.json for one saved to a file")
+	flag.BoolVar(&migrateOnly, "migrate-only", false, "Migrate the DB schema and exit")
+	flag.IntVar(&migrateTo, "migrate-to", -1, "DB schema version to migrate to with -migrate-only, -1 for the latest")
+	flag.StringVar(&adminPass, "adminPass", "", "Admin user password, set on every start")
 	flag.StringVar(&logLevel, "log-level", "info", "Syslog level")
 	flag.IntVar(&httpPort, "httpPort", 8080, "Controller HTTP port")
 	flag.IntVar(&grpcPort, "grpcPort", 8081, "Controller gRPC port")
-	flag.IntVar(&elaPort, "elaPort", 42101, "Port to dial ELA on edge node")
-	flag.IntVar(&evaPort, "evaPort", 42102, "Port to dial EVA on edge node")
 	flag.IntVar(&syslogPort, "syslogPort", 6514, "Telemetry ingress port for syslog")
 	flag.IntVar(&statsdPort, "statsdPort", 8125, "Telemetry ingress port for statsd")
 	flag.StringVar(&syslogOut, "syslog-path", "./syslog.log", "Syslog output file path")
 	flag.StringVar(&statsdOut, "statsd-path", "./statsd.log", "StatsD output file path")
+	flag.IntVar(&eventBuffer, "event-buffer", 1024, "Number of events kept for resuming GET /events streams")
+	flag.DurationVar(&accessTokenTTL, "access-token-ttl", jose.DefaultAccessTokenTTL, "Lifetime of access tokens")
+	flag.DurationVar(&refreshTokenTTL, "refresh-token-ttl", jose.DefaultRefreshTokenTTL, "Lifetime of refresh tokens")
+	flag.DurationVar(&nodeCertValidity, "node-cert-validity", 0, "Validity of the certificates issued to nodes "+
+		"when they enroll or renew their credentials, 0 for until the CA expires")
+	flag.DurationVar(&tlsCertValidity, "tls-cert-validity", 0, "Validity of the Controller's own TLS "+
+		"certificates, 0 for until the CA expires")
+	flag.BoolVar(&requireIntermediateCA, "require-intermediate-ca", false, "Only start with an intermediate CA "+
+		"installed with cceca, never generating a self-signed root CA")
+	flag.StringVar(&externalCASignURL, "external-ca-sign-url", "", "URL of an external CA that signs the CSRs "+
+		"of nodes instead of the Controller CA, e.g. https://vault:8200/v1/pki/sign/node")
+	flag.StringVar(&externalCAChainURL, "external-ca-chain-url", "", "URL of the PEM-encoded chain of the "+
+		"external CA, e.g. https://vault:8200/v1/pki/ca_chain")
+	flag.StringVar(&externalCATokenFile, "external-ca-token-file", "", "File holding the bearer token of "+
+		"the external CA")
+	flag.DurationVar(&reconcileInterval, "reconcile-interval", reconcile.DefaultInterval, "Interval in which "+
+		"the desired state of the nodes is reconciled with their actual state, 0 to disable")
+	flag.DurationVar(&heartbeatInterval, "heartbeat-interval", liveness.DefaultInterval, "Interval in which "+
+		"nodes send heartbeats")
+	flag.DurationVar(&heartbeatTimeout, "heartbeat-timeout", liveness.DefaultTimeout, "Time since the last "+
+		"heartbeat of a node after which it is offline")
+	flag.DurationVar(&nodeConnIdleTimeout, "node-conn-idle-timeout", node.DefaultIdleTimeout, "Time after "+
+		"which unused gRPC connections to nodes are closed, 0 to keep them open")
+	flag.StringVar(&externalCATLSCA, "external-ca-tls-ca", "", "PEM file of the CAs verifying the external "+
+		"CA's HTTPS endpoint, empty for the system CAs")
+	flag.IntVar(&maxPendingEnrollments, "max-pending-enrollments", 0, "Number of unknown nodes requesting "+
+		"credentials that are queued for approval at /enrollments, 0 to not queue them")
+	flag.BoolVar(&acceptLegacySerials, "accept-legacy-serials", false, "Accept nodes approved by the legacy "+
+		"MD5 serial of their public key and migrate them to the SHA-256 serial")
+	flag.IntVar(&loginMaxFailures, "login-max-failures", cce.DefaultMaxUserFailures,
+		"Failed logins after which a username is locked out, 0 to not throttle logins")
+	flag.IntVar(&loginMaxSourceFailures, "login-max-source-failures", cce.DefaultMaxSourceFailures,
+		"Failed logins after which a source address is locked out")
+
+	// OpenID Connect provider
+	flag.StringVar(&oidcIssuer, "oidc-issuer", "", "Issuer URL of an OpenID Connect provider whose tokens "+
+		"are accepted, empty to only accept tokens issued by POST /auth")
+	flag.StringVar(&oidcAudience, "oidc-audience", "", "Audience (client ID) the provider's tokens must be issued for")
+	flag.StringVar(&oidcUsernameClaim, "oidc-username-claim", oidc.DefaultUsernameClaim,
+		"Token claim holding the username")
+	flag.StringVar(&oidcGroupsClaim, "oidc-groups-claim", oidc.DefaultGroupsClaim, "Token claim holding the groups")
+	flag.StringVar(&oidcRoleMapping, "oidc-role-mapping", "", "Comma-separated group=role pairs mapping groups to "+
+		"the roles read-only, operator or admin, e.g. ops=operator,sre=admin")
 
 	// application orchestration mode
 	flag.StringVar(&orchMode, "orchestration-mode", "native", "Orchestration mode."+
@@ -112,15 +204,23 @@ func main() {
 	flag.Parse()
 
 	// Validate flags
-	if adminPass == "" {
+	if adminPass == "" && !migrateOnly {
 		log.Alert("User admin password cannot be empty")
 		os.Exit(1)
 	}
+	if accessTokenTTL <= 0 || refreshTokenTTL < accessTokenTTL {
+		log.Alert("Token lifetimes must be positive and refresh tokens must not expire before access tokens")
+		os.Exit(1)
+	}
+	if heartbeatInterval <= 0 || heartbeatTimeout < heartbeatInterval {
+		log.Alert("Heartbeat interval must be positive and the timeout must not be shorter than the interval")
+		os.Exit(1)
+	}
 
 	// Set log level
 	lvl, err := logger.ParseLevel(logLevel)
 	if err != nil {
-		log.Alert("Bad log level %q: %v", logLevel, err)
+		log.Alertf("Bad log level %q: %v", logLevel, err)
 		os.Exit(1)
 	}
 	log.Infof("Setting log level to: %s", logLevel)
@@ -135,35 +235,82 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Connect to the db and verify
-	db := connectDB(dsn)
+	// Connect to the db, verify and migrate its schema
+	ps := connectPersistence(dsn)
+	if migrateOnly {
+		log.Info("Migration complete, exiting")
+		return
+	}
 
-	// Initialize self-signed root CA
-	rootCA, err := pki.InitRootCA(filepath.Join(certsDir, "ca"))
+	// Initialize the intermediate CA if one is installed, or else the
+	// self-signed root CA
+	initCA := pki.InitRootCA
+	if requireIntermediateCA {
+		initCA = pki.LoadIntermediateCA
+	}
+	rootCA, err := initCA(filepath.Join(certsDir, "ca"))
 	if err != nil {
 		log.Alertf("Error initializing Controller CA: %v", err)
 		os.Exit(1)
 	}
+	rootCA.NodeCertValidity = nodeCertValidity
+	rootCA.TLSCertValidity = tlsCertValidity
 	log.Info("Initialized Controller CA")
 
 	// TODO: Replace printing to STDERR with writing to a file or making the
 	// certificate available via an HTTP endpoint.
 	log.Infof("Root CA:\n%s", encodeCA(rootCA))
 
-	// Define controller service
+	// Issue node certificates with the external CA, if any
+	authority, serverCAs, nodeCAs := getAuthority(rootCA)
+
+	// Open the telemetry output files. The authentication events are written
+	// to the syslog output along with the syslog messages of the nodes.
+	syslogFile := openTelemetryFile(syslogOut)
+	defer syslogFile.Close()
+	syslog := &telemetry.SyncWriter{W: syslogFile}
+	statsd := openTelemetryFile(statsdOut)
+	defer statsd.Close()
+
+	// Define controller service. Changes are recorded in the audit trail and
+	// published to the event stream. Webhook deliveries and token revocations
+	// are neither, and of node statuses only the transitions are published.
+	eventHub := cce.NewEventHub(eventBuffer)
+	webhooks := webhook.NewDispatcher(ps)
+	heartbeats := liveness.NewMonitor(ps)
+	heartbeats.Hub = eventHub
+	heartbeats.NotificationService = webhooks
+	heartbeats.Interval = heartbeatInterval
+	heartbeats.Timeout = heartbeatTimeout
 	controller := &cce.Controller{
-		PersistenceService: &mysql.PersistenceService{DB: db},
-		AuthorityService:   rootCA,
-		TokenService:       getTokenSigner(),
+		PersistenceService: &events.PersistenceService{
+			PersistenceService: &audit.PersistenceService{PersistenceService: ps},
+			Hub:                eventHub,
+		},
+		EventHub:              eventHub,
+		NotificationService:   webhooks,
+		HeartbeatService:      heartbeats,
+		MaxPendingEnrollments: maxPendingEnrollments,
+		AcceptLegacySerials:   acceptLegacySerials,
+		AuthorityService:      authority,
+		ServerCAs:             serverCAs,
+		TokenService:          getTokenSigner(&cce.TokenRevocations{PersistenceService: ps}),
+		IdentityProvider:      getIdentityProvider(),
+		LoginThrottle:         getLoginThrottle(),
+		AuthEvents:            &telemetry.AuthEventLogger{W: syslog, Hostname: hostname()},
 		AdminCreds: &cce.AuthCreds{
 			Username: "admin",
 			Password: adminPass,
 		},
 		OrchestrationMode: orchestrationMode,
 		KubernetesClient:  &k8sClient,
-		ELAPort:           strconv.Itoa(elaPort),
-		EVAPort:           strconv.Itoa(evaPort),
-		EdgeNodeCreds:     newClientTLSConf(rootCA, "controller.openness"),
+		EdgeNodeCreds:     newClientTLSConf(rootCA, nodeCAs, "controller.openness"),
+	}
+
+	// Create the admin user or reset its password to adminPass
+	if err = cce.EnsureAdminUser(context.Background(), controller.PersistenceService, controller.AdminCreds); err != nil {
+		log.Alertf("Error creating admin user: %v", err)
+		os.Exit(1)
 	}
 
 	// Create an error group to manage server goroutines
@@ -189,10 +336,32 @@ func main() {
 	grpcAddr := fmt.Sprintf(":%d", grpcPort)
 	syslogAddr := fmt.Sprintf(":%d", syslogPort)
 	statsdAddr := fmt.Sprintf(":%d", statsdPort)
-	eg.Go(serveHTTP(ctx, controller, httpAddr))
-	eg.Go(serveGRPC(ctx, controller, grpcAddr, getGRPCTLS(rootCA)))
-	eg.Go(serveTelemetry(ctx, syslogOut, syslogAddr, newTLSConf(rootCA, telemetry.SyslogSNI)))
-	eg.Go(serveTelemetry(ctx, statsdOut, statsdAddr, newTLSConf(rootCA, telemetry.StatsdSNI)))
+	// Share one gRPC connection per node and service between the requests
+	nodeConns := node.NewPool(controller.EdgeNodeCreds)
+	nodeConns.IdleTimeout = nodeConnIdleTimeout
+	eg.Go(func() error { return nodeConns.Run(ctx) })
+
+	eg.Go(serveHTTP(ctx, controller, nodeConns, httpAddr))
+	revocations := &cce.CertificateRevocations{PersistenceService: ps}
+	eg.Go(serveGRPC(ctx, controller, grpcAddr, getGRPCTLS(rootCA, nodeCAs, revocations)))
+	eg.Go(serveTelemetry(ctx, syslog, syslogAddr, newTLSConf(rootCA, nodeCAs, telemetry.SyslogSNI), revocations))
+	eg.Go(serveTelemetry(ctx, statsd, statsdAddr, newTLSConf(rootCA, nodeCAs, telemetry.StatsdSNI), revocations))
+
+	// Deliver webhook notifications
+	eg.Go(func() error { return webhooks.Run(ctx) })
+
+	// Mark the nodes whose heartbeats are late degraded or offline
+	eg.Go(func() error { return heartbeats.Run(ctx) })
+
+	// Reconcile the nodes. Sync statuses are not audited.
+	if reconcileInterval > 0 {
+		reconciler := reconcile.NewReconciler(controller, ps)
+		reconciler.Interval = reconcileInterval
+		reconciler.Dial = func(ctx context.Context, nodeID string, svc node.Service) (*node.ClientConn, error) {
+			return nodeConns.Get(ctx, ps, nodeID, svc)
+		}
+		eg.Go(func() error { return reconciler.Run(ctx) })
+	}
 
 	log.Info("Controller CE ready")
 
@@ -217,7 +386,27 @@ func registerAllNodes(ctx context.Context, ps cce.PersistenceService) {
 	}
 }
 
-// Connect to a mysql DB and ping it for readiness.
+// Connect to the persistence backend selected by the scheme of the DSN.
+func connectPersistence(dsn string) cce.PersistenceService {
+	if strings.HasPrefix(dsn, memoryScheme) {
+		path := strings.TrimPrefix(dsn, memoryScheme)
+		ps, err := memory.NewPersistenceService(path)
+		if err != nil {
+			log.Alertf("Error opening in-memory db: %v", err)
+			os.Exit(1)
+		}
+		if path == "" {
+			log.Info("Using ephemeral in-memory db")
+		} else {
+			log.Infof("Using in-memory db saved to %q", path)
+		}
+		return ps
+	}
+
+	return &mysql.PersistenceService{DB: connectDB(strings.TrimPrefix(dsn, mysqlScheme))}
+}
+
+// Connect to a mysql DB, ping it for readiness and apply pending migrations.
 func connectDB(dsn string) *sql.DB {
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
@@ -231,39 +420,161 @@ func connectDB(dsn string) *sql.DB {
 		os.Exit(1)
 	}
 	log.Info("DB connection established")
+
+	target := mysql.LatestVersion()
+	if migrateOnly && migrateTo >= 0 {
+		target = migrateTo
+	}
+	from, to, err := mysql.Migrate(context.Background(), db, target)
+	if err != nil {
+		log.Alertf("DB migration from schema version %d failed at version %d: %v", from, to, err)
+		os.Exit(1)
+	}
+	if from != to {
+		log.Infof("Migrated DB schema from version %d to %d", from, to)
+	} else {
+		log.Infof("DB schema is at version %d", to)
+	}
+
 	return db
 }
 
-// Encode self-signed Controller CA. This is used to manually configure the
-// Appliance by adding the Controller to its trust anchor pool for TLS
-// connections.
+// Encode the root CA of the Controller CA chain. This is used to manually
+// configure the Appliance by adding the Controller to its trust anchor pool
+// for TLS connections.
 func encodeCA(rootCA *pki.RootCA) string {
+	chain, _ := rootCA.CAChain()
 	return string(pem.EncodeToMemory(
 		&pem.Block{
 			Type:  "CERTIFICATE",
-			Bytes: rootCA.Cert.Raw,
+			Bytes: chain[len(chain)-1].Raw,
 		},
 	))
 }
 
-// Generate a key for signing authentication tokens. The key is only stored
-// in memory and will be re-generated upon Controller restart.
-//
-// TODO: Persist the key to avoid having API/UI users have to login and get a
-// new token every time the Controller is restarted.
-func getTokenSigner() *jose.JWSTokenIssuer {
-	joseKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+// Load the keys for signing authentication tokens, so that tokens stay valid
+// across restarts and are accepted by controllers sharing the certificates
+// directory.
+func getTokenSigner(revocations jose.Revocations) *jose.JWSTokenIssuer {
+	keys, err := jose.LoadKeyRing(filepath.Join(certsDir, "jwt"))
 	if err != nil {
-		log.Alertf("error generating token signing key: %v", err)
+		log.Alertf("Error loading token signing keys: %v", err)
 		os.Exit(1)
 	}
 	return &jose.JWSTokenIssuer{
-		Key:          joseKey,
-		KeyAlgorithm: "ES384",
+		Keys:            keys,
+		AccessTokenTTL:  accessTokenTTL,
+		RefreshTokenTTL: refreshTokenTTL,
+		Revocations:     revocations,
+	}
+}
+
+// Configure the OpenID Connect provider whose tokens are accepted, if any.
+// Its keys are discovered when the first token is verified.
+func getIdentityProvider() cce.IdentityProvider {
+	if oidcIssuer == "" {
+		return nil
+	}
+	if oidcAudience == "" {
+		log.Alert("OpenID Connect audience cannot be empty")
+		os.Exit(1)
+	}
+	mapping, err := oidc.ParseRoleMapping(oidcRoleMapping)
+	if err != nil {
+		log.Alertf("Bad OpenID Connect role mapping: %v", err)
+		os.Exit(1)
+	}
+
+	p := oidc.NewProvider(oidcIssuer, oidcAudience, mapping)
+	p.UsernameClaim = oidcUsernameClaim
+	p.GroupsClaim = oidcGroupsClaim
+	log.Infof("Accepting tokens of OpenID Connect provider %s", p.Issuer)
+
+	return p
+}
+
+// Configure the CA that issues node certificates: the external CA if one is
+// set, or else the Controller CA, which always issues the Controller's own
+// TLS certificates. It returns the root CAs of the Controller's TLS
+// certificates that nodes are given in addition to the chain of their
+// certificates, and the pool of CAs verifying the certificates of nodes.
+func getAuthority(rootCA *pki.RootCA) (cce.AuthorityService, []*x509.Certificate, *x509.CertPool) {
+	if externalCASignURL == "" {
+		return rootCA, nil, rootCA.CertPool()
+	}
+	if externalCAChainURL == "" {
+		log.Alert("External CA chain URL cannot be empty")
+		os.Exit(1)
+	}
+
+	var rootCAs *x509.CertPool
+	if externalCATLSCA != "" {
+		certs, err := pki.LoadCertificates(externalCATLSCA)
+		if err != nil {
+			log.Alertf("Error loading external CA TLS CAs: %v", err)
+			os.Exit(1)
+		}
+		rootCAs = x509.NewCertPool()
+		for _, cert := range certs {
+			rootCAs.AddCert(cert)
+		}
+	}
+	ca := externalca.NewCA(externalCASignURL, externalCAChainURL, "", rootCAs)
+	ca.Validity = nodeCertValidity
+	if externalCATokenFile != "" {
+		token, err := ioutil.ReadFile(filepath.Clean(externalCATokenFile))
+		if err != nil {
+			log.Alertf("Error reading external CA token: %v", err)
+			os.Exit(1)
+		}
+		ca.Token = strings.TrimSpace(string(token))
 	}
+
+	// Nodes are issued certificates by the external CA, but the Controller's
+	// own certificates are still issued by the Controller CA
+	chain, err := ca.CAChain()
+	if err != nil {
+		log.Alertf("Error fetching external CA chain: %v", err)
+		os.Exit(1)
+	}
+	nodeCAs := rootCA.CertPool()
+	for _, cert := range chain {
+		nodeCAs.AddCert(cert)
+	}
+	localChain, _ := rootCA.CAChain()
+	log.Infof("Issuing node certificates with external CA %s", chain[0].Subject)
+
+	return ca, localChain[len(localChain)-1:], nodeCAs
 }
 
-func serveHTTP(ctx context.Context, controller *cce.Controller, addr string) func() error {
+func getLoginThrottle() *cce.LoginThrottle {
+	if loginMaxFailures == 0 {
+		log.Warning("Failed logins are not throttled")
+		return nil
+	}
+	if loginMaxFailures < 0 || loginMaxSourceFailures <= 0 {
+		log.Alert("Maximum failed logins must be positive")
+		os.Exit(1)
+	}
+
+	t := cce.NewLoginThrottle()
+	t.MaxUserFailures = loginMaxFailures
+	t.MaxSourceFailures = loginMaxSourceFailures
+
+	return t
+}
+
+// hostname returns the hostname of the syslog messages of the controller.
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		log.Warningf("Error getting hostname: %v", err)
+		return ""
+	}
+	return name
+}
+
+func serveHTTP(ctx context.Context, controller *cce.Controller, nodeConns *node.Pool, addr string) func() error {
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.Alertf("Could not listen on %q: %v", addr, err)
@@ -276,15 +587,23 @@ func serveHTTP(ctx context.Context, controller *cce.Controller, addr string) fun
 	// handler must be applied at the top-level router.
 	cors := handlers.CORS(
 		handlers.AllowedOrigins([]string{"*"}),
-		handlers.AllowedHeaders([]string{"Authorization", "Content-Type", "ContentType"}),
+		handlers.AllowedHeaders([]string{"Authorization", "Content-Type", "ContentType", "If-Match",
+			"Last-Event-ID"}),
 		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE"}),
+		handlers.ExposedHeaders([]string{"ETag"}),
 	)
 
 	// Configure http server
-	koko := gorilla.NewGorilla(controller)
+	koko := gorilla.NewGorilla(controller, nodeConns)
 
 	httpServer := http.NewServer(cors(koko))
 
+	// End the event streams on shutdown as they would otherwise hold their
+	// connections open until the shutdown times out
+	if controller.EventHub != nil {
+		httpServer.RegisterOnShutdown(controller.EventHub.Close)
+	}
+
 	// Shutdown http server on exit signal
 	go func() {
 		<-ctx.Done()
@@ -351,15 +670,22 @@ func serveGRPC(ctx context.Context, controller *cce.Controller, addr string, con
 	}
 }
 
-func serveTelemetry(ctx context.Context, outfile, addr string, conf *tls.Config) func() error {
+func serveTelemetry(
+	ctx context.Context,
+	w io.Writer,
+	addr string,
+	conf *tls.Config,
+	revocations *cce.CertificateRevocations,
+) func() error {
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.Alertf("Could not listen on %q: %v", addr, err)
 		os.Exit(1)
 	}
 
-	// Upgrade to TLS
+	// Upgrade to TLS, rejecting revoked client certificates
 	conf.ClientAuth = tls.RequireAndVerifyClientCert
+	conf.VerifyPeerCertificate = revocations.VerifyPeerCertificate
 	lis = tls.NewListener(lis, conf)
 
 	// Shutdown syslog server on exit signal
@@ -368,8 +694,19 @@ func serveTelemetry(ctx context.Context, outfile, addr string, conf *tls.Config)
 		<-ctx.Done()
 	}()
 
-	// Start the syslog server
-	if err = os.MkdirAll(filepath.Dir(outfile), 0750); err != nil {
+	log.Infof("Telemetry server serving on %q", addr)
+	return func() error {
+		return telemetry.WriteToByLine(w, 0, telemetry.AcceptTCP(lis))
+	}
+}
+
+// openTelemetryFile opens a telemetry output file for appending.
+//
+// TODO: Buffer writes for performance. This currently causes telemetry
+// integration tests to fail, because writes won't occur until shutdown of the
+// Controller is initiated and the buffered writer is flushed.
+func openTelemetryFile(outfile string) *os.File {
+	if err := os.MkdirAll(filepath.Dir(outfile), 0750); err != nil {
 		log.Alertf("Error creating directory for telemetry file %q: %v", outfile, err)
 		os.Exit(1)
 	}
@@ -378,20 +715,9 @@ func serveTelemetry(ctx context.Context, outfile, addr string, conf *tls.Config)
 		log.Alertf("Error opening telemetry file %q: %v", outfile, err)
 		os.Exit(1)
 	}
-	log.Infof("Telemetry server serving on %q, writing to %q", addr, outfile)
-	return func() error {
-		defer f.Close()
-
-		// TODO: Buffer writes for performance. This currently causes telemetry
-		// integration tests to fail, because writes won't occur until shutdown
-		// of the Controller is initiated and the buffered writer is flushed.
-		//
-		//     w := bufio.NewWriter(f)
-		//     defer w.Flush()
-		w := io.Writer(f)
+	log.Infof("Writing telemetry to %q", outfile)
 
-		return telemetry.WriteToByLine(w, 0, telemetry.AcceptTCP(lis))
-	}
+	return f
 }
 
 // Generate a TLS config that handles two server names:
@@ -401,14 +727,20 @@ func serveTelemetry(ctx context.Context, outfile, addr string, conf *tls.Config)
 //
 // In the gRPC server the servername will be considered for the particular RPCs
 // authorized to the client.
-func getGRPCTLS(rootCA *pki.RootCA) *tls.Config {
-	// Generate server TLS config for post-enrollment
-	serverConf := newTLSConf(rootCA, grpc.SNI)
+func getGRPCTLS(
+	rootCA *pki.RootCA,
+	clientCAs *x509.CertPool,
+	revocations *cce.CertificateRevocations,
+) *tls.Config {
+	// Generate server TLS config for post-enrollment, rejecting revoked client
+	// certificates
+	serverConf := newTLSConf(rootCA, clientCAs, grpc.SNI)
 	serverConf.NextProtos = []string{"h2"}
 	serverConf.ClientAuth = tls.RequireAndVerifyClientCert
+	serverConf.VerifyPeerCertificate = revocations.VerifyPeerCertificate
 
 	// Generate server TLS config for enrollment
-	enrollmentConf := newTLSConf(rootCA, grpc.EnrollmentSNI)
+	enrollmentConf := newTLSConf(rootCA, clientCAs, grpc.EnrollmentSNI)
 	enrollmentConf.NextProtos = []string{"h2"}
 	enrollmentConf.ClientAuth = tls.NoClientCert
 
@@ -430,8 +762,8 @@ func getGRPCTLS(rootCA *pki.RootCA) *tls.Config {
 }
 
 // Generate a new TLS key/cert pair from a root CA for use in a TLS server with
-// some server name.
-func newTLSConf(rootCA *pki.RootCA, sni string) *tls.Config {
+// some server name, verifying client certificates with clientCAs.
+func newTLSConf(rootCA *pki.RootCA, clientCAs *x509.CertPool, sni string) *tls.Config {
 	tlsKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
 	if err != nil {
 		log.Alertf("error generating TLS key for server %q: %v", sni, err)
@@ -451,23 +783,21 @@ func newTLSConf(rootCA *pki.RootCA, sni string) *tls.Config {
 	for _, caCert := range tlsCAChain {
 		tlsChain = append(tlsChain, caCert.Raw)
 	}
-	tlsRoots := x509.NewCertPool()
-	tlsRoots.AddCert(tlsCAChain[len(tlsCAChain)-1])
 	return &tls.Config{
 		Certificates: []tls.Certificate{{
 			Certificate: tlsChain,
 			PrivateKey:  tlsKey,
 			Leaf:        tlsCert,
 		}},
-		ClientCAs:    tlsRoots,
+		ClientCAs:    clientCAs,
 		MinVersion:   tls.VersionTLS12,
 		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
 	}
 }
 
 // Generate a new TLS key/cert pair from a root CA for use in a TLS server with
-// some server name.
-func newClientTLSConf(rootCA *pki.RootCA, sni string) *tls.Config {
+// some server name, verifying server certificates with rootCAs.
+func newClientTLSConf(rootCA *pki.RootCA, rootCAs *x509.CertPool, sni string) *tls.Config {
 	tlsKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
 	if err != nil {
 		log.Alertf("error generating TLS key for server %q: %v", sni, err)
@@ -487,15 +817,13 @@ func newClientTLSConf(rootCA *pki.RootCA, sni string) *tls.Config {
 	for _, caCert := range tlsCAChain {
 		tlsChain = append(tlsChain, caCert.Raw)
 	}
-	tlsRoots := x509.NewCertPool()
-	tlsRoots.AddCert(tlsCAChain[len(tlsCAChain)-1])
 	return &tls.Config{
 		Certificates: []tls.Certificate{{
 			Certificate: tlsChain,
 			PrivateKey:  tlsKey,
 			Leaf:        tlsCert,
 		}},
-		RootCAs:      tlsRoots,
+		RootCAs:      rootCAs,
 		MinVersion:   tls.VersionTLS12,
 		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
 	}
@@ -8,12 +8,10 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/md5" //nolint:gosec
 	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
-	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
@@ -39,6 +37,7 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/grpclog"
 
+	cce "github.com/open-ness/edgecontroller"
 	cceGRPC "github.com/open-ness/edgecontroller/grpc"
 	authpb "github.com/open-ness/edgecontroller/pb/auth"
 	"github.com/open-ness/edgecontroller/pki"
@@ -125,13 +124,15 @@ func startup() {
 		"-dsn", fmt.Sprintf("root:%s@tcp(:8083)/controller_ce", dbPass),
 		"-httpPort", "8080",
 		"-grpcPort", "8081",
-		"-elaPort", "42101",
-		"-evaPort", "42102",
 		"-syslogPort", "6514",
 		"-statsdPort", "8125",
 		"-syslog-path", filepath.Join(telemDir, "syslog.log"),
 		"-statsd-path", filepath.Join(telemDir, "statsd.log"),
-		"-adminPass", adminPass)
+		"-adminPass", adminPass,
+		"-max-pending-enrollments", "10",
+		"-accept-legacy-serials",
+		// the suite uses a single access token
+		"-access-token-ttl", "24h")
 	ctrl, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
 	Expect(err).ToNot(HaveOccurred(), "Problem starting service")
 
@@ -242,11 +243,15 @@ func insertNFDTags(values string) {
 }
 
 func authToken() string {
+	return userToken("admin", adminPass)
+}
+
+func userToken(username, password string) string {
 	payload, err := json.Marshal(
 		struct {
 			Username string `json:"username"`
 			Password string `json:"password"`
-		}{"admin", adminPass})
+		}{username, password})
 	Expect(err).ToNot(HaveOccurred())
 
 	req, err := http.NewRequest(
@@ -453,8 +458,7 @@ func createAndRegisterNode() *nodeConfig {
 		})
 
 	By("Pre-approving Node by serial")
-	hash := md5.Sum(certReq.RawSubjectPublicKeyInfo) //nolint:gosec
-	serial := base64.RawURLEncoding.EncodeToString(hash[:])
+	serial := cce.NodeSerial(certReq.RawSubjectPublicKeyInfo)
 	nodeID := postNodesSerial(serial)
 
 	By("Resetting the node")
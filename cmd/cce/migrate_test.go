@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/open-ness/edgecontroller/mysql"
+)
+
+// migrationsDB is the database the migrations are tested on, so the schema of
+// the controller under test is left alone.
+const migrationsDB = "controller_ce_migrations"
+
+var _ = Describe("Schema migrations", func() {
+	var (
+		ctx context.Context
+		db  *sql.DB
+	)
+
+	exec := func(query string, args ...interface{}) {
+		_, err := db.ExecContext(ctx, query, args...)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	version := func() int {
+		var v sql.NullInt64
+		Expect(db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_version").Scan(&v)).To(Succeed())
+		return int(v.Int64)
+	}
+
+	tables := func() []string {
+		rows, err := db.QueryContext(ctx,
+			"SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE()")
+		Expect(err).ToNot(HaveOccurred())
+		defer rows.Close()
+
+		var names []string
+		for rows.Next() {
+			var name string
+			Expect(rows.Scan(&name)).To(Succeed())
+			names = append(names, name)
+		}
+		Expect(rows.Err()).ToNot(HaveOccurred())
+		return names
+	}
+
+	migrate := func(target int) (int, int) {
+		from, to, err := mysql.Migrate(ctx, db, target)
+		Expect(err).ToNot(HaveOccurred())
+		return from, to
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		By("Creating an empty database")
+		root, err := sql.Open("mysql", fmt.Sprintf("root:%s@tcp(:8083)/", dbPass))
+		Expect(err).ToNot(HaveOccurred())
+		defer root.Close()
+		_, err = root.ExecContext(ctx, "DROP DATABASE IF EXISTS "+migrationsDB)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = root.ExecContext(ctx, "CREATE DATABASE "+migrationsDB)
+		Expect(err).ToNot(HaveOccurred())
+
+		db, err = sql.Open("mysql", fmt.Sprintf("root:%s@tcp(:8083)/%s", dbPass, migrationsDB))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		exec("DROP DATABASE " + migrationsDB)
+		Expect(db.Close()).To(Succeed())
+	})
+
+	It("Should migrate an empty database to the latest version", func() {
+		from, to := migrate(mysql.LatestVersion())
+
+		Expect(from).To(Equal(0))
+		Expect(to).To(Equal(mysql.LatestVersion()))
+		Expect(version()).To(Equal(mysql.LatestVersion()))
+		Expect(tables()).To(ContainElement("nodes"))
+		Expect(tables()).To(ContainElement("revoked_tokens"))
+
+		By("Verifying migrating it again does nothing")
+		from, to = migrate(mysql.LatestVersion())
+		Expect(from).To(Equal(mysql.LatestVersion()))
+		Expect(to).To(Equal(mysql.LatestVersion()))
+	})
+
+	It("Should record a schema created before migrations as version 1", func() {
+		By("Creating the tables of the initial schema without schema_version")
+		for _, stmt := range mysql.Migrations[0].Up {
+			exec(stmt)
+		}
+
+		from, to := migrate(mysql.LatestVersion())
+
+		Expect(from).To(Equal(1))
+		Expect(to).To(Equal(mysql.LatestVersion()))
+		var description string
+		Expect(db.QueryRowContext(ctx, "SELECT description FROM schema_version WHERE version = 1").
+			Scan(&description)).To(Succeed())
+		Expect(description).To(HaveSuffix("(legacy)"))
+	})
+
+	It("Should revert migrations and apply them again", func() {
+		migrate(mysql.LatestVersion())
+
+		By("Reverting to the initial schema")
+		from, to := migrate(1)
+		Expect(from).To(Equal(mysql.LatestVersion()))
+		Expect(to).To(Equal(1))
+		Expect(version()).To(Equal(1))
+		Expect(tables()).To(ContainElement("nodes"))
+		Expect(tables()).ToNot(ContainElement("revoked_tokens"))
+
+		By("Reverting all migrations")
+		migrate(0)
+		Expect(tables()).To(ConsistOf("schema_version"))
+
+		By("Applying them again")
+		_, to = migrate(mysql.LatestVersion())
+		Expect(to).To(Equal(mysql.LatestVersion()))
+		Expect(version()).To(Equal(mysql.LatestVersion()))
+	})
+
+	It("Should reject unknown versions", func() {
+		_, _, err := mysql.Migrate(ctx, db, -1)
+		Expect(err).To(MatchError("unknown schema version -1"))
+		_, _, err = mysql.Migrate(ctx, db, mysql.LatestVersion()+1)
+		Expect(err).To(MatchError(fmt.Sprintf("unknown schema version %d", mysql.LatestVersion()+1)))
+
+		By("Recording a version newer than the latest known one")
+		migrate(mysql.LatestVersion())
+		exec("INSERT INTO schema_version (version, description) VALUES (?, 'from the future')",
+			mysql.LatestVersion()+1)
+
+		_, _, err = mysql.Migrate(ctx, db, mysql.LatestVersion())
+		Expect(err).To(MatchError(fmt.Sprintf("schema version %d is newer than the latest known version %d",
+			mysql.LatestVersion()+1, mysql.LatestVersion())))
+	})
+
+	It("Should wait for the migration lock", func() {
+		By("Holding the migration lock on another connection")
+		conn, err := db.Conn(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		var locked int
+		Expect(conn.QueryRowContext(ctx, "SELECT GET_LOCK('controller_ce.migrations', 0)").
+			Scan(&locked)).To(Succeed())
+		Expect(locked).To(Equal(1))
+
+		done := make(chan error, 1)
+		go func() {
+			_, _, err := mysql.Migrate(ctx, db, mysql.LatestVersion())
+			done <- err
+		}()
+		Consistently(done, 500*time.Millisecond).ShouldNot(Receive())
+		Expect(tables()).To(BeEmpty())
+
+		By("Releasing the migration lock")
+		_, err = conn.ExecContext(ctx, "SELECT RELEASE_LOCK('controller_ce.migrations')")
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(done, 10*time.Second).Should(Receive(BeNil()))
+		Expect(version()).To(Equal(mysql.LatestVersion()))
+	})
+})
@@ -8,16 +8,22 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"net"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
 
 	cce "github.com/open-ness/edgecontroller"
+	cceGRPC "github.com/open-ness/edgecontroller/grpc"
 	authpb "github.com/open-ness/edgecontroller/pb/auth"
 )
 
@@ -91,6 +97,126 @@ var _ = Describe("Node Auth Service", func() {
 		})
 	})
 
+	Describe("RenewCredentials", func() {
+		var (
+			nodeCfg *nodeConfig
+			conns   []*grpc.ClientConn
+		)
+
+		BeforeEach(func() {
+			clearGRPCTargetsTable()
+			nodeCfg = createAndRegisterNode()
+		})
+
+		AfterEach(func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+			conns = nil
+		})
+
+		parseCert := func(certPEM string) *x509.Certificate {
+			block, _ := pem.Decode([]byte(certPEM))
+			Expect(block).ToNot(BeNil())
+			cert, err := x509.ParseCertificate(block.Bytes)
+			Expect(err).ToNot(HaveOccurred())
+			return cert
+		}
+
+		// dialAuthSvc connects to the auth service with a node certificate
+		dialAuthSvc := func(cert *x509.Certificate) authpb.AuthServiceClient {
+			caPool := x509.NewCertPool()
+			Expect(caPool.AppendCertsFromPEM(controllerRootPEM)).To(BeTrue())
+			tlsCreds := credentials.NewTLS(&tls.Config{
+				Certificates: []tls.Certificate{{
+					Certificate: [][]byte{cert.Raw},
+					PrivateKey:  nodeCfg.key,
+				}},
+				RootCAs:    caPool,
+				ServerName: cceGRPC.SNI,
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			conn, err := grpc.DialContext(
+				ctx,
+				net.JoinHostPort("127.0.0.1", "8081"),
+				grpc.WithTransportCredentials(tlsCreds),
+				grpc.WithBlock())
+			Expect(err).ToNot(HaveOccurred())
+			conns = append(conns, conn)
+
+			return authpb.NewAuthServiceClient(conn)
+		}
+
+		newCSR := func(key interface{}) string {
+			csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+			Expect(err).ToNot(HaveOccurred())
+			return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+		}
+
+		It("Should renew the credentials and revoke the superseded certificate", func() {
+			oldCert := parseCert(nodeCfg.creds.Certificate)
+
+			By("Renewing the credentials with the current certificate")
+			renewed, err := dialAuthSvc(oldCert).RenewCredentials(
+				context.TODO(),
+				&authpb.Identity{Csr: newCSR(nodeCfg.key)},
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("Verifying a new certificate was issued for the node")
+			newCert := parseCert(renewed.Certificate)
+			Expect(newCert.SerialNumber).ToNot(Equal(oldCert.SerialNumber))
+			Expect(newCert.Subject.CommonName).To(Equal(nodeCfg.nodeID))
+			Expect(renewed.CaChain).To(Equal(nodeCfg.creds.CaChain))
+
+			By("Verifying the new certificate can renew the credentials")
+			_, err = dialAuthSvc(newCert).RenewCredentials(
+				context.TODO(),
+				&authpb.Identity{Csr: newCSR(nodeCfg.key)},
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("Verifying the superseded certificate was revoked")
+			caPool := x509.NewCertPool()
+			Expect(caPool.AppendCertsFromPEM(controllerRootPEM)).To(BeTrue())
+			_, err = tls.Dial("tcp", "127.0.0.1:8081", &tls.Config{
+				Certificates: []tls.Certificate{{
+					Certificate: [][]byte{oldCert.Raw},
+					PrivateKey:  nodeCfg.key,
+				}},
+				RootCAs:    caPool,
+				ServerName: cceGRPC.SNI,
+				// The server rejects client certificates during a TLS 1.2 handshake
+				MaxVersion: tls.VersionTLS12,
+			})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should return a gRPC PermissionDenied error for a CSR of another key", func() {
+			By("Generating another private key")
+			key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("Renewing the credentials with a CSR of the other key")
+			_, err = dialAuthSvc(parseCert(nodeCfg.creds.Certificate)).RenewCredentials(
+				context.TODO(),
+				&authpb.Identity{Csr: newCSR(key)},
+			)
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+		})
+
+		It("Should return a gRPC PermissionDenied error without a client certificate", func() {
+			By("Renewing the credentials on the enrollment connection")
+			_, err := authSvcCli.RenewCredentials(
+				context.TODO(),
+				&authpb.Identity{Csr: newCSR(nodeCfg.key)},
+			)
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+		})
+	})
+
 	Describe("Errors", func() {
 		It("Should return an error if payload is empty", func() {
 			By("Requesting credentials from auth service")
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	cce "github.com/open-ness/edgecontroller"
+	cceGRPC "github.com/open-ness/edgecontroller/grpc"
+	hbpb "github.com/open-ness/edgecontroller/pb/heartbeat"
+)
+
+var _ = Describe("Node Heartbeat Service", func() {
+	var (
+		nodeCfg *nodeConfig
+		conns   []*grpc.ClientConn
+	)
+
+	BeforeEach(func() {
+		clearGRPCTargetsTable()
+		nodeCfg = createAndRegisterNode()
+	})
+
+	AfterEach(func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		conns = nil
+	})
+
+	// dialHeartbeatSvc connects to the heartbeat service on a server name,
+	// with the node certificate if clientCert is set
+	dialHeartbeatSvc := func(serverName string, clientCert bool) hbpb.HeartbeatServiceClient {
+		caPool := x509.NewCertPool()
+		Expect(caPool.AppendCertsFromPEM(controllerRootPEM)).To(BeTrue())
+		conf := &tls.Config{
+			RootCAs:    caPool,
+			ServerName: serverName,
+		}
+		if clientCert {
+			block, _ := pem.Decode([]byte(nodeCfg.creds.Certificate))
+			Expect(block).ToNot(BeNil())
+			conf.Certificates = []tls.Certificate{{
+				Certificate: [][]byte{block.Bytes},
+				PrivateKey:  nodeCfg.key,
+			}}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		conn, err := grpc.DialContext(
+			ctx,
+			net.JoinHostPort("127.0.0.1", "8081"),
+			grpc.WithTransportCredentials(credentials.NewTLS(conf)),
+			grpc.WithBlock())
+		Expect(err).ToNot(HaveOccurred())
+		conns = append(conns, conn)
+
+		return hbpb.NewHeartbeatServiceClient(conn)
+	}
+
+	It("Should bring the node online", func() {
+		By("Verifying the node is offline before its first heartbeat")
+		Expect(getNode(nodeCfg.nodeID).Status).To(Equal(cce.NodeStatusOffline))
+
+		By("Sending a heartbeat")
+		resp, err := dialHeartbeatSvc(cceGRPC.SNI, true).Heartbeat(context.TODO(), &hbpb.HeartbeatRequest{
+			AgentVersion:  "1.2.3",
+			UptimeSeconds: 3600,
+			Resources: &hbpb.ResourceUsage{
+				CpuPercent:       25,
+				MemoryUsedBytes:  1 << 30,
+				MemoryTotalBytes: 4 << 30,
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.IntervalSeconds).To(BeEquivalentTo(30))
+
+		By("Verifying the node is online")
+		node := getNode(nodeCfg.nodeID)
+		Expect(node.Status).To(Equal(cce.NodeStatusOnline))
+		Expect(node.LastSeen).ToNot(BeEmpty())
+	})
+
+	It("Should return a gRPC PermissionDenied error without a client certificate", func() {
+		_, err := dialHeartbeatSvc(cceGRPC.EnrollmentSNI, false).Heartbeat(
+			context.TODO(), &hbpb.HeartbeatRequest{})
+		Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+	})
+})
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	cce "github.com/open-ness/edgecontroller"
+	authpb "github.com/open-ness/edgecontroller/pb/auth"
+)
+
+var _ = Describe("Node serials", func() {
+	var (
+		csrPEM string
+		pubKey []byte
+	)
+
+	BeforeEach(func() {
+		clearGRPCTargetsTable()
+
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+		Expect(err).ToNot(HaveOccurred())
+		csrPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+		pubKey, err = x509.MarshalPKIXPublicKey(&key.PublicKey)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("Should migrate a node approved by its legacy serial", func() {
+		nodeID := postNodesSerial(cce.LegacyNodeSerial(pubKey))
+
+		By("Requesting credentials")
+		_, err := authSvcCli.RequestCredentials(context.TODO(), &authpb.Identity{Csr: csrPEM})
+		Expect(err).ToNot(HaveOccurred())
+
+		By("Verifying the serial of the node was migrated")
+		Expect(getNode(nodeID).Serial).To(Equal(cce.NodeSerial(pubKey)))
+	})
+
+	It("Should not create a node with the serial of another node", func() {
+		serial := cce.NodeSerial(pubKey)
+		postNodesSerial(serial)
+
+		By("Sending a POST /nodes request")
+		resp, err := apiCli.Post(
+			"http://127.0.0.1:8080/nodes",
+			"application/json",
+			strings.NewReader(fmt.Sprintf(`{"name": "node", "location": "loc", "serial": "%s"}`, serial)))
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		By("Verifying a 422 Unprocessable Entity response")
+		Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+	})
+})
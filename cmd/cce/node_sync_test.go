@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/swagger"
+	"github.com/open-ness/edgecontroller/uuid"
+)
+
+var _ = Describe("Node sync", func() {
+	Describe("GET /nodes/{node_id}/sync", func() {
+		It("Should return pending for a node that was not reconciled yet", func() {
+			nodeID := postNodesSerial("ABCD")
+
+			By("Sending a GET /nodes/{node_id}/sync request")
+			resp, err := apiCli.Get("http://127.0.0.1:8080/nodes/" + nodeID + "/sync")
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			By("Verifying a 200 OK response")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			By("Reading the response body")
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			var sync swagger.NodeSync
+
+			By("Unmarshaling the response")
+			Expect(json.Unmarshal(body, &sync)).To(Succeed())
+
+			By("Verifying the response")
+			Expect(sync).To(Equal(swagger.NodeSync{
+				NodeID: nodeID,
+				Status: cce.NodeSyncPending,
+				Drift:  []swagger.NodeDrift{},
+			}))
+		})
+
+		It("Should return 404 for a missing node", func() {
+			By("Sending a GET /nodes/{node_id}/sync request")
+			resp, err := apiCli.Get("http://127.0.0.1:8080/nodes/" + uuid.New() + "/sync")
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			By("Verifying a 404 Not Found response")
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"strings"
 
+	cce "github.com/open-ness/edgecontroller"
 	"github.com/open-ness/edgecontroller/swagger"
 	"github.com/open-ness/edgecontroller/uuid"
 
@@ -147,6 +148,7 @@ var _ = Describe("/nodes", func() {
 						Name:     "Test Node 1",
 						Location: "Localhost port 42101",
 						Serial:   nodeCfg.serial,
+						Status:   cce.NodeStatusOffline,
 					}))
 			},
 			Entry("GET /nodes"),
@@ -168,6 +170,7 @@ var _ = Describe("/nodes", func() {
 							Name:     "Test Node 1",
 							Location: "Localhost port 42101",
 							Serial:   nodeCfg.serial,
+							Status:   cce.NodeStatusOffline,
 						},
 					},
 				))
@@ -391,10 +394,17 @@ var _ = Describe("/nodes", func() {
 		)
 
 		DescribeTable("404 Not Found",
-			func(id string) {
+			func(id, etag string) {
 				By("Sending a DELETE /nodes/{id} request")
-				resp, err := apiCli.Delete(
-					fmt.Sprintf("http://127.0.0.1:8080/nodes/%s", id))
+				req, err := http.NewRequest(
+					http.MethodDelete,
+					fmt.Sprintf("http://127.0.0.1:8080/nodes/%s", id),
+					nil)
+				Expect(err).ToNot(HaveOccurred())
+				if etag != "" {
+					req.Header.Set("If-Match", etag)
+				}
+				resp, err := apiCli.Do(req)
 				Expect(err).ToNot(HaveOccurred())
 				defer resp.Body.Close()
 
@@ -403,7 +413,10 @@ var _ = Describe("/nodes", func() {
 			},
 			Entry(
 				"DELETE /nodes/{id} with nonexistent ID",
-				uuid.New()),
+				uuid.New(), ""),
+			Entry(
+				"DELETE /nodes/{id} with nonexistent ID and If-Match",
+				uuid.New(), `"1"`),
 		)
 
 		DescribeTable("422 Unprocessable Entity",
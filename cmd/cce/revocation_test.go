@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/open-ness/edgecontroller/swagger"
+	"github.com/open-ness/edgecontroller/telemetry"
+)
+
+var _ = Describe("Node certificate revocation", func() {
+	var (
+		nodeCfg *nodeConfig
+		cert    *x509.Certificate
+		ca      *x509.Certificate
+	)
+
+	BeforeEach(func() {
+		clearGRPCTargetsTable()
+		nodeCfg = createAndRegisterNode()
+
+		block, _ := pem.Decode([]byte(nodeCfg.creds.Certificate))
+		Expect(block).ToNot(BeNil())
+		var err error
+		cert, err = x509.ParseCertificate(block.Bytes)
+		Expect(err).ToNot(HaveOccurred())
+
+		block, _ = pem.Decode(controllerRootPEM)
+		Expect(block).ToNot(BeNil())
+		ca, err = x509.ParseCertificate(block.Bytes)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	dialSyslog := func() error {
+		clientConf := &tls.Config{
+			Certificates: []tls.Certificate{{
+				Certificate: [][]byte{cert.Raw},
+				PrivateKey:  nodeCfg.key,
+			}},
+			RootCAs:    x509.NewCertPool(),
+			ServerName: telemetry.SyslogSNI,
+			// The server rejects client certificates during a TLS 1.2 handshake
+			MaxVersion: tls.VersionTLS12,
+		}
+		clientConf.RootCAs.AddCert(ca)
+
+		conn, err := tls.Dial("tcp", "127.0.0.1:6514", clientConf)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	revoke := func(body string) *http.Response {
+		By("Sending a POST /nodes/{node_id}/revoke request")
+		resp, err := apiCli.Post(
+			"http://127.0.0.1:8080/nodes/"+nodeCfg.nodeID+"/revoke",
+			"application/json",
+			strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		return resp
+	}
+
+	crlSerials := func() []string {
+		By("Sending a GET /crl request without authentication")
+		resp, err := http.Get("http://127.0.0.1:8080/crl")
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		der, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("Verifying the CRL is signed by the Controller CA")
+		crl, err := x509.ParseCRL(der)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ca.CheckCRLSignature(crl)).To(Succeed())
+
+		var serials []string
+		for _, c := range crl.TBSCertList.RevokedCertificates {
+			serials = append(serials, c.SerialNumber.Text(16))
+		}
+		return serials
+	}
+
+	ocspStatus := func() *ocsp.Response {
+		req, err := ocsp.CreateRequest(cert, ca, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("Sending a POST /ocsp request without authentication")
+		resp, err := http.Post("http://127.0.0.1:8080/ocsp", "application/ocsp-request", bytes.NewReader(req))
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		der, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("Verifying the OCSP response is signed by the Controller CA")
+		status, err := ocsp.ParseResponseForCert(der, cert, ca)
+		Expect(err).ToNot(HaveOccurred())
+		return status
+	}
+
+	Describe("POST /nodes/{node_id}/revoke", func() {
+		It("Should revoke the certificate of the node", func() {
+			By("Verifying the node can connect with its certificate")
+			Expect(dialSyslog()).To(Succeed())
+			Expect(ocspStatus().Status).To(Equal(ocsp.Good))
+
+			resp := revoke(`{"reason": "key_compromise"}`)
+			defer resp.Body.Close()
+
+			By("Verifying a 200 OK response with the revoked certificate")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var revoked swagger.RevokedCertificate
+			Expect(json.NewDecoder(resp.Body).Decode(&revoked)).To(Succeed())
+			Expect(revoked.Serial).To(Equal(cert.SerialNumber.Text(16)))
+			Expect(revoked.NodeID).To(Equal(nodeCfg.nodeID))
+			Expect(revoked.Reason).To(Equal("key_compromise"))
+
+			By("Verifying the node can no longer connect with its certificate")
+			Expect(dialSyslog()).ToNot(Succeed())
+
+			By("Verifying the certificate is published as revoked")
+			Expect(crlSerials()).To(ContainElement(revoked.Serial))
+			status := ocspStatus()
+			Expect(status.Status).To(Equal(ocsp.Revoked))
+			Expect(status.RevocationReason).To(Equal(ocsp.KeyCompromise))
+
+			By("Verifying the revoked certificate cannot be revoked again")
+			resp = revoke("")
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		})
+
+		It("Should not accept an unknown reason", func() {
+			resp := revoke(`{"reason": "bored"}`)
+			defer resp.Body.Close()
+
+			By("Verifying a 400 Bad Request response")
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(HavePrefix("Validation failed: reason must be one of"))
+		})
+	})
+
+	Describe("DELETE /nodes/{node_id}", func() {
+		It("Should revoke the certificate of the node", func() {
+			By("Sending a DELETE /nodes/{node_id} request")
+			resp, err := apiCli.Delete("http://127.0.0.1:8080/nodes/" + nodeCfg.nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			By("Verifying the certificate is revoked")
+			Expect(dialSyslog()).ToNot(Succeed())
+			Expect(crlSerials()).To(ContainElement(cert.SerialNumber.Text(16)))
+			Expect(ocspStatus().Status).To(Equal(ocsp.Revoked))
+		})
+	})
+})
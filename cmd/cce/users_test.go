@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/open-ness/edgecontroller/swagger"
+	"github.com/open-ness/edgecontroller/uuid"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("/users", func() {
+	postUser := func(username, password, role string) (int, []byte) {
+		By("Sending a POST /users request")
+		resp, err := apiCli.Post("http://127.0.0.1:8080/users", "application/json",
+			strings.NewReader(fmt.Sprintf(`{"username": %q, "password": %q, "role": %q}`, username, password, role)))
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return resp.StatusCode, body
+	}
+
+	getStatus := func(cli *apiClient, url string) int {
+		resp, err := cli.Get(url)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// createUser creates an operator and returns its ID and a client with its
+	// access token
+	createUser := func() (string, *apiClient) {
+		username := "user-" + uuid.New()[:8]
+		status, body := postUser(username, "user-pass", "operator")
+		Expect(status).To(Equal(http.StatusCreated))
+		var created swagger.UserSummary
+		Expect(json.Unmarshal(body, &created)).To(Succeed())
+
+		return created.ID, &apiClient{Token: userToken(username, "user-pass")}
+	}
+
+	Describe("POST /users", func() {
+		It("Should create users whose role limits what they can do", func() {
+			suffix := uuid.New()[:8]
+
+			By("Creating an operator and a read-only user")
+			status, _ := postUser("operator-"+suffix, "operator-pass", "operator")
+			Expect(status).To(Equal(http.StatusCreated))
+			status, _ = postUser("reader-"+suffix, "reader-pass", "read-only")
+			Expect(status).To(Equal(http.StatusCreated))
+
+			operatorCli := &apiClient{Token: userToken("operator-"+suffix, "operator-pass")}
+			readerCli := &apiClient{Token: userToken("reader-"+suffix, "reader-pass")}
+
+			By("Verifying the read-only user can read but not change apps")
+			Expect(getStatus(readerCli, "http://127.0.0.1:8080/apps")).To(Equal(http.StatusOK))
+			resp, err := readerCli.Post("http://127.0.0.1:8080/apps", "application/json", strings.NewReader("{}"))
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+
+			By("Verifying the operator cannot manage users")
+			Expect(getStatus(operatorCli, "http://127.0.0.1:8080/users")).To(Equal(http.StatusForbidden))
+
+			By("Verifying the admin can list the users without their passwords")
+			resp, err = apiCli.Get("http://127.0.0.1:8080/users")
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).ToNot(ContainSubstring("password"))
+			var users swagger.UserList
+			Expect(json.Unmarshal(body, &users)).To(Succeed())
+			var usernames []string
+			for _, u := range users.Users {
+				usernames = append(usernames, u.Username)
+			}
+			Expect(usernames).To(ContainElement("operator-" + suffix))
+		})
+
+		It("Should not create a user whose username already exists", func() {
+			username := "user-" + uuid.New()[:8]
+			status, _ := postUser(username, "user-pass", "read-only")
+			Expect(status).To(Equal(http.StatusCreated))
+
+			By("Verifying a 409 Conflict response")
+			status, body := postUser(username, "user-pass", "read-only")
+			Expect(status).To(Equal(http.StatusConflict))
+			Expect(string(body)).To(Equal(fmt.Sprintf("username %q already exists", username)))
+		})
+
+		DescribeTable("400 Bad Request",
+			func(username, password, role, expectedResp string) {
+				status, body := postUser(username, password, role)
+
+				By("Verifying a 400 Bad Request response")
+				Expect(status).To(Equal(http.StatusBadRequest))
+				Expect(string(body)).To(Equal(expectedResp))
+			},
+			Entry("POST /users with a short password",
+				"short", "pass", "operator",
+				"Validation failed: password must be at least 8 characters"),
+			Entry("POST /users with an unknown role",
+				"superuser", "super-pass", "root",
+				"Validation failed: role must be one of read-only, operator, admin"),
+		)
+	})
+
+	Describe("PATCH /users/{user_id}", func() {
+		It("Should revoke the sessions of a user whose role is changed", func() {
+			id, cli := createUser()
+			Expect(getStatus(cli, "http://127.0.0.1:8080/apps")).To(Equal(http.StatusOK))
+
+			By("Sending a PATCH /users/{user_id} request")
+			resp, err := apiCli.Patch("http://127.0.0.1:8080/users/"+id, "application/json",
+				strings.NewReader(`{"role": "read-only"}`))
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			By("Verifying the access token of the old role is rejected")
+			Expect(getStatus(cli, "http://127.0.0.1:8080/apps")).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Describe("POST /users/{user_id}/revoke", func() {
+		It("Should revoke the sessions of a user", func() {
+			id, cli := createUser()
+			Expect(getStatus(cli, "http://127.0.0.1:8080/apps")).To(Equal(http.StatusOK))
+
+			By("Sending a POST /users/{user_id}/revoke request")
+			resp, err := apiCli.Post("http://127.0.0.1:8080/users/"+id+"/revoke", "application/json", nil)
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+
+			By("Verifying a 204 No Content response")
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+			By("Verifying the access token is rejected")
+			Expect(getStatus(cli, "http://127.0.0.1:8080/apps")).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("Should respond with 404 Not Found for an unknown user", func() {
+			resp, err := apiCli.Post("http://127.0.0.1:8080/users/"+uuid.New()+"/revoke", "application/json", nil)
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("DELETE /users/{user_id}", func() {
+		It("Should revoke the sessions of the deleted user", func() {
+			id, cli := createUser()
+			Expect(getStatus(cli, "http://127.0.0.1:8080/apps")).To(Equal(http.StatusOK))
+
+			By("Sending a DELETE /users/{user_id} request")
+			resp, err := apiCli.Delete("http://127.0.0.1:8080/users/" + id)
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			By("Verifying the access token is rejected")
+			Expect(getStatus(cli, "http://127.0.0.1:8080/apps")).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("Should not delete the last admin", func() {
+			resp, err := apiCli.Get("http://127.0.0.1:8080/users?role=admin")
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			var users swagger.UserList
+			Expect(json.NewDecoder(resp.Body).Decode(&users)).To(Succeed())
+			Expect(users.Users).To(HaveLen(1))
+
+			By("Sending a DELETE /users/{user_id} request")
+			resp, err = apiCli.Delete("http://127.0.0.1:8080/users/" + users.Users[0].ID)
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			By("Verifying a 409 Conflict response")
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("cannot remove the last admin"))
+		})
+	})
+})
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/open-ness/edgecontroller/swagger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("/webhooks", func() {
+	postWebhooks := func(body string) *http.Response {
+		By("Sending a POST /webhooks request")
+		resp, err := apiCli.Post("http://127.0.0.1:8080/webhooks", "application/json", strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		return resp
+	}
+
+	Describe("POST /webhooks", func() {
+		DescribeTable("201 Created",
+			func() {
+				resp := postWebhooks(`
+					{
+						"url": "https://noc.example.com/hooks/controller",
+						"secret": "0123456789abcdef",
+						"events": ["node_app.deploy_failed"]
+					}`)
+				defer resp.Body.Close()
+
+				By("Verifying a 201 Created response")
+				Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+				By("Reading the response body")
+				body, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+
+				var rb respBody
+
+				By("Unmarshaling the response")
+				Expect(json.Unmarshal(body, &rb)).To(Succeed())
+
+				By("Sending a GET /webhooks/{webhook_id} request")
+				resp2, err := apiCli.Get(fmt.Sprintf("http://127.0.0.1:8080/webhooks/%s", rb.ID))
+				Expect(err).ToNot(HaveOccurred())
+				defer resp2.Body.Close()
+
+				By("Verifying a 200 OK response without the secret")
+				Expect(resp2.StatusCode).To(Equal(http.StatusOK))
+				body, err = ioutil.ReadAll(resp2.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body).To(MatchJSON(fmt.Sprintf(`
+					{
+						"id": "%s",
+						"url": "https://noc.example.com/hooks/controller",
+						"events": ["node_app.deploy_failed"]
+					}`, rb.ID)))
+
+				By("Sending a GET /webhooks/{webhook_id}/deliveries request")
+				resp3, err := apiCli.Get(fmt.Sprintf("http://127.0.0.1:8080/webhooks/%s/deliveries", rb.ID))
+				Expect(err).ToNot(HaveOccurred())
+				defer resp3.Body.Close()
+
+				By("Verifying a 200 OK response with an empty delivery log")
+				Expect(resp3.StatusCode).To(Equal(http.StatusOK))
+				body, err = ioutil.ReadAll(resp3.Body)
+				Expect(err).ToNot(HaveOccurred())
+				var deliveries swagger.WebhookDeliveryList
+				Expect(json.Unmarshal(body, &deliveries)).To(Succeed())
+				Expect(deliveries.Deliveries).To(BeEmpty())
+
+				By("Sending a DELETE /webhooks/{webhook_id} request")
+				resp4, err := apiCli.Delete(fmt.Sprintf("http://127.0.0.1:8080/webhooks/%s", rb.ID))
+				Expect(err).ToNot(HaveOccurred())
+				defer resp4.Body.Close()
+
+				By("Verifying a 204 No Content response")
+				Expect(resp4.StatusCode).To(Equal(http.StatusNoContent))
+			},
+			Entry("POST /webhooks"),
+		)
+
+		DescribeTable("400 Bad Request",
+			func(body string, expectedResp string) {
+				resp := postWebhooks(body)
+				defer resp.Body.Close()
+
+				By("Verifying a 400 Bad Request response")
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+				By("Reading the response body")
+				respBody, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(respBody)).To(Equal(expectedResp))
+			},
+			Entry("POST /webhooks without url",
+				`{"secret": "0123456789abcdef"}`,
+				"Validation failed: url must be an absolute http or https URL"),
+			Entry("POST /webhooks with short secret",
+				`{"url": "https://noc.example.com", "secret": "secret"}`,
+				"Validation failed: secret must be at least 16 characters"),
+			Entry("POST /webhooks with unknown event",
+				`{"url": "https://noc.example.com", "secret": "0123456789abcdef", "events": ["node.exploded"]}`,
+				`Validation failed: event "node.exploded" is invalid`),
+		)
+	})
+
+	Describe("GET /webhooks/{webhook_id}", func() {
+		DescribeTable("404 Not Found",
+			func(path string) {
+				By("Sending a GET request")
+				resp, err := apiCli.Get("http://127.0.0.1:8080" + path)
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				By("Verifying a 404 Not Found response")
+				Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			},
+			Entry("GET /webhooks/{id}", "/webhooks/123"),
+			Entry("GET /webhooks/{id}/deliveries", "/webhooks/123/deliveries"),
+		)
+	})
+})
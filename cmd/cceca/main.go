@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+// Command cceca manages the two-tier CA of the Controller: an offline root CA
+// and the online intermediate CA the Controller issues certificates with.
+//
+// The root CA is generated on an offline host:
+//
+//	cceca root -dir /offline/root
+//
+// The Controller generates the key of its next intermediate CA, so that the key
+// never leaves the Controller host, and a CSR for the root CA to sign:
+//
+//	cceca csr -dir ./certificates/ca > intermediate.csr
+//
+// The root CA signs it on the offline host:
+//
+//	cceca sign -root /offline/root -csr intermediate.csr > intermediate.pem
+//
+// The signed intermediate CA is installed on the Controller host, which is
+// then restarted. Installing an intermediate CA rotates the one installed
+// before, whose certificates are trusted until it expires:
+//
+//	cceca install -dir ./certificates/ca -cert intermediate.pem
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/open-ness/edgecontroller/pki"
+	"github.com/pkg/errors"
+)
+
+const usage = `Usage: cceca <command> [flags]
+
+Commands:
+  root     Generate an offline root CA
+  csr      Generate the key and CSR of the next intermediate CA of the Controller
+  sign     Sign the CSR of an intermediate CA with the root CA
+  install  Install a signed intermediate CA, rotating the current one
+
+Run cceca <command> -h for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "root":
+		err = createRoot(args)
+	case "csr":
+		err = createCSR(args)
+	case "sign":
+		err = sign(args)
+	case "install":
+		err = install(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unrecognized command: %s\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error when executing command: [%s] err: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func createRoot(args []string) error {
+	fs := flag.NewFlagSet("root", flag.ExitOnError)
+	dir := fs.String("dir", "", "Directory to store the root CA key and certificate in")
+	validity := fs.Duration("validity", pki.DefaultRootCAValidity, "Validity of the root CA")
+	_ = fs.Parse(args)
+	if *dir == "" {
+		return errors.New("-dir is required")
+	}
+
+	if _, err := pki.CreateRootCA(*dir, *validity); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Generated root CA in %s, keep it offline\n", *dir)
+
+	return nil
+}
+
+func createCSR(args []string) error {
+	fs := flag.NewFlagSet("csr", flag.ExitOnError)
+	dir := fs.String("dir", "./certificates/ca", "CA directory of the Controller")
+	_ = fs.Parse(args)
+
+	csr, err := pki.CreateIntermediateCSR(*dir)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(csr)
+
+	return err
+}
+
+func sign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	rootDir := fs.String("root", "", "Directory of the root CA")
+	csrPath := fs.String("csr", "", "Path of the CSR of the intermediate CA")
+	validity := fs.Duration("validity", pki.DefaultIntermediateCAValidity, "Validity of the intermediate CA")
+	_ = fs.Parse(args)
+	if *rootDir == "" || *csrPath == "" {
+		return errors.New("-root and -csr are required")
+	}
+
+	key, err := pki.LoadKey(filepath.Join(*rootDir, "key.pem"))
+	if err != nil {
+		return err
+	}
+	cert, err := pki.LoadCertificate(filepath.Join(*rootDir, "cert.pem"))
+	if err != nil {
+		return err
+	}
+	root := &pki.RootCA{Cert: cert, Key: key}
+
+	csrDER, err := loadCSR(*csrPath)
+	if err != nil {
+		return err
+	}
+	intermediate, err := root.SignIntermediateCSR(csrDER, *validity)
+	if err != nil {
+		return err
+	}
+
+	// Write the intermediate CA followed by its chain, as install expects
+	for _, c := range []*x509.Certificate{intermediate, cert} {
+		if err = pem.Encode(os.Stdout, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadCSR loads a PEM-encoded CSR and returns it ASN.1 DER encoded.
+func loadCSR(path string) ([]byte, error) {
+	csrPEM, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read CSR file")
+	}
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("unable to decode CSR")
+	}
+
+	return block.Bytes, nil
+}
+
+func install(args []string) error {
+	fs := flag.NewFlagSet("install", flag.ExitOnError)
+	dir := fs.String("dir", "./certificates/ca", "CA directory of the Controller")
+	certPath := fs.String("cert", "", "Path of the intermediate CA certificate followed by its chain")
+	_ = fs.Parse(args)
+	if *certPath == "" {
+		return errors.New("-cert is required")
+	}
+
+	certs, err := pki.LoadCertificates(*certPath)
+	if err != nil {
+		return err
+	}
+	if err = pki.InstallIntermediateCA(*dir, certs); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Installed intermediate CA in %s, restart the Controller to use it\n", *dir)
+
+	return nil
+}
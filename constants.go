@@ -10,12 +10,18 @@ import (
 // MaxBodySize is the maximum size (in bytes) of an acceptable request body
 const MaxBodySize = 64 * 1024
 
+// MaxImportBodySize is the maximum size (in bytes) of an archive accepted by POST /import
+const MaxImportBodySize = 64 * 1024 * 1024
+
 // MaxHTTPRequestTime is the maximum time to request HTTP data before timing out
 const MaxHTTPRequestTime = 2 * time.Minute
 
 // MaxDBRequestTime is the maximum time to request database data before timing out
 const MaxDBRequestTime = 10 * time.Second
 
+// MaxPageLimit is the maximum number of entities that can be requested in a single page
+const MaxPageLimit = 1000
+
 // MaxCores is the maximum number of cores that an application can use.
 const MaxCores = 8
 
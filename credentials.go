@@ -4,9 +4,7 @@
 package cce
 
 import (
-	"crypto/md5" //nolint:gosec
 	"crypto/x509"
-	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -17,7 +15,8 @@ import (
 // credentials. These credentials may be used to further communicate with
 // endpoint(s) that are protected by a form of authentication.
 type Credentials struct {
-	// ID is the base64-encoded MD5 hash of the certificate's public key.
+	// ID is the ID of the node the certificate was issued to, which is the
+	// certificate's Common Name.
 	ID string `json:"id"`
 	// Certificate is a PEM-encoded X.509 certificate.
 	Certificate string `json:"certificate"`
@@ -57,16 +56,8 @@ func (c *Credentials) Validate() error {
 		return errors.New("certificate not a valid certificate")
 	}
 
-	pubKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
-	if err != nil {
-		return errors.New("certificate public key not a valid public key")
-	}
-
-	// gosec: not hashing user input/passwords
-	hash := md5.Sum(pubKey) //nolint:gosec
-
-	if c.ID != base64.RawURLEncoding.EncodeToString(hash[:]) {
-		return errors.New("id not derived from certificate public key")
+	if c.ID != cert.Subject.CommonName {
+		return errors.New("id not the certificate common name")
 	}
 
 	return nil
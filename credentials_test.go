@@ -34,7 +34,7 @@ HjqhNuXFDM1RVkkcBuAD0lQZHQJdXJGmqNju
 
 	BeforeEach(func() {
 		creds = &cce.Credentials{
-			ID:          "ysRMvw8aMviv9qPcXIlAsA",
+			ID:          "cJl_0X_uNWvMGiYNS4_PSA",
 			Certificate: testCertificate,
 		}
 	})
@@ -48,7 +48,7 @@ HjqhNuXFDM1RVkkcBuAD0lQZHQJdXJGmqNju
 	Describe("GetID", func() {
 		It("Should return the ID", func() {
 			Expect(creds.GetID()).To(Equal(
-				"ysRMvw8aMviv9qPcXIlAsA"))
+				"cJl_0X_uNWvMGiYNS4_PSA"))
 		})
 	})
 
@@ -63,6 +63,10 @@ HjqhNuXFDM1RVkkcBuAD0lQZHQJdXJGmqNju
 	})
 
 	Describe("Validate", func() {
+		It("Should not return an error for valid credentials", func() {
+			Expect(creds.Validate()).To(Succeed())
+		})
+
 		It("Should return an error if ID is empty", func() {
 			creds.ID = ""
 			Expect(creds.Validate()).To(MatchError(
@@ -87,10 +91,16 @@ HjqhNuXFDM1RVkkcBuAD0lQZHQJdXJGmqNju
 				"certificate not a valid certificate"))
 		})
 
-		It("Should return an error if ID is not derived from Certificate public key", func() {
+		It("Should return an error if ID is not the Certificate common name", func() {
 			creds.ID = "123"
 			Expect(creds.Validate()).To(MatchError(
-				"id not derived from certificate public key"))
+				"id not the certificate common name"))
+		})
+
+		It("Should return an error if ID is the serial of Certificate public key", func() {
+			creds.ID = "qrYziTswmdAn2MdiIRjlkp_ljLldKk1urdJ1Xhsf5Wk"
+			Expect(creds.Validate()).To(MatchError(
+				"id not the certificate common name"))
 		})
 	})
 
@@ -98,7 +108,7 @@ HjqhNuXFDM1RVkkcBuAD0lQZHQJdXJGmqNju
 		It("Should return the string value", func() {
 			Expect(creds.String()).To(Equal(strings.TrimSpace(`
 Credentials[
-    ID: ysRMvw8aMviv9qPcXIlAsA
+    ID: cJl_0X_uNWvMGiYNS4_PSA
     Certificate: -----BEGIN CERTIFICATE-----
 MIIBNzCB3qADAgECAggUBcLhJDUGvDAKBggqhkjOPQQDAjAfMR0wGwYDVQQKExRD
 b250cm9sbGVyIEF1dGhvcml0eTAeFw0xOTA1MDkyMzM1MThaFw0yMjA1MDYxNjQ3
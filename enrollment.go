@@ -0,0 +1,398 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/open-ness/edgecontroller/uuid"
+)
+
+// EnrollmentTokenPrefix prefixes every enrollment token, so that they can be
+// recognized by secret scanners.
+const EnrollmentTokenPrefix = "cceet_"
+
+// Enrollment statuses
+const (
+	EnrollmentStatusPending  = "pending"
+	EnrollmentStatusRejected = "rejected"
+)
+
+var (
+	// ErrInvalidEnrollmentToken is returned when an enrollment token is
+	// unknown, expired or has an invalid secret.
+	ErrInvalidEnrollmentToken = errors.New("invalid enrollment token")
+	// ErrEnrollmentRejected is returned when a node whose enrollment was
+	// rejected requests to enroll.
+	ErrEnrollmentRejected = errors.New("enrollment rejected")
+	// ErrEnrollmentQueueFull is returned when an unknown node requests to
+	// enroll while the maximum number of enrollments is pending.
+	ErrEnrollmentQueueFull = errors.New("too many pending enrollments")
+	// ErrSerialInUse is returned when enrolling a node with the serial of an
+	// existing node.
+	ErrSerialInUse = errors.New("serial is in use by another node")
+)
+
+// EnrollmentToken is a one-time token for a node that is not pre-approved by
+// its serial. The node presents it when it requests credentials, which
+// creates the node with the ID, name and location of the token and the serial
+// of its public key. The token itself is only returned when it is created,
+// only its SHA-256 hash is stored.
+type EnrollmentToken struct {
+	ID         string `json:"id"`
+	NodeID     string `json:"node_id"`
+	Name       string `json:"name"`
+	Location   string `json:"location"`
+	Expiry     string `json:"expiry"`
+	CreatedBy  string `json:"created_by"`
+	SecretHash string `json:"secret_hash"`
+}
+
+// GetTableName returns the name of the persistence table.
+func (*EnrollmentToken) GetTableName() string {
+	return "enrollment_tokens"
+}
+
+// GetID gets the ID.
+func (t *EnrollmentToken) GetID() string {
+	return t.ID
+}
+
+// SetID sets the ID.
+func (t *EnrollmentToken) SetID(id string) {
+	t.ID = id
+}
+
+// FilterFields returns the filterable fields for this model.
+func (*EnrollmentToken) FilterFields() []string {
+	return []string{
+		"node_id",
+		"created_by",
+	}
+}
+
+// Validate validates the model.
+func (t *EnrollmentToken) Validate() error {
+	if !uuid.IsValid(t.ID) {
+		return errors.New("id not a valid uuid")
+	}
+	if !uuid.IsValid(t.NodeID) {
+		return errors.New("node_id not a valid uuid")
+	}
+	if t.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	if t.Location == "" {
+		return errors.New("location cannot be empty")
+	}
+	if _, err := time.Parse(time.RFC3339, t.Expiry); err != nil {
+		return errors.New("expiry not a valid RFC 3339 timestamp")
+	}
+	if t.SecretHash == "" {
+		return errors.New("secret_hash cannot be empty")
+	}
+
+	return nil
+}
+
+// GenerateSecret sets the secret hash to the hash of a new random secret and
+// returns the token, which is the prefix, the ID and the secret.
+func (t *EnrollmentToken) GenerateSecret() (string, error) {
+	secret, err := generateSecret()
+	if err != nil {
+		return "", err
+	}
+	t.SecretHash = hashSecret(secret)
+
+	return EnrollmentTokenPrefix + t.ID + "." + secret, nil
+}
+
+// ParseEnrollmentToken returns the ID and secret of an enrollment token. ok
+// is false if token is not an enrollment token.
+func ParseEnrollmentToken(token string) (id, secret string, ok bool) {
+	return parseSecretToken(EnrollmentTokenPrefix, token)
+}
+
+// CheckSecret returns whether secret is the secret of the token. The hashes
+// are compared in constant time.
+func (t *EnrollmentToken) CheckSecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(t.SecretHash), []byte(hashSecret(secret))) == 1
+}
+
+// Expired returns whether the token has expired at now.
+func (t *EnrollmentToken) Expired(now time.Time) bool {
+	expiry, err := time.Parse(time.RFC3339, t.Expiry)
+	return err != nil || !now.Before(expiry)
+}
+
+// Redacted returns a copy of the enrollment token without the secret hash.
+func (t *EnrollmentToken) Redacted() Persistable {
+	redacted := *t
+	redacted.SecretHash = ""
+	return &redacted
+}
+
+func (t *EnrollmentToken) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+EnrollmentToken[
+    ID: %s
+    NodeID: %s
+    Name: %s
+    Location: %s
+    Expiry: %s
+    CreatedBy: %s
+]`),
+		t.ID,
+		t.NodeID,
+		t.Name,
+		t.Location,
+		t.Expiry,
+		t.CreatedBy)
+}
+
+// Enrollment is the request of an unknown node to enroll, pending the
+// approval of an admin. The serial identifies the node's public key like the
+// serial of a Node, the subject is that of the node's CSR and the peer IP is
+// the address it last requested to enroll from.
+type Enrollment struct {
+	ID          string `json:"id"`
+	Serial      string `json:"serial"`
+	PeerIP      string `json:"peer_ip"`
+	Subject     string `json:"subject"`
+	Status      string `json:"status"`
+	RequestedAt string `json:"requested_at"`
+}
+
+// GetTableName returns the name of the persistence table.
+func (*Enrollment) GetTableName() string {
+	return "enrollments"
+}
+
+// GetID gets the ID.
+func (e *Enrollment) GetID() string {
+	return e.ID
+}
+
+// SetID sets the ID.
+func (e *Enrollment) SetID(id string) {
+	e.ID = id
+}
+
+// FilterFields returns the filterable fields for this model.
+func (*Enrollment) FilterFields() []string {
+	return []string{
+		"serial",
+		"status",
+	}
+}
+
+// Validate validates the model.
+func (e *Enrollment) Validate() error {
+	if !uuid.IsValid(e.ID) {
+		return errors.New("id not a valid uuid")
+	}
+	if e.Serial == "" {
+		return errors.New("serial cannot be empty")
+	}
+	switch e.Status {
+	case EnrollmentStatusPending, EnrollmentStatusRejected:
+	default:
+		return fmt.Errorf("status must be one of [%s, %s]", EnrollmentStatusPending, EnrollmentStatusRejected)
+	}
+	if _, err := time.Parse(time.RFC3339, e.RequestedAt); err != nil {
+		return errors.New("requested_at not a valid RFC 3339 timestamp")
+	}
+
+	return nil
+}
+
+func (e *Enrollment) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+Enrollment[
+    ID: %s
+    Serial: %s
+    PeerIP: %s
+    Subject: %s
+    Status: %s
+    RequestedAt: %s
+]`),
+		e.ID,
+		e.Serial,
+		e.PeerIP,
+		e.Subject,
+		e.Status,
+		e.RequestedAt)
+}
+
+// NodeEnrollments enrolls the nodes that are not pre-approved by their serial,
+// either with an enrollment token or by the approval of a pending enrollment.
+// The methods that change more than one entity should be called in a
+// transaction.
+type NodeEnrollments struct {
+	PersistenceService PersistenceService
+	// MaxPending is the maximum number of pending enrollments.
+	MaxPending int
+}
+
+// RedeemToken creates the node of an enrollment token with serial and deletes
+// the token, so that it cannot be used again.
+func (e *NodeEnrollments) RedeemToken(ctx context.Context, token, serial string) (*Node, error) {
+	id, secret, ok := ParseEnrollmentToken(token)
+	if !ok {
+		return nil, ErrInvalidEnrollmentToken
+	}
+	persisted, err := e.PersistenceService.Read(ctx, id, &EnrollmentToken{})
+	if err != nil {
+		return nil, err
+	}
+	if persisted == nil {
+		return nil, ErrInvalidEnrollmentToken
+	}
+	t := persisted.(*EnrollmentToken)
+	if !t.CheckSecret(secret) || t.Expired(time.Now()) {
+		return nil, ErrInvalidEnrollmentToken
+	}
+
+	node := &Node{
+		ID:       t.NodeID,
+		Name:     t.Name,
+		Location: t.Location,
+		Serial:   serial,
+	}
+	if err = e.createNode(ctx, node); err != nil {
+		return nil, err
+	}
+	if _, err = e.PersistenceService.Delete(ctx, t.ID, &EnrollmentToken{}); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// Request queues the enrollment of an unknown node for approval, or updates
+// its pending enrollment. It returns ErrEnrollmentRejected if the enrollment
+// of the node was rejected and ErrEnrollmentQueueFull if too many enrollments
+// are pending. created is true if the enrollment was queued.
+func (e *NodeEnrollments) Request(ctx context.Context, enrollment *Enrollment) (created bool, err error) {
+	existing, err := e.PersistenceService.Filter(ctx, &Enrollment{}, []Filter{{
+		Field: "serial",
+		Value: enrollment.Serial,
+	}})
+	if err != nil {
+		return false, err
+	}
+	if len(existing) > 0 {
+		persisted := existing[0].(*Enrollment)
+		if persisted.Status == EnrollmentStatusRejected {
+			return false, ErrEnrollmentRejected
+		}
+		persisted.PeerIP = enrollment.PeerIP
+		persisted.Subject = enrollment.Subject
+		persisted.RequestedAt = enrollment.RequestedAt
+		*enrollment = *persisted
+		return false, e.PersistenceService.BulkUpdate(ctx, []Persistable{persisted})
+	}
+
+	pending, err := e.PersistenceService.Filter(ctx, &Enrollment{}, []Filter{{
+		Field: "status",
+		Value: EnrollmentStatusPending,
+	}})
+	if err != nil {
+		return false, err
+	}
+	if len(pending) >= e.MaxPending {
+		return false, ErrEnrollmentQueueFull
+	}
+
+	enrollment.ID = uuid.New()
+	enrollment.Status = EnrollmentStatusPending
+	if err = enrollment.Validate(); err != nil {
+		return false, err
+	}
+	if err = e.PersistenceService.Create(ctx, enrollment); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Approve creates the node of an enrollment with name and location, which
+// deletes the enrollment, so that the node is issued credentials when it next
+// requests them. It returns nil if the enrollment does not exist.
+func (e *NodeEnrollments) Approve(ctx context.Context, id, name, location string) (*Node, error) {
+	persisted, err := e.PersistenceService.Read(ctx, id, &Enrollment{})
+	if err != nil || persisted == nil {
+		return nil, err
+	}
+	enrollment := persisted.(*Enrollment)
+
+	node := &Node{
+		ID:       uuid.New(),
+		Name:     name,
+		Location: location,
+		Serial:   enrollment.Serial,
+	}
+	if err = e.createNode(ctx, node); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// Reject rejects an enrollment, so that the node is refused when it requests
+// to enroll until the enrollment is deleted. It returns nil if the enrollment
+// does not exist.
+func (e *NodeEnrollments) Reject(ctx context.Context, id string) (*Enrollment, error) {
+	persisted, err := e.PersistenceService.Read(ctx, id, &Enrollment{})
+	if err != nil || persisted == nil {
+		return nil, err
+	}
+	enrollment := persisted.(*Enrollment)
+
+	enrollment.Status = EnrollmentStatusRejected
+	if err = e.PersistenceService.BulkUpdate(ctx, []Persistable{enrollment}); err != nil {
+		return nil, err
+	}
+
+	return enrollment, nil
+}
+
+// createNode validates and creates an enrolled node unless another node has
+// its serial. The pending enrollment of the serial, if any, is deleted.
+func (e *NodeEnrollments) createNode(ctx context.Context, node *Node) error {
+	if err := node.Validate(); err != nil {
+		return err
+	}
+
+	nodes, err := e.PersistenceService.Filter(ctx, &Node{}, []Filter{{Field: "serial", Value: node.Serial}})
+	if err != nil {
+		return err
+	}
+	if len(nodes) > 0 {
+		return ErrSerialInUse
+	}
+	if err = e.PersistenceService.Create(ctx, node); err != nil {
+		return err
+	}
+
+	enrollments, err := e.PersistenceService.Filter(ctx, &Enrollment{}, []Filter{{
+		Field: "serial",
+		Value: node.Serial,
+	}})
+	if err != nil {
+		return err
+	}
+	for _, enrollment := range enrollments {
+		if _, err = e.PersistenceService.Delete(ctx, enrollment.GetID(), &Enrollment{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
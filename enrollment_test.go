@@ -0,0 +1,247 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/memory"
+)
+
+var _ = Describe("Entities: EnrollmentToken", func() {
+	var (
+		t *cce.EnrollmentToken
+	)
+
+	BeforeEach(func() {
+		t = &cce.EnrollmentToken{
+			ID:         "3c6b2f1e-8a4d-4e2b-9f1c-7d5e3a2b1c0d",
+			NodeID:     "9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f",
+			Name:       "node123",
+			Location:   "Localhost port 42101",
+			Expiry:     "2030-01-01T00:00:00Z",
+			CreatedBy:  "admin",
+			SecretHash: "hash",
+		}
+	})
+
+	Describe("GetTableName", func() {
+		It(`Should return "enrollment_tokens"`, func() {
+			Expect(t.GetTableName()).To(Equal("enrollment_tokens"))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("Should not return an error for a valid token", func() {
+			Expect(t.Validate()).To(Succeed())
+		})
+
+		It("Should return an error for an invalid node ID", func() {
+			t.NodeID = "123"
+			Expect(t.Validate()).To(MatchError("node_id not a valid uuid"))
+		})
+
+		It("Should return an error for an empty name", func() {
+			t.Name = ""
+			Expect(t.Validate()).To(MatchError("name cannot be empty"))
+		})
+
+		It("Should return an error for an empty location", func() {
+			t.Location = ""
+			Expect(t.Validate()).To(MatchError("location cannot be empty"))
+		})
+
+		It("Should return an error for an invalid expiry", func() {
+			t.Expiry = "tomorrow"
+			Expect(t.Validate()).To(MatchError("expiry not a valid RFC 3339 timestamp"))
+		})
+	})
+
+	Describe("GenerateSecret", func() {
+		It("Should generate a token that checks against the hash", func() {
+			token, err := t.GenerateSecret()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(token).To(HavePrefix(cce.EnrollmentTokenPrefix + t.ID + "."))
+
+			id, secret, ok := cce.ParseEnrollmentToken(token)
+			Expect(ok).To(BeTrue())
+			Expect(id).To(Equal(t.ID))
+			Expect(t.CheckSecret(secret)).To(BeTrue())
+			Expect(t.CheckSecret(secret + "x")).To(BeFalse())
+		})
+
+		It("Should not parse API keys as enrollment tokens", func() {
+			_, _, ok := cce.ParseEnrollmentToken(cce.APIKeyPrefix + t.ID + ".secret")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Redacted", func() {
+		It("Should remove the secret hash", func() {
+			Expect(t.Redacted().(*cce.EnrollmentToken).SecretHash).To(BeEmpty())
+			Expect(t.SecretHash).To(Equal("hash"))
+		})
+	})
+})
+
+var _ = Describe("Entities: Enrollment", func() {
+	var (
+		e *cce.Enrollment
+	)
+
+	BeforeEach(func() {
+		e = &cce.Enrollment{
+			ID:          "6e2d1c0b-5a4f-4e3d-8c2b-1a0f9e8d7c6b",
+			Serial:      "ABCD",
+			PeerIP:      "192.0.2.1",
+			Subject:     "CN=node",
+			Status:      cce.EnrollmentStatusPending,
+			RequestedAt: "2020-03-01T12:00:00Z",
+		}
+	})
+
+	Describe("Validate", func() {
+		It("Should not return an error for a valid enrollment", func() {
+			Expect(e.Validate()).To(Succeed())
+		})
+
+		It("Should return an error for an empty serial", func() {
+			e.Serial = ""
+			Expect(e.Validate()).To(MatchError("serial cannot be empty"))
+		})
+
+		It("Should return an error for an unknown status", func() {
+			e.Status = "approved"
+			Expect(e.Validate()).To(MatchError("status must be one of [pending, rejected]"))
+		})
+	})
+
+	Describe("NodeEnrollments", func() {
+		var (
+			ctx         = context.Background()
+			ps          cce.PersistenceService
+			enrollments *cce.NodeEnrollments
+		)
+
+		BeforeEach(func() {
+			var err error
+			ps, err = memory.NewPersistenceService("")
+			Expect(err).ToNot(HaveOccurred())
+			enrollments = &cce.NodeEnrollments{PersistenceService: ps, MaxPending: 2}
+		})
+
+		request := func(serial string) (*cce.Enrollment, error) {
+			enrollment := &cce.Enrollment{
+				Serial:      serial,
+				PeerIP:      "192.0.2.1",
+				RequestedAt: time.Now().UTC().Format(time.RFC3339),
+			}
+			_, err := enrollments.Request(ctx, enrollment)
+			return enrollment, err
+		}
+
+		createToken := func(expiry time.Time) (*cce.EnrollmentToken, string) {
+			t := &cce.EnrollmentToken{
+				ID:       "3c6b2f1e-8a4d-4e2b-9f1c-7d5e3a2b1c0d",
+				NodeID:   "9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f",
+				Name:     "node123",
+				Location: "Localhost port 42101",
+				Expiry:   expiry.UTC().Format(time.RFC3339),
+			}
+			token, err := t.GenerateSecret()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ps.Create(ctx, t)).To(Succeed())
+			return t, token
+		}
+
+		It("Should create the node of an enrollment token once", func() {
+			t, token := createToken(time.Now().Add(time.Hour))
+
+			node, err := enrollments.RedeemToken(ctx, token, "ABCD")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(node).To(Equal(&cce.Node{
+				ID:       t.NodeID,
+				Name:     t.Name,
+				Location: t.Location,
+				Serial:   "ABCD",
+			}))
+			Expect(ps.Read(ctx, t.NodeID, &cce.Node{})).To(Equal(node))
+
+			_, err = enrollments.RedeemToken(ctx, token, "EFGH")
+			Expect(err).To(Equal(cce.ErrInvalidEnrollmentToken))
+		})
+
+		It("Should not redeem expired or invalid enrollment tokens", func() {
+			_, token := createToken(time.Now().Add(-time.Minute))
+			_, err := enrollments.RedeemToken(ctx, token, "ABCD")
+			Expect(err).To(Equal(cce.ErrInvalidEnrollmentToken))
+
+			_, err = enrollments.RedeemToken(ctx, "cceet_3c6b2f1e-8a4d-4e2b-9f1c-7d5e3a2b1c0d.wrong", "ABCD")
+			Expect(err).To(Equal(cce.ErrInvalidEnrollmentToken))
+
+			_, err = enrollments.RedeemToken(ctx, "token", "ABCD")
+			Expect(err).To(Equal(cce.ErrInvalidEnrollmentToken))
+		})
+
+		It("Should queue unknown nodes up to the maximum", func() {
+			first, err := request("ABCD")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(first.Status).To(Equal(cce.EnrollmentStatusPending))
+
+			By("Updating the pending enrollment of a node")
+			again, err := request("ABCD")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(again.ID).To(Equal(first.ID))
+
+			_, err = request("EFGH")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = request("IJKL")
+			Expect(err).To(Equal(cce.ErrEnrollmentQueueFull))
+		})
+
+		It("Should create the node of an approved enrollment", func() {
+			enrollment, err := request("ABCD")
+			Expect(err).ToNot(HaveOccurred())
+
+			node, err := enrollments.Approve(ctx, enrollment.ID, "node123", "Localhost port 42101")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(node.Serial).To(Equal("ABCD"))
+			Expect(ps.Read(ctx, node.ID, &cce.Node{})).To(Equal(node))
+			Expect(ps.Read(ctx, enrollment.ID, &cce.Enrollment{})).To(BeNil())
+
+			By("Not approving a missing enrollment")
+			Expect(enrollments.Approve(ctx, enrollment.ID, "node123", "Localhost port 42101")).To(BeNil())
+		})
+
+		It("Should not approve an enrollment of the serial of another node", func() {
+			enrollment, err := request("ABCD")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ps.Create(ctx, &cce.Node{
+				ID:       "9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f",
+				Name:     "node123",
+				Location: "Localhost port 42101",
+				Serial:   "ABCD",
+			})).To(Succeed())
+
+			_, err = enrollments.Approve(ctx, enrollment.ID, "node456", "Localhost port 42101")
+			Expect(err).To(Equal(cce.ErrSerialInUse))
+		})
+
+		It("Should refuse nodes whose enrollment was rejected", func() {
+			enrollment, err := request("ABCD")
+			Expect(err).ToNot(HaveOccurred())
+
+			rejected, err := enrollments.Reject(ctx, enrollment.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rejected.Status).To(Equal(cce.EnrollmentStatusRejected))
+
+			_, err = request("ABCD")
+			Expect(err).To(Equal(cce.ErrEnrollmentRejected))
+		})
+	})
+})
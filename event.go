@@ -0,0 +1,286 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	EventCreate = "create"
+	EventUpdate = "update"
+	EventDelete = "delete"
+	// EventReset tells a subscriber that events were missed, e.g. because it resumed from a sequence number that is
+	// no longer buffered, and that it has to re-read the resources it is watching.
+	EventReset = "reset"
+)
+
+// EventResources are the tables whose changes are published as events.
+var EventResources = []string{
+	"nodes",
+	"apps",
+	"nodes_apps",
+	"traffic_policies",
+	"nodes_apps_traffic_policies",
+	"nodes_network_interfaces_traffic_policies",
+	"dns_configs",
+	"nodes_dns_configs",
+	"dns_configs_app_aliases",
+	"node_statuses",
+}
+
+// IsEventResource returns whether changes of the table are published as events.
+func IsEventResource(table string) bool {
+	for _, r := range EventResources {
+		if r == table {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ErrEventHubClosed is returned by EventSubscription.Next once the EventHub is closed.
+var ErrEventHubClosed = errors.New("event hub closed")
+
+// Event is a change of a resource. Seq numbers the events published by an EventHub starting at 1. Entity is the
+// resource after a create or update and before a delete.
+type Event struct {
+	Seq       uint64          `json:"seq"`
+	Type      string          `json:"type"`
+	Resource  string          `json:"resource,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	NodeID    string          `json:"node_id,omitempty"`
+	Timestamp string          `json:"timestamp"`
+	Entity    json.RawMessage `json:"entity,omitempty"`
+}
+
+func (e *Event) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+Event[
+    Seq: %d
+    Type: %s
+    Resource: %s
+    ID: %s
+    NodeID: %s
+    Timestamp: %s
+]`),
+		e.Seq,
+		e.Type,
+		e.Resource,
+		e.ID,
+		e.NodeID,
+		e.Timestamp)
+}
+
+// EventFilter selects the events delivered to a subscriber. An empty Resources or NodeID matches any resource or
+// node. Reset events match every filter.
+type EventFilter struct {
+	Resources []string
+	NodeID    string
+}
+
+// Matches returns whether e is selected by the filter.
+func (f EventFilter) Matches(e *Event) bool {
+	if e.Type == EventReset {
+		return true
+	}
+	if f.NodeID != "" && e.NodeID != f.NodeID {
+		return false
+	}
+	if len(f.Resources) == 0 {
+		return true
+	}
+	for _, r := range f.Resources {
+		if r == e.Resource {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EventHub publishes events to subscribers. The last events are kept in a ring buffer so that subscribers can resume
+// after a disconnect. Publishing never blocks on slow subscribers: a subscriber that falls behind by more than the
+// buffer size receives a reset event.
+type EventHub struct {
+	mu     sync.Mutex
+	buf    []Event
+	next   uint64
+	subs   map[*EventSubscription]struct{}
+	closed bool
+}
+
+// NewEventHub creates an EventHub buffering the last size events.
+func NewEventHub(size int) *EventHub {
+	if size < 1 {
+		size = 1
+	}
+
+	return &EventHub{
+		buf:  make([]Event, size),
+		next: 1,
+		subs: make(map[*EventSubscription]struct{}),
+	}
+}
+
+// Publish assigns sequence numbers and timestamps to es and publishes them in order.
+func (h *EventHub) Publish(es ...Event) {
+	if len(es) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	ts := AuditTimestamp(time.Now())
+	for _, e := range es {
+		e.Seq = h.next
+		e.Timestamp = ts
+		h.buf[h.next%uint64(len(h.buf))] = e
+		h.next++
+	}
+
+	for s := range h.subs {
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// LastSeq returns the sequence number of the last published event, or 0 if there is none.
+func (h *EventHub) LastSeq() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.next - 1
+}
+
+// Subscribe subscribes to the events selected by f. If resume is false only events published from now on are
+// delivered. Otherwise delivery resumes with the event following the one numbered after; if that event is no longer
+// buffered or after is not a sequence number of this hub (e.g. the controller was restarted), a reset event is
+// delivered first.
+func (h *EventHub) Subscribe(f EventFilter, resume bool, after uint64) *EventSubscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := &EventSubscription{
+		hub:    h,
+		filter: f,
+		next:   h.next,
+		notify: make(chan struct{}, 1),
+	}
+	if resume {
+		s.next = after + 1
+		if after >= h.next {
+			// Force a reset on the first call of Next
+			s.next = 0
+		}
+	}
+	h.subs[s] = struct{}{}
+
+	return s
+}
+
+// Close closes the hub. Blocked and subsequent calls of EventSubscription.Next return ErrEventHubClosed.
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for s := range h.subs {
+		close(s.notify)
+		delete(h.subs, s)
+	}
+}
+
+// oldest returns the sequence number of the oldest buffered event. It must be called with mu held.
+func (h *EventHub) oldest() uint64 {
+	if h.next-1 < uint64(len(h.buf)) {
+		return 1
+	}
+
+	return h.next - uint64(len(h.buf))
+}
+
+// EventSubscription is a subscription to the events of an EventHub. It must be closed when it is no longer used.
+type EventSubscription struct {
+	hub    *EventHub
+	filter EventFilter
+	next   uint64
+	notify chan struct{}
+}
+
+// Next returns the next events selected by the subscription's filter, blocking until there is at least one, ctx is
+// done or the hub is closed.
+func (s *EventSubscription) Next(ctx context.Context) ([]Event, error) {
+	for {
+		es, err := s.poll()
+		if err != nil || len(es) > 0 {
+			return es, err
+		}
+
+		select {
+		case _, ok := <-s.notify:
+			if !ok {
+				return nil, ErrEventHubClosed
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// poll returns the buffered events the subscriber has not seen yet.
+func (s *EventSubscription) poll() ([]Event, error) {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrEventHubClosed
+	}
+
+	var es []Event
+	if oldest := h.oldest(); s.next < oldest {
+		es = append(es, Event{
+			Seq:       oldest - 1,
+			Type:      EventReset,
+			Timestamp: AuditTimestamp(time.Now()),
+		})
+		s.next = oldest
+	}
+	for ; s.next < h.next; s.next++ {
+		e := h.buf[s.next%uint64(len(h.buf))]
+		if s.filter.Matches(&e) {
+			es = append(es, e)
+		}
+	}
+
+	return es, nil
+}
+
+// Close cancels the subscription.
+func (s *EventSubscription) Close() {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.notify)
+	}
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+)
+
+var _ = Describe("EventHub", func() {
+	var (
+		hub    *cce.EventHub
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+
+	BeforeEach(func() {
+		hub = cce.NewEventHub(3)
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	publish := func(resources ...string) {
+		for _, r := range resources {
+			hub.Publish(cce.Event{Type: cce.EventCreate, Resource: r, NodeID: "node-" + r})
+		}
+	}
+
+	seqs := func(es []cce.Event) []uint64 {
+		var s []uint64
+		for _, e := range es {
+			s = append(s, e.Seq)
+		}
+		return s
+	}
+
+	It("Should deliver events published after subscribing", func() {
+		publish("nodes")
+		sub := hub.Subscribe(cce.EventFilter{}, false, 0)
+		defer sub.Close()
+		publish("apps", "dns_configs")
+
+		es, err := sub.Next(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seqs(es)).To(Equal([]uint64{2, 3}))
+		Expect(es[0].Resource).To(Equal("apps"))
+		Expect(es[0].Timestamp).ToNot(BeEmpty())
+		Expect(hub.LastSeq()).To(Equal(uint64(3)))
+	})
+
+	It("Should filter events by resource and node", func() {
+		sub := hub.Subscribe(cce.EventFilter{Resources: []string{"apps", "nodes"}, NodeID: "node-apps"}, false, 0)
+		defer sub.Close()
+		publish("nodes", "apps", "dns_configs")
+
+		es, err := sub.Next(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seqs(es)).To(Equal([]uint64{2}))
+	})
+
+	It("Should resume after a sequence number", func() {
+		publish("nodes", "apps", "dns_configs")
+		sub := hub.Subscribe(cce.EventFilter{}, true, 1)
+		defer sub.Close()
+
+		es, err := sub.Next(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seqs(es)).To(Equal([]uint64{2, 3}))
+	})
+
+	It("Should deliver a reset event if events were dropped", func() {
+		publish("nodes", "apps", "dns_configs", "nodes_apps")
+		sub := hub.Subscribe(cce.EventFilter{}, true, 0)
+		defer sub.Close()
+
+		es, err := sub.Next(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seqs(es)).To(Equal([]uint64{1, 2, 3, 4}))
+		Expect(es[0].Type).To(Equal(cce.EventReset))
+	})
+
+	It("Should deliver a reset event for an unknown sequence number", func() {
+		publish("nodes")
+		sub := hub.Subscribe(cce.EventFilter{}, true, 42)
+		defer sub.Close()
+
+		es, err := sub.Next(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(es[0].Type).To(Equal(cce.EventReset))
+		Expect(seqs(es)).To(Equal([]uint64{0, 1}))
+	})
+
+	It("Should block until an event is published", func() {
+		sub := hub.Subscribe(cce.EventFilter{}, false, 0)
+		defer sub.Close()
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(10 * time.Millisecond)
+			publish("nodes")
+		}()
+
+		es, err := sub.Next(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seqs(es)).To(Equal([]uint64{1}))
+	})
+
+	It("Should return when the context is done", func() {
+		sub := hub.Subscribe(cce.EventFilter{}, false, 0)
+		defer sub.Close()
+
+		_, err := sub.Next(ctx)
+		Expect(err).To(Equal(context.DeadlineExceeded))
+	})
+
+	It("Should return an error once the hub is closed", func() {
+		sub := hub.Subscribe(cce.EventFilter{}, false, 0)
+		defer sub.Close()
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(10 * time.Millisecond)
+			hub.Close()
+		}()
+
+		_, err := sub.Next(ctx)
+		Expect(err).To(Equal(cce.ErrEventHubClosed))
+	})
+})
@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+// Package events publishes the changes made through a cce.PersistenceService to a cce.EventHub.
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/pkg/errors"
+)
+
+// PersistenceService wraps a cce.PersistenceService and publishes an event for every Create, BulkUpdate and Delete
+// (including the IfMatch variants) of a cce.EventResources table. The events of a transaction are published once it
+// is committed and dropped if it is rolled back. Rows deleted by ON DELETE CASCADE are not published.
+type PersistenceService struct {
+	cce.PersistenceService
+	Hub *cce.EventHub
+
+	// pending collects the events of the transaction the PersistenceService is bound to
+	pending *[]cce.Event
+}
+
+// Create persists a resource and publishes its creation.
+func (s *PersistenceService) Create(
+	ctx context.Context,
+	e cce.Persistable,
+) error {
+	if s.pending == nil {
+		return s.WithTx(ctx, func(tx cce.PersistenceService) error {
+			return tx.Create(ctx, e)
+		})
+	}
+
+	if err := s.PersistenceService.Create(ctx, e); err != nil {
+		return err
+	}
+
+	return s.add(ctx, cce.EventCreate, e.GetTableName(), e.GetID(), e)
+}
+
+// BulkUpdate updates multiple resources and publishes their updates.
+func (s *PersistenceService) BulkUpdate(
+	ctx context.Context,
+	es []cce.Persistable,
+) error {
+	if s.pending == nil {
+		return s.WithTx(ctx, func(tx cce.PersistenceService) error {
+			return tx.BulkUpdate(ctx, es)
+		})
+	}
+
+	if err := s.PersistenceService.BulkUpdate(ctx, es); err != nil {
+		return err
+	}
+
+	for _, e := range es {
+		if err := s.add(ctx, cce.EventUpdate, e.GetTableName(), e.GetID(), e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateIfMatch updates a resource if its revision matches rev and publishes
+// the update.
+func (s *PersistenceService) UpdateIfMatch(
+	ctx context.Context,
+	e cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	if s.pending == nil {
+		err = s.WithTx(ctx, func(tx cce.PersistenceService) error {
+			ok, err = tx.UpdateIfMatch(ctx, e, rev)
+			return err
+		})
+		return ok, err
+	}
+
+	if ok, err = s.PersistenceService.UpdateIfMatch(ctx, e, rev); err != nil || !ok {
+		return ok, err
+	}
+
+	return true, s.add(ctx, cce.EventUpdate, e.GetTableName(), e.GetID(), e)
+}
+
+// Delete deletes a resource and publishes the deletion.
+func (s *PersistenceService) Delete(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+) (ok bool, err error) {
+	return s.delete(ctx, id, zv, 0)
+}
+
+// DeleteIfMatch deletes a resource if its revision matches rev and publishes
+// the deletion.
+func (s *PersistenceService) DeleteIfMatch(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	return s.delete(ctx, id, zv, rev)
+}
+
+func (s *PersistenceService) delete(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	if s.pending == nil {
+		err = s.WithTx(ctx, func(tx cce.PersistenceService) error {
+			ok, err = tx.(*PersistenceService).delete(ctx, id, zv, rev)
+			return err
+		})
+		return ok, err
+	}
+
+	if !cce.IsEventResource(zv.GetTableName()) {
+		if rev == 0 {
+			return s.PersistenceService.Delete(ctx, id, zv)
+		}
+		return s.PersistenceService.DeleteIfMatch(ctx, id, zv, rev)
+	}
+
+	// Read the resource first as the event carries its node ID
+	before, err := s.PersistenceService.Read(ctx, id, zv)
+	if err != nil || before == nil {
+		return false, err
+	}
+
+	if rev == 0 {
+		ok, err = s.PersistenceService.Delete(ctx, id, zv)
+	} else {
+		ok, err = s.PersistenceService.DeleteIfMatch(ctx, id, zv, rev)
+	}
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return true, s.add(ctx, cce.EventDelete, zv.GetTableName(), id, before)
+}
+
+// WithTx runs fn in a transaction and publishes the changes made by fn once
+// the transaction is committed.
+func (s *PersistenceService) WithTx(
+	ctx context.Context,
+	fn func(tx cce.PersistenceService) error,
+) error {
+	if s.pending != nil {
+		return s.PersistenceService.WithTx(ctx, func(tx cce.PersistenceService) error {
+			return fn(&PersistenceService{PersistenceService: tx, Hub: s.Hub, pending: s.pending})
+		})
+	}
+
+	var pending []cce.Event
+	if err := s.PersistenceService.WithTx(ctx, func(tx cce.PersistenceService) error {
+		pending = nil
+		return fn(&PersistenceService{PersistenceService: tx, Hub: s.Hub, pending: &pending})
+	}); err != nil {
+		return err
+	}
+
+	s.Hub.Publish(pending...)
+
+	return nil
+}
+
+// add adds an event to the pending events of the transaction if the table is
+// a cce.EventResources table.
+func (s *PersistenceService) add(
+	ctx context.Context,
+	typ string,
+	table string,
+	id string,
+	e cce.Persistable,
+) error {
+	if !cce.IsEventResource(table) {
+		return nil
+	}
+
+	nodeID, err := s.nodeID(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	entity, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling event entity")
+	}
+
+	*s.pending = append(*s.pending, cce.Event{
+		Type:     typ,
+		Resource: table,
+		ID:       id,
+		NodeID:   nodeID,
+		Entity:   entity,
+	})
+
+	return nil
+}
+
+// nodeID returns the ID of the node e belongs to or "" if it does not belong
+// to a node.
+func (s *PersistenceService) nodeID(ctx context.Context, e cce.Persistable) (string, error) {
+	switch e := e.(type) {
+	case cce.NodeEntity:
+		return e.GetNodeID(), nil
+	case *cce.NodeInterfaceTrafficPolicy:
+		return e.NodeID, nil
+	case *cce.NodeAppTrafficPolicy:
+		nodeApp, err := s.PersistenceService.Read(ctx, e.NodeAppID, &cce.NodeApp{})
+		if err != nil {
+			return "", errors.Wrap(err, "error reading node app of event")
+		}
+		if nodeApp == nil {
+			return "", nil
+		}
+		return nodeApp.(*cce.NodeApp).NodeID, nil
+	default:
+		return "", nil
+	}
+}
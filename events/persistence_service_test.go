@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package events_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/events"
+	"github.com/open-ness/edgecontroller/memory"
+)
+
+var _ = Describe("PersistenceService", func() {
+	var (
+		ctx = context.Background()
+		hub *cce.EventHub
+		sub *cce.EventSubscription
+		ps  *events.PersistenceService
+	)
+
+	// next returns the events published since the last call
+	next := func() []cce.Event {
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+		es, err := sub.Next(ctx)
+		if err == context.DeadlineExceeded {
+			return nil
+		}
+		Expect(err).ToNot(HaveOccurred())
+		return es
+	}
+
+	BeforeEach(func() {
+		inner, err := memory.NewPersistenceService("")
+		Expect(err).ToNot(HaveOccurred())
+		hub = cce.NewEventHub(16)
+		sub = hub.Subscribe(cce.EventFilter{}, false, 0)
+		ps = &events.PersistenceService{PersistenceService: inner, Hub: hub}
+
+		Expect(ps.Create(ctx, &cce.Node{ID: "node-1", Name: "node"})).To(Succeed())
+		Expect(ps.Create(ctx, &cce.App{ID: "app-1", Type: "container", Name: "app"})).To(Succeed())
+		Expect(next()).To(HaveLen(2))
+	})
+
+	AfterEach(func() {
+		sub.Close()
+	})
+
+	It("Should publish a create", func() {
+		Expect(ps.Create(ctx, &cce.NodeApp{ID: "node-app-1", NodeID: "node-1", AppID: "app-1"})).To(Succeed())
+
+		es := next()
+		Expect(es).To(HaveLen(1))
+		Expect(es[0].Seq).To(Equal(uint64(3)))
+		Expect(es[0].Type).To(Equal(cce.EventCreate))
+		Expect(es[0].Resource).To(Equal("nodes_apps"))
+		Expect(es[0].ID).To(Equal("node-app-1"))
+		Expect(es[0].NodeID).To(Equal("node-1"))
+		Expect(es[0].Entity).To(MatchJSON(`{"id":"node-app-1","node_id":"node-1","app_id":"app-1"}`))
+	})
+
+	It("Should publish an update", func() {
+		Expect(ps.UpdateIfMatch(ctx, &cce.App{ID: "app-1", Type: "container", Name: "renamed"}, 1)).To(BeTrue())
+
+		es := next()
+		Expect(es).To(HaveLen(1))
+		Expect(es[0].Type).To(Equal(cce.EventUpdate))
+		Expect(es[0].NodeID).To(BeEmpty())
+	})
+
+	It("Should not publish an update if the revision does not match", func() {
+		Expect(ps.UpdateIfMatch(ctx, &cce.App{ID: "app-1", Type: "container", Name: "renamed"}, 2)).To(BeFalse())
+		Expect(next()).To(BeEmpty())
+	})
+
+	It("Should publish a delete with the node ID of the deleted resource", func() {
+		Expect(ps.Create(ctx, &cce.NodeApp{ID: "node-app-1", NodeID: "node-1", AppID: "app-1"})).To(Succeed())
+		Expect(ps.Create(ctx, &cce.TrafficPolicy{ID: "policy-1"})).To(Succeed())
+		Expect(ps.Create(ctx, &cce.NodeAppTrafficPolicy{
+			ID: "node-app-policy-1", NodeAppID: "node-app-1", TrafficPolicyID: "policy-1",
+		})).To(Succeed())
+		Expect(next()).To(HaveLen(3))
+
+		Expect(ps.Delete(ctx, "node-app-policy-1", &cce.NodeAppTrafficPolicy{})).To(BeTrue())
+
+		es := next()
+		Expect(es).To(HaveLen(1))
+		Expect(es[0].Type).To(Equal(cce.EventDelete))
+		Expect(es[0].ID).To(Equal("node-app-policy-1"))
+		Expect(es[0].NodeID).To(Equal("node-1"))
+	})
+
+	It("Should not publish a delete of a missing resource", func() {
+		Expect(ps.Delete(ctx, "app-2", &cce.App{})).To(BeFalse())
+		Expect(next()).To(BeEmpty())
+	})
+
+	It("Should publish the changes of a transaction once it is committed", func() {
+		Expect(ps.WithTx(ctx, func(tx cce.PersistenceService) error {
+			Expect(tx.Create(ctx, &cce.App{ID: "app-2", Type: "container", Name: "app"})).To(Succeed())
+			Expect(tx.Delete(ctx, "app-1", &cce.App{})).To(BeTrue())
+			Expect(hub.LastSeq()).To(Equal(uint64(2)))
+			return nil
+		})).To(Succeed())
+
+		es := next()
+		Expect(es).To(HaveLen(2))
+		Expect(es[0].ID).To(Equal("app-2"))
+		Expect(es[1].ID).To(Equal("app-1"))
+	})
+
+	It("Should not publish the changes of a rolled back transaction", func() {
+		Expect(ps.WithTx(ctx, func(tx cce.PersistenceService) error {
+			Expect(tx.Create(ctx, &cce.App{ID: "app-2", Type: "container", Name: "app"})).To(Succeed())
+			return errors.New("rollback")
+		})).To(MatchError("rollback"))
+
+		Expect(next()).To(BeEmpty())
+	})
+
+	It("Should not publish changes of other tables", func() {
+		Expect(ps.Create(ctx, &cce.Credentials{ID: "node-1"})).To(Succeed())
+		Expect(next()).To(BeEmpty())
+	})
+})
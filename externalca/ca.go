@@ -0,0 +1,299 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+// Package externalca issues node certificates with an external CA, such as
+// the PKI secrets engine of HashiCorp Vault, over its HTTP API.
+package externalca
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/open-ness/common/log"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ocsp"
+)
+
+var log = logger.DefaultLogger.WithField("pkg", "externalca")
+
+// DefaultTimeout is the timeout of the requests of a CA created by NewCA.
+const DefaultTimeout = 10 * time.Second
+
+// maxResponseSize bounds the size of the responses read from the CA.
+const maxResponseSize = 1 << 20
+
+// CA is an AuthorityService that delegates signing CSRs to an external CA.
+//
+// CSRs are POSTed to SignURL as JSON with the fields "csr", the PEM-encoded
+// CSR, "common_name" and, if Validity is set, "ttl". The response holds the
+// PEM-encoded certificate and its chain in its "data" object:
+//
+//	{"data": {"certificate": "...", "issuing_ca": "...", "ca_chain": ["...", "..."]}}
+//
+// ChainURL returns the PEM-encoded chain of the issuing CA, starting with the
+// issuing CA. This is the API of the sign and ca_chain endpoints of the Vault
+// PKI secrets engine. Requests are authenticated with Token as bearer token.
+//
+// The external CA publishes the revocations of its certificates, so CreateCRL
+// and CreateOCSPResponse return cce.ErrRevocationUnsupported.
+type CA struct {
+	SignURL  string
+	ChainURL string
+	Token    string
+	Validity time.Duration
+
+	Client *http.Client
+
+	mu    sync.Mutex
+	chain []*x509.Certificate
+}
+
+var _ cce.AuthorityService = &CA{}
+
+// NewCA creates a CA with the default settings, verifying the HTTPS
+// certificates of the external CA with rootCAs or, if nil, the system CAs.
+func NewCA(signURL, chainURL, token string, rootCAs *x509.CertPool) *CA {
+	return &CA{
+		SignURL:  signURL,
+		ChainURL: chainURL,
+		Token:    token,
+		Client: &http.Client{
+			Timeout: DefaultTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12},
+			},
+		},
+	}
+}
+
+type signRequest struct {
+	CSR        string `json:"csr"`
+	CommonName string `json:"common_name,omitempty"`
+	TTL        string `json:"ttl,omitempty"`
+}
+
+type signResponse struct {
+	Data struct {
+		Certificate string   `json:"certificate"`
+		IssuingCA   string   `json:"issuing_ca"`
+		CAChain     []string `json:"ca_chain"`
+	} `json:"data"`
+}
+
+type errorResponse struct {
+	Errors []string `json:"errors"`
+}
+
+// CAChain returns the chain of the issuing CA. It is fetched from ChainURL
+// once and updated with the chain returned by every signed CSR.
+func (ca *CA) CAChain() ([]*x509.Certificate, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	if ca.chain != nil {
+		return ca.chain, nil
+	}
+
+	body, err := ca.do(http.MethodGet, ca.ChainURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch CA chain")
+	}
+	chain, err := parseCertificates(string(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse CA chain")
+	}
+	ca.chain = chain
+
+	return chain, nil
+}
+
+// SignCSR signs an ASN.1 DER encoded certificate signing request with the
+// external CA. The common name of the template subject is requested, the
+// external CA decides on the other fields of the certificate. The certificate
+// is rejected unless it is for the key of the CSR and the requested common
+// name and chains to CAChain.
+func (ca *CA) SignCSR(der []byte, template *x509.Certificate) (*x509.Certificate, error) {
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse CSR")
+	}
+
+	req := signRequest{
+		CSR:        string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})),
+		CommonName: template.Subject.CommonName,
+	}
+	if ca.Validity > 0 {
+		req.TTL = fmt.Sprintf("%ds", int64(ca.Validity/time.Second))
+	}
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ca.do(http.MethodPost, ca.SignURL, reqBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to sign CSR")
+	}
+	var resp signResponse
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "unable to decode sign response")
+	}
+
+	certs, err := parseCertificates(resp.Data.Certificate)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse signed certificate")
+	}
+	cert := certs[0]
+	// Do not trust the external CA to have signed the key of the CSR for the
+	// requested common name, it may use the common name of the CSR instead
+	if !bytes.Equal(cert.RawSubjectPublicKeyInfo, csr.RawSubjectPublicKeyInfo) {
+		return nil, errors.New("signed certificate is not for the public key of the CSR")
+	}
+	if cert.Subject.CommonName != template.Subject.CommonName {
+		return nil, errors.Errorf("signed certificate is for common name %q instead of %q",
+			cert.Subject.CommonName, template.Subject.CommonName)
+	}
+	if err = ca.verify(cert, certs[1:], resp.Data.CAChain, resp.Data.IssuingCA); err != nil {
+		return nil, errors.Wrap(err, "signed certificate is not issued by the CA")
+	}
+
+	ca.updateChain(resp.Data.CAChain, resp.Data.IssuingCA)
+
+	return cert, nil
+}
+
+// CreateCRL is not supported, the external CA publishes its CRL.
+func (ca *CA) CreateCRL(revoked []pkix.RevokedCertificate, now, nextUpdate time.Time) ([]byte, error) {
+	return nil, cce.ErrRevocationUnsupported
+}
+
+// CreateOCSPResponse is not supported, the external CA runs its OCSP
+// responder.
+func (ca *CA) CreateOCSPResponse(template ocsp.Response) ([]byte, error) {
+	return nil, cce.ErrRevocationUnsupported
+}
+
+// verify verifies that a signed certificate chains to the cached chain of the
+// CA. The certificates of the sign response are used as intermediates, so
+// that a rotated issuing CA signed by the cached chain is accepted.
+func (ca *CA) verify(cert *x509.Certificate, extra []*x509.Certificate, chainPEM []string, issuingPEM string) error {
+	chain, err := ca.CAChain()
+	if err != nil {
+		return err
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, c := range chain {
+		opts.Roots.AddCert(c)
+	}
+	for _, c := range extra {
+		opts.Intermediates.AddCert(c)
+	}
+	if respChain, err := parseCertificates(strings.Join(chainPEM, "\n") + "\n" + issuingPEM); err == nil {
+		for _, c := range respChain {
+			opts.Intermediates.AddCert(c)
+		}
+	}
+
+	_, err = cert.Verify(opts)
+	return err
+}
+
+// updateChain replaces the cached chain with the chain of a sign response,
+// so that a rotated issuing CA is picked up.
+func (ca *CA) updateChain(chainPEM []string, issuingPEM string) {
+	if len(chainPEM) == 0 && issuingPEM == "" {
+		return
+	}
+	if len(chainPEM) == 0 {
+		chainPEM = []string{issuingPEM}
+	}
+
+	chain, err := parseCertificates(strings.Join(chainPEM, "\n"))
+	if err != nil {
+		log.Warningf("Ignoring invalid CA chain of sign response: %v", err)
+		return
+	}
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.chain = chain
+}
+
+// do sends a request to the external CA and returns the body of a successful
+// response.
+func (ca *CA) do(method, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if ca.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+ca.Token)
+	}
+
+	resp, err := ca.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read response")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e errorResponse
+		if json.Unmarshal(respBody, &e) == nil && len(e.Errors) > 0 {
+			return nil, errors.Errorf("%s: %s", resp.Status, strings.Join(e.Errors, "; "))
+		}
+		return nil, errors.New(resp.Status)
+	}
+
+	return respBody, nil
+}
+
+// parseCertificates parses the PEM-encoded certificates of s.
+func parseCertificates(s string) ([]*x509.Certificate, error) {
+	var (
+		rest  = []byte(s)
+		certs []*x509.Certificate
+	)
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM-encoded certificate")
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package externalca_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/externalca"
+	"github.com/open-ness/edgecontroller/pki"
+	"golang.org/x/crypto/ocsp"
+)
+
+const token = "s.stand-in-token"
+
+// standInCA is a stand-in for the PKI secrets engine of Vault, signing CSRs
+// with a local CA.
+type standInCA struct {
+	*httptest.Server
+
+	ca      *pki.RootCA
+	mu      sync.Mutex
+	signed  []map[string]string
+	wrongCA *pki.RootCA
+	// untrustedCA signs the CSR instead of ca if set
+	untrustedCA *pki.RootCA
+	// csrCommonName uses the common name of the CSR, as Vault does with
+	// use_csr_common_name, instead of the requested one
+	csrCommonName bool
+}
+
+func newStandInCA(dir string) *standInCA {
+	ca, err := pki.InitRootCA(dir)
+	Expect(err).ToNot(HaveOccurred())
+	s := &standInCA{ca: ca}
+
+	encode := func(cert *x509.Certificate) string {
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/pki/ca_chain", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		_, _ = w.Write([]byte(encode(ca.Cert)))
+	})
+	mux.HandleFunc("/v1/pki/sign/node", func(w http.ResponseWriter, r *http.Request) {
+		Expect(r.Method).To(Equal(http.MethodPost))
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		var req map[string]string
+		Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+		s.mu.Lock()
+		s.signed = append(s.signed, req)
+		signer := s.ca
+		if s.wrongCA != nil {
+			signer = s.wrongCA
+		}
+		if s.untrustedCA != nil {
+			signer = s.untrustedCA
+		}
+		commonName := req["common_name"]
+		s.mu.Unlock()
+
+		block, _ := pem.Decode([]byte(req["csr"]))
+		if block == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":["csr contains no data"]}`))
+			return
+		}
+		der := block.Bytes
+		if s.wrongCA != nil {
+			// Sign a CSR of another key
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			der, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		if s.csrCommonName {
+			csr, err := x509.ParseCertificateRequest(der)
+			Expect(err).ToNot(HaveOccurred())
+			commonName = csr.Subject.CommonName
+		}
+		cert, err := signer.SignCSR(der, &x509.Certificate{Subject: pkix.Name{CommonName: commonName}})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"certificate": encode(cert),
+				"issuing_ca":  encode(signer.Cert),
+				"ca_chain":    []string{encode(signer.Cert)},
+			},
+		})).To(Succeed())
+	})
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+func (s *standInCA) requests() []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.signed
+}
+
+var _ = Describe("CA", func() {
+	var (
+		tmpDir  string
+		standIn *standInCA
+		ca      *externalca.CA
+		csrDER  []byte
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "externalca_test")
+		Expect(err).ToNot(HaveOccurred())
+		standIn = newStandInCA(tmpDir)
+		ca = externalca.NewCA(standIn.URL+"/v1/pki/sign/node", standIn.URL+"/v1/pki/ca_chain", token, nil)
+
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		csrDER, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		standIn.Close()
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	Describe("CAChain", func() {
+		It("Should fetch the chain of the external CA", func() {
+			Expect(ca.CAChain()).To(Equal([]*x509.Certificate{standIn.ca.Cert}))
+		})
+
+		It("Should return the error of the external CA", func() {
+			ca.Token = "wrong"
+			_, err := ca.CAChain()
+			Expect(err).To(MatchError("unable to fetch CA chain: 403 Forbidden: permission denied"))
+		})
+	})
+
+	Describe("SignCSR", func() {
+		It("Should sign the CSR with the external CA", func() {
+			ca.Validity = 24 * time.Hour
+			cert, err := ca.SignCSR(csrDER, &x509.Certificate{Subject: pkix.Name{CommonName: "node-1"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cert.Subject.CommonName).To(Equal("node-1"))
+			Expect(cert.CheckSignatureFrom(standIn.ca.Cert)).To(Succeed())
+
+			By("Verifying the request")
+			Expect(standIn.requests()).To(HaveLen(1))
+			Expect(standIn.requests()[0]).To(HaveKeyWithValue("common_name", "node-1"))
+			Expect(standIn.requests()[0]).To(HaveKeyWithValue("ttl", "86400s"))
+			Expect(standIn.requests()[0]["csr"]).To(HavePrefix("-----BEGIN CERTIFICATE REQUEST-----"))
+
+			By("Verifying the chain of the response is cached")
+			standIn.Close()
+			Expect(ca.CAChain()).To(Equal([]*x509.Certificate{standIn.ca.Cert}))
+		})
+
+		It("Should reject a certificate for another public key", func() {
+			wrongCA, err := pki.InitRootCA(tmpDir + "/wrong")
+			Expect(err).ToNot(HaveOccurred())
+			standIn.wrongCA = wrongCA
+
+			_, err = ca.SignCSR(csrDER, &x509.Certificate{})
+			Expect(err).To(MatchError("signed certificate is not for the public key of the CSR"))
+		})
+
+		It("Should reject a certificate for the common name of the CSR", func() {
+			key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			csrDER, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+				Subject: pkix.Name{CommonName: "node-2"},
+			}, key)
+			Expect(err).ToNot(HaveOccurred())
+			standIn.csrCommonName = true
+
+			_, err = ca.SignCSR(csrDER, &x509.Certificate{Subject: pkix.Name{CommonName: "node-1"}})
+			Expect(err).To(MatchError(`signed certificate is for common name "node-2" instead of "node-1"`))
+		})
+
+		It("Should reject a certificate not chaining to the CA", func() {
+			untrustedCA, err := pki.InitRootCA(tmpDir + "/untrusted")
+			Expect(err).ToNot(HaveOccurred())
+			standIn.untrustedCA = untrustedCA
+
+			_, err = ca.SignCSR(csrDER, &x509.Certificate{Subject: pkix.Name{CommonName: "node-1"}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("signed certificate is not issued by the CA: "))
+
+			By("Verifying the chain of the response is not cached")
+			Expect(ca.CAChain()).To(Equal([]*x509.Certificate{standIn.ca.Cert}))
+		})
+
+		It("Should return the error of the external CA", func() {
+			ca.Token = ""
+			_, err := ca.SignCSR(csrDER, &x509.Certificate{})
+			Expect(err).To(MatchError("unable to sign CSR: 403 Forbidden: permission denied"))
+		})
+	})
+
+	Describe("Revocation", func() {
+		It("Should not sign CRLs or OCSP responses", func() {
+			_, err := ca.CreateCRL(nil, time.Now(), time.Now().Add(time.Hour))
+			Expect(err).To(Equal(cce.ErrRevocationUnsupported))
+			_, err = ca.CreateOCSPResponse(ocsp.Response{})
+			Expect(err).To(Equal(cce.ErrRevocationUnsupported))
+		})
+	})
+})
@@ -18,7 +18,7 @@ require (
 	github.com/open-ness/common/proxy v0.0.0-20200630151257-4ca7188ac3be
 	github.com/pkg/errors v0.8.1
 	github.com/satori/go.uuid v1.2.0
-	golang.org/x/crypto v0.0.0-20200510223506-06a226fb4e37 // indirect
+	golang.org/x/crypto v0.0.0-20200510223506-06a226fb4e37
 	golang.org/x/sync v0.0.0-20200317015054-43a5402ce75a
 	google.golang.org/genproto v0.0.0-20190927181202-20e1ac93f88c
 	google.golang.org/grpc v1.29.1
@@ -28,6 +28,7 @@ require (
 	k8s.io/client-go v0.0.0-20190501104856-ef81ee0960bf
 	k8s.io/utils v0.0.0-20190520173318-324c5df7d3f0 // indirect
 	sigs.k8s.io/node-feature-discovery v0.5.0
+	sigs.k8s.io/yaml v1.1.0
 )
 
 replace golang.org/x/sys => golang.org/x/sys v0.0.0-20190226215855-775f8194d0f9
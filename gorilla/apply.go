@@ -0,0 +1,962 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package gorilla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/swagger"
+	"github.com/open-ness/edgecontroller/uuid"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Resources of the steps of an apply plan
+const (
+	applyApps                  = "apps"
+	applyPolicies              = "policies"
+	applyNodes                 = "nodes"
+	applyNodeDNS               = "node_dns"
+	applyNodeApps              = "node_apps"
+	applyNodeAppPolicies       = "node_app_policies"
+	applyNodeInterfacePolicies = "node_interface_policies"
+)
+
+// Actions of the steps of an apply plan
+const (
+	applyCreate = "create"
+	applyUpdate = "update"
+	applyDelete = "delete"
+	applyNone   = "none"
+)
+
+// Used for POST /apply endpoint
+//
+// The manifest (a swagger.Manifest) is accepted as JSON or YAML. It is
+// validated as a whole and compared with the persisted resources to plan the
+// steps needed to reach it. Applying is additive: resources that are not in
+// the manifest are left untouched, unless prune=true, with which the DNS
+// settings, apps and policies of the manifest's nodes that are not in the
+// manifest are deleted. With dryRun=true only the plan is returned.
+// Otherwise the steps are applied in dependency order (policies, apps, nodes
+// and then the DNS settings, apps and policies of every node) by dispatching
+// the same requests a client would send, so every step is validated, audited
+// and published like any other request. Applying stops at the first failed
+// step and the response has the status of that step. Steps that have been
+// applied before are not rolled back, as they may have been sent to nodes.
+func (g *Gorilla) swagPOSTApply(w http.ResponseWriter, r *http.Request) {
+	body := r.Context().Value(contextKey("body")).([]byte)
+
+	dryRun, err := queryBool(r, "dryRun")
+	if err != nil {
+		writeInvalidQuery(w, r, err)
+		return
+	}
+	prune, err := queryBool(r, "prune")
+	if err != nil {
+		writeInvalidQuery(w, r, err)
+		return
+	}
+
+	manifest, err := parseManifest(body)
+	if err != nil {
+		writeInvalidManifest(w, err)
+		return
+	}
+
+	a := &applier{g: g, r: r, ctrl: getController(r.Context()), prune: prune}
+	if err = a.plan(r.Context(), manifest); err != nil {
+		if _, ok := err.(*manifestError); ok {
+			writeInvalidManifest(w, err)
+			return
+		}
+		log.Errf("Error planning manifest: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if !dryRun {
+		status = a.apply()
+	}
+
+	plan := swagger.ApplyPlan{DryRun: dryRun, Steps: []swagger.ApplyStep{}}
+	for _, s := range a.steps {
+		plan.Steps = append(plan.Steps, s.ApplyStep)
+	}
+	planJSON, err := json.Marshal(plan)
+	if err != nil {
+		log.Errf("Error marshaling plan: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err = w.Write(planJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// queryBool parses a boolean query parameter, which defaults to false.
+func queryBool(r *http.Request, name string) (bool, error) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(param)
+	if err != nil {
+		return false, fmt.Errorf("%s %q is not a boolean", name, param)
+	}
+
+	return b, nil
+}
+
+// parseManifest parses a JSON or YAML manifest. Unknown fields are rejected
+// to catch misspelled keys.
+func parseManifest(body []byte) (*swagger.Manifest, error) {
+	// JSON is a subset of YAML
+	manifestJSON, err := yaml.YAMLToJSON(body)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &swagger.Manifest{}
+	dec := json.NewDecoder(bytes.NewReader(manifestJSON))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+func writeInvalidManifest(w http.ResponseWriter, err error) {
+	log.Debugf("Invalid manifest: %v", err)
+	w.WriteHeader(http.StatusBadRequest)
+	if _, err = w.Write([]byte(fmt.Sprintf("Invalid manifest: %v", err))); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// manifestError is an error in the manifest as opposed to an error planning
+// it.
+type manifestError struct {
+	msg string
+}
+
+func (e *manifestError) Error() string {
+	return e.msg
+}
+
+func invalidManifest(format string, args ...interface{}) error {
+	return &manifestError{msg: fmt.Sprintf(format, args...)}
+}
+
+// applyRef is a resource of a plan. Its ID is empty until the step creating
+// it has been applied.
+type applyRef struct {
+	id string
+}
+
+type applyStep struct {
+	swagger.ApplyStep
+
+	// ref is set to the ID of the resource created by the step
+	ref *applyRef
+	// apply applies the step and returns the response body
+	apply func() ([]byte, error)
+}
+
+// applier plans and applies a manifest.
+type applier struct {
+	g     *Gorilla
+	r     *http.Request
+	ctrl  *cce.Controller
+	steps []*applyStep
+
+	// prune plans deleting what the manifest's nodes have but the manifest
+	// does not
+	prune bool
+
+	// apps and policies of the manifest by ID and name
+	apps     map[string]*applyRef
+	policies map[string]*applyRef
+
+	// persisted apps and policies
+	persistedApps     []cce.Persistable
+	persistedPolicies []cce.Persistable
+}
+
+func (a *applier) plan(ctx context.Context, m *swagger.Manifest) error {
+	a.apps = make(map[string]*applyRef)
+	a.policies = make(map[string]*applyRef)
+
+	kubeOVN := a.ctrl.OrchestrationMode == cce.OrchestrationModeKubernetesOVN
+	if kubeOVN && len(m.Policies) != 0 {
+		return invalidManifest("policies: not supported in kubernetes-ovn orchestration mode")
+	}
+
+	var err error
+	if a.persistedApps, err = a.ctrl.PersistenceService.ReadAll(ctx, &cce.App{}); err != nil {
+		return errors.Wrap(err, "error reading apps")
+	}
+	if !kubeOVN {
+		if a.persistedPolicies, err = a.ctrl.PersistenceService.ReadAll(ctx, &cce.TrafficPolicy{}); err != nil {
+			return errors.Wrap(err, "error reading traffic policies")
+		}
+	}
+
+	for i := range m.Policies {
+		if err = a.planPolicy(fmt.Sprintf("policies[%d]", i), &m.Policies[i]); err != nil {
+			return err
+		}
+	}
+	for i := range m.Apps {
+		if err = a.planApp(fmt.Sprintf("apps[%d]", i), &m.Apps[i]); err != nil {
+			return err
+		}
+	}
+
+	serials := make(map[string]bool)
+	for i := range m.Nodes {
+		field, n := fmt.Sprintf("nodes[%d]", i), &m.Nodes[i]
+		if kubeOVN && hasNodePolicies(n) {
+			return invalidManifest("%s: policies are not supported in kubernetes-ovn orchestration mode", field)
+		}
+		if err = a.planNode(ctx, field, n); err != nil {
+			return err
+		}
+
+		if serials[n.Serial] {
+			return invalidManifest("%s: duplicate node serial %q", field, n.Serial)
+		}
+		serials[n.Serial] = true
+	}
+
+	return nil
+}
+
+func hasNodePolicies(n *swagger.ManifestNode) bool {
+	if len(n.Interfaces) != 0 {
+		return true
+	}
+	for _, app := range n.Apps {
+		if app.Policy != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *applier) planPolicy(field string, p *swagger.PolicyDetail) error {
+	persisted, err := match(field, "policy", p.ID, p.Name, a.persistedPolicies, func(e cce.Persistable) string {
+		return e.(*cce.TrafficPolicy).Name
+	})
+	if err != nil {
+		return err
+	}
+
+	desired := &cce.TrafficPolicy{ID: p.ID, Name: p.Name, Rules: p.Rules}
+	ref, err := a.planEntity(field, applyPolicies, p.Name, desired, persisted, "/policies")
+	if err != nil {
+		return err
+	}
+
+	return register(field, "policy", a.policies, ref, p.ID, p.Name)
+}
+
+func (a *applier) planApp(field string, app *swagger.AppDetail) error {
+	persisted, err := match(field, "app", app.ID, app.Name, a.persistedApps, func(e cce.Persistable) string {
+		return e.(*cce.App).Name
+	})
+	if err != nil {
+		return err
+	}
+
+	desired := &cce.App{
+		ID:          app.ID,
+		Type:        app.Type,
+		Name:        app.Name,
+		Version:     app.Version,
+		Vendor:      app.Vendor,
+		Description: app.Description,
+		Cores:       app.Cores,
+		Memory:      app.Memory,
+		Ports:       app.Ports,
+		Source:      app.Source,
+		EPAFeatures: app.EPAFeatures,
+	}
+	ref, err := a.planEntity(field, applyApps, app.Name, desired, persisted, "/apps")
+	if err != nil {
+		return err
+	}
+
+	return register(field, "app", a.apps, ref, app.ID, app.Name)
+}
+
+// planEntity validates an app, policy or node of the manifest and plans its
+// creation or update. The entity is created with POST to path and updated
+// with PATCH to path/{id}.
+func (a *applier) planEntity(
+	field string,
+	resource string,
+	name string,
+	desired interface {
+		cce.Persistable
+		cce.Validatable
+	},
+	persisted cce.Persistable,
+	path string,
+) (*applyRef, error) {
+	step := &applyStep{ApplyStep: swagger.ApplyStep{Resource: resource, Name: name}}
+
+	// Validate with a placeholder ID for new entities
+	if persisted == nil {
+		desired.SetID(uuid.New())
+	} else {
+		desired.SetID(persisted.GetID())
+	}
+	if err := desired.Validate(); err != nil {
+		return nil, invalidManifest("%s: %v", field, err)
+	}
+
+	if persisted == nil {
+		desired.SetID("")
+		step.Action = applyCreate
+		step.ref = &applyRef{}
+		step.apply = func() ([]byte, error) {
+			return a.call(http.MethodPost, path, desired)
+		}
+		a.steps = append(a.steps, step)
+		return step.ref, nil
+	}
+
+	step.ID = persisted.GetID()
+	step.ref = &applyRef{id: step.ID}
+	if equalJSON(desired, persisted) {
+		step.Action = applyNone
+	} else {
+		step.Action = applyUpdate
+		step.apply = func() ([]byte, error) {
+			return a.call(http.MethodPatch, path+"/"+step.ID, desired)
+		}
+	}
+	a.steps = append(a.steps, step)
+
+	return step.ref, nil
+}
+
+// match returns the persisted entity with the given ID or, if id is empty,
+// the one with the given name. An error is returned if the ID is not found or
+// the name is ambiguous.
+func match(
+	field string,
+	kind string,
+	id string,
+	name string,
+	persisted []cce.Persistable,
+	nameOf func(cce.Persistable) string,
+) (cce.Persistable, error) {
+	if id != "" {
+		for _, e := range persisted {
+			if e.GetID() == id {
+				return e, nil
+			}
+		}
+		return nil, invalidManifest("%s: %s %s not found", field, kind, id)
+	}
+
+	var found cce.Persistable
+	for _, e := range persisted {
+		if nameOf(e) != name {
+			continue
+		}
+		if found != nil {
+			return nil, invalidManifest("%s: %s name %q is ambiguous, use the ID", field, kind, name)
+		}
+		found = e
+	}
+
+	return found, nil
+}
+
+// register registers a manifest entity by ID and name for references.
+func register(field, kind string, refs map[string]*applyRef, ref *applyRef, id, name string) error {
+	for _, key := range []string{id, name} {
+		if key == "" {
+			continue
+		}
+		if _, ok := refs[key]; ok {
+			return invalidManifest("%s: duplicate %s %q", field, kind, key)
+		}
+		refs[key] = ref
+	}
+
+	return nil
+}
+
+// resolve resolves a reference to an app or policy of the manifest or the
+// persistence by ID or name.
+func resolve(
+	field string,
+	kind string,
+	key string,
+	refs map[string]*applyRef,
+	persisted []cce.Persistable,
+	nameOf func(cce.Persistable) string,
+) (*applyRef, error) {
+	if key == "" {
+		return nil, invalidManifest("%s: %s is required", field, kind)
+	}
+	if ref, ok := refs[key]; ok {
+		return ref, nil
+	}
+
+	if uuid.IsValid(key) {
+		if e, err := match(field, kind, key, "", persisted, nameOf); err == nil {
+			return &applyRef{id: e.GetID()}, nil
+		}
+	}
+	e, err := match(field, kind, "", key, persisted, nameOf)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, invalidManifest("%s: %s %q not found", field, kind, key)
+	}
+
+	return &applyRef{id: e.GetID()}, nil
+}
+
+func (a *applier) resolveApp(field, key string) (*applyRef, error) {
+	return resolve(field, "app", key, a.apps, a.persistedApps, func(e cce.Persistable) string {
+		return e.(*cce.App).Name
+	})
+}
+
+func (a *applier) resolvePolicy(field, key string) (*applyRef, error) {
+	return resolve(field, "policy", key, a.policies, a.persistedPolicies, func(e cce.Persistable) string {
+		return e.(*cce.TrafficPolicy).Name
+	})
+}
+
+func (a *applier) planNode(ctx context.Context, field string, n *swagger.ManifestNode) error {
+	var persisted cce.Persistable
+	if n.ID != "" {
+		e, err := a.ctrl.PersistenceService.Read(ctx, n.ID, &cce.Node{})
+		if err != nil {
+			return errors.Wrap(err, "error reading node")
+		}
+		if e == nil {
+			return invalidManifest("%s: node %s not found", field, n.ID)
+		}
+		persisted = e
+	} else {
+		es, err := a.ctrl.PersistenceService.Filter(ctx, &cce.Node{}, []cce.Filter{{Field: "serial", Value: n.Serial}})
+		if err != nil {
+			return errors.Wrap(err, "error filtering nodes")
+		}
+		if len(es) > 1 {
+			return invalidManifest("%s: node serial %q is ambiguous, use the ID", field, n.Serial)
+		}
+		if len(es) == 1 {
+			persisted = es[0]
+		}
+	}
+
+	desired := &cce.Node{ID: n.ID, Name: n.Name, Location: n.Location, Serial: n.Serial}
+	node, err := a.planEntity(field, applyNodes, n.Name, desired, persisted, "/nodes")
+	if err != nil {
+		return err
+	}
+
+	if n.DNS != nil {
+		if err = a.planNodeDNS(ctx, field+".dns", n, node); err != nil {
+			return err
+		}
+	}
+	policies, err := a.planNodeApps(ctx, field, n, node)
+	if err != nil {
+		return err
+	}
+	if err = a.planNodeInterfaces(ctx, field, n, node); err != nil {
+		return err
+	}
+
+	if !a.prune || !resolved(node) {
+		return nil
+	}
+	return a.planNodePrune(ctx, n, node, policies)
+}
+
+func (a *applier) planNodeDNS(ctx context.Context, field string, n *swagger.ManifestNode, node *applyRef) error {
+	if len(n.DNS.Configurations.Forwarders) != 0 {
+		return invalidManifest("%s.configurations.forwarders: not implemented", field)
+	}
+
+	aliases := make(map[int]*applyRef)
+	for i, rec := range n.DNS.Records.A {
+		recField := fmt.Sprintf("%s.records.a[%d]", field, i)
+		if !rec.Alias {
+			record := &cce.DNSARecord{Name: rec.Name, Description: rec.Description, IPs: rec.Values}
+			if err := record.Validate(); err != nil {
+				return invalidManifest("%s: %v", recField, err)
+			}
+			continue
+		}
+
+		if len(rec.Values) != 1 {
+			return invalidManifest("%s: alias must have exactly one app", recField)
+		}
+		app, err := a.resolveApp(recField, rec.Values[0])
+		if err != nil {
+			return err
+		}
+		aliases[i] = app
+		alias := &cce.DNSConfigAppAlias{
+			ID:          uuid.New(),
+			DNSConfigID: uuid.New(),
+			Name:        rec.Name,
+			Description: rec.Description,
+			AppID:       uuid.New(),
+		}
+		if err = alias.Validate(); err != nil {
+			return invalidManifest("%s: %v", recField, err)
+		}
+	}
+
+	// desired returns the DNS settings with the aliases resolved to app IDs
+	desired := func() swagger.DNSDetail {
+		dns := swagger.DNSDetail{DNSSummary: swagger.DNSSummary{Name: n.DNS.Name}}
+		for i, rec := range n.DNS.Records.A {
+			if app, ok := aliases[i]; ok {
+				rec.Values = []string{app.id}
+			}
+			dns.Records.A = append(dns.Records.A, rec)
+		}
+		return dns
+	}
+
+	step := &applyStep{ApplyStep: swagger.ApplyStep{Action: applyCreate, Resource: applyNodeDNS, Name: n.Name}}
+	step.apply = func() ([]byte, error) {
+		return a.call(http.MethodPatch, "/nodes/"+node.id+"/dns", desired())
+	}
+	a.steps = append(a.steps, step)
+
+	if !resolved(node) {
+		return nil
+	}
+
+	body, err := a.call(http.MethodGet, "/nodes/"+node.id+"/dns", nil)
+	if err != nil {
+		return errors.Wrap(err, "error reading node DNS")
+	}
+	var current swagger.DNSDetail
+	if err = json.Unmarshal(body, &current); err != nil {
+		return errors.Wrap(err, "error unmarshaling node DNS")
+	}
+	if current.ID == "" {
+		return nil
+	}
+	step.ID = current.ID
+	current.ID = ""
+	step.Action = applyUpdate
+
+	for _, app := range aliases {
+		if !resolved(app) {
+			return nil
+		}
+	}
+	if equalJSON(normalizeDNS(current), normalizeDNS(desired())) {
+		step.Action = applyNone
+	}
+
+	return nil
+}
+
+// normalizeDNS sorts the A records of DNS settings so they can be compared.
+func normalizeDNS(dns swagger.DNSDetail) swagger.DNSDetail {
+	records := []swagger.DNSARecord{}
+	for _, rec := range dns.Records.A {
+		if rec.Values == nil {
+			rec.Values = []string{}
+		}
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Alias != records[j].Alias {
+			return !records[i].Alias
+		}
+		return records[i].Name < records[j].Name
+	})
+	dns.Records.A = records
+	dns.Configurations.Forwarders = []swagger.DNSForwarder{}
+
+	return dns
+}
+
+// planNodeApps plans deploying the apps of a node and returns whether they
+// have a policy by the IDs of the existing apps.
+func (a *applier) planNodeApps(
+	ctx context.Context,
+	field string,
+	n *swagger.ManifestNode,
+	node *applyRef,
+) (map[string]bool, error) {
+	deployed := make(map[*applyRef]bool)
+	policies := make(map[string]bool)
+	for i, nodeApp := range n.Apps {
+		appField := fmt.Sprintf("%s.apps[%d]", field, i)
+		app, err := a.resolveApp(appField+".app", nodeApp.App)
+		if err != nil {
+			return nil, err
+		}
+		if deployed[app] {
+			return nil, invalidManifest("%s: duplicate app %q", appField, nodeApp.App)
+		}
+		deployed[app] = true
+
+		var policy *applyRef
+		if nodeApp.Policy != "" {
+			if policy, err = a.resolvePolicy(appField+".policy", nodeApp.Policy); err != nil {
+				return nil, err
+			}
+		}
+		if resolved(app) {
+			policies[app.id] = policy != nil
+		}
+
+		if err = a.planNodeApp(ctx, n.Name+"/"+nodeApp.App, node, app, policy); err != nil {
+			return nil, err
+		}
+	}
+
+	return policies, nil
+}
+
+func (a *applier) planNodeApp(ctx context.Context, name string, node, app, policy *applyRef) error {
+	step := &applyStep{ApplyStep: swagger.ApplyStep{Action: applyCreate, Resource: applyNodeApps, Name: name}}
+	step.apply = func() ([]byte, error) {
+		return a.call(http.MethodPost, "/nodes/"+node.id+"/apps", swagger.BaseResource{ID: app.id})
+	}
+	a.steps = append(a.steps, step)
+
+	var policyStep *applyStep
+	if policy != nil {
+		policyStep = &applyStep{
+			ApplyStep: swagger.ApplyStep{Action: applyCreate, Resource: applyNodeAppPolicies, Name: name},
+		}
+		policyStep.apply = func() ([]byte, error) {
+			return a.call(http.MethodPatch, "/nodes/"+node.id+"/apps/"+app.id+"/policy",
+				swagger.BaseResource{ID: policy.id})
+		}
+		a.steps = append(a.steps, policyStep)
+	}
+
+	if !resolved(node) || !resolved(app) {
+		return nil
+	}
+	nodeApps, err := a.ctrl.PersistenceService.Filter(ctx, &cce.NodeApp{}, []cce.Filter{
+		{Field: "node_id", Value: node.id},
+		{Field: "app_id", Value: app.id},
+	})
+	if err != nil {
+		return errors.Wrap(err, "error filtering node apps")
+	}
+	if len(nodeApps) == 0 {
+		return nil
+	}
+	step.Action = applyNone
+	step.ID = nodeApps[0].GetID()
+
+	if policyStep == nil {
+		return nil
+	}
+	policies, err := a.ctrl.PersistenceService.Filter(ctx, &cce.NodeAppTrafficPolicy{}, []cce.Filter{
+		{Field: "nodes_apps_id", Value: step.ID},
+	})
+	if err != nil {
+		return errors.Wrap(err, "error filtering node app traffic policies")
+	}
+	if len(policies) != 0 {
+		policyStep.Action = applyUpdate
+		policyStep.ID = policies[0].GetID()
+		if resolved(policy) && policies[0].(*cce.NodeAppTrafficPolicy).TrafficPolicyID == policy.id {
+			policyStep.Action = applyNone
+		}
+	}
+
+	return nil
+}
+
+func (a *applier) planNodeInterfaces(ctx context.Context, field string, n *swagger.ManifestNode, node *applyRef) error {
+	seen := make(map[string]bool)
+	for i, iface := range n.Interfaces {
+		ifaceField := fmt.Sprintf("%s.interfaces[%d]", field, i)
+		if iface.ID == "" {
+			return invalidManifest("%s.id: interface ID is required", ifaceField)
+		}
+		if seen[iface.ID] {
+			return invalidManifest("%s: duplicate interface %q", ifaceField, iface.ID)
+		}
+		seen[iface.ID] = true
+
+		policy, err := a.resolvePolicy(ifaceField+".policy", iface.Policy)
+		if err != nil {
+			return err
+		}
+
+		ifaceID := iface.ID
+		step := &applyStep{ApplyStep: swagger.ApplyStep{
+			Action:   applyCreate,
+			Resource: applyNodeInterfacePolicies,
+			Name:     n.Name + "/" + ifaceID,
+		}}
+		step.apply = func() ([]byte, error) {
+			return a.call(http.MethodPatch, "/nodes/"+node.id+"/interfaces/"+ifaceID+"/policy",
+				swagger.BaseResource{ID: policy.id})
+		}
+		a.steps = append(a.steps, step)
+
+		if !resolved(node) {
+			continue
+		}
+		policies, err := a.ctrl.PersistenceService.Filter(ctx, &cce.NodeInterfaceTrafficPolicy{}, []cce.Filter{
+			{Field: "node_id", Value: node.id},
+			{Field: "network_interface_id", Value: ifaceID},
+		})
+		if err != nil {
+			return errors.Wrap(err, "error filtering node interface traffic policies")
+		}
+		if len(policies) != 0 {
+			step.Action = applyUpdate
+			step.ID = policies[0].GetID()
+			if resolved(policy) && policies[0].(*cce.NodeInterfaceTrafficPolicy).TrafficPolicyID == policy.id {
+				step.Action = applyNone
+			}
+		}
+	}
+
+	return nil
+}
+
+// planNodePrune plans deleting the apps, policies and DNS settings of an
+// existing node that are not in the manifest. policies are whether the apps
+// of the manifest have a policy by app ID. The policy of an app is deleted
+// before the app, which cannot be undeployed while it has one. Policies are
+// left alone in kubernetes-ovn orchestration mode, where a manifest has none.
+func (a *applier) planNodePrune(
+	ctx context.Context,
+	n *swagger.ManifestNode,
+	node *applyRef,
+	policies map[string]bool,
+) error {
+	kubeOVN := a.ctrl.OrchestrationMode == cce.OrchestrationModeKubernetesOVN
+	ps := a.ctrl.PersistenceService
+
+	nodeApps, err := ps.Filter(ctx, &cce.NodeApp{}, []cce.Filter{{Field: "node_id", Value: node.id}})
+	if err != nil {
+		return errors.Wrap(err, "error filtering node apps")
+	}
+	for _, e := range nodeApps {
+		nodeApp := e.(*cce.NodeApp)
+		name := n.Name + "/" + a.appName(nodeApp.AppID)
+		path := "/nodes/" + node.id + "/apps/" + nodeApp.AppID
+		hasPolicy, kept := policies[nodeApp.AppID]
+
+		if !kubeOVN && !hasPolicy {
+			appPolicies, err := ps.Filter(ctx, &cce.NodeAppTrafficPolicy{}, []cce.Filter{
+				{Field: "nodes_apps_id", Value: nodeApp.ID},
+			})
+			if err != nil {
+				return errors.Wrap(err, "error filtering node app traffic policies")
+			}
+			if len(appPolicies) != 0 {
+				a.planDelete(applyNodeAppPolicies, name, appPolicies[0].GetID(), path+"/policy")
+			}
+		}
+		if !kept {
+			a.planDelete(applyNodeApps, name, nodeApp.ID, path)
+		}
+	}
+
+	if !kubeOVN {
+		ifacePolicies, err := ps.Filter(ctx, &cce.NodeInterfaceTrafficPolicy{}, []cce.Filter{
+			{Field: "node_id", Value: node.id},
+		})
+		if err != nil {
+			return errors.Wrap(err, "error filtering node interface traffic policies")
+		}
+		ifaces := make(map[string]bool)
+		for _, iface := range n.Interfaces {
+			ifaces[iface.ID] = true
+		}
+		for _, e := range ifacePolicies {
+			p := e.(*cce.NodeInterfaceTrafficPolicy)
+			if !ifaces[p.NetworkInterfaceID] {
+				a.planDelete(applyNodeInterfacePolicies, n.Name+"/"+p.NetworkInterfaceID, p.ID,
+					"/nodes/"+node.id+"/interfaces/"+p.NetworkInterfaceID+"/policy")
+			}
+		}
+	}
+
+	if n.DNS == nil {
+		dnsConfigs, err := ps.Filter(ctx, &cce.NodeDNSConfig{}, []cce.Filter{{Field: "node_id", Value: node.id}})
+		if err != nil {
+			return errors.Wrap(err, "error filtering node DNS configs")
+		}
+		if len(dnsConfigs) != 0 {
+			a.planDelete(applyNodeDNS, n.Name, dnsConfigs[0].(*cce.NodeDNSConfig).DNSConfigID, "/nodes/"+node.id+"/dns")
+		}
+	}
+
+	return nil
+}
+
+// planDelete plans deleting a resource with DELETE to path.
+func (a *applier) planDelete(resource, name, id, path string) {
+	step := &applyStep{ApplyStep: swagger.ApplyStep{Action: applyDelete, Resource: resource, Name: name, ID: id}}
+	step.apply = func() ([]byte, error) {
+		return a.call(http.MethodDelete, path, nil)
+	}
+	a.steps = append(a.steps, step)
+}
+
+// appName returns the name of a persisted app or its ID if it is not found.
+func (a *applier) appName(id string) string {
+	for _, e := range a.persistedApps {
+		if e.GetID() == id {
+			return e.(*cce.App).Name
+		}
+	}
+
+	return id
+}
+
+// resolved returns whether a resource exists before the plan is applied.
+func resolved(ref *applyRef) bool {
+	return ref.id != ""
+}
+
+// apply applies the steps in order and returns the status of the response:
+// 200 if all steps have been applied or the status of the failed step.
+func (a *applier) apply() int {
+	for _, s := range a.steps {
+		if s.Action == applyNone {
+			continue
+		}
+
+		body, err := s.apply()
+		if err == nil && s.Action == applyCreate && s.ref != nil {
+			var created swagger.BaseResource
+			if err = json.Unmarshal(body, &created); err == nil {
+				s.ref.id = created.ID
+				s.ID = created.ID
+			}
+		}
+		if err != nil {
+			s.Error = err.Error()
+			if e, ok := err.(*applyError); ok {
+				return e.status
+			}
+			return http.StatusInternalServerError
+		}
+
+		s.Applied = true
+	}
+
+	return http.StatusOK
+}
+
+// applyError is the error response to the request of a step.
+type applyError struct {
+	status int
+	msg    string
+}
+
+func (e *applyError) Error() string {
+	if e.msg == "" {
+		return http.StatusText(e.status)
+	}
+	return fmt.Sprintf("%s: %s", http.StatusText(e.status), e.msg)
+}
+
+// call dispatches a request on behalf of the client applying the manifest and
+// returns the response body. A non-2xx response is returned as an
+// *applyError.
+func (a *applier) call(method, path string, body interface{}) ([]byte, error) {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return nil, errors.Wrap(err, "error marshaling request")
+		}
+	}
+
+	req, err := http.NewRequestWithContext(a.r.Context(), method, path, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+	req.RequestURI = path
+	req.RemoteAddr = a.r.RemoteAddr
+	req.Header.Set("Authorization", a.r.Header.Get("Authorization"))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := &applyResponse{header: make(http.Header)}
+	a.g.router.ServeHTTP(resp, req)
+
+	if resp.status == 0 {
+		resp.status = http.StatusOK
+	}
+	if resp.status < 200 || resp.status > 299 {
+		return nil, &applyError{status: resp.status, msg: resp.body.String()}
+	}
+
+	return resp.body.Bytes(), nil
+}
+
+// applyResponse records the response to the request of a step.
+type applyResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *applyResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *applyResponse) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+func (w *applyResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// equalJSON returns whether two values have the same JSON encoding.
+func equalJSON(x, y interface{}) bool {
+	xJSON, err := json.Marshal(x)
+	if err != nil {
+		return false
+	}
+	yJSON, err := json.Marshal(y)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(xJSON, yJSON)
+}
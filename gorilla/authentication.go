@@ -4,13 +4,38 @@
 package gorilla
 
 import (
+	"context"
 	"encoding/json"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gorilla/mux"
 	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/jose"
+	"github.com/open-ness/edgecontroller/swagger"
+	"github.com/pkg/errors"
 )
 
+// dummyPasswordHash is a bcrypt hash checked for unknown users.
+const dummyPasswordHash = "$2a$10$qQpUz8pmVdzY.2tleBqjXuBOWd/pr.9P86rBVQpr1aZ6aOp1Ikw76"
+
+// adminPaths are the paths that only admins may access, including their
+// subpaths.
+var adminPaths = []string{
+	"/apikeys",
+	"/auth/keys",
+	"/enrollment_tokens",
+	"/enrollments",
+	"/users",
+	"/webhooks",
+	"/audit",
+	"/export",
+	"/import",
+}
+
 func authenticate(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctrl = r.Context().Value(contextKey("controller")).(*cce.Controller)
@@ -24,33 +49,262 @@ func authenticate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify the user name and password
-	if u.Username != ctrl.AdminCreds.Username {
-		log.Debugf("Unsuccessful login attempt for user '%s'", u.Username)
-		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+	// Reject the login without checking the password while the user or the
+	// source is locked out. Otherwise the attempt counts as failed until the
+	// password is verified, so concurrent logins cannot get around the
+	// lockout.
+	source := remoteHost(r)
+	if d := reserveLogin(ctrl, u.Username, source); d > 0 {
+		log.Debugf("Login attempt for locked out user '%s' from %s", u.Username, source)
+		logAuthEvent(ctrl, r, cce.AuthActionLogin, cce.AuthOutcomeLockedOut, u.Username, "too many failed logins")
+		writeTooManyRequests(w, d)
+		return
+	}
+
+	// Look up the user
+	users, err := ctrl.PersistenceService.Filter(
+		r.Context(),
+		&cce.User{},
+		[]cce.Filter{{Field: "username", Value: u.Username}},
+	)
+	if err != nil {
+		log.Errf("Error reading users: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	if u.Password != ctrl.AdminCreds.Password {
-		log.Debugf("Unsuccessful login attempt for user '%s'", u.Username)
+
+	// Verify the user name and password. bcrypt compares the hashes in
+	// constant time and the password of an unknown user is checked against a
+	// dummy hash, so the response time does not tell whether the user exists
+	// or how much of the password is correct.
+	user := &cce.User{PasswordHash: dummyPasswordHash}
+	if len(users) == 1 {
+		user = users[0].(*cce.User)
+	}
+	if !user.CheckPassword(u.Password) || len(users) != 1 {
+		log.Debugf("Unsuccessful login attempt for user '%s' from %s", u.Username, source)
+		logAuthEvent(ctrl, r, cce.AuthActionLogin, cce.AuthOutcomeFailure, u.Username, "invalid username or password")
+		logLoginLockout(ctrl, u.Username, source)
 		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
 	log.Debugf("Successfully authenticated user: %s", u.Username)
+	if ctrl.LoginThrottle != nil {
+		ctrl.LoginThrottle.Succeeded(u.Username, source)
+	}
+
+	// Start a session
+	access, refresh, err := ctrl.TokenService.Issue(user.Username, user.Role)
+	if err != nil {
+		log.Debugf("Error signing authentication token: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	logAuthEvent(ctrl, r, cce.AuthActionLogin, cce.AuthOutcomeSuccess, user.Username, "")
+
+	writeTokens(w, access, refresh)
+}
+
+// reserveLogin reserves a login attempt and returns for how much longer the
+// user or the source is locked out, or zero if neither is or logins are not
+// throttled.
+func reserveLogin(ctrl *cce.Controller, username, source string) time.Duration {
+	if ctrl.LoginThrottle == nil {
+		return 0
+	}
+	return ctrl.LoginThrottle.Attempt(username, source, time.Now())
+}
+
+// logLoginLockout logs the lockout of a failed login, if it caused one.
+func logLoginLockout(ctrl *cce.Controller, username, source string) {
+	if ctrl.LoginThrottle == nil {
+		return
+	}
+	if d := ctrl.LoginThrottle.Locked(username, source, time.Now()); d > 0 {
+		log.Infof("Locked out user '%s' or source %s for %v", username, source, d)
+	}
+}
+
+// writeTooManyRequests responds with status code 429 and a Retry-After header
+// of the lockout rounded up to seconds.
+func writeTooManyRequests(w http.ResponseWriter, lockout time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((lockout+time.Second-1)/time.Second)))
+	http.Error(w, "Too many failed login attempts", http.StatusTooManyRequests)
+}
+
+// logAuthEvent records an authentication event of a request, if the
+// controller records them.
+func logAuthEvent(ctrl *cce.Controller, r *http.Request, action, outcome, username, reason string) {
+	if ctrl.AuthEvents == nil {
+		return
+	}
+
+	ctrl.AuthEvents.LogAuthEvent(&cce.AuthEvent{
+		Time:     time.Now(),
+		Action:   action,
+		Outcome:  outcome,
+		Username: username,
+		Source:   remoteHost(r),
+		Reason:   reason,
+	})
+}
+
+// remoteHost returns the host of the remote address of a request.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// refreshToken issues a new access token for the session of a refresh token.
+// The user is looked up again, so that a deleted user cannot refresh and a
+// changed role applies to the new token.
+func refreshToken(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctrl = r.Context().Value(contextKey("controller")).(*cce.Controller)
+		body = r.Context().Value(contextKey("body")).([]byte)
+	)
 
-	// Create an auth token
-	token, err := ctrl.TokenService.Issue()
+	// Extract the refresh token from JSON
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Validate the refresh token
+	claims, err := ctrl.TokenService.ValidateRefresh(req.RefreshToken)
+	if err != nil {
+		log.Debugf("Invalid refresh token: %v", err)
+		logAuthEvent(ctrl, r, cce.AuthActionRefresh, cce.AuthOutcomeFailure, "", "invalid refresh token")
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	// Look up the user
+	users, err := ctrl.PersistenceService.Filter(
+		r.Context(),
+		&cce.User{},
+		[]cce.Filter{{Field: "username", Value: claims.Subject}},
+	)
+	if err != nil {
+		log.Errf("Error reading users: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(users) != 1 {
+		log.Debugf("Refresh token of unknown user '%s'", claims.Subject)
+		logAuthEvent(ctrl, r, cce.AuthActionRefresh, cce.AuthOutcomeFailure, claims.Subject, "unknown user")
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	user := users[0].(*cce.User)
+
+	access, err := ctrl.TokenService.IssueAccess(user.Username, user.Role, claims.Session)
 	if err != nil {
 		log.Debugf("Error signing authentication token: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	logAuthEvent(ctrl, r, cce.AuthActionRefresh, cce.AuthOutcomeSuccess, user.Username, "")
+
+	writeTokens(w, access, "")
+}
+
+// logout revokes the session of the access token, so that neither its access
+// nor its refresh tokens are valid anymore.
+func logout(w http.ResponseWriter, r *http.Request) {
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Sessions of the identity provider are not managed by the controller
+	claims, ok := r.Context().Value(contextKey("claims")).(*jose.Claims)
+	if !ok {
+		http.Error(w, "Only sessions started with POST /auth can be logged out", http.StatusBadRequest)
+		return
+	}
 
-	// Wrap auth token in JSON
+	if err := ctrl.TokenService.Revoke(claims); err != nil {
+		log.Errf("Error revoking session of user '%s': %v", claims.Subject, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	log.Debugf("User '%s' logged out", claims.Subject)
+	logAuthEvent(ctrl, r, cce.AuthActionLogout, cce.AuthOutcomeSuccess, claims.Subject, "")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listTokenKeys lists the token signing keys.
+func listTokenKeys(w http.ResponseWriter, r *http.Request) {
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	list := swagger.TokenKeyList{Keys: []swagger.TokenKey{}}
+	keys := ctrl.TokenService.Keys.Keys()
+	for i := range keys {
+		list.Keys = append(list.Keys, toTokenKey(&keys[i]))
+	}
+
+	writeTokenKeyResponse(w, http.StatusOK, list)
+}
+
+// rotateTokenKey generates a new token signing key. Tokens signed with the
+// previous keys stay valid until they expire.
+func rotateTokenKey(w http.ResponseWriter, r *http.Request) {
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	k, err := ctrl.TokenService.RotateKey()
+	if err != nil {
+		log.Errf("Error rotating token signing key: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	log.Infof("User '%s' rotated the token signing key to %s", cce.ActorFromContext(r.Context()), k.ID)
+
+	writeTokenKeyResponse(w, http.StatusCreated, toTokenKey(k))
+}
+
+func toTokenKey(k *jose.Key) swagger.TokenKey {
+	key := swagger.TokenKey{
+		ID:      k.ID,
+		Created: k.Created.UTC().Format(time.RFC3339),
+	}
+	if !k.Retired.IsZero() {
+		key.Retired = k.Retired.UTC().Format(time.RFC3339)
+	}
+
+	return key
+}
+
+func writeTokenKeyResponse(w http.ResponseWriter, status int, v interface{}) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		log.Errf("Error marshaling token keys: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err = w.Write(bytes); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// writeTokens responds with status code 201 and the JSON-encoded tokens. The
+// refresh token is omitted if empty.
+func writeTokens(w http.ResponseWriter, access, refresh string) {
+	// Wrap auth tokens in JSON
 	bytes, err := json.Marshal(
 		struct {
-			Token string `json:"token"`
+			Token        string `json:"token"`
+			RefreshToken string `json:"refresh_token,omitempty"`
 		}{
-			token,
+			access,
+			refresh,
 		})
 	if err != nil {
 		log.Errf("Error marshaling authentication token: %v", err)
@@ -59,17 +313,18 @@ func authenticate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Respond with status code 201
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 
-	// Return JSON-encoded auth token
-	w.Header().Set("Content-Type", "application/json")
+	// Return JSON-encoded auth tokens
 	if _, err = w.Write(bytes); err != nil {
 		log.Errf("Error writing response: %v", err)
 	}
 }
 
 // requireAuthHandler is a handler that only allows HTTP requests with a valid
-// JSON Web Token issued by the Controller Token Authentication service.
+// JSON Web Token issued by the Controller Token Authentication service whose
+// role is permitted to use the route, see requiredRole.
 func requireAuthHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
@@ -89,12 +344,106 @@ func requireAuthHandler(next http.Handler) http.Handler {
 		}
 
 		// Validate the auth token
-		err := ctrl.TokenService.Validate(bearer[1])
+		subject, role, claims, err := validateToken(r, ctrl, bearer[1])
 		if err != nil {
+			log.Debugf("Invalid auth token: %v", err)
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		// Check the role of the subject is permitted to use the route
+		if !cce.RoleIncludes(role, requiredRole(r)) {
+			log.Debugf("User '%s' with role '%s' denied %s %s", subject, role, r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		// Record the subject as the actor of any changes
+		ctx := r.Context()
+		if claims != nil {
+			ctx = context.WithValue(ctx, contextKey("claims"), claims)
+		}
+		next.ServeHTTP(w, r.WithContext(cce.WithActor(ctx, subject)))
 	})
 }
+
+// validateToken validates an API key, a token issued by the token service or
+// else by the identity provider, if any, and returns its subject and role.
+// The claims are only returned for tokens of the token service.
+func validateToken(
+	r *http.Request,
+	ctrl *cce.Controller,
+	token string,
+) (subject, role string, claims *jose.Claims, err error) {
+	if id, secret, ok := cce.ParseAPIKey(token); ok {
+		subject, role, err = validateAPIKey(r, ctrl, id, secret)
+		return subject, role, nil, err
+	}
+
+	claims, err = ctrl.TokenService.Validate(token)
+	if err == nil {
+		return claims.Subject, claims.Role, claims, nil
+	}
+	if ctrl.IdentityProvider == nil {
+		return "", "", nil, err
+	}
+
+	subject, role, idpErr := ctrl.IdentityProvider.Verify(r.Context(), token)
+	if idpErr != nil {
+		return "", "", nil, errors.Errorf("%v; identity provider: %v", err, idpErr)
+	}
+
+	return subject, role, nil, nil
+}
+
+// validateAPIKey validates an API key and returns its actor and role. The
+// role is empty if the request is outside the scopes of the key.
+func validateAPIKey(r *http.Request, ctrl *cce.Controller, id, secret string) (subject, role string, err error) {
+	persisted, err := ctrl.PersistenceService.Read(r.Context(), id, &cce.APIKey{})
+	if err != nil {
+		return "", "", errors.Wrap(err, "unable to read API key")
+	}
+	if persisted == nil {
+		return "", "", errors.Errorf("API key %s not found", id)
+	}
+	key := persisted.(*cce.APIKey)
+
+	if !key.CheckSecret(secret) {
+		return "", "", errors.Errorf("invalid secret of API key %s", id)
+	}
+	if key.Expired(time.Now()) {
+		return "", "", errors.Errorf("API key %s has expired", id)
+	}
+
+	subject = cce.APIKeyActor(key.ID)
+	if !key.InScope(r.URL.Path) {
+		log.Debugf("Path %s is outside the scopes of API key %s", r.URL.Path, key.ID)
+		return subject, "", nil
+	}
+	log.Debugf("Authenticated API key %s (%s) for %s %s", key.ID, key.Name, r.Method, r.URL.Path)
+
+	return subject, key.Role, nil
+}
+
+// requiredRole returns the role required for a request: the admin paths
+// require admins, GET requests and the session endpoints under /auth
+// read-only users and all other requests operators.
+func requiredRole(r *http.Request) string {
+	path := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			path = tmpl
+		}
+	}
+
+	for _, p := range adminPaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return cce.RoleAdmin
+		}
+	}
+	if r.Method == http.MethodGet || strings.HasPrefix(path, "/auth/") {
+		return cce.RoleReadOnly
+	}
+
+	return cce.RoleOperator
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 
 	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/grpc/node"
 )
 
 func handleCreateNodesApps(ctx context.Context, ps cce.PersistenceService, e cce.Persistable) error {
@@ -19,17 +20,14 @@ func handleCreateNodesApps(ctx context.Context, ps cce.PersistenceService, e cce
 	log.Debugf("Loaded app %s\n%+v", app.GetID(), app)
 
 	ctrl := getController(ctx)
-	nodePort := ctrl.EVAPort
-	if nodePort == "" {
-		nodePort = defaultEVAPort
-	}
-	nodeCC, err := connectNode(ctx, ps, e.(*cce.NodeApp), nodePort, ctrl.EdgeNodeCreds)
+	nodeCC, err := connectNode(ctx, ps, e.(*cce.NodeApp), node.EVA)
 	if err != nil {
 		return fmt.Errorf("Error connecting to node: %v", err)
 	}
 	defer disconnectNode(nodeCC)
 
 	if err := nodeCC.AppDeploySvcCli.Deploy(ctx, app.(*cce.App)); err != nil {
+		notifyDeployFailure(ctx, ctrl, e.(*cce.NodeApp), err)
 		return err
 	}
 
@@ -40,6 +38,7 @@ func handleCreateNodesApps(ctx context.Context, ps cce.PersistenceService, e cce
 			e.(*cce.NodeApp).GetNodeID(),
 			toK8SApp(app.(*cce.App)))
 		if err != nil {
+			notifyDeployFailure(ctx, ctrl, e.(*cce.NodeApp), err)
 			return err
 		}
 	}
@@ -60,12 +59,7 @@ func handleCreateNodesDNSConfigs(
 	}
 	log.Debugf("Loaded DNS Config %s\n%+v", dnsConfig.GetID(), dnsConfig)
 
-	ctrl := getController(ctx)
-	nodePort := ctrl.ELAPort
-	if nodePort == "" {
-		nodePort = defaultELAPort
-	}
-	nodeCC, err := connectNode(ctx, ps, e.(*cce.NodeDNSConfig), nodePort, ctrl.EdgeNodeCreds)
+	nodeCC, err := connectNode(ctx, ps, e.(*cce.NodeDNSConfig), node.ELA)
 	if err != nil {
 		return err
 	}
@@ -87,12 +81,7 @@ func handleCreateNodesDNSConfigsWithAliases(
 	dnsConfig cce.Persistable,
 	dnsAliases []cce.Persistable,
 ) error {
-	ctrl := getController(ctx)
-	nodePort := ctrl.ELAPort
-	if nodePort == "" {
-		nodePort = defaultELAPort
-	}
-	nodeCC, err := connectNode(ctx, ps, nodeDNS.(*cce.NodeDNSConfig), nodePort, ctrl.EdgeNodeCreds)
+	nodeCC, err := connectNode(ctx, ps, nodeDNS.(*cce.NodeDNSConfig), node.ELA)
 	if err != nil {
 		return err
 	}
@@ -124,3 +113,11 @@ func handleCreateNodesDNSConfigsWithAliases(
 
 	return nil
 }
+
+func notifyDeployFailure(ctx context.Context, ctrl *cce.Controller, nodeApp *cce.NodeApp, err error) {
+	ctrl.Notify(ctx, cce.NotificationAppDeployFailed, &cce.AppDeployFailure{
+		NodeID: nodeApp.NodeID,
+		AppID:  nodeApp.AppID,
+		Error:  err.Error(),
+	})
+}
@@ -111,3 +111,33 @@ func checkDBCreateNodesDNSConfigs(
 
 	return 0, nil
 }
+
+func checkDBCreateNodes(
+	ctx context.Context,
+	ps cce.PersistenceService,
+	e cce.Persistable,
+) (statusCode int, err error) {
+	var es []cce.Persistable
+
+	if es, err = ps.Filter(
+		ctx,
+		&cce.Node{},
+		[]cce.Filter{
+			{
+				Field: "serial",
+				Value: e.(*cce.Node).Serial,
+			},
+		},
+	); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if len(es) != 0 {
+		return http.StatusUnprocessableEntity, fmt.Errorf(
+			"duplicate record in %s detected for serial %s",
+			e.(*cce.Node).GetTableName(),
+			e.(*cce.Node).Serial)
+	}
+
+	return 0, nil
+}
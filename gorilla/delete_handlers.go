@@ -7,6 +7,7 @@ import (
 	"context"
 
 	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/grpc/node"
 	"github.com/pkg/errors"
 )
 
@@ -20,11 +21,7 @@ func handleDeleteNodesApps(ctx context.Context, ps cce.PersistenceService, e cce
 	}
 
 	ctrl := getController(ctx)
-	nodePort := ctrl.EVAPort
-	if nodePort == "" {
-		nodePort = defaultEVAPort
-	}
-	nodeCC, err := connectNode(ctx, ps, e.(*cce.NodeApp), nodePort, ctrl.EdgeNodeCreds)
+	nodeCC, err := connectNode(ctx, ps, e.(*cce.NodeApp), node.EVA)
 	if err != nil {
 		return err
 	}
@@ -58,12 +55,7 @@ func handleDeleteNodesDNSConfigs(
 	}
 	log.Debugf("Loaded DNS Config %s\n%+v", dnsConfig.GetID(), dnsConfig)
 
-	ctrl := getController(ctx)
-	nodePort := ctrl.ELAPort
-	if nodePort == "" {
-		nodePort = defaultELAPort
-	}
-	nodeCC, err := connectNode(ctx, ps, e.(*cce.NodeDNSConfig), nodePort, ctrl.EdgeNodeCreds)
+	nodeCC, err := connectNode(ctx, ps, e.(*cce.NodeDNSConfig), node.ELA)
 	if err != nil {
 		return err
 	}
@@ -84,12 +76,7 @@ func handleDeleteNodesDNSConfigsWithAliases(
 	dnsConfig cce.Persistable,
 	dnsAliases []cce.Persistable,
 ) error {
-	ctrl := getController(ctx)
-	nodePort := ctrl.ELAPort
-	if nodePort == "" {
-		nodePort = defaultELAPort
-	}
-	nodeCC, err := connectNode(ctx, ps, nodeDNS.(*cce.NodeDNSConfig), nodePort, ctrl.EdgeNodeCreds)
+	nodeCC, err := connectNode(ctx, ps, nodeDNS.(*cce.NodeDNSConfig), node.ELA)
 	if err != nil {
 		return err
 	}
@@ -122,12 +109,12 @@ func handleDeleteNodesDNSConfigsWithAliases(
 	return nil
 }
 
-func handleForcedDeleteNodesDNSConfigs(ctx context.Context, ctrl *cce.Controller, nodeID string) error {
+func handleForcedDeleteNodesDNSConfigs(ctx context.Context, ps cce.PersistenceService, nodeID string) error {
 	var persistedNode []cce.Persistable
 	var err error
 
 	// Filter nodes_dns_config to get the ID
-	if persistedNode, err = ctrl.PersistenceService.Filter(
+	if persistedNode, err = ps.Filter(
 		ctx,
 		&cce.NodeDNSConfig{},
 		[]cce.Filter{
@@ -147,7 +134,7 @@ func handleForcedDeleteNodesDNSConfigs(ctx context.Context, ctrl *cce.Controller
 
 	log.Errf("Deleting dns: %v", persistedNode[0].(*cce.NodeDNSConfig).DNSConfigID)
 
-	persistedConfig, err := ctrl.PersistenceService.Read(
+	persistedConfig, err := ps.Read(
 		ctx,
 		persistedNode[0].(*cce.NodeDNSConfig).DNSConfigID,
 		&cce.DNSConfig{},
@@ -158,7 +145,7 @@ func handleForcedDeleteNodesDNSConfigs(ctx context.Context, ctrl *cce.Controller
 	}
 
 	// Fetch the DNS aliases from persistence
-	persistedAliases, err := ctrl.PersistenceService.Filter(
+	persistedAliases, err := ps.Filter(
 		ctx,
 		&cce.DNSConfigAppAlias{},
 		[]cce.Filter{
@@ -170,7 +157,7 @@ func handleForcedDeleteNodesDNSConfigs(ctx context.Context, ctrl *cce.Controller
 		return err
 	}
 
-	_, err = ctrl.PersistenceService.Delete(
+	_, err = ps.Delete(
 		ctx,
 		persistedNode[0].(*cce.NodeDNSConfig).DNSConfigID,
 		&cce.NodeDNSConfig{},
@@ -181,7 +168,7 @@ func handleForcedDeleteNodesDNSConfigs(ctx context.Context, ctrl *cce.Controller
 	}
 
 	// Delete the association from persistence
-	if _, err = ctrl.PersistenceService.Delete(
+	if _, err = ps.Delete(
 		ctx, persistedNode[0].GetID(), persistedNode[0],
 	); err != nil {
 		log.Errf("Error deleting association: %v", err)
@@ -190,14 +177,14 @@ func handleForcedDeleteNodesDNSConfigs(ctx context.Context, ctrl *cce.Controller
 
 	// Delete the aliases from persistence
 	for _, alias := range persistedAliases {
-		if _, err = ctrl.PersistenceService.Delete(ctx, alias.GetID(), alias); err != nil {
+		if _, err = ps.Delete(ctx, alias.GetID(), alias); err != nil {
 			log.Errf("Error deleting aliases: %v", err)
 			return err
 		}
 	}
 
 	// Delete the config from persistence
-	if _, err = ctrl.PersistenceService.Delete(ctx, persistedConfig.GetID(), persistedConfig); err != nil {
+	if _, err = ps.Delete(ctx, persistedConfig.GetID(), persistedConfig); err != nil {
 		log.Errf("Error deleting DNS config: %v", err)
 		return err
 	}
@@ -205,12 +192,12 @@ func handleForcedDeleteNodesDNSConfigs(ctx context.Context, ctrl *cce.Controller
 	return nil
 }
 
-func handleForceDeleteNodesApps(ctx context.Context, ctrl *cce.Controller, nodeID string) error {
+func handleForceDeleteNodesApps(ctx context.Context, ps cce.PersistenceService, nodeID string) error {
 	var persistedNode []cce.Persistable
 	var err error
 
 	// Filter node apps
-	if persistedNode, err = ctrl.PersistenceService.Filter(
+	if persistedNode, err = ps.Filter(
 		ctx,
 		&cce.NodeApp{},
 		[]cce.Filter{
@@ -226,7 +213,7 @@ func handleForceDeleteNodesApps(ctx context.Context, ctrl *cce.Controller, nodeI
 
 	for _, app := range persistedNode {
 		// Filter nodes_apps_traffic_policies to get the ID
-		nodeAppPolicies, err := ctrl.PersistenceService.Filter(
+		nodeAppPolicies, err := ps.Filter(
 			ctx,
 			&cce.NodeAppTrafficPolicy{},
 			[]cce.Filter{
@@ -243,7 +230,7 @@ func handleForceDeleteNodesApps(ctx context.Context, ctrl *cce.Controller, nodeI
 		// Delete policies
 		var ok bool
 		for _, policy := range nodeAppPolicies {
-			ok, err = ctrl.PersistenceService.Delete(ctx, policy.GetID(), &cce.NodeAppTrafficPolicy{})
+			ok, err = ps.Delete(ctx, policy.GetID(), &cce.NodeAppTrafficPolicy{})
 			if err != nil {
 				log.Errf("Error deleting from nodes_apps_traffic_policies: %v", err)
 				return err
@@ -256,7 +243,7 @@ func handleForceDeleteNodesApps(ctx context.Context, ctrl *cce.Controller, nodeI
 
 		log.Infof("Deleting app: %v", app.GetID())
 		// Delete app info on the controller
-		_, err = ctrl.PersistenceService.Delete(ctx, app.GetID(), &cce.NodeApp{})
+		_, err = ps.Delete(ctx, app.GetID(), &cce.NodeApp{})
 		if err != nil {
 			log.Errf("Error deleting app: %v", err)
 			return err
@@ -266,12 +253,12 @@ func handleForceDeleteNodesApps(ctx context.Context, ctrl *cce.Controller, nodeI
 	return nil
 }
 
-func handleForceDeleteNodesInterfacePolicy(ctx context.Context, ctrl *cce.Controller, nodeID string) error {
+func handleForceDeleteNodesInterfacePolicy(ctx context.Context, ps cce.PersistenceService, nodeID string) error {
 	var persistedPolicy []cce.Persistable
 	var err error
 
 	// Filter node apps
-	if persistedPolicy, err = ctrl.PersistenceService.Filter(
+	if persistedPolicy, err = ps.Filter(
 		ctx,
 		&cce.NodeInterfaceTrafficPolicy{},
 		[]cce.Filter{
@@ -286,7 +273,7 @@ func handleForceDeleteNodesInterfacePolicy(ctx context.Context, ctrl *cce.Contro
 	}
 
 	for _, policy := range persistedPolicy {
-		ok, err := ctrl.PersistenceService.Delete(ctx, policy.GetID(), policy)
+		ok, err := ps.Delete(ctx, policy.GetID(), policy)
 		if err != nil {
 			log.Errf("Error deleting from nodes_interfaces_traffic_policies: %v", err)
 			return err
@@ -300,15 +287,21 @@ func handleForceDeleteNodesInterfacePolicy(ctx context.Context, ctrl *cce.Contro
 	return nil
 }
 
-func handleDeleteNode(ctx context.Context, ctrl *cce.Controller, nodeID string) error {
+// errPreconditionFailed is returned when an entity was not deleted because its
+// revision did not match the If-Match header of the request.
+var errPreconditionFailed = errors.New("node revision does not match If-Match")
+
+// handleDeleteNode deletes a node and revokes its certificate. If rev is not
+// zero the node is only deleted at that revision.
+func handleDeleteNode(ctx context.Context, ps cce.PersistenceService, nodeID string, rev int64) error {
 	// Check that we can delete the entity
-	if statusCode, err := checkDBDeleteNodes(ctx, ctrl.PersistenceService, nodeID); err != nil {
+	if statusCode, err := checkDBDeleteNodes(ctx, ps, nodeID); err != nil {
 		log.Errf("Error running DB logic: %v, %v", err, statusCode)
 		return err
 	}
 
 	// Fetch the entity from persistence and check if it's there
-	persisted, err := ctrl.PersistenceService.Read(ctx, nodeID, &cce.Node{})
+	persisted, err := ps.Read(ctx, nodeID, &cce.Node{})
 	if err != nil {
 		log.Errf("Error reading entity: %v", err)
 		return err
@@ -319,14 +312,25 @@ func handleDeleteNode(ctx context.Context, ctrl *cce.Controller, nodeID string)
 	}
 
 	var ok bool
-	ok, err = ctrl.PersistenceService.Delete(ctx, nodeID, &cce.Node{})
+	if rev != 0 {
+		ok, err = ps.DeleteIfMatch(ctx, nodeID, &cce.Node{}, rev)
+	} else {
+		ok, err = ps.Delete(ctx, nodeID, &cce.Node{})
+	}
 	if err != nil {
 		return err
 	}
 
 	// we just fetched the entity, so if !ok then something went wrong
 	if !ok {
+		if rev != 0 {
+			return errPreconditionFailed
+		}
 		return errors.New("Fetched entity could not be used")
 	}
-	return nil
+
+	// Revoke the certificate of the node so that it can no longer connect
+	_, err = (&cce.CertificateRevocations{PersistenceService: ps}).
+		RevokeNodeCredentials(ctx, nodeID, cce.RevocationReasonCessationOfOperation)
+	return errors.Wrap(err, "error revoking node certificate")
 }
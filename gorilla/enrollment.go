@@ -0,0 +1,349 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package gorilla
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/swagger"
+	"github.com/open-ness/edgecontroller/uuid"
+	"github.com/pkg/errors"
+)
+
+// defaultEnrollmentTokenValidity is how long an enrollment token is valid if
+// no expiry is requested.
+const defaultEnrollmentTokenValidity = 24 * time.Hour
+
+// Used for GET /enrollment_tokens endpoint
+func (g *Gorilla) swagGETEnrollmentTokens(w http.ResponseWriter, r *http.Request) {
+	// Fetch the requested page of enrollment tokens from persistence
+	persisted, nextCursor, ok := readPage(w, r, &cce.EnrollmentToken{})
+	if !ok {
+		return
+	}
+
+	// Construct the response object
+	tokens := swagger.EnrollmentTokenList{
+		EnrollmentTokens: []swagger.EnrollmentTokenSummary{},
+		NextCursor:       nextCursor,
+	}
+	for _, e := range persisted {
+		tokens.EnrollmentTokens = append(tokens.EnrollmentTokens, toEnrollmentTokenSummary(e.(*cce.EnrollmentToken)))
+	}
+
+	// Marshal the response object to JSON
+	tokensJSON, err := json.Marshal(tokens)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(tokensJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// Used for POST /enrollment_tokens endpoint
+//
+// The response is the only time the token is returned.
+func (g *Gorilla) swagPOSTEnrollmentTokens(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence and the payload
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	body := r.Context().Value(contextKey("body")).([]byte)
+
+	// Unmarshal the payload
+	requested := swagger.EnrollmentTokenSummary{}
+	if err := json.Unmarshal(body, &requested); err != nil {
+		log.Errf("Error unmarshaling json: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if requested.ID != "" || requested.NodeID != "" || requested.CreatedBy != "" {
+		writeUserResponse(w, http.StatusBadRequest,
+			"Validation failed: id, node_id and created_by cannot be specified in POST request")
+		return
+	}
+	if requested.Expiry == "" {
+		requested.Expiry = time.Now().Add(defaultEnrollmentTokenValidity).UTC().Format(time.RFC3339)
+	}
+
+	// Convert it to a persistable object and generate the token
+	token := &cce.EnrollmentToken{
+		ID:        uuid.New(),
+		NodeID:    uuid.New(),
+		Name:      requested.Name,
+		Location:  requested.Location,
+		Expiry:    requested.Expiry,
+		CreatedBy: cce.ActorFromContext(r.Context()),
+	}
+	secret, err := token.GenerateSecret()
+	if err != nil {
+		log.Errf("Error generating enrollment token: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Validate the object
+	if err = token.Validate(); err != nil {
+		log.Debugf("Validation failed for %v: %v", token, err)
+		writeUserResponse(w, http.StatusBadRequest, fmt.Sprintf("Validation failed: %v", err))
+		return
+	}
+	if token.Expired(time.Now()) {
+		writeUserResponse(w, http.StatusBadRequest, "Validation failed: expiry must be in the future")
+		return
+	}
+
+	// Persist the object
+	if err = ctrl.PersistenceService.Create(r.Context(), token); err != nil {
+		log.Errf("Error creating enrollment token: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	log.Infof("Enrollment token %s for node %s created by '%s'", token.ID, token.NodeID, token.CreatedBy)
+
+	// Marshal the response object to JSON
+	tokenJSON, err := json.Marshal(swagger.EnrollmentTokenDetail{
+		EnrollmentTokenSummary: toEnrollmentTokenSummary(token),
+		Token:                  secret,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if _, err = w.Write(tokenJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// Used for DELETE /enrollment_tokens/{token_id} endpoint
+func (g *Gorilla) swagDELETEEnrollmentTokenByID(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Fetch the entity from persistence and check if it's there
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["token_id"], &cce.EnrollmentToken{})
+	if err != nil {
+		log.Errf("Error reading entity: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if persisted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	log.Infof("Enrollment token %s revoked by '%s'", persisted.GetID(), cce.ActorFromContext(r.Context()))
+
+	deleteEntity(w, r, persisted.GetID(), &cce.EnrollmentToken{}, rev)
+}
+
+func toEnrollmentTokenSummary(t *cce.EnrollmentToken) swagger.EnrollmentTokenSummary {
+	return swagger.EnrollmentTokenSummary{
+		ID:        t.ID,
+		NodeID:    t.NodeID,
+		Name:      t.Name,
+		Location:  t.Location,
+		Expiry:    t.Expiry,
+		CreatedBy: t.CreatedBy,
+	}
+}
+
+// Used for GET /enrollments endpoint
+func (g *Gorilla) swagGETEnrollments(w http.ResponseWriter, r *http.Request) {
+	// Fetch the requested page of enrollments from persistence
+	persisted, nextCursor, ok := readPage(w, r, &cce.Enrollment{})
+	if !ok {
+		return
+	}
+
+	// Construct the response object
+	enrollments := swagger.EnrollmentList{Enrollments: []swagger.Enrollment{}, NextCursor: nextCursor}
+	for _, e := range persisted {
+		enrollments.Enrollments = append(enrollments.Enrollments, toEnrollment(e.(*cce.Enrollment)))
+	}
+
+	// Marshal the response object to JSON
+	enrollmentsJSON, err := json.Marshal(enrollments)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(enrollmentsJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// Used for GET /enrollments/{enrollment_id} endpoint
+func (g *Gorilla) swagGETEnrollmentByID(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Fetch the entity from persistence and check if it's there
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["enrollment_id"], &cce.Enrollment{})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if persisted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// Marshal the response object to JSON
+	enrollmentJSON, err := json.Marshal(toEnrollment(persisted.(*cce.Enrollment)))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	setETag(w, rev)
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(enrollmentJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// Used for POST /enrollments/{enrollment_id}/approve endpoint
+//
+// Creates the node of the enrollment, which is issued credentials when it
+// next requests them.
+func (g *Gorilla) swagPOSTEnrollmentApprove(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence and the payload
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	body := r.Context().Value(contextKey("body")).([]byte)
+
+	// Unmarshal the payload
+	requested := swagger.EnrollmentApproval{}
+	if err := json.Unmarshal(body, &requested); err != nil {
+		log.Errf("Error unmarshaling json: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if requested.Name == "" || requested.Location == "" {
+		writeUserResponse(w, http.StatusBadRequest, "Validation failed: name and location cannot be empty")
+		return
+	}
+
+	enrollmentID := mux.Vars(r)["enrollment_id"]
+	var node *cce.Node
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) error {
+		var txErr error
+		node, txErr = (&cce.NodeEnrollments{PersistenceService: tx}).
+			Approve(r.Context(), enrollmentID, requested.Name, requested.Location)
+		return txErr
+	})
+	switch {
+	case errors.Cause(err) == cce.ErrSerialInUse:
+		writeUserResponse(w, http.StatusConflict, "Serial of the enrollment is in use by another node")
+		return
+	case err != nil:
+		log.Errf("Error approving enrollment %s: %v", enrollmentID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	case node == nil:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	log.Infof("Enrollment %s of node %s with serial %s approved by '%s'",
+		enrollmentID, node.ID, node.Serial, cce.ActorFromContext(r.Context()))
+
+	// Marshal the response object to JSON
+	nodeJSON, err := json.Marshal(swagger.NodeDetail{
+		NodeSummary: swagger.NodeSummary{
+			ID:       node.ID,
+			Name:     node.Name,
+			Location: node.Location,
+			Serial:   node.Serial,
+			Status:   cce.NodeStatusOffline,
+		},
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if _, err = w.Write(nodeJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// Used for POST /enrollments/{enrollment_id}/reject endpoint
+//
+// The node of a rejected enrollment is refused until the enrollment is
+// deleted.
+func (g *Gorilla) swagPOSTEnrollmentReject(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	enrollmentID := mux.Vars(r)["enrollment_id"]
+	enrollment, err := (&cce.NodeEnrollments{PersistenceService: ctrl.PersistenceService}).
+		Reject(r.Context(), enrollmentID)
+	if err != nil {
+		log.Errf("Error rejecting enrollment %s: %v", enrollmentID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if enrollment == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	log.Infof("Enrollment %s of serial %s rejected by '%s'",
+		enrollmentID, enrollment.Serial, cce.ActorFromContext(r.Context()))
+
+	// Marshal the response object to JSON
+	enrollmentJSON, err := json.Marshal(toEnrollment(enrollment))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(enrollmentJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// Used for DELETE /enrollments/{enrollment_id} endpoint
+//
+// Deleting a rejected enrollment lets the node request to enroll again.
+func (g *Gorilla) swagDELETEEnrollmentByID(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Fetch the entity from persistence and check if it's there
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["enrollment_id"], &cce.Enrollment{})
+	if err != nil {
+		log.Errf("Error reading entity: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if persisted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	deleteEntity(w, r, persisted.GetID(), &cce.Enrollment{}, rev)
+}
+
+func toEnrollment(e *cce.Enrollment) swagger.Enrollment {
+	return swagger.Enrollment{
+		ID:          e.ID,
+		Serial:      e.Serial,
+		PeerIP:      e.PeerIP,
+		Subject:     e.Subject,
+		Status:      e.Status,
+		RequestedAt: e.RequestedAt,
+	}
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package gorilla
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	cce "github.com/open-ness/edgecontroller"
+)
+
+// setETag sets the ETag header of a response to the revision of an entity.
+func setETag(w http.ResponseWriter, rev int64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(rev, 10)))
+}
+
+// ifMatch parses the If-Match header of a request. The request is not
+// conditional if the header is missing or "*". An entity tag that was not
+// issued by setETag is returned as revision 0, which never matches.
+func ifMatch(r *http.Request) (rev int64, conditional bool) {
+	etag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if etag == "" || etag == "*" {
+		return 0, false
+	}
+
+	unquoted, err := strconv.Unquote(etag)
+	if err != nil {
+		return 0, true
+	}
+	if rev, err = strconv.ParseInt(unquoted, 10, 64); err != nil {
+		return 0, true
+	}
+
+	return rev, true
+}
+
+// writePreconditionFailed writes a 412 response for a request whose If-Match
+// header did not match the current revision of the entity.
+func writePreconditionFailed(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusPreconditionFailed)
+	if _, err := w.Write([]byte("If-Match does not match the current revision")); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// maxUpdateAttempts is the number of times an update without If-Match is
+// attempted when the entity is concurrently updated.
+const maxUpdateAttempts = 3
+
+// updateEntity persists an updated entity and sets the ETag of its new
+// revision. If the request has an If-Match header the entity is only updated
+// at the matching revision, otherwise at its current revision. If false is
+// returned the error response has already been written.
+func updateEntity(w http.ResponseWriter, r *http.Request, e cce.Persistable) bool {
+	ctrl := getController(r.Context())
+
+	rev, conditional := ifMatch(r)
+	for attempt := 1; ; attempt++ {
+		if !conditional {
+			current, currentRev, err := ctrl.PersistenceService.ReadWithRevision(r.Context(), e.GetID(), e)
+			if err != nil {
+				log.Errf("Error reading entity: %v", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return false
+			}
+			if current == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return false
+			}
+			rev = currentRev
+		}
+
+		ok, err := ctrl.PersistenceService.UpdateIfMatch(r.Context(), e, rev)
+		if err != nil {
+			log.Errf("Error updating entity: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return false
+		}
+		if ok {
+			setETag(w, rev+1)
+			return true
+		}
+
+		switch {
+		case conditional:
+			writePreconditionFailed(w)
+			return false
+		case attempt == maxUpdateAttempts:
+			log.Errf("Entity %s was updated concurrently %d times", e.GetID(), attempt)
+			w.WriteHeader(http.StatusConflict)
+			return false
+		}
+	}
+}
+
+// deleteEntity deletes an entity that was just read at revision rev. If the
+// request has an If-Match header the entity is only deleted at the matching
+// revision. If false is returned the error response has already been written.
+func deleteEntity(w http.ResponseWriter, r *http.Request, id string, zv cce.Persistable, rev int64) bool {
+	ctrl := getController(r.Context())
+
+	var (
+		ok  bool
+		err error
+	)
+	ifRev, conditional := ifMatch(r)
+	switch {
+	case !conditional:
+		ok, err = ctrl.PersistenceService.Delete(r.Context(), id, zv)
+	case ifRev == rev:
+		ok, err = ctrl.PersistenceService.DeleteIfMatch(r.Context(), id, zv, rev)
+	default:
+		writePreconditionFailed(w)
+		return false
+	}
+	if err != nil {
+		log.Errf("Error deleting entity: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+
+	// we just fetched the entity, so if !ok then it was either modified in
+	// the meantime or something went wrong
+	if !ok {
+		if conditional {
+			writePreconditionFailed(w)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package gorilla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/uuid"
+)
+
+// eventsKeepAlive is the interval of the comments sent on an idle event
+// stream to keep proxies from closing the connection.
+const eventsKeepAlive = 30 * time.Second
+
+// Used for GET /events endpoint
+//
+// The events are streamed as Server-Sent Events with the sequence number as
+// the event ID, the event type (create, update, delete or reset) as the event
+// name and the JSON encoded cce.Event as the data. The stream can be
+// filtered with the resource (repeated or comma-separated) and node_id query
+// parameters. A client resumes a stream by sending the ID of the last event
+// it received in the Last-Event-ID header or the last_event_id query
+// parameter.
+func (g *Gorilla) swagGETEvents(w http.ResponseWriter, r *http.Request) {
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	if ctrl.EventHub == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	filter, resume, after, err := parseEventsQuery(r)
+	if err != nil {
+		writeInvalidQuery(w, r, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Err("Error streaming events: response writer does not support flushing")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	sub := ctrl.EventHub.Subscribe(filter, resume, after)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err = fmt.Fprint(w, ": stream opened\n\n"); err != nil {
+		log.Errf("Error writing response: %v", err)
+		return
+	}
+	flusher.Flush()
+
+	for {
+		ctx, cancel := context.WithTimeout(r.Context(), eventsKeepAlive)
+		es, err := sub.Next(ctx)
+		cancel()
+
+		switch {
+		case err == context.DeadlineExceeded && r.Context().Err() == nil:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case err != nil:
+			// The client went away or the controller is shutting down
+			return
+		default:
+			err = writeEvents(w, es)
+		}
+		if err != nil {
+			log.Debugf("Error writing events: %v", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func parseEventsQuery(r *http.Request) (filter cce.EventFilter, resume bool, after uint64, err error) {
+	params := r.URL.Query()
+
+	for _, param := range params["resource"] {
+		for _, resource := range strings.Split(param, ",") {
+			if !cce.IsEventResource(resource) {
+				return filter, false, 0, fmt.Errorf("resource %q is invalid", resource)
+			}
+			filter.Resources = append(filter.Resources, resource)
+		}
+	}
+
+	if filter.NodeID = params.Get("node_id"); filter.NodeID != "" && !uuid.IsValid(filter.NodeID) {
+		return filter, false, 0, fmt.Errorf("node_id %q is not a valid uuid", filter.NodeID)
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = params.Get("last_event_id")
+	}
+	if lastEventID != "" {
+		if after, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			return filter, false, 0, fmt.Errorf("last event ID %q is invalid", lastEventID)
+		}
+		resume = true
+	}
+
+	return filter, resume, after, nil
+}
+
+func writeEvents(w http.ResponseWriter, es []cce.Event) error {
+	for i := range es {
+		data, err := json.Marshal(&es[i])
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", es[i].Seq, es[i].Type, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publishNodeAppCommand publishes an update event for a lifecycle command
+// sent to a node app. The entity of the event carries the command.
+func publishNodeAppCommand(ctrl *cce.Controller, req *cce.NodeAppReq) {
+	if ctrl.EventHub == nil {
+		return
+	}
+
+	entity, err := json.Marshal(req)
+	if err != nil {
+		log.Errf("Error marshaling node app event: %v", err)
+		return
+	}
+
+	ctrl.EventHub.Publish(cce.Event{
+		Type:     cce.EventUpdate,
+		Resource: req.GetTableName(),
+		ID:       req.ID,
+		NodeID:   req.NodeID,
+		Entity:   entity,
+	})
+}
@@ -0,0 +1,290 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package gorilla
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/nfd-master"
+	"github.com/open-ness/edgecontroller/swagger"
+	"github.com/pkg/errors"
+)
+
+// Conflict policies of POST /import for entities whose ID already exists
+const (
+	importConflictFail      = "fail"
+	importConflictSkip      = "skip"
+	importConflictOverwrite = "overwrite"
+)
+
+// exportTable is a table included in exports.
+type exportTable struct {
+	zv cce.Persistable
+	// validate is whether imported entities are validated
+	validate bool
+}
+
+// exportTables returns the exported tables in the order they are imported,
+// so that every entity is imported after the entities it references. Audit
+// records and webhooks (which hold secrets) are not exported.
+func exportTables(mode cce.OrchestrationMode) []exportTable {
+	var policy cce.Persistable = &cce.TrafficPolicy{}
+	if mode == cce.OrchestrationModeKubernetesOVN {
+		policy = &cce.TrafficPolicyKubeOVN{}
+	}
+
+	return []exportTable{
+		{zv: &cce.Node{}, validate: true},
+		{zv: &cce.NodeGRPCTarget{}},
+		{zv: &cce.Credentials{}, validate: true},
+		{zv: &cce.RevokedCertificate{}, validate: true},
+		{zv: &nfd.NodeFeatureNFD{}},
+		{zv: &cce.App{}, validate: true},
+		{zv: policy, validate: true},
+		{zv: &cce.DNSConfig{}, validate: true},
+		{zv: &cce.DNSConfigAppAlias{}, validate: true},
+		{zv: &cce.NodeApp{}, validate: true},
+		{zv: &cce.NodeDNSConfig{}, validate: true},
+		{zv: &cce.NodeInterfaceTrafficPolicy{}, validate: true},
+		{zv: &cce.NodeAppTrafficPolicy{}, validate: true},
+	}
+}
+
+// Used for GET /export endpoint
+//
+// The archive (a swagger.Export) is a consistent snapshot of the exported
+// tables, read in a single transaction.
+func (g *Gorilla) swagGETExport(w http.ResponseWriter, r *http.Request) {
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	now := time.Now()
+	export := swagger.Export{
+		Version:    swagger.ExportVersion,
+		ExportedAt: cce.AuditTimestamp(now),
+		Tables:     make(map[string][]json.RawMessage),
+	}
+
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) error {
+		for _, t := range exportTables(ctrl.OrchestrationMode) {
+			es, err := tx.ReadAll(r.Context(), t.zv)
+			if err != nil {
+				return errors.Wrapf(err, "error reading %s", t.zv.GetTableName())
+			}
+
+			entities := []json.RawMessage{}
+			for _, e := range es {
+				entity, err := json.Marshal(e)
+				if err != nil {
+					return errors.Wrapf(err, "error marshaling %s", t.zv.GetTableName())
+				}
+				entities = append(entities, entity)
+			}
+			export.Tables[t.zv.GetTableName()] = entities
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errf("Error exporting: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Marshal the response object to JSON
+	exportJSON, err := json.Marshal(export)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="controller-export-%s.json"`, now.UTC().Format("20060102T150405Z")))
+	if _, err = w.Write(exportJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// Used for POST /import endpoint
+//
+// The archive is imported in a single transaction. The conflict query
+// parameter decides what happens to an entity whose ID already exists: "fail"
+// (the default) aborts the import, "skip" keeps the existing entity and
+// "overwrite" replaces it. The import fails with 409 Conflict if an entity
+// conflicts with the existing entities in any other way, e.g. references an
+// entity that does not exist. Imported configuration is not sent to the
+// nodes.
+func (g *Gorilla) swagPOSTImport(w http.ResponseWriter, r *http.Request) {
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	body := r.Context().Value(contextKey("body")).([]byte)
+
+	conflict := r.URL.Query().Get("conflict")
+	switch conflict {
+	case "":
+		conflict = importConflictFail
+	case importConflictFail, importConflictSkip, importConflictOverwrite:
+	default:
+		writeInvalidQuery(w, r, fmt.Errorf("conflict %q must be one of fail, skip or overwrite", conflict))
+		return
+	}
+
+	tables, err := parseImport(body, exportTables(ctrl.OrchestrationMode))
+	if err != nil {
+		log.Debugf("Invalid archive: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		if _, err = w.Write([]byte(fmt.Sprintf("Invalid archive: %v", err))); err != nil {
+			log.Errf("Error writing response: %v", err)
+		}
+		return
+	}
+
+	var result swagger.ImportResult
+	err = ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) error {
+		result.Created = make(map[string]int)
+		result.Updated = make(map[string]int)
+		result.Skipped = make(map[string]int)
+
+		for _, es := range tables {
+			for _, e := range es {
+				if err := importEntity(r, tx, e, conflict, &result); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if conflictErr, ok := errors.Cause(err).(*importConflictError); ok {
+		log.Debugf("Import conflict: %v", conflictErr)
+		w.WriteHeader(http.StatusConflict)
+		if _, err = w.Write([]byte(fmt.Sprintf("Import failed: %v", conflictErr))); err != nil {
+			log.Errf("Error writing response: %v", err)
+		}
+		return
+	}
+	if err != nil {
+		log.Errf("Error importing: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Marshal the response object to JSON
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(resultJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// parseImport parses and validates an archive and returns its entities in the
+// order of the tables.
+func parseImport(body []byte, tables []exportTable) ([][]cce.Persistable, error) {
+	var archive swagger.Export
+	if err := json.Unmarshal(body, &archive); err != nil {
+		return nil, err
+	}
+	if archive.Version != swagger.ExportVersion {
+		return nil, fmt.Errorf("version %d is not supported", archive.Version)
+	}
+
+	known := make(map[string]bool)
+	for _, t := range tables {
+		known[t.zv.GetTableName()] = true
+	}
+	for name := range archive.Tables {
+		if !known[name] {
+			return nil, fmt.Errorf("table %q is unknown", name)
+		}
+	}
+
+	var entities [][]cce.Persistable
+	for _, t := range tables {
+		name := t.zv.GetTableName()
+		var es []cce.Persistable
+		for i, raw := range archive.Tables[name] {
+			e := reflect.New(reflect.ValueOf(t.zv).Elem().Type()).Interface().(cce.Persistable)
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(e); err != nil {
+				return nil, fmt.Errorf("%s[%d]: %v", name, i, err)
+			}
+			if e.GetID() == "" {
+				return nil, fmt.Errorf("%s[%d]: id cannot be empty", name, i)
+			}
+			if v, ok := e.(cce.Validatable); ok && t.validate {
+				if err := v.Validate(); err != nil {
+					return nil, fmt.Errorf("%s[%d]: %v", name, i, err)
+				}
+			}
+			es = append(es, e)
+		}
+		entities = append(entities, es)
+	}
+
+	return entities, nil
+}
+
+// importConflictError is an entity of an archive that conflicts with the
+// existing entities, by its ID or a unique or foreign key.
+type importConflictError struct {
+	msg string
+}
+
+func (e *importConflictError) Error() string {
+	return e.msg
+}
+
+// importEntity creates an entity or resolves its conflict with an existing
+// entity of the same ID.
+func importEntity(
+	r *http.Request,
+	tx cce.PersistenceService,
+	e cce.Persistable,
+	conflict string,
+	result *swagger.ImportResult,
+) error {
+	table := e.GetTableName()
+
+	zv := reflect.New(reflect.ValueOf(e).Elem().Type()).Interface().(cce.Persistable)
+	persisted, err := tx.Read(r.Context(), e.GetID(), zv)
+	if err != nil {
+		return errors.Wrapf(err, "error reading %s %s", table, e.GetID())
+	}
+
+	switch {
+	case persisted == nil:
+		if err = tx.Create(r.Context(), e); err != nil {
+			return importError(err, "error creating %s %s", table, e.GetID())
+		}
+		result.Created[table]++
+	case conflict == importConflictSkip:
+		result.Skipped[table]++
+	case conflict == importConflictOverwrite:
+		if err = tx.BulkUpdate(r.Context(), []cce.Persistable{e}); err != nil {
+			return importError(err, "error updating %s %s", table, e.GetID())
+		}
+		result.Updated[table]++
+	default:
+		return &importConflictError{msg: fmt.Sprintf("%s %s already exists", table, e.GetID())}
+	}
+
+	return nil
+}
+
+// importError returns an importConflictError if err violates a unique or
+// foreign key and err wrapped with the formatted message otherwise.
+func importError(err error, format string, args ...interface{}) error {
+	if _, ok := errors.Cause(err).(*cce.ConstraintError); ok {
+		return &importConflictError{msg: fmt.Sprintf(format+": %v", append(args, err)...)}
+	}
+
+	return errors.Wrapf(err, format, args...)
+}
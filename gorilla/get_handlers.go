@@ -7,6 +7,7 @@ import (
 	"context"
 
 	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/grpc/node"
 )
 
 func handleGetNodes(
@@ -14,13 +15,7 @@ func handleGetNodes(
 	ps cce.PersistenceService,
 	e cce.Persistable,
 ) (cce.RespEntity, error) {
-	ctrl := getController(ctx)
-	nodePort := ctrl.ELAPort
-	if nodePort == "" {
-		nodePort = defaultELAPort
-	}
-
-	nodeCC, err := connectNode(ctx, ps, e.(*cce.Node), nodePort, ctrl.EdgeNodeCreds)
+	nodeCC, err := connectNode(ctx, ps, e.(*cce.Node), node.ELA)
 
 	if err != nil {
 		return nil, err
@@ -40,12 +35,7 @@ func handleGetNodes(
 
 func handleGetNodesApps(ctx context.Context, ps cce.PersistenceService, e cce.Persistable) (cce.RespEntity, error) {
 	ctrl := getController(ctx)
-	nodePort := ctrl.EVAPort
-	if nodePort == "" {
-		nodePort = defaultEVAPort
-	}
-
-	nodeCC, err := connectNode(ctx, ps, e.(*cce.NodeApp), nodePort, ctrl.EdgeNodeCreds)
+	nodeCC, err := connectNode(ctx, ps, e.(*cce.NodeApp), node.EVA)
 	if err != nil {
 		return nil, err
 	}
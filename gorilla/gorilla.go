@@ -13,6 +13,7 @@ import (
 	"github.com/gorilla/mux"
 	logger "github.com/open-ness/common/log"
 	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/grpc/node"
 )
 
 var log = logger.DefaultLogger.WithField("pkg", "gorilla")
@@ -34,11 +35,15 @@ type Gorilla struct {
 	dnsConfigsAppAliasesHandler *handler
 	nodesDNSConfigsHandler      *handler
 	nodesAppsHandler            *handler
+
+	webhooksHandler *handler
 }
 
-// NewGorilla creates a new Gorilla.
+// NewGorilla creates a new Gorilla. Its handlers connect to nodes through
+// nodeConns.
 func NewGorilla( //nolint:gocyclo
 	controller *cce.Controller,
+	nodeConns *node.Pool,
 ) *Gorilla {
 	g := &Gorilla{
 		// router
@@ -49,6 +54,7 @@ func NewGorilla( //nolint:gocyclo
 			model:    &cce.Node{},
 			reqModel: &cce.NodeReq{},
 
+			checkDBCreate: checkDBCreateNodes,
 			checkDBDelete: checkDBDeleteNodes,
 
 			handleGet:    handleGetNodes,
@@ -96,6 +102,10 @@ func NewGorilla( //nolint:gocyclo
 			handleCreate: handleCreateNodesDNSConfigs,
 			handleDelete: handleDeleteNodesDNSConfigs,
 		},
+
+		webhooksHandler: &handler{
+			model: &cce.Webhook{},
+		},
 	}
 
 	nativePoliciesHandlers := map[string]http.HandlerFunc{
@@ -127,7 +137,11 @@ func NewGorilla( //nolint:gocyclo
 	}
 
 	routes := map[string]http.HandlerFunc{
-		"POST     /auth": authenticate,
+		"POST     /auth":         authenticate,
+		"POST     /auth/refresh": refreshToken,
+		"POST     /auth/logout":  logout,
+		"GET      /auth/keys":    listTokenKeys,
+		"POST     /auth/keys":    rotateTokenKey,
 
 		"GET      /nodes":           g.swagGETNodes,
 		"POST     /nodes":           g.swagPOSTNodes,
@@ -135,6 +149,23 @@ func NewGorilla( //nolint:gocyclo
 		"PATCH    /nodes/{node_id}": g.swagPATCHNodeByID,
 		"DELETE   /nodes/{node_id}": g.swagDELETENodeByID,
 
+		"POST     /nodes/{node_id}/revoke": g.swagPOSTNodeRevoke,
+		"GET      /nodes/{node_id}/sync":   g.swagGETNodeSync,
+
+		"GET      /enrollment_tokens":            g.swagGETEnrollmentTokens,
+		"POST     /enrollment_tokens":            g.swagPOSTEnrollmentTokens,
+		"DELETE   /enrollment_tokens/{token_id}": g.swagDELETEEnrollmentTokenByID,
+
+		"GET      /enrollments":                         g.swagGETEnrollments,
+		"GET      /enrollments/{enrollment_id}":         g.swagGETEnrollmentByID,
+		"DELETE   /enrollments/{enrollment_id}":         g.swagDELETEEnrollmentByID,
+		"POST     /enrollments/{enrollment_id}/approve": g.swagPOSTEnrollmentApprove,
+		"POST     /enrollments/{enrollment_id}/reject":  g.swagPOSTEnrollmentReject,
+
+		"GET      /crl":               getCRL,
+		"GET      /ocsp/{request:.+}": respondOCSP,
+		"POST     /ocsp":              respondOCSP,
+
 		"GET      /apps":          g.swagGETApps,
 		"POST     /apps":          g.swagPOSTApps,
 		"GET      /apps/{app_id}": g.swagGETAppByID,
@@ -156,6 +187,34 @@ func NewGorilla( //nolint:gocyclo
 		"DELETE   /nodes/{node_id}/apps/{app_id}": g.swagDELETENodeAppByID,
 
 		"GET      /nodes/{node_id}/nfd": g.swagGETNodeNFDTags,
+
+		"GET      /audit": g.swagGETAudit,
+
+		"GET      /events": g.swagGETEvents,
+
+		"POST     /apply": g.swagPOSTApply,
+
+		"GET      /export": g.swagGETExport,
+		"POST     /import": g.swagPOSTImport,
+
+		"GET      /users":           g.swagGETUsers,
+		"POST     /users":           g.swagPOSTUsers,
+		"GET      /users/{user_id}": g.swagGETUserByID,
+		"PATCH    /users/{user_id}": g.swagPATCHUserByID,
+		"DELETE   /users/{user_id}": g.swagDELETEUserByID,
+
+		"POST     /users/{user_id}/revoke": g.swagPOSTUserRevoke,
+
+		"GET      /apikeys":             g.swagGETAPIKeys,
+		"POST     /apikeys":             g.swagPOSTAPIKeys,
+		"GET      /apikeys/{apikey_id}": g.swagGETAPIKeyByID,
+		"DELETE   /apikeys/{apikey_id}": g.swagDELETEAPIKeyByID,
+
+		"GET      /webhooks":                         g.swagGETWebhooks,
+		"POST     /webhooks":                         g.swagPOSTWebhooks,
+		"GET      /webhooks/{webhook_id}":            g.swagGETWebhookByID,
+		"DELETE   /webhooks/{webhook_id}":            g.swagDELETEWebhookByID,
+		"GET      /webhooks/{webhook_id}/deliveries": g.swagGETWebhookDeliveries,
 	}
 
 	if controller.OrchestrationMode == cce.OrchestrationModeKubernetesOVN {
@@ -190,35 +249,47 @@ func NewGorilla( //nolint:gocyclo
 	// Limit size of all request payloads to prevent resource starvation
 	g.router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			r.Body = http.MaxBytesReader(w, r.Body, cce.MaxBodySize)
+			maxBodySize := int64(cce.MaxBodySize)
+			if r.URL.Path == "/import" {
+				maxBodySize = cce.MaxImportBodySize
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
 			next.ServeHTTP(w, r)
 		})
 	})
 
-	// Set a timeout on all requests to prevent resource starvation
+	// Set a timeout on all requests except the event stream to prevent
+	// resource starvation
 	g.router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/events" {
+				next.ServeHTTP(w, r)
+				return
+			}
 			ctx, cancel := context.WithTimeout(r.Context(), cce.MaxHTTPRequestTime)
 			defer cancel()
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	})
 
-	// Inject the controller
+	// Inject the controller and the node connections
 	g.router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := context.WithValue(
 				r.Context(),
 				contextKey("controller"),
 				controller)
+			ctx = context.WithValue(ctx, contextKey("nodeConns"), nodeConns)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	})
 
-	// Require auth token for all endpoints except POST /auth
+	// Require auth token for all endpoints except POST /auth, POST
+	// /auth/refresh and the certificate revocation status queried by nodes
 	g.router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.RequestURI == "/auth" {
+			if r.RequestURI == "/auth" || r.RequestURI == "/auth/refresh" ||
+				r.URL.Path == "/crl" || r.URL.Path == "/ocsp" || strings.HasPrefix(r.URL.Path, "/ocsp/") {
 				next.ServeHTTP(w, r)
 			} else {
 				requireAuthHandler(next).ServeHTTP(w, r)
@@ -240,10 +311,11 @@ func NewGorilla( //nolint:gocyclo
 
 				ctx := context.WithValue(r.Context(), contextKey("body"), body)
 
-				// Scrub for the body payload for potentially sensitive authentication data
-				// (this only affects logging, not the actual request body)
+				// Scrub for the body payload for potentially sensitive authentication data or secrets
+				// and for archives too large to log (this only affects logging, not the actual request body)
 				// TODO: Log the JSON payload here but with the password field scrubbed
-				if r.URL.Path == "/auth" {
+				if r.URL.Path == "/webhooks" || r.URL.Path == "/import" ||
+					strings.HasPrefix(r.URL.Path, "/auth") || strings.HasPrefix(r.URL.Path, "/users") {
 					body = []byte("***** REDACTED *****")
 				}
 
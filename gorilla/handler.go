@@ -84,31 +84,44 @@ func (h *handler) create(w http.ResponseWriter, r *http.Request) { //nolint:gocy
 		return
 	}
 
-	if h.checkDBCreate != nil {
-		if statusCode, err := h.checkDBCreate(r.Context(), ctrl.PersistenceService, p); err != nil {
-			log.Errf("Error checking DB create: %v", err)
-			w.WriteHeader(statusCode)
-			_, err = w.Write([]byte(err.Error()))
-			if err != nil {
-				log.Errf("Error writing response: %v", err)
+	// Check and persist the entity as one unit
+	var (
+		statusCode int
+		handlerErr error
+	)
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) error {
+		if h.checkDBCreate != nil {
+			if statusCode, handlerErr = h.checkDBCreate(r.Context(), tx, p); handlerErr != nil {
+				log.Errf("Error checking DB create: %v", handlerErr)
+				return handlerErr
 			}
-			return
 		}
-	}
 
-	if h.handleCreate != nil {
-		if err := h.handleCreate(r.Context(), ctrl.PersistenceService, p); err != nil {
-			log.Errf("Error handling create logic: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			_, err = w.Write([]byte(err.Error()))
-			if err != nil {
-				log.Errf("Error writing response: %v", err)
-			}
-			return
+		return tx.Create(r.Context(), p)
+	})
+
+	// Handle the created entity on the node once it is committed and remove
+	// it again if that fails
+	if err == nil && h.handleCreate != nil {
+		handlerErr = callNode(r.Context(), ctrl.PersistenceService, func() error {
+			return h.handleCreate(r.Context(), ctrl.PersistenceService, p)
+		}, func(tx cce.PersistenceService) error {
+			_, err := tx.Delete(r.Context(), p.GetID(), p)
+			return err
+		})
+		if handlerErr != nil {
+			log.Errf("Error handling create logic: %v", handlerErr)
+			statusCode = http.StatusInternalServerError
 		}
 	}
-
-	if err := ctrl.PersistenceService.Create(r.Context(), p); err != nil {
+	if handlerErr != nil {
+		w.WriteHeader(statusCode)
+		if _, err = w.Write([]byte(handlerErr.Error())); err != nil {
+			log.Errf("Error writing response: %v", err)
+		}
+		return
+	}
+	if err != nil {
 		log.Errf("Error creating entity: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
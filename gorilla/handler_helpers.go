@@ -5,8 +5,10 @@ package gorilla
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
 
 	cce "github.com/open-ness/edgecontroller"
 	"github.com/open-ness/edgecontroller/grpc/node"
@@ -14,63 +16,179 @@ import (
 	"github.com/pkg/errors"
 )
 
-const (
-	defaultELAPort = "42101"
-	defaultEVAPort = "42102"
-)
-
+// connectNode returns the pooled connection to a service of the node of e.
+// The node is read with ps, so that a transaction sees its own changes.
 func connectNode(
 	ctx context.Context,
 	ps cce.PersistenceService,
 	e cce.NodeEntity,
-	port string,
-	conf *tls.Config,
+	svc node.Service,
 ) (*node.ClientConn, error) {
-	targets, err := ps.Filter(
-		ctx,
-		&cce.NodeGRPCTarget{},
-		[]cce.Filter{
-			{
-				Field: "node_id",
-				Value: e.GetNodeID(),
-			},
-		})
+	log.Debugf("connectNode(%v): connecting to %v", e.GetNodeID(), svc)
+
+	nodeCC, err := getNodeConns(ctx).Get(ctx, ps, e.GetNodeID(), svc)
 	if err != nil {
-		return nil, errors.Wrapf(err, "could not fetch gRPC target from DB")
+		log.Noticef("Could not connect to node: %v", err)
+		return nil, err
 	}
-	// sanity check since we are about to access targets[0]
-	if len(targets) != 1 {
-		return nil, fmt.Errorf("filter returned %v", targets)
+	log.Debugf("Connection to node %s established: %s", e.GetNodeID(), nodeCC.Addr)
+
+	return nodeCC, nil
+}
+
+// disconnectNode releases a connection from connectNode, which stays open in
+// the pool.
+func disconnectNode(nodeCC *node.ClientConn) {
+	log.Debugf("Releasing connection to %s of node at %s", nodeCC.Service, nodeCC.Addr)
+	nodeCC.Disconnect()
+}
+
+// callNode makes the calls to a node for DB changes that were already
+// committed, as the calls can neither be rolled back with the transaction nor
+// should keep it open. If call fails, undo reverts the changes in another
+// transaction and the error of call is returned. Should undo fail as well, the
+// node diverges from the DB until the reconciler repairs it, which deploys and
+// applies what the DB holds but does not remove what the DB lacks.
+func callNode(
+	ctx context.Context,
+	ps cce.PersistenceService,
+	call func() error,
+	undo func(tx cce.PersistenceService) error,
+) error {
+	err := call()
+	if err == nil || undo == nil {
+		return err
 	}
 
-	target := targets[0].(*cce.NodeGRPCTarget)
-	addr := target.GRPCTarget
-	if conf != nil {
-		conf = conf.Clone()
-		conf.ServerName = e.GetNodeID()
+	if undoErr := ps.WithTx(ctx, undo); undoErr != nil {
+		log.Errf("Error reverting DB changes after failed node call: %v", undoErr)
 	}
 
-	log.Debugf("connectNode(%v): connecting to %v", e.GetNodeID(), target)
+	return err
+}
+
+// statusError is an error returned from a transaction of a handler along with
+// its response.
+type statusError struct {
+	status int
+	msg    string
+}
 
-	nodeCC := node.ClientConn{Addr: addr, Port: port, TLS: conf}
-	if err := nodeCC.Connect(ctx); err != nil {
-		log.Noticef("Could not connect to node: %v", err)
-		return nil, errors.Wrap(err, "could not connect to node")
+func (e *statusError) Error() string {
+	if e.msg == "" {
+		return http.StatusText(e.status)
 	}
-	log.Debugf("Connection to node %s established: %s", e.GetNodeID(), addr)
+	return e.msg
+}
 
-	return &nodeCC, nil
+func withStatus(status int, format string, args ...interface{}) error {
+	return &statusError{status: status, msg: fmt.Sprintf(format, args...)}
 }
 
-func disconnectNode(nodeCC *node.ClientConn) {
-	log.Debugf("Disconnecting %v", nodeCC)
-	nodeCC.Disconnect()
+// writeTxError writes the response for the error of a transaction. A
+// *statusError is written as it is, other errors as 500 Internal Server Error.
+func writeTxError(w http.ResponseWriter, err error) {
+	se, ok := errors.Cause(err).(*statusError)
+	if !ok {
+		log.Errf("Error in transaction: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(se.status)
+	if se.msg == "" {
+		return
+	}
+	if _, err = w.Write([]byte(se.msg)); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// parseQuery parses the paging, sorting and filtering parameters of a request
+// on a collection endpoint. Every parameter other than limit, cursor and sort
+// filters on the field of the same name.
+func parseQuery(params url.Values) (cce.Query, error) {
+	var q cce.Query
+
+	for key, vals := range params {
+		switch key {
+		case "limit":
+			limit, err := strconv.Atoi(vals[0])
+			if err != nil {
+				return q, fmt.Errorf("limit %q is not a number", vals[0])
+			}
+			q.Limit = limit
+		case "cursor":
+			q.Cursor = vals[0]
+		case "sort":
+			q.Sort = vals[0]
+		default:
+			q.Filters = append(q.Filters, cce.Filter{Field: key, Value: vals[0]})
+		}
+	}
+
+	return q, q.Validate()
+}
+
+// readPage reads the page of zv entities selected by the request query, with
+// fs applied on top of the requested filters. If false is returned the error
+// response has already been written.
+func readPage(
+	w http.ResponseWriter,
+	r *http.Request,
+	zv cce.Filterable,
+	fs ...cce.Filter,
+) (ps []cce.Persistable, nextCursor string, ok bool) {
+	q, err := parseQuery(r.URL.Query())
+	if err != nil {
+		writeInvalidQuery(w, r, err)
+		return nil, "", false
+	}
+	q.Filters = append(q.Filters, fs...)
+
+	return readQueryPage(w, r, zv, q)
+}
+
+// readQueryPage reads the page of zv entities selected by q. If false is
+// returned the error response has already been written.
+func readQueryPage(
+	w http.ResponseWriter,
+	r *http.Request,
+	zv cce.Filterable,
+	q cce.Query,
+) (ps []cce.Persistable, nextCursor string, ok bool) {
+	ctrl := getController(r.Context())
+
+	ps, nextCursor, err := ctrl.PersistenceService.ReadPage(r.Context(), zv, q)
+	if err == nil {
+		return ps, nextCursor, true
+	}
+	if errors.Cause(err) == cce.ErrInvalidCursor {
+		writeInvalidQuery(w, r, err)
+		return nil, "", false
+	}
+
+	log.Errf("Error reading %s page: %v", zv.GetTableName(), err)
+	w.WriteHeader(http.StatusInternalServerError)
+	return nil, "", false
+}
+
+func writeInvalidQuery(w http.ResponseWriter, r *http.Request, err error) {
+	log.Debugf("Invalid query %q: %v", r.URL.RawQuery, err)
+	w.WriteHeader(http.StatusBadRequest)
+	if _, err = w.Write([]byte(fmt.Sprintf("Invalid query: %v", err))); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
 }
 
 func getController(ctx context.Context) *cce.Controller {
 	return ctx.Value(contextKey("controller")).(*cce.Controller)
 }
 
+func getNodeConns(ctx context.Context) *node.Pool {
+	return ctx.Value(contextKey("nodeConns")).(*node.Pool)
+}
+
 func toK8SApp(app *cce.App) k8s.App {
 	var ports []*k8s.PortProto
 	for _, port := range app.Ports {
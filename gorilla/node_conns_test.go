@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package gorilla_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/gorilla"
+	gclients "github.com/open-ness/edgecontroller/grpc/clients"
+	"github.com/open-ness/edgecontroller/grpc/node"
+	"github.com/open-ness/edgecontroller/jose"
+	"github.com/open-ness/edgecontroller/memory"
+	ctrlgmock "github.com/open-ness/edgecontroller/mock/controller/grpc"
+	nodegmock "github.com/open-ness/edgecontroller/mock/node/grpc"
+	ggrpc "google.golang.org/grpc"
+)
+
+// The handlers connect to nodes through the pool with the memory backend, so
+// the node must be read in the transaction of the handler to see its changes.
+var _ = Describe("Node connections", func() {
+	const (
+		nodeID = "9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f"
+		appID  = "3e4b7a1c-5d2f-4e8a-9b6c-1a2d3e4f5a6b"
+	)
+
+	var (
+		ctx     = context.Background()
+		ps      cce.PersistenceService
+		keysDir string
+		srv     *httptest.Server
+		token   string
+		dialed  int
+		// connErr fails connecting to the node if set
+		connErr error
+	)
+
+	BeforeEach(func() {
+		var err error
+		ps, err = memory.NewPersistenceService("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ps.Create(ctx, &cce.Node{
+			ID:       nodeID,
+			Name:     "node123",
+			Location: "Localhost port 42101",
+			Serial:   "ABCD",
+		})).To(Succeed())
+		Expect(ps.Create(ctx, &cce.NodeGRPCTarget{ID: "a1", NodeID: nodeID, GRPCTarget: "192.0.2.1"})).To(Succeed())
+		Expect(ps.Create(ctx, &cce.App{
+			ID:          appID,
+			Type:        "container",
+			Name:        "app123",
+			Version:     "1.0",
+			Vendor:      "vendor",
+			Description: "description",
+			Cores:       4,
+			Memory:      1024,
+			Source:      "http://www.test.com/my_file.tar.gz",
+		})).To(Succeed())
+
+		keysDir, err = ioutil.TempDir("", "gorilla-keys")
+		Expect(err).ToNot(HaveOccurred())
+		keys, err := jose.LoadKeyRing(keysDir)
+		Expect(err).ToNot(HaveOccurred())
+		tokens := &jose.JWSTokenIssuer{Keys: keys}
+		token, _, err = tokens.Issue("admin", cce.RoleAdmin)
+		Expect(err).ToNot(HaveOccurred())
+
+		mockNode := nodegmock.NewMockNode()
+		dialed = 0
+		connErr = nil
+		nodeConns := node.NewPool(nil)
+		nodeConns.Connect = func(ctx context.Context, cc *node.ClientConn, opts ...ggrpc.DialOption) error {
+			if connErr != nil {
+				return connErr
+			}
+			dialed++
+			cc.AppDeploySvcCli = &gclients.ApplicationDeploymentServiceClient{
+				PBCli: &ctrlgmock.MockPBApplicationDeploymentServiceClient{MockNode: mockNode},
+			}
+			cc.AppLifeSvcCli = &gclients.ApplicationLifecycleServiceClient{
+				PBCli: &ctrlgmock.MockPBApplicationLifecycleServiceClient{MockNode: mockNode},
+			}
+			cc.DNSSvcCli = &gclients.DNSServiceClient{
+				PBCli: &ctrlgmock.MockPBDNSServiceClient{MockNode: mockNode},
+			}
+			return nil
+		}
+
+		srv = httptest.NewServer(gorilla.NewGorilla(&cce.Controller{
+			PersistenceService: ps,
+			TokenService:       tokens,
+			OrchestrationMode:  cce.OrchestrationModeNative,
+		}, nodeConns))
+	})
+
+	AfterEach(func() {
+		// Closing waits for the handlers, which never return if they hang
+		if !CurrentGinkgoTestDescription().Failed {
+			srv.Close()
+		}
+		os.RemoveAll(keysDir)
+	})
+
+	// do sends a request and fails instead of hanging if the handler does.
+	do := func(method, path, body string) int {
+		codes := make(chan int, 1)
+		go func() {
+			defer GinkgoRecover()
+			req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+			codes <- resp.StatusCode
+		}()
+
+		var code int
+		Eventually(codes, 5*time.Second).Should(Receive(&code))
+		return code
+	}
+
+	It("deploys an app to a node", func() {
+		Expect(do("POST", "/nodes/"+nodeID+"/apps", `{"id":"`+appID+`"}`)).To(Equal(http.StatusOK))
+
+		nodeApps, err := ps.Filter(ctx, &cce.NodeApp{}, []cce.Filter{{Field: "node_id", Value: nodeID}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(nodeApps).To(HaveLen(1))
+		Expect(nodeApps[0].(*cce.NodeApp).AppID).To(Equal(appID))
+	})
+
+	It("removes the node app if deploying it fails", func() {
+		connErr = errors.New("node unreachable")
+		Expect(do("POST", "/nodes/"+nodeID+"/apps", `{"id":"`+appID+`"}`)).To(
+			Equal(http.StatusInternalServerError))
+
+		Expect(ps.Filter(ctx, &cce.NodeApp{}, []cce.Filter{{Field: "node_id", Value: nodeID}})).To(BeEmpty())
+	})
+
+	It("restores the node app if undeploying it fails", func() {
+		nodeApp := &cce.NodeApp{ID: "4c5d6e7f-8a9b-4c0d-9e1f-2a3b4c5d6e7f", NodeID: nodeID, AppID: appID}
+		Expect(ps.Create(ctx, nodeApp)).To(Succeed())
+
+		connErr = errors.New("node unreachable")
+		Expect(do("DELETE", "/nodes/"+nodeID+"/apps/"+appID, "")).To(Equal(http.StatusInternalServerError))
+
+		Expect(ps.Read(ctx, nodeApp.ID, &cce.NodeApp{})).To(Equal(nodeApp))
+	})
+
+	It("undeploys the apps of a node missing from a manifest applied with prune", func() {
+		Expect(do("POST", "/nodes/"+nodeID+"/apps", `{"id":"`+appID+`"}`)).To(Equal(http.StatusOK))
+		manifest := `{"nodes":[{"id":"` + nodeID + `","name":"node123","location":"Localhost port 42101",` +
+			`"serial":"ABCD"}]}`
+
+		Expect(do("POST", "/apply", manifest)).To(Equal(http.StatusOK))
+		Expect(ps.Filter(ctx, &cce.NodeApp{}, []cce.Filter{{Field: "node_id", Value: nodeID}})).To(HaveLen(1))
+
+		Expect(do("POST", "/apply?prune=true", manifest)).To(Equal(http.StatusOK))
+		Expect(ps.Filter(ctx, &cce.NodeApp{}, []cce.Filter{{Field: "node_id", Value: nodeID}})).To(BeEmpty())
+	})
+
+	It("configures the DNS of a node", func() {
+		body := `{"name":"dns123","records":{"a":[` +
+			`{"name":"a.example.com","description":"record","values":["192.0.2.10"]}]}}`
+		Expect(do("PATCH", "/nodes/"+nodeID+"/dns", body)).To(Equal(http.StatusOK))
+		Expect(do("DELETE", "/nodes/"+nodeID+"/dns", "")).To(Equal(http.StatusNoContent))
+		Expect(dialed).To(Equal(1))
+	})
+})
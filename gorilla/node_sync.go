@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package gorilla
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/swagger"
+)
+
+// Used for GET /nodes/{node_id}/sync endpoint
+//
+// Returns the outcome of the last reconciliation of the node, or the pending
+// status if it was not reconciled yet.
+func (g *Gorilla) swagGETNodeSync(w http.ResponseWriter, r *http.Request) {
+	ctrl := getController(r.Context())
+	nodeID := mux.Vars(r)["node_id"]
+
+	// Check the node exists
+	node, err := ctrl.PersistenceService.Read(r.Context(), nodeID, &cce.Node{})
+	if err != nil {
+		log.Errf("Error reading node: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if node == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	syncs, err := ctrl.PersistenceService.Filter(
+		r.Context(), &cce.NodeSync{}, []cce.Filter{{Field: "node_id", Value: nodeID}})
+	if err != nil {
+		log.Errf("Error reading node sync: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Construct the response object
+	resp := swagger.NodeSync{NodeID: nodeID, Status: cce.NodeSyncPending, Drift: []swagger.NodeDrift{}}
+	if len(syncs) > 0 {
+		ns := syncs[0].(*cce.NodeSync)
+		resp.Status = ns.Status
+		resp.CheckedAt = ns.CheckedAt
+		resp.SyncedAt = ns.SyncedAt
+		resp.Error = ns.Error
+		for _, d := range ns.Drift {
+			resp.Drift = append(resp.Drift, swagger.NodeDrift{
+				Resource: d.Resource,
+				ID:       d.ID,
+				Reason:   d.Reason,
+				Repaired: d.Repaired,
+				Error:    d.Error,
+			})
+		}
+	}
+
+	// Marshal the response object to JSON
+	syncJSON, err := json.Marshal(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(syncJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
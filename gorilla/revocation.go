@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package gorilla
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/swagger"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ocsp"
+)
+
+// revocationValidity is how long CRLs and OCSP responses are valid. Clients
+// fetch them again after it, so it bounds how long a revoked certificate may
+// still be accepted by a node.
+const revocationValidity = time.Hour
+
+// Used for POST /nodes/{node_id}/revoke endpoint
+//
+// Revokes the certificate the node was issued when it enrolled. The node can
+// enroll again for a new certificate as long as it is not deleted.
+func (g *Gorilla) swagPOSTNodeRevoke(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence and the payload
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	body := r.Context().Value(contextKey("body")).([]byte)
+
+	// Unmarshal the optional payload
+	requested := swagger.RevocationRequest{Reason: cce.RevocationReasonUnspecified}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &requested); err != nil {
+			log.Errf("Error unmarshaling json: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if err := cce.ValidateRevocationReason(requested.Reason); err != nil {
+		writeUserResponse(w, http.StatusBadRequest, fmt.Sprintf("Validation failed: %v", err))
+		return
+	}
+
+	// Check the node exists
+	nodeID := mux.Vars(r)["node_id"]
+	node, err := ctrl.PersistenceService.Read(r.Context(), nodeID, &cce.Node{})
+	if err != nil {
+		log.Errf("Error reading entity: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if node == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var revoked *cce.RevokedCertificate
+	err = ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) error {
+		var txErr error
+		revoked, txErr = (&cce.CertificateRevocations{PersistenceService: tx}).
+			RevokeNodeCredentials(r.Context(), nodeID, requested.Reason)
+		return txErr
+	})
+	if err != nil {
+		log.Errf("Error revoking certificate of node %s: %v", nodeID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if revoked == nil {
+		writeUserResponse(w, http.StatusConflict, "Node has no certificate to revoke")
+		return
+	}
+	log.Infof("Certificate %s of node %s revoked by '%s'", revoked.Serial, nodeID, cce.ActorFromContext(r.Context()))
+
+	// Marshal the response object to JSON
+	revokedJSON, err := json.Marshal(toRevokedCertificate(revoked))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(revokedJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+func toRevokedCertificate(c *cce.RevokedCertificate) swagger.RevokedCertificate {
+	return swagger.RevokedCertificate{
+		Serial:    c.Serial,
+		NodeID:    c.NodeID,
+		Reason:    c.Reason,
+		RevokedAt: c.RevokedAt,
+	}
+}
+
+// getCRL responds with the DER encoded certificate revocation list of the
+// node certificates. It requires no authentication, so that nodes can fetch
+// it.
+func getCRL(w http.ResponseWriter, r *http.Request) {
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	now := time.Now()
+	revoked, err := (&cce.CertificateRevocations{PersistenceService: ctrl.PersistenceService}).
+		RevocationList(r.Context(), now)
+	if err != nil {
+		log.Errf("Error reading revoked certificates: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	crl, err := ctrl.AuthorityService.CreateCRL(revoked, now, now.Add(revocationValidity))
+	if errors.Cause(err) == cce.ErrRevocationUnsupported {
+		writeUserResponse(w, http.StatusNotImplemented, "CRL is published by the external CA")
+		return
+	}
+	if err != nil {
+		log.Errf("Error creating CRL: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	if _, err = w.Write(crl); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// respondOCSP is an OCSP responder for the node certificates as specified in
+// RFC 6960. Requests are either POSTed or base64-encoded in the path of a GET
+// request. It requires no authentication, so that nodes can query it.
+func respondOCSP(w http.ResponseWriter, r *http.Request) {
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	der, err := ocspRequestDER(r)
+	if err != nil {
+		log.Debugf("Invalid OCSP request: %v", err)
+		writeOCSPResponse(w, ocsp.MalformedRequestErrorResponse)
+		return
+	}
+	req, err := ocsp.ParseRequest(der)
+	if err != nil {
+		log.Debugf("Invalid OCSP request: %v", err)
+		writeOCSPResponse(w, ocsp.MalformedRequestErrorResponse)
+		return
+	}
+
+	// Only certificates issued by the issuing CA are known
+	chain, err := ctrl.AuthorityService.CAChain()
+	if err != nil || len(chain) == 0 {
+		log.Errf("Error getting CA chain: %v", err)
+		writeOCSPResponse(w, ocsp.InternalErrorErrorResponse)
+		return
+	}
+	if !issuedBy(req, chain[0].RawSubject, chain[0].RawSubjectPublicKeyInfo) {
+		writeOCSPResponse(w, ocsp.UnauthorizedErrorResponse)
+		return
+	}
+
+	revoked, err := (&cce.CertificateRevocations{PersistenceService: ctrl.PersistenceService}).
+		Lookup(r.Context(), req.SerialNumber)
+	if err != nil {
+		log.Errf("Error reading revoked certificates: %v", err)
+		writeOCSPResponse(w, ocsp.InternalErrorErrorResponse)
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(revocationValidity),
+		IssuerHash:   req.HashAlgorithm,
+	}
+	if revoked != nil {
+		template.Status = ocsp.Revoked
+		template.RevokedAt, _ = time.Parse(time.RFC3339, revoked.RevokedAt)
+		template.RevocationReason = revoked.ReasonCode()
+	}
+
+	resp, err := ctrl.AuthorityService.CreateOCSPResponse(template)
+	if errors.Cause(err) == cce.ErrRevocationUnsupported {
+		// The external CA is the authoritative responder
+		writeOCSPResponse(w, ocsp.UnauthorizedErrorResponse)
+		return
+	}
+	if err != nil {
+		log.Errf("Error creating OCSP response: %v", err)
+		writeOCSPResponse(w, ocsp.InternalErrorErrorResponse)
+		return
+	}
+
+	writeOCSPResponse(w, resp)
+}
+
+// ocspRequestDER returns the DER encoded OCSP request of a POST or GET
+// request.
+func ocspRequestDER(r *http.Request) ([]byte, error) {
+	if r.Method == http.MethodPost {
+		return r.Context().Value(contextKey("body")).([]byte), nil
+	}
+
+	// The path may have been URL-encoded by the client
+	encoded, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/ocsp/"))
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// issuedBy returns whether the certificate of an OCSP request was issued by
+// the CA with the given subject and public key.
+func issuedBy(req *ocsp.Request, rawSubject, rawSubjectPublicKeyInfo []byte) bool {
+	if !req.HashAlgorithm.Available() {
+		return false
+	}
+
+	// The issuer key hash is the hash of the subject public key bit string
+	var spki struct {
+		Algorithm asn1.RawValue
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(rawSubjectPublicKeyInfo, &spki); err != nil {
+		return false
+	}
+
+	nameHash := req.HashAlgorithm.New()
+	nameHash.Write(rawSubject)
+	keyHash := req.HashAlgorithm.New()
+	keyHash.Write(spki.PublicKey.RightAlign())
+
+	return bytes.Equal(nameHash.Sum(nil), req.IssuerNameHash) &&
+		bytes.Equal(keyHash.Sum(nil), req.IssuerKeyHash)
+}
+
+func writeOCSPResponse(w http.ResponseWriter, resp []byte) {
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	if _, err := w.Write(resp); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
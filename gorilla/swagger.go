@@ -23,36 +23,44 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/grpc/node"
 	"github.com/open-ness/edgecontroller/nfd-master"
 	"github.com/open-ness/edgecontroller/swagger"
 	"github.com/open-ness/edgecontroller/uuid"
+	"github.com/pkg/errors"
 )
 
 // The following handlers are compliant to our published Swagger (OpenAPI 3.0) schema.
 
 // Used for GET /nodes endpoint
 func (g *Gorilla) swagGETNodes(w http.ResponseWriter, r *http.Request) {
-	// Load the controller to access the persistence
-	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
-
-	// Fetch the nodes from persistence
-	persisted, err := ctrl.PersistenceService.ReadAll(r.Context(), &cce.Node{})
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	// Fetch the requested page of nodes from persistence
+	persisted, nextCursor, ok := readPage(w, r, &cce.Node{})
+	if !ok {
 		return
 	}
 
 	// Construct the response object
-	nodes := swagger.NodeList{Nodes: []swagger.NodeSummary{}}
+	ctrl := getController(r.Context())
+	nodes := swagger.NodeList{Nodes: []swagger.NodeSummary{}, NextCursor: nextCursor}
 	for _, n := range persisted {
+		status, err := cce.ReadNodeStatus(r.Context(), ctrl.PersistenceService, n.GetID())
+		if err != nil {
+			log.Errf("Error reading node status: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 		node := swagger.NodeSummary{
 			ID:       n.(*cce.Node).ID,
 			Name:     n.(*cce.Node).Name,
 			Location: n.(*cce.Node).Location,
 			Serial:   n.(*cce.Node).Serial,
+			Status:   status.Status,
+			LastSeen: status.LastSeen,
 		}
 		nodes.Nodes = append(nodes.Nodes, node)
 	}
@@ -80,7 +88,8 @@ func (g *Gorilla) swagGETNodeByID(w http.ResponseWriter, r *http.Request) {
 	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
 	// Fetch the nodes from persistence and check if it's there
-	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["node_id"], &cce.Node{})
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["node_id"], &cce.Node{})
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -90,6 +99,13 @@ func (g *Gorilla) swagGETNodeByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	status, err := cce.ReadNodeStatus(r.Context(), ctrl.PersistenceService, persisted.GetID())
+	if err != nil {
+		log.Errf("Error reading node status: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	// Construct the response object
 	node := swagger.NodeDetail{
 		NodeSummary: swagger.NodeSummary{
@@ -97,6 +113,8 @@ func (g *Gorilla) swagGETNodeByID(w http.ResponseWriter, r *http.Request) {
 			Name:     persisted.(*cce.Node).Name,
 			Location: persisted.(*cce.Node).Location,
 			Serial:   persisted.(*cce.Node).Serial,
+			Status:   status.Status,
+			LastSeen: status.LastSeen,
 		},
 	}
 
@@ -106,6 +124,7 @@ func (g *Gorilla) swagGETNodeByID(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	setETag(w, rev)
 	w.Header().Set("Content-Type", "application/json")
 	if _, err = w.Write(nodeJSON); err != nil {
 		log.Errf("Error writing response: %v", err)
@@ -114,8 +133,7 @@ func (g *Gorilla) swagGETNodeByID(w http.ResponseWriter, r *http.Request) {
 
 // Used for PATCH /nodes/{node_id} endpoint
 func (g *Gorilla) swagPATCHNodeByID(w http.ResponseWriter, r *http.Request) {
-	// Load the controller to access the persistence and the payload
-	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	// Load the payload
 	body := r.Context().Value(contextKey("body")).([]byte)
 
 	// Unmarshal the payload
@@ -145,17 +163,30 @@ func (g *Gorilla) swagPATCHNodeByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Persist the object
-	if err := ctrl.PersistenceService.BulkUpdate(r.Context(), []cce.Persistable{&persisted}); err != nil {
-		log.Errf("Error updating entities: %v", err)
+	// Check the serial is not in use by another node
+	ctrl := getController(r.Context())
+	nodes, err := ctrl.PersistenceService.Filter(
+		r.Context(), &cce.Node{}, []cce.Filter{{Field: "serial", Value: persisted.Serial}})
+	if err != nil {
+		log.Errf("Error filtering nodes: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	for _, n := range nodes {
+		if n.GetID() != persisted.ID {
+			writeUserResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf(
+				"duplicate record in %s detected for serial %s", persisted.GetTableName(), persisted.Serial))
+			return
+		}
+	}
+
+	// Persist the object
+	updateEntity(w, r, &persisted)
 }
 
 // Used for DELETE /nodes/{node_id} endpoint
 // and DELETE /nodes/{node_id}?force=true endpoint
-func (g *Gorilla) swagDELETENodeByID(w http.ResponseWriter, r *http.Request) {
+func (g *Gorilla) swagDELETENodeByID(w http.ResponseWriter, r *http.Request) { //nolint:gocyclo
 	// Load the controller to access the persistence and the payload
 	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
@@ -176,27 +207,55 @@ func (g *Gorilla) swagDELETENodeByID(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if forced {
-		if err = handleForcedDeleteNodesDNSConfigs(r.Context(), ctrl, mux.Vars(r)["node_id"]); err != nil {
-			log.Errf("Error force-deleting node's DNS: %v", err)
+	// Check the If-Match precondition before force-deleting anything
+	rev, conditional := ifMatch(r)
+	if conditional {
+		var (
+			persisted    cce.Persistable
+			persistedRev int64
+		)
+		persisted, persistedRev, err = ctrl.PersistenceService.ReadWithRevision(
+			r.Context(), mux.Vars(r)["node_id"], &cce.Node{})
+		if err != nil {
+			log.Errf("Error reading entity: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-
-		if err = handleForceDeleteNodesApps(r.Context(), ctrl, mux.Vars(r)["node_id"]); err != nil {
-			log.Errf("Error force-deleting node's apps: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+		if persisted == nil {
+			w.WriteHeader(http.StatusNotFound)
 			return
 		}
-
-		if err = handleForceDeleteNodesInterfacePolicy(r.Context(), ctrl, mux.Vars(r)["node_id"]); err != nil {
-			log.Errf("Error force-deleting node's interface policies: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+		if persistedRev != rev {
+			writePreconditionFailed(w)
 			return
 		}
 	}
 
-	if err = handleDeleteNode(r.Context(), ctrl, mux.Vars(r)["node_id"]); err != nil {
+	// Delete the node and, if forced, everything attached to it as one unit. The forced deletes only remove
+	// rows; no node is called while the transaction is open.
+	err = ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) error {
+		nodeID := mux.Vars(r)["node_id"]
+		if forced {
+			if err := handleForcedDeleteNodesDNSConfigs(r.Context(), tx, nodeID); err != nil {
+				return errors.Wrap(err, "error force-deleting node's DNS")
+			}
+
+			if err := handleForceDeleteNodesApps(r.Context(), tx, nodeID); err != nil {
+				return errors.Wrap(err, "error force-deleting node's apps")
+			}
+
+			if err := handleForceDeleteNodesInterfacePolicy(r.Context(), tx, nodeID); err != nil {
+				return errors.Wrap(err, "error force-deleting node's interface policies")
+			}
+		}
+
+		return handleDeleteNode(r.Context(), tx, nodeID, rev)
+	})
+	if err != nil {
+		if errors.Cause(err) == errPreconditionFailed {
+			writePreconditionFailed(w)
+			return
+		}
 		log.Errf("Error deleting node: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		_, err = w.Write([]byte(err.Error()))
@@ -209,18 +268,14 @@ func (g *Gorilla) swagDELETENodeByID(w http.ResponseWriter, r *http.Request) {
 
 // Used for GET /apps endpoint
 func (g *Gorilla) swagGETApps(w http.ResponseWriter, r *http.Request) {
-	// Load the controller to access the persistence
-	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
-
-	// Fetch the nodes from persistence
-	persisted, err := ctrl.PersistenceService.ReadAll(r.Context(), &cce.App{})
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	// Fetch the requested page of apps from persistence
+	persisted, nextCursor, ok := readPage(w, r, &cce.App{})
+	if !ok {
 		return
 	}
 
 	// Construct the response object
-	apps := swagger.AppList{Apps: []swagger.AppSummary{}}
+	apps := swagger.AppList{Apps: []swagger.AppSummary{}, NextCursor: nextCursor}
 	for _, a := range persisted {
 		app := swagger.AppSummary{
 			ID:          a.(*cce.App).ID,
@@ -256,7 +311,8 @@ func (g *Gorilla) swagGETAppByID(w http.ResponseWriter, r *http.Request) {
 	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
 	// Fetch the entity from persistence and check if it's there
-	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["app_id"], &cce.App{})
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["app_id"], &cce.App{})
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -289,6 +345,7 @@ func (g *Gorilla) swagGETAppByID(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	setETag(w, rev)
 	w.Header().Set("Content-Type", "application/json")
 	if _, err = w.Write(appJSON); err != nil {
 		log.Errf("Error writing response: %v", err)
@@ -297,8 +354,7 @@ func (g *Gorilla) swagGETAppByID(w http.ResponseWriter, r *http.Request) {
 
 // Used for PATCH /apps/{app_id} endpoint
 func (g *Gorilla) swagPATCHAppByID(w http.ResponseWriter, r *http.Request) {
-	// Load the controller to access the persistence and the payload
-	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	// Load the payload
 	body := r.Context().Value(contextKey("body")).([]byte)
 
 	// Unmarshal the payload
@@ -336,11 +392,7 @@ func (g *Gorilla) swagPATCHAppByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Persist the object
-	if err := ctrl.PersistenceService.BulkUpdate(r.Context(), []cce.Persistable{&persisted}); err != nil {
-		log.Errf("Error updating entities: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	updateEntity(w, r, &persisted)
 }
 
 // Used for DELETE /apps/{app_id} endpoint
@@ -360,7 +412,8 @@ func (g *Gorilla) swagDELETEAppByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch the entity from persistence and check if it's there
-	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["app_id"], &cce.App{})
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["app_id"], &cce.App{})
 	if err != nil {
 		log.Errf("Error reading entity: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -375,34 +428,19 @@ func (g *Gorilla) swagDELETEAppByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ok, err := ctrl.PersistenceService.Delete(r.Context(), mux.Vars(r)["app_id"], &cce.App{})
-	if err != nil {
-		log.Errf("Error deleting entity: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// we just fetched the entity, so if !ok then something went wrong
-	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	deleteEntity(w, r, mux.Vars(r)["app_id"], &cce.App{}, rev)
 }
 
 // Used for GET /policies endpoint
 func (g *Gorilla) swagGETPolicies(w http.ResponseWriter, r *http.Request) {
-	// Load the controller to access the persistence
-	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
-
-	// Fetch the nodes from persistence
-	persisted, err := ctrl.PersistenceService.ReadAll(r.Context(), &cce.TrafficPolicy{})
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	// Fetch the requested page of policies from persistence
+	persisted, nextCursor, ok := readPage(w, r, &cce.TrafficPolicy{})
+	if !ok {
 		return
 	}
 
 	// Construct the response object
-	policies := swagger.PolicyList{Policies: []swagger.PolicySummary{}}
+	policies := swagger.PolicyList{Policies: []swagger.PolicySummary{}, NextCursor: nextCursor}
 	for _, a := range persisted {
 		policy := swagger.PolicySummary{
 			ID:   a.(*cce.TrafficPolicy).ID,
@@ -434,7 +472,8 @@ func (g *Gorilla) swagGETPolicyByID(w http.ResponseWriter, r *http.Request) {
 	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
 	// Fetch the entity from persistence and check if it's there
-	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["policy_id"], &cce.TrafficPolicy{})
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["policy_id"], &cce.TrafficPolicy{})
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -459,6 +498,7 @@ func (g *Gorilla) swagGETPolicyByID(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	setETag(w, rev)
 	w.Header().Set("Content-Type", "application/json")
 	if _, err = w.Write(policyJSON); err != nil {
 		log.Errf("Error writing response: %v", err)
@@ -467,8 +507,7 @@ func (g *Gorilla) swagGETPolicyByID(w http.ResponseWriter, r *http.Request) {
 
 // Used for PATCH /policies/{policy_id} endpoint
 func (g *Gorilla) swagPATCHPolicyByID(w http.ResponseWriter, r *http.Request) {
-	// Load the controller to access the persistence and the payload
-	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	// Load the payload
 	body := r.Context().Value(contextKey("body")).([]byte)
 
 	// Unmarshal the payload
@@ -498,11 +537,7 @@ func (g *Gorilla) swagPATCHPolicyByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Persist the object
-	if err := ctrl.PersistenceService.BulkUpdate(r.Context(), []cce.Persistable{&persisted}); err != nil {
-		log.Errf("Error updating entities: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	updateEntity(w, r, &persisted)
 }
 
 // Used for DELETE /policies/{policy_id}
@@ -525,7 +560,8 @@ func (g *Gorilla) swagDELETEPolicyByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch the entity from persistence and check if it's there
-	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["policy_id"], &cce.TrafficPolicy{})
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["policy_id"], &cce.TrafficPolicy{})
 	if err != nil {
 		log.Errf("Error reading entity: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -540,35 +576,19 @@ func (g *Gorilla) swagDELETEPolicyByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ok, err := ctrl.PersistenceService.Delete(r.Context(), mux.Vars(r)["policy_id"], &cce.TrafficPolicy{})
-	if err != nil {
-		log.Errf("Error deleting entity: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// we just fetched the entity, so if !ok then something went wrong
-	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	deleteEntity(w, r, mux.Vars(r)["policy_id"], &cce.TrafficPolicy{}, rev)
 }
 
 // Used for GET /kube_ovn/policies endpoints
 func (g *Gorilla) swagGETKubeOVNPolicies(w http.ResponseWriter, r *http.Request) {
-	// Load the controller to access the persistence
-	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
-
-	// Fetch the nodes from persistence
-	persisted, err := ctrl.PersistenceService.ReadAll(r.Context(), &cce.TrafficPolicyKubeOVN{})
-	if err != nil {
-		log.Errf("Failed to fetch the nodes from persistence: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	// Fetch the requested page of policies from persistence
+	persisted, nextCursor, ok := readPage(w, r, &cce.TrafficPolicyKubeOVN{})
+	if !ok {
 		return
 	}
 
 	// Construct the response object
-	policies := swagger.PolicyList{Policies: []swagger.PolicySummary{}}
+	policies := swagger.PolicyList{Policies: []swagger.PolicySummary{}, NextCursor: nextCursor}
 	for _, a := range persisted {
 		policy := swagger.PolicySummary{
 			ID:   a.(*cce.TrafficPolicyKubeOVN).ID,
@@ -601,7 +621,8 @@ func (g *Gorilla) swagGETKubeOVNPolicyByID(w http.ResponseWriter, r *http.Reques
 	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
 	// Fetch the entity from persistence and check if it's there
-	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["policy_id"], &cce.TrafficPolicyKubeOVN{})
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["policy_id"], &cce.TrafficPolicyKubeOVN{})
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -627,6 +648,7 @@ func (g *Gorilla) swagGETKubeOVNPolicyByID(w http.ResponseWriter, r *http.Reques
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	setETag(w, rev)
 	w.Header().Set("Content-Type", "application/json")
 	if _, err = w.Write(policyJSON); err != nil {
 		log.Errf("Error writing response: %v", err)
@@ -635,8 +657,7 @@ func (g *Gorilla) swagGETKubeOVNPolicyByID(w http.ResponseWriter, r *http.Reques
 
 // Used for PATCH /kube_ovn/policies/{policy_id} endpoint
 func (g *Gorilla) swagPATCHKubeOVNPolicyByID(w http.ResponseWriter, r *http.Request) {
-	// Load the controller to access the persistence and the payload
-	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	// Load the payload
 	body := r.Context().Value(contextKey("body")).([]byte)
 
 	// Unmarshal the payload
@@ -667,11 +688,7 @@ func (g *Gorilla) swagPATCHKubeOVNPolicyByID(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Persist the object
-	if err := ctrl.PersistenceService.BulkUpdate(r.Context(), []cce.Persistable{&persisted}); err != nil {
-		log.Errf("Error updating entities: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	updateEntity(w, r, &persisted)
 }
 
 // Used for DELETE /kube_ovn/policies/{policy_id}
@@ -694,7 +711,8 @@ func (g *Gorilla) swagDELETEKubeOVNPolicyByID(w http.ResponseWriter, r *http.Req
 	}
 
 	// Fetch the entity from persistence and check if it's there
-	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["policy_id"], &cce.TrafficPolicyKubeOVN{})
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["policy_id"], &cce.TrafficPolicyKubeOVN{})
 	if err != nil {
 		log.Errf("Error reading entity: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -709,18 +727,7 @@ func (g *Gorilla) swagDELETEKubeOVNPolicyByID(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	ok, err := ctrl.PersistenceService.Delete(r.Context(), mux.Vars(r)["policy_id"], &cce.TrafficPolicyKubeOVN{})
-	if err != nil {
-		log.Errf("Error deleting entity: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// we just fetched the entity, so if !ok then something went wrong
-	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	deleteEntity(w, r, mux.Vars(r)["policy_id"], &cce.TrafficPolicyKubeOVN{}, rev)
 }
 
 // Used for GET /nodes/{node_id}/dns endpoint
@@ -829,32 +836,53 @@ func (g *Gorilla) swagPATCHNodeDNS(w http.ResponseWriter, r *http.Request) {
 	// Load the controller to access the persistence and the payload
 	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
-	// Fetch the nodes from persistence and check if it's there
-	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["node_id"], &cce.Node{})
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if persisted == nil {
-		w.WriteHeader(http.StatusNotFound)
+	requested, ok := requestedNodeDNS(w, r)
+	if !ok {
 		return
 	}
 
-	// Delete the old persisted data
-	if err := g.swagDNSDeleteHelper(w, r); err != nil {
-		_, err = w.Write([]byte(fmt.Sprintf("DNS call failed mid operation: %v", err)))
-		if err != nil {
-			log.Errf("Error writing response: %v", err)
+	// Replace the old persisted data with the new requested data as one unit
+	var persisted *dnsEntities
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) (err error) {
+		if persisted, err = readNodeDNS(r.Context(), tx, mux.Vars(r)["node_id"]); err != nil {
+			return err
+		}
+		if persisted != nil {
+			if err = persisted.delete(r.Context(), tx); err != nil {
+				return err
+			}
 		}
+		return requested.create(r.Context(), tx)
+	})
+	if err != nil {
+		writeTxError(w, err)
 		return
 	}
 
-	// Create the new requested data
-	if err := g.swagDNSCreateHelper(w, r); err != nil {
-		_, err = w.Write([]byte(fmt.Sprintf("DNS call failed mid operation: %v", err)))
-		if err != nil {
-			log.Errf("Error writing response: %v", err)
+	// Replace the DNS config and aliases on the node and restore the old
+	// persisted data if that fails
+	err = callNode(r.Context(), ctrl.PersistenceService, func() error {
+		if persisted != nil {
+			if err := handleDeleteNodesDNSConfigsWithAliases(
+				r.Context(), ctrl.PersistenceService, persisted.nodeDNS, persisted.config, persisted.aliases,
+			); err != nil {
+				return err
+			}
+		}
+		return handleCreateNodesDNSConfigsWithAliases(
+			r.Context(), ctrl.PersistenceService, requested.nodeDNS, requested.config, requested.aliases)
+	}, func(tx cce.PersistenceService) error {
+		if err := requested.delete(r.Context(), tx); err != nil {
+			return err
+		}
+		if persisted == nil {
+			return nil
 		}
+		return persisted.create(r.Context(), tx)
+	})
+	if err != nil {
+		log.Errf("Error updating DNS of node: %v", err)
+		writeDNSError(w, http.StatusInternalServerError, err)
 		return
 	}
 }
@@ -864,46 +892,74 @@ func (g *Gorilla) swagDELETENodeDNS(w http.ResponseWriter, r *http.Request) {
 	// Load the controller to access the persistence and the payload
 	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
-	// Fetch the nodes from persistence and check if it's there
-	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["node_id"], &cce.Node{})
+	// Delete the old persisted data as one unit
+	var persisted *dnsEntities
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) (err error) {
+		if persisted, err = readNodeDNS(r.Context(), tx, mux.Vars(r)["node_id"]); err != nil {
+			return err
+		}
+		if persisted == nil {
+			return nil
+		}
+		return persisted.delete(r.Context(), tx)
+	})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if persisted == nil {
-		w.WriteHeader(http.StatusNotFound)
+		writeTxError(w, err)
 		return
 	}
 
-	// Delete the old persisted data
-	if err := g.swagDNSDeleteHelper(w, r); err != nil {
-		_, err = w.Write([]byte(fmt.Sprintf("DNS call failed mid operation: %v", err)))
+	// Delete the DNS config and aliases from the node and restore the
+	// persisted data if that fails
+	if persisted != nil {
+		err = callNode(r.Context(), ctrl.PersistenceService, func() error {
+			return handleDeleteNodesDNSConfigsWithAliases(
+				r.Context(), ctrl.PersistenceService, persisted.nodeDNS, persisted.config, persisted.aliases)
+		}, func(tx cce.PersistenceService) error {
+			return persisted.create(r.Context(), tx)
+		})
 		if err != nil {
-			log.Errf("Error writing response: %v", err)
+			log.Errf("Error deleting DNS of node: %v", err)
+			writeDNSError(w, http.StatusInternalServerError, err)
+			return
 		}
-		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (g *Gorilla) swagDNSCreateHelper(w http.ResponseWriter, r *http.Request) error { //nolint:gocyclo
-	// Load the controller to access the persistence and the payload
-	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+// writeDNSError writes the response for a failed DNS request.
+func writeDNSError(w http.ResponseWriter, status int, err error) {
+	w.WriteHeader(status)
+	if _, err = w.Write([]byte(fmt.Sprintf("DNS call failed mid operation: %v", err))); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// dnsEntities are the DNS config of a node along with its aliases.
+type dnsEntities struct {
+	nodeDNS *cce.NodeDNSConfig
+	config  *cce.DNSConfig
+	aliases []cce.Persistable
+}
+
+// requestedNodeDNS returns the DNS config of the request for its node. If
+// false is returned the error response has already been written.
+func requestedNodeDNS(w http.ResponseWriter, r *http.Request) (*dnsEntities, bool) { //nolint:gocyclo
+	// Load the payload
 	body := r.Context().Value(contextKey("body")).([]byte)
 
 	// Unmarshal the requested DNS configurations
 	requested := swagger.DNSDetail{}
 	if err := json.Unmarshal(body, &requested); err != nil {
 		log.Errf("Error unmarshaling json: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return err
+		writeDNSError(w, http.StatusBadRequest, err)
+		return nil, false
 	}
 
 	if len(requested.Configurations.Forwarders) != 0 {
 		log.Err("Received unimplemented field forwarders in request")
-		w.WriteHeader(http.StatusNotImplemented)
-		return fmt.Errorf("received unimplemented field forwarders in request")
+		writeDNSError(w, http.StatusNotImplemented, errors.New("received unimplemented field forwarders in request"))
+		return nil, false
 	}
 
 	// Create the new persistable entity for the DNS config
@@ -935,8 +991,8 @@ func (g *Gorilla) swagDNSCreateHelper(w http.ResponseWriter, r *http.Request) er
 			}
 			if err := record.Validate(); err != nil {
 				log.Errf("Error creating DNS config aliases: %v", err)
-				w.WriteHeader(http.StatusBadRequest)
-				return err
+				writeDNSError(w, http.StatusBadRequest, err)
+				return nil, false
 			}
 			newAliases = append(newAliases, &record)
 		case !req.Alias:
@@ -947,8 +1003,8 @@ func (g *Gorilla) swagDNSCreateHelper(w http.ResponseWriter, r *http.Request) er
 			}
 			if err := record.Validate(); err != nil {
 				log.Errf("Error creating DNS config non-aliases: %v", err)
-				w.WriteHeader(http.StatusBadRequest)
-				return err
+				writeDNSError(w, http.StatusBadRequest, err)
+				return nil, false
 			}
 			newConfig.ARecords = append(newConfig.ARecords, record)
 		}
@@ -961,116 +1017,106 @@ func (g *Gorilla) swagDNSCreateHelper(w http.ResponseWriter, r *http.Request) er
 		}
 		if err := config.Validate(); err != nil {
 			log.Errf("Error creating DNS config forwarders: %v", err)
-			w.WriteHeader(http.StatusBadRequest)
-			return err
+			writeDNSError(w, http.StatusBadRequest, err)
+			return nil, false
 		}
 		newConfig.Forwarders = append(newConfig.Forwarders, config)
 	}
 
-	// Create the DNS config and aliases from the node
-	if err := handleCreateNodesDNSConfigsWithAliases(
-		r.Context(), ctrl.PersistenceService, nodeDNS, newConfig, newAliases,
-	); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return err
-	}
+	return &dnsEntities{nodeDNS: nodeDNS, config: newConfig, aliases: newAliases}, true
+}
 
-	// Create the config in persistence
-	if err := ctrl.PersistenceService.Create(r.Context(), newConfig); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return err
+// readNodeDNS reads the DNS config of a node. It returns nil if the node has
+// none and a 404 *statusError if there is no such node.
+func readNodeDNS(ctx context.Context, ps cce.PersistenceService, nodeID string) (*dnsEntities, error) {
+	// Fetch the nodes from persistence and check if it's there
+	node, err := ps.Read(ctx, nodeID, &cce.Node{})
+	if err != nil {
+		return nil, err
 	}
-
-	// Create the aliases in persistence
-	for _, alias := range newAliases {
-		if err := ctrl.PersistenceService.Create(r.Context(), alias); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return err
-		}
+	if node == nil {
+		return nil, &statusError{status: http.StatusNotFound}
 	}
 
-	// Create the association in persistence
-	if err := ctrl.PersistenceService.Create(r.Context(), nodeDNS); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return err
+	// Fetch the entity from persistence
+	persistedNode, err := ps.Filter(
+		ctx,
+		&cce.NodeDNSConfig{},
+		[]cce.Filter{{Field: "node_id", Value: nodeID}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(persistedNode) == 0 {
+		return nil, nil
 	}
 
-	return nil
-}
-
-func (g *Gorilla) swagDNSDeleteHelper(w http.ResponseWriter, r *http.Request) error {
-	// Load the controller to access the persistence and the payload
-	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	// Fetch the DNS config from persistence
+	persistedConfig, err := ps.Read(
+		ctx,
+		persistedNode[0].(*cce.NodeDNSConfig).DNSConfigID,
+		&cce.DNSConfig{},
+	)
+	if err != nil {
+		return nil, err
+	}
 
-	// Fetch the entity from persistence
-	persistedNode, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeDNSConfig{},
-		[]cce.Filter{{Field: "node_id", Value: mux.Vars(r)["node_id"]}},
+	// Fetch the DNS aliases from persistence
+	persistedAliases, err := ps.Filter(
+		ctx,
+		&cce.DNSConfigAppAlias{},
+		[]cce.Filter{
+			{Field: "dns_config_id", Value: persistedNode[0].(*cce.NodeDNSConfig).DNSConfigID},
+		},
 	)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return err
+		return nil, err
 	}
 
-	// If there's persisted DNS data, delete it from the node and from persistence
-	if len(persistedNode) != 0 {
-		// Fetch the DNS config from persistence
-		persistedConfig, err := ctrl.PersistenceService.Read(
-			r.Context(),
-			persistedNode[0].(*cce.NodeDNSConfig).DNSConfigID,
-			&cce.DNSConfig{},
-		)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return err
-		}
+	return &dnsEntities{
+		nodeDNS: persistedNode[0].(*cce.NodeDNSConfig),
+		config:  persistedConfig.(*cce.DNSConfig),
+		aliases: persistedAliases,
+	}, nil
+}
 
-		// Fetch the DNS aliases from persistence
-		persistedAliases, err := ctrl.PersistenceService.Filter(
-			r.Context(),
-			&cce.DNSConfigAppAlias{},
-			[]cce.Filter{
-				{Field: "dns_config_id", Value: persistedNode[0].(*cce.NodeDNSConfig).DNSConfigID},
-			},
-		)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return err
-		}
+// create persists the DNS config, its aliases and its association with the
+// node.
+func (d *dnsEntities) create(ctx context.Context, ps cce.PersistenceService) error {
+	// Create the config in persistence
+	if err := ps.Create(ctx, d.config); err != nil {
+		return err
+	}
 
-		// Delete the DNS config and aliases from the node
-		if err := handleDeleteNodesDNSConfigsWithAliases(
-			r.Context(), ctrl.PersistenceService, persistedNode[0], persistedConfig, persistedAliases,
-		); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+	// Create the aliases in persistence
+	for _, alias := range d.aliases {
+		if err := ps.Create(ctx, alias); err != nil {
 			return err
 		}
+	}
 
-		// Delete the association from persistence
-		if _, err := ctrl.PersistenceService.Delete(
-			r.Context(), persistedNode[0].GetID(), persistedNode[0],
-		); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return err
-		}
+	// Create the association in persistence
+	return ps.Create(ctx, d.nodeDNS)
+}
 
-		// Delete the aliases from persistence
-		for _, alias := range persistedAliases {
-			if _, err := ctrl.PersistenceService.Delete(r.Context(), alias.GetID(), alias); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				return err
-			}
-		}
+// delete deletes the persisted DNS config, its aliases and its association
+// with the node.
+func (d *dnsEntities) delete(ctx context.Context, ps cce.PersistenceService) error {
+	// Delete the association from persistence
+	if _, err := ps.Delete(ctx, d.nodeDNS.GetID(), d.nodeDNS); err != nil {
+		return err
+	}
 
-		// Delete the config from persistence
-		if _, err := ctrl.PersistenceService.Delete(r.Context(), persistedConfig.GetID(), persistedConfig); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+	// Delete the aliases from persistence
+	for _, alias := range d.aliases {
+		if _, err := ps.Delete(ctx, alias.GetID(), alias); err != nil {
 			return err
 		}
-
 	}
-	return nil
+
+	// Delete the config from persistence
+	_, err := ps.Delete(ctx, d.config.GetID(), d.config)
+	return err
 }
 
 // Used for GET /nodes/{node_id}/interfaces endpoint
@@ -1320,32 +1366,42 @@ func (g *Gorilla) swagPATCHNodeInterfacePolicy(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Fetch the nodes from persistence and check if it's there
-	node, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["node_id"], &cce.Node{})
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if node == nil {
-		w.WriteHeader(http.StatusNotFound)
-		return
+	// Convert the base resource to a persistable object
+	persisted := &cce.NodeInterfaceTrafficPolicy{
+		ID:                 uuid.New(),
+		NodeID:             mux.Vars(r)["node_id"],
+		NetworkInterfaceID: mux.Vars(r)["interface_id"],
+		TrafficPolicyID:    baseResource.ID,
 	}
 
-	// TODO: Verify the interface ID is valid
+	// Check and replace the persisted policy of the interface as one unit
+	var replaced cce.Persistable
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) error {
+		// Fetch the nodes from persistence and check if it's there
+		node, err := tx.Read(r.Context(), mux.Vars(r)["node_id"], &cce.Node{})
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			return &statusError{status: http.StatusNotFound}
+		}
 
-	// Query traffic_policies to verify the baseResourceID is valid
-	policy, err := ctrl.PersistenceService.Read(r.Context(), baseResource.ID, &cce.TrafficPolicy{})
-	if err != nil {
-		log.Errf("Error reading traffic_policies: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if policy == nil {
-		w.WriteHeader(http.StatusNotFound)
-		_, err = w.Write([]byte(fmt.Sprintf("traffic policy %s not found", baseResource.ID)))
+		// TODO: Verify the interface ID is valid
+
+		// Query traffic_policies to verify the baseResourceID is valid
+		policy, err := tx.Read(r.Context(), baseResource.ID, &cce.TrafficPolicy{})
 		if err != nil {
-			log.Errf("Error writing response: %v", err)
+			return errors.Wrap(err, "error reading traffic_policies")
+		}
+		if policy == nil {
+			return withStatus(http.StatusNotFound, "traffic policy %s not found", baseResource.ID)
 		}
+
+		replaced, err = replaceNodeInterfacePolicy(r.Context(), tx, persisted)
+		return err
+	})
+	if err != nil {
+		writeTxError(w, err)
 		return
 	}
 
@@ -1362,10 +1418,15 @@ func (g *Gorilla) swagPATCHNodeInterfacePolicy(w http.ResponseWriter, r *http.Re
 		},
 	}
 
-	// Update the remote node
-	code, err := handleUpdateNodes(r.Context(), ctrl.PersistenceService, &requested)
-	switch {
-	case code != 0:
+	// Update the remote node and restore the replaced policy if that fails
+	var code int
+	err = callNode(r.Context(), ctrl.PersistenceService, func() (err error) {
+		code, err = handleUpdateNodes(r.Context(), ctrl.PersistenceService, &requested)
+		return err
+	}, func(tx cce.PersistenceService) error {
+		return restoreNodeInterfacePolicy(r.Context(), tx, persisted, replaced)
+	})
+	if code != 0 {
 		log.Errf("Error updating remote entities: %v", err)
 		w.WriteHeader(code)
 		_, err = w.Write([]byte(err.Error()))
@@ -1374,56 +1435,6 @@ func (g *Gorilla) swagPATCHNodeInterfacePolicy(w http.ResponseWriter, r *http.Re
 		}
 		return
 	}
-
-	// Filter nodes_interfaces_traffic_policies to see if a record already exists
-	nodeIfacePolicy, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeInterfaceTrafficPolicy{},
-		[]cce.Filter{
-			{
-				Field: "network_interface_id",
-				Value: mux.Vars(r)["interface_id"],
-			},
-		})
-	if err != nil {
-		log.Errf("Error reading nodes_interfaces_traffic_policies: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// If it exists, delete it
-	if len(nodeIfacePolicy) == 1 {
-		ok, err := ctrl.PersistenceService.Delete(
-			r.Context(),
-			nodeIfacePolicy[0].GetID(),
-			&cce.NodeInterfaceTrafficPolicy{},
-		)
-		if err != nil {
-			log.Errf("Error deleting from nodes_interfaces_traffic_policies: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if !ok {
-			log.Err("Did not delete 1 record from nodes_interfaces_traffic_policies")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Convert the base resource to a persistable object
-	persisted := cce.NodeInterfaceTrafficPolicy{
-		ID:                 uuid.New(),
-		NodeID:             mux.Vars(r)["node_id"],
-		NetworkInterfaceID: mux.Vars(r)["interface_id"],
-		TrafficPolicyID:    baseResource.ID,
-	}
-
-	// Persist the object
-	if err := ctrl.PersistenceService.Create(r.Context(), &persisted); err != nil {
-		log.Errf("Error creating entity: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
 }
 
 // Used for DELETE /nodes/{node_id}/interfaces/{interface_id}/policy endpoint
@@ -1431,17 +1442,29 @@ func (g *Gorilla) swagDELETENodeInterfacePolicy(w http.ResponseWriter, r *http.R
 	// Load the controller to access the persistence and the payload
 	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
-	// Fetch the nodes from persistence and check if it's there
-	node, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["node_id"], &cce.Node{})
+	// Check and delete the persisted policy of the interface as one unit
+	var deleted cce.Persistable
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) error {
+		// Fetch the nodes from persistence and check if it's there
+		node, err := tx.Read(r.Context(), mux.Vars(r)["node_id"], &cce.Node{})
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			return &statusError{status: http.StatusNotFound}
+		}
+		// TODO: Verify the interface ID is valid
+
+		deleted, err = replaceNodeInterfacePolicy(r.Context(), tx, &cce.NodeInterfaceTrafficPolicy{
+			NodeID:             mux.Vars(r)["node_id"],
+			NetworkInterfaceID: mux.Vars(r)["interface_id"],
+		})
+		return err
+	})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if node == nil {
-		w.WriteHeader(http.StatusNotFound)
+		writeTxError(w, err)
 		return
 	}
-	// TODO: Verify the interface ID is valid
 
 	// Construct the update object to dial to the node
 	requested := cce.NodeReq{
@@ -1456,10 +1479,15 @@ func (g *Gorilla) swagDELETENodeInterfacePolicy(w http.ResponseWriter, r *http.R
 		},
 	}
 
-	// Update the remote node
-	code, err := handleUpdateNodes(r.Context(), ctrl.PersistenceService, &requested)
-	switch {
-	case code != 0:
+	// Update the remote node and restore the deleted policy if that fails
+	var code int
+	err = callNode(r.Context(), ctrl.PersistenceService, func() (err error) {
+		code, err = handleUpdateNodes(r.Context(), ctrl.PersistenceService, &requested)
+		return err
+	}, func(tx cce.PersistenceService) error {
+		return restoreNodeInterfacePolicy(r.Context(), tx, nil, deleted)
+	})
+	if code != 0 {
 		log.Errf("Error updating remote entities: %v", err)
 		w.WriteHeader(code)
 		_, err = w.Write([]byte(err.Error()))
@@ -1469,48 +1497,79 @@ func (g *Gorilla) swagDELETENodeInterfacePolicy(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// replaceNodeInterfacePolicy replaces the persisted policy of the interface of
+// p with p, or only deletes it if p has no traffic policy, and returns the
+// replaced policy if there was one.
+func replaceNodeInterfacePolicy(
+	ctx context.Context,
+	ps cce.PersistenceService,
+	p *cce.NodeInterfaceTrafficPolicy,
+) (cce.Persistable, error) {
 	// Filter nodes_interfaces_traffic_policies to see if a record already exists
-	nodeIfacePolicy, err := ctrl.PersistenceService.Filter(
-		r.Context(),
+	nodeIfacePolicy, err := ps.Filter(
+		ctx,
 		&cce.NodeInterfaceTrafficPolicy{},
 		[]cce.Filter{
+			{
+				Field: "node_id",
+				Value: p.NodeID,
+			},
 			{
 				Field: "network_interface_id",
-				Value: mux.Vars(r)["interface_id"],
+				Value: p.NetworkInterfaceID,
 			},
 		})
 	if err != nil {
-		log.Errf("Error reading nodes_interfaces_traffic_policies: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, errors.Wrap(err, "error reading nodes_interfaces_traffic_policies")
 	}
 
 	// If it exists, delete it
+	var replaced cce.Persistable
 	if len(nodeIfacePolicy) == 1 {
-		ok, err := ctrl.PersistenceService.Delete(
-			r.Context(),
-			nodeIfacePolicy[0].GetID(),
-			&cce.NodeInterfaceTrafficPolicy{},
-		)
+		replaced = nodeIfacePolicy[0]
+		ok, err := ps.Delete(ctx, replaced.GetID(), &cce.NodeInterfaceTrafficPolicy{})
 		if err != nil {
-			log.Errf("Error deleting from nodes_interfaces_traffic_policies: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return nil, errors.Wrap(err, "error deleting from nodes_interfaces_traffic_policies")
 		}
 		if !ok {
-			log.Err("Did not delete 1 record from nodes_interfaces_traffic_policies")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return nil, errors.New("did not delete 1 record from nodes_interfaces_traffic_policies")
 		}
 	}
-	w.WriteHeader(http.StatusNoContent)
+
+	if p.TrafficPolicyID == "" {
+		return replaced, nil
+	}
+
+	// Persist the object
+	return replaced, errors.Wrap(ps.Create(ctx, p), "error creating entity")
+}
+
+// restoreNodeInterfacePolicy reverts replaceNodeInterfacePolicy.
+func restoreNodeInterfacePolicy(
+	ctx context.Context,
+	ps cce.PersistenceService,
+	p *cce.NodeInterfaceTrafficPolicy,
+	replaced cce.Persistable,
+) error {
+	if p != nil {
+		if _, err := ps.Delete(ctx, p.ID, p); err != nil {
+			return err
+		}
+	}
+	if replaced == nil {
+		return nil
+	}
+
+	return ps.Create(ctx, replaced)
 }
 
 // Query the DB to get the NFD features for a node. Return in a map form.
-func getNfdFeatures(ctx context.Context, nodeID string) (map[string]string, error) {
-	ctrl := ctx.Value(contextKey("controller")).(*cce.Controller)
+func getNfdFeatures(ctx context.Context, ps cce.PersistenceService, nodeID string) (map[string]string, error) {
 	// Fetch the NFD features for node from persistence
-	persistedNodeNFD, err := ctrl.PersistenceService.Filter(
+	persistedNodeNFD, err := ps.Filter(
 		ctx,
 		&nfd.NodeFeatureNFD{},
 		[]cce.Filter{
@@ -1552,24 +1611,17 @@ func (g *Gorilla) swagGETNodeApps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Construct the response object
-	nodeApps := swagger.NodeAppList{NodeApps: []swagger.NodeAppSummary{}}
-
-	// Filter nodes_apps to get the node_app_id
-	persisted, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeApp{},
-		[]cce.Filter{
-			{
-				Field: "node_id",
-				Value: mux.Vars(r)["node_id"],
-			},
-		})
-	if err != nil {
-		log.Errf("Error filtering node_apps: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	// Filter nodes_apps to get the requested page of the node's apps
+	persisted, nextCursor, ok := readPage(w, r, &cce.NodeApp{}, cce.Filter{
+		Field: "node_id",
+		Value: mux.Vars(r)["node_id"],
+	})
+	if !ok {
 		return
 	}
+
+	// Construct the response object
+	nodeApps := swagger.NodeAppList{NodeApps: []swagger.NodeAppSummary{}, NextCursor: nextCursor}
 	for _, a := range persisted {
 		nodeApps.NodeApps = append(nodeApps.NodeApps, swagger.NodeAppSummary{
 			ID: a.(*cce.NodeApp).AppID,
@@ -1610,49 +1662,6 @@ func (g *Gorilla) swagPOSTNodeApp(w http.ResponseWriter, r *http.Request) { //no
 		return
 	}
 
-	// Fetch the entity from persistence and check if it's there
-	nodeApps, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeApp{},
-		[]cce.Filter{
-			{
-				Field: "node_id",
-				Value: mux.Vars(r)["node_id"],
-			},
-			{
-				Field: "app_id",
-				Value: baseResource.ID,
-			},
-		})
-	if err != nil {
-		log.Errf("Error filtering node_apps: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if len(nodeApps) != 0 {
-		log.Errf("Filter node_apps returned %d records", len(nodeApps))
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		_, err = w.Write([]byte(fmt.Sprintf(
-			"duplicate record in nodes_apps detected for node_id %s and app_id %s",
-			mux.Vars(r)["node_id"], baseResource.ID,
-		)))
-		if err != nil {
-			log.Errf("Error writing response: %v", err)
-		}
-		return
-	}
-
-	// Fetch the entity from persistence and check if it's there
-	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["node_id"], &cce.Node{})
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if persisted == nil {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
 	// Construct the create object to dial to the node app
 	nodeApp := cce.NodeApp{
 		ID:     uuid.New(),
@@ -1661,7 +1670,7 @@ func (g *Gorilla) swagPOSTNodeApp(w http.ResponseWriter, r *http.Request) { //no
 	}
 
 	// Validate the object
-	if err = nodeApp.Validate(); err != nil {
+	if err := nodeApp.Validate(); err != nil {
 		log.Debugf("Validation failed for %#v: %v", nodeApp, err)
 		w.WriteHeader(http.StatusBadRequest)
 		_, err = w.Write([]byte(fmt.Sprintf("Validation failed: %v", err)))
@@ -1671,47 +1680,83 @@ func (g *Gorilla) swagPOSTNodeApp(w http.ResponseWriter, r *http.Request) { //no
 		return
 	}
 
-	// Fetch the entity from persistence and check if it's there
-	persisted, err = ctrl.PersistenceService.Read(r.Context(), baseResource.ID, &cce.App{})
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if persisted == nil {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
+	// Check and persist the node app as one unit
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) error {
+		// Fetch the entity from persistence and check if it's there
+		nodeApps, err := tx.Filter(
+			r.Context(),
+			&cce.NodeApp{},
+			[]cce.Filter{
+				{
+					Field: "node_id",
+					Value: mux.Vars(r)["node_id"],
+				},
+				{
+					Field: "app_id",
+					Value: baseResource.ID,
+				},
+			})
+		if err != nil {
+			return errors.Wrap(err, "error filtering node_apps")
+		}
+		if len(nodeApps) != 0 {
+			log.Errf("Filter node_apps returned %d records", len(nodeApps))
+			return withStatus(http.StatusUnprocessableEntity,
+				"duplicate record in nodes_apps detected for node_id %s and app_id %s",
+				mux.Vars(r)["node_id"], baseResource.ID)
+		}
 
-	// EPA validation
-	features, err := getNfdFeatures(r.Context(), mux.Vars(r)["node_id"])
-	if err != nil {
-		log.Errf("swagPOSTNodeApp(): getNfdFeatures() failed: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "error: %v\n", err)
-		return
-	}
-	err = persisted.(*cce.App).EPAValidate(features)
+		// Fetch the entity from persistence and check if it's there
+		persisted, err := tx.Read(r.Context(), mux.Vars(r)["node_id"], &cce.Node{})
+		if err != nil {
+			return err
+		}
+		if persisted == nil {
+			return &statusError{status: http.StatusNotFound}
+		}
+
+		// Fetch the entity from persistence and check if it's there
+		persisted, err = tx.Read(r.Context(), baseResource.ID, &cce.App{})
+		if err != nil {
+			return err
+		}
+		if persisted == nil {
+			return &statusError{status: http.StatusNotFound}
+		}
+
+		// EPA validation
+		features, err := getNfdFeatures(r.Context(), tx, mux.Vars(r)["node_id"])
+		if err != nil {
+			log.Errf("swagPOSTNodeApp(): getNfdFeatures() failed: %v", err)
+			return withStatus(http.StatusInternalServerError, "error: %v\n", err)
+		}
+		err = persisted.(*cce.App).EPAValidate(features)
+		if err != nil {
+			log.Errf("Unable to deploy app [%s] on node [%s]: %v", nodeApp.AppID, mux.Vars(r)["node_id"], err)
+			return &statusError{status: http.StatusInternalServerError}
+		}
+
+		// Persist the object
+		return tx.Create(r.Context(), &nodeApp)
+	})
 	if err != nil {
-		log.Errf("Unable to deploy app [%s] on node [%s]: %v", nodeApp.AppID, mux.Vars(r)["node_id"], err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeTxError(w, err)
 		return
 	}
 
-	// Create the remote node app
-	err = handleCreateNodesApps(r.Context(), ctrl.PersistenceService, &nodeApp)
+	// Create the remote node app and remove the persisted one if that fails
+	err = callNode(r.Context(), ctrl.PersistenceService, func() error {
+		return handleCreateNodesApps(r.Context(), ctrl.PersistenceService, &nodeApp)
+	}, func(tx cce.PersistenceService) error {
+		_, err := tx.Delete(r.Context(), nodeApp.ID, &cce.NodeApp{})
+		return err
+	})
 	if err != nil {
 		log.Errf("Error creating node app: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintf(w, "Error: %v", err)
 		return
 	}
-
-	// Persist the object
-	if err := ctrl.PersistenceService.Create(r.Context(), &nodeApp); err != nil {
-		log.Errf("Error creating entity: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
 }
 
 // Used for GET /nodes/{node_id}/apps/{app_id} endpoint
@@ -1852,6 +1897,11 @@ func (g *Gorilla) swagPATCHNodeAppsByID(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
+
+	// Lifecycle commands do not change the persisted node app, so publish
+	// the command to the event stream here
+	publishNodeAppCommand(ctrl, &requested)
+	ctrl.Notify(r.Context(), cce.NotificationAppLifecycle, &requested)
 }
 
 // Used for DELETE /nodes/{node_id}/apps/{app_id} endpoint
@@ -1859,80 +1909,77 @@ func (g *Gorilla) swagDELETENodeAppByID(w http.ResponseWriter, r *http.Request)
 	// Load the controller to access the persistence and the payload
 	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
-	// Fetch the entity from persistence and check if it's there
-	nodeApps, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeApp{},
-		[]cce.Filter{
-			{
-				Field: "node_id",
-				Value: mux.Vars(r)["node_id"],
-			},
-			{
-				Field: "app_id",
-				Value: mux.Vars(r)["app_id"],
-			},
-		})
-	if err != nil {
-		log.Errf("Error filtering node_apps: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if len(nodeApps) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	if len(nodeApps) > 1 {
-		log.Errf("Filter node_apps returned %d records", len(nodeApps))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// Check that we can delete the entity
-	var statusCode int
-	if statusCode, err = checkDBDeleteNodesApps(
-		r.Context(), ctrl.PersistenceService, nodeApps[0].(*cce.NodeApp).ID,
-	); err != nil {
-		log.Errf("Error running DB logic: %v", err)
-		w.WriteHeader(statusCode)
-		_, err = w.Write([]byte(
-			fmt.Sprintf("cannot delete app %s: record in use in nodes_apps_traffic_policies", mux.Vars(r)["app_id"])))
-		if err != nil {
-			log.Errf("Error writing response: %v", err)
-		}
-		return
-	}
-
-	// Delete the app from the node
-	if err = handleDeleteNodesApps(
-		r.Context(), ctrl.PersistenceService, nodeApps[0],
-	); err != nil {
-		log.Errf("Error making remote call: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// Delete the resource
-	ok, err := ctrl.PersistenceService.Delete(r.Context(), nodeApps[0].(*cce.NodeApp).ID, &cce.NodeApp{})
-	if err != nil {
-		log.Errf("Error deleting entity: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-// Used for GET /nodes/{node_id}/apps/{app_id}/policy endpoint
-func (g *Gorilla) swagGETNodeAppPolicy(w http.ResponseWriter, r *http.Request) {
-	// Load the controller to access the persistence
-	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
-
-	// Filter nodes_apps to get the node_app_id
+	// Check and delete the node app as one unit
+	var nodeApp *cce.NodeApp
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) error {
+		// Fetch the entity from persistence and check if it's there
+		nodeApps, err := tx.Filter(
+			r.Context(),
+			&cce.NodeApp{},
+			[]cce.Filter{
+				{
+					Field: "node_id",
+					Value: mux.Vars(r)["node_id"],
+				},
+				{
+					Field: "app_id",
+					Value: mux.Vars(r)["app_id"],
+				},
+			})
+		if err != nil {
+			return errors.Wrap(err, "error filtering node_apps")
+		}
+		if len(nodeApps) == 0 {
+			return &statusError{status: http.StatusNotFound}
+		}
+		if len(nodeApps) > 1 {
+			return errors.Errorf("filter node_apps returned %d records", len(nodeApps))
+		}
+		nodeApp = nodeApps[0].(*cce.NodeApp)
+
+		// Check that we can delete the entity
+		if statusCode, err := checkDBDeleteNodesApps(r.Context(), tx, nodeApp.ID); err != nil {
+			log.Errf("Error running DB logic: %v", err)
+			return withStatus(statusCode,
+				"cannot delete app %s: record in use in nodes_apps_traffic_policies", mux.Vars(r)["app_id"])
+		}
+
+		// Delete the resource
+		ok, err := tx.Delete(r.Context(), nodeApp.ID, &cce.NodeApp{})
+		if err != nil {
+			return errors.Wrap(err, "error deleting entity")
+		}
+		if !ok {
+			return errors.New("node app was not deleted")
+		}
+
+		return nil
+	})
+	if err != nil {
+		writeTxError(w, err)
+		return
+	}
+
+	// Delete the app from the node and restore the node app if that fails
+	if err = callNode(r.Context(), ctrl.PersistenceService, func() error {
+		return handleDeleteNodesApps(r.Context(), ctrl.PersistenceService, nodeApp)
+	}, func(tx cce.PersistenceService) error {
+		return tx.Create(r.Context(), nodeApp)
+	}); err != nil {
+		log.Errf("Error making remote call: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Used for GET /nodes/{node_id}/apps/{app_id}/policy endpoint
+func (g *Gorilla) swagGETNodeAppPolicy(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Filter nodes_apps to get the node_app_id
 	nodeApps, err := ctrl.PersistenceService.Filter(
 		r.Context(),
 		&cce.NodeApp{},
@@ -2017,116 +2064,59 @@ func (g *Gorilla) swagPATCHNodeAppPolicy(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Filter nodes_apps to get the node_app_id
-	nodeApps, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeApp{},
-		[]cce.Filter{
-			{
-				Field: "node_id",
-				Value: mux.Vars(r)["node_id"],
-			},
-			{
-				Field: "app_id",
-				Value: mux.Vars(r)["app_id"],
-			},
-		})
-	if err != nil {
-		log.Errf("Error filtering node_apps: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if len(nodeApps) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	if len(nodeApps) > 1 {
-		log.Errf("Filter node_apps returned %d records", len(nodeApps))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// Query traffic_policies to verify the baseResourceID is valid
-	policy, err := ctrl.PersistenceService.Read(r.Context(), baseResource.ID, &cce.TrafficPolicy{})
-	if err != nil {
-		log.Errf("Error reading traffic_policies: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if policy == nil {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
-	// Connect to node
-	nodePort := ctrl.ELAPort
-	if nodePort == "" {
-		nodePort = defaultELAPort
-	}
-	nodeCC, err := connectNode(
-		r.Context(),
-		ctrl.PersistenceService,
-		nodeApps[0].(*cce.NodeApp),
-		nodePort,
-		ctrl.EdgeNodeCreds)
-	if err != nil {
-		log.Errf("Error connecting to node: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	// Check and replace the persisted policy of the node app as one unit
+	var (
+		nodeApp   *cce.NodeApp
+		policy    cce.Persistable
+		persisted *cce.NodeAppTrafficPolicy
+		replaced  cce.Persistable
+	)
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) (err error) {
+		nodeApp, err = readNodeApp(r.Context(), tx, mux.Vars(r)["node_id"], mux.Vars(r)["app_id"])
+		if err != nil {
+			return err
+		}
 
-	// Make gRPC call to node to set the policy
-	if err = nodeCC.AppPolicySvcCli.Set(
-		r.Context(),
-		nodeApps[0].(*cce.NodeApp).AppID,
-		policy.(*cce.TrafficPolicy),
-	); err != nil {
-		log.Errf("Error setting policy: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+		// Query traffic_policies to verify the baseResourceID is valid
+		policy, err = tx.Read(r.Context(), baseResource.ID, &cce.TrafficPolicy{})
+		if err != nil {
+			return errors.Wrap(err, "error reading traffic_policies")
+		}
+		if policy == nil {
+			return &statusError{status: http.StatusNotFound}
+		}
 
-	// Filter nodes_apps_traffic_policies to see if a record already exists
-	nodeAppPolicies, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeAppTrafficPolicy{},
-		[]cce.Filter{
-			{
-				Field: "nodes_apps_id",
-				Value: nodeApps[0].GetID(),
-			},
-		})
+		// Convert the base resource to a persistable object
+		persisted = &cce.NodeAppTrafficPolicy{
+			ID:              uuid.New(),
+			NodeAppID:       nodeApp.ID,
+			TrafficPolicyID: baseResource.ID,
+		}
+		replaced, err = replaceNodeAppPolicy(r.Context(), tx, persisted)
+		return err
+	})
 	if err != nil {
-		log.Errf("Error reading nodes_apps_traffic_policies: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeTxError(w, err)
 		return
 	}
 
-	// If it exists, delete it
-	if len(nodeAppPolicies) == 1 {
-		ok, err := ctrl.PersistenceService.Delete(r.Context(), nodeAppPolicies[0].GetID(), &cce.NodeAppTrafficPolicy{})
+	// Make gRPC call to node to set the policy and restore the replaced
+	// policy if that fails
+	err = callNode(r.Context(), ctrl.PersistenceService, func() error {
+		nodeCC, err := connectNode(r.Context(), ctrl.PersistenceService, nodeApp, node.ELA)
 		if err != nil {
-			log.Errf("Error deleting from nodes_apps_traffic_policies: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if !ok {
-			log.Err("Did not delete 1 record from nodes_apps_traffic_policies")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return errors.Wrap(err, "error connecting to node")
 		}
-	}
-
-	// Convert the base resource to a persistable object
-	persisted := cce.NodeAppTrafficPolicy{
-		ID:              uuid.New(),
-		NodeAppID:       nodeApps[0].GetID(),
-		TrafficPolicyID: baseResource.ID,
-	}
+		defer disconnectNode(nodeCC)
 
-	// Persist the object
-	if err := ctrl.PersistenceService.Create(r.Context(), &persisted); err != nil {
-		log.Errf("Error creating entity: %v", err)
+		return errors.Wrap(
+			nodeCC.AppPolicySvcCli.Set(r.Context(), nodeApp.AppID, policy.(*cce.TrafficPolicy)),
+			"error setting policy")
+	}, func(tx cce.PersistenceService) error {
+		return restoreNodeAppPolicy(r.Context(), tx, persisted, replaced)
+	})
+	if err != nil {
+		log.Errf("Error updating node: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -2137,87 +2127,40 @@ func (g *Gorilla) swagDELETENodeAppPolicy(w http.ResponseWriter, r *http.Request
 	// Load the controller to access the persistence and the payload
 	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
-	// Filter nodes_apps to get the node_app_id
-	nodeApps, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeApp{},
-		[]cce.Filter{
-			{
-				Field: "node_id",
-				Value: mux.Vars(r)["node_id"],
-			},
-			{
-				Field: "app_id",
-				Value: mux.Vars(r)["app_id"],
-			},
-		})
-	if err != nil {
-		log.Errf("Error filtering node_apps: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if len(nodeApps) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	if len(nodeApps) > 1 {
-		log.Errf("Filter node_apps returned %d records", len(nodeApps))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// Filter nodes_apps_traffic_policies to get the ID
-	nodeAppPolicies, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeAppTrafficPolicy{},
-		[]cce.Filter{
-			{
-				Field: "nodes_apps_id",
-				Value: nodeApps[0].GetID(),
-			},
-		})
-	if err != nil {
-		log.Errf("Error reading nodes_apps_traffic_policies: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	// Check and delete the persisted policy of the node app as one unit
+	var (
+		nodeApp *cce.NodeApp
+		deleted cce.Persistable
+	)
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) (err error) {
+		nodeApp, err = readNodeApp(r.Context(), tx, mux.Vars(r)["node_id"], mux.Vars(r)["app_id"])
+		if err != nil {
+			return err
+		}
 
-	// Connect to node
-	nodePort := ctrl.ELAPort
-	if nodePort == "" {
-		nodePort = defaultELAPort
-	}
-	nodeCC, err := connectNode(
-		r.Context(),
-		ctrl.PersistenceService,
-		nodeApps[0].(*cce.NodeApp),
-		nodePort,
-		ctrl.EdgeNodeCreds)
+		deleted, err = replaceNodeAppPolicy(r.Context(), tx, &cce.NodeAppTrafficPolicy{NodeAppID: nodeApp.ID})
+		return err
+	})
 	if err != nil {
-		log.Errf("Error connecting to node: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeTxError(w, err)
 		return
 	}
 
-	// Make gRPC call to node to delete the policy
-	if err = nodeCC.AppPolicySvcCli.Delete(
-		r.Context(),
-		nodeApps[0].(*cce.NodeApp).AppID,
-	); err != nil {
-		log.Errf("Error deleting policy: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	// Make gRPC call to node to delete the policy and restore the deleted
+	// policy if that fails
+	err = callNode(r.Context(), ctrl.PersistenceService, func() error {
+		nodeCC, err := connectNode(r.Context(), ctrl.PersistenceService, nodeApp, node.ELA)
+		if err != nil {
+			return errors.Wrap(err, "error connecting to node")
+		}
+		defer disconnectNode(nodeCC)
 
-	// Delete the resource
-	ok, err := ctrl.PersistenceService.Delete(r.Context(), nodeAppPolicies[0].GetID(), &cce.NodeAppTrafficPolicy{})
+		return errors.Wrap(nodeCC.AppPolicySvcCli.Delete(r.Context(), nodeApp.AppID), "error deleting policy")
+	}, func(tx cce.PersistenceService) error {
+		return restoreNodeAppPolicy(r.Context(), tx, nil, deleted)
+	})
 	if err != nil {
-		log.Errf("Error deleting from nodes_apps_traffic_policies: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if !ok {
-		log.Err("Did not delete 1 record from nodes_apps_traffic_policies")
+		log.Errf("Error updating node: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -2315,208 +2258,879 @@ func (g *Gorilla) swagPATCHNodeAppKubeOVNPolicy(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Filter nodes_apps to get the node_app_id
-	nodeApps, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeApp{},
-		[]cce.Filter{
-			{
-				Field: "node_id",
-				Value: mux.Vars(r)["node_id"],
-			},
-			{
-				Field: "app_id",
-				Value: mux.Vars(r)["app_id"],
-			},
-		})
+	// Check and replace the persisted policy of the node app as one unit
+	var (
+		nodeApp   *cce.NodeApp
+		policy    cce.Persistable
+		persisted *cce.NodeAppTrafficPolicy
+		replaced  cce.Persistable
+	)
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) (err error) {
+		nodeApp, err = readNodeApp(r.Context(), tx, mux.Vars(r)["node_id"], mux.Vars(r)["app_id"])
+		if err != nil {
+			return err
+		}
+
+		// Query traffic_policies to verify the baseResourceID is valid
+		policy, err = tx.Read(r.Context(), baseResource.ID, &cce.TrafficPolicyKubeOVN{})
+		if err != nil {
+			return errors.Wrap(err, "error reading traffic_policies")
+		}
+		if policy == nil {
+			return &statusError{status: http.StatusNotFound}
+		}
+
+		// Convert the base resource to a persistable object
+		persisted = &cce.NodeAppTrafficPolicy{
+			ID:              uuid.New(),
+			NodeAppID:       nodeApp.ID,
+			TrafficPolicyID: baseResource.ID,
+		}
+		replaced, err = replaceNodeAppPolicy(r.Context(), tx, persisted)
+		return err
+	})
 	if err != nil {
-		log.Errf("Error filtering node_apps: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if len(nodeApps) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	if len(nodeApps) > 1 {
-		log.Errf("Filter node_apps returned %d records", len(nodeApps))
-		w.WriteHeader(http.StatusInternalServerError)
+		writeTxError(w, err)
 		return
 	}
 
-	// Query traffic_policies to verify the baseResourceID is valid
-	policy, err := ctrl.PersistenceService.Read(r.Context(), baseResource.ID, &cce.TrafficPolicyKubeOVN{})
+	// Apply the network policy for the app and restore the replaced policy if
+	// that fails
+	err = callNode(r.Context(), ctrl.PersistenceService, func() error {
+		// Try delete network policy for app
+		_ = ctrl.KubernetesClient.DeleteNetworkPolicy(r.Context(), nodeApp.NodeID, nodeApp.AppID)
+
+		// Apply new network policy for app
+		return errors.Wrap(ctrl.KubernetesClient.ApplyNetworkPolicy(r.Context(), nodeApp.NodeID, nodeApp.AppID,
+			policy.(*cce.TrafficPolicyKubeOVN).ToK8s()), "error setting policy")
+	}, func(tx cce.PersistenceService) error {
+		return restoreNodeAppPolicy(r.Context(), tx, persisted, replaced)
+	})
 	if err != nil {
-		log.Errf("Error reading traffic_policies: %v", err)
+		log.Errf("Error updating network policy: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	if policy == nil {
-		w.WriteHeader(http.StatusNotFound)
+}
+
+// Used for DELETE /nodes/{node_id}/apps/{app_id}/kube_ovn/policy endpoint
+func (g *Gorilla) swagDELETENodeAppKubeOVNPolicy(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence and the payload
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Check and delete the persisted policy of the node app as one unit
+	var (
+		nodeApp *cce.NodeApp
+		deleted cce.Persistable
+	)
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) (err error) {
+		nodeApp, err = readNodeApp(r.Context(), tx, mux.Vars(r)["node_id"], mux.Vars(r)["app_id"])
+		if err != nil {
+			return err
+		}
+
+		deleted, err = replaceNodeAppPolicy(r.Context(), tx, &cce.NodeAppTrafficPolicy{NodeAppID: nodeApp.ID})
+		return err
+	})
+	if err != nil {
+		writeTxError(w, err)
 		return
 	}
 
-	// Try delete network policy for app
-	_ = ctrl.KubernetesClient.DeleteNetworkPolicy(r.Context(), nodeApps[0].(*cce.NodeApp).NodeID,
-		nodeApps[0].(*cce.NodeApp).AppID)
-
-	// Apply new network policy for app
-	if err = ctrl.KubernetesClient.ApplyNetworkPolicy(r.Context(), nodeApps[0].(*cce.NodeApp).NodeID,
-		nodeApps[0].(*cce.NodeApp).AppID, policy.(*cce.TrafficPolicyKubeOVN).ToK8s(),
-	); err != nil {
-		log.Errf("Error setting policy: %v", err)
+	// Delete the network policy of the app and restore the deleted policy if
+	// that fails
+	err = callNode(r.Context(), ctrl.PersistenceService, func() error {
+		return errors.Wrap(ctrl.KubernetesClient.DeleteNetworkPolicy(r.Context(), nodeApp.NodeID, nodeApp.AppID),
+			"error deleting policy")
+	}, func(tx cce.PersistenceService) error {
+		return restoreNodeAppPolicy(r.Context(), tx, nil, deleted)
+	})
+	if err != nil {
+		log.Errf("Error updating network policy: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	// Filter nodes_apps_traffic_policies to see if a record already exists
-	nodeAppPolicies, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeAppTrafficPolicy{},
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readNodeApp reads the node app of an app on a node. A missing node app is
+// returned as 404 *statusError.
+func readNodeApp(ctx context.Context, ps cce.PersistenceService, nodeID, appID string) (*cce.NodeApp, error) {
+	// Filter nodes_apps to get the node_app_id
+	nodeApps, err := ps.Filter(
+		ctx,
+		&cce.NodeApp{},
 		[]cce.Filter{
 			{
-				Field: "nodes_apps_id",
-				Value: nodeApps[0].GetID(),
+				Field: "node_id",
+				Value: nodeID,
+			},
+			{
+				Field: "app_id",
+				Value: appID,
 			},
 		})
 	if err != nil {
-		log.Errf("Error reading nodes_apps_traffic_policies: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, errors.Wrap(err, "error filtering node_apps")
+	}
+	if len(nodeApps) == 0 {
+		return nil, &statusError{status: http.StatusNotFound}
+	}
+	if len(nodeApps) > 1 {
+		return nil, errors.Errorf("filter node_apps returned %d records", len(nodeApps))
+	}
+
+	return nodeApps[0].(*cce.NodeApp), nil
+}
+
+// replaceNodeAppPolicy replaces the persisted policy of the node app of p with
+// p, or only deletes it if p has no traffic policy, and returns the replaced
+// policy if there was one.
+func replaceNodeAppPolicy(
+	ctx context.Context,
+	ps cce.PersistenceService,
+	p *cce.NodeAppTrafficPolicy,
+) (cce.Persistable, error) {
+	// Filter nodes_apps_traffic_policies to see if a record already exists
+	nodeAppPolicies, err := ps.Filter(
+		ctx,
+		&cce.NodeAppTrafficPolicy{},
+		[]cce.Filter{
+			{
+				Field: "nodes_apps_id",
+				Value: p.NodeAppID,
+			},
+		})
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading nodes_apps_traffic_policies")
 	}
 
 	// If it exists, delete it
+	var replaced cce.Persistable
 	if len(nodeAppPolicies) == 1 {
-		ok, err := ctrl.PersistenceService.Delete(r.Context(), nodeAppPolicies[0].GetID(), &cce.NodeAppTrafficPolicy{})
+		replaced = nodeAppPolicies[0]
+		ok, err := ps.Delete(ctx, replaced.GetID(), &cce.NodeAppTrafficPolicy{})
 		if err != nil {
-			log.Errf("Error deleting from nodes_apps_traffic_policies: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return nil, errors.Wrap(err, "error deleting from nodes_apps_traffic_policies")
 		}
 		if !ok {
-			log.Err("Did not delete 1 record from nodes_apps_traffic_policies")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return nil, errors.New("did not delete 1 record from nodes_apps_traffic_policies")
 		}
 	}
 
-	// Convert the base resource to a persistable object
-	persisted := cce.NodeAppTrafficPolicy{
-		ID:              uuid.New(),
-		NodeAppID:       nodeApps[0].GetID(),
-		TrafficPolicyID: baseResource.ID,
+	if p.TrafficPolicyID == "" {
+		return replaced, nil
 	}
 
 	// Persist the object
-	if err := ctrl.PersistenceService.Create(r.Context(), &persisted); err != nil {
-		log.Errf("Error creating entity: %v", err)
+	return replaced, errors.Wrap(ps.Create(ctx, p), "error creating entity")
+}
+
+// restoreNodeAppPolicy reverts replaceNodeAppPolicy.
+func restoreNodeAppPolicy(
+	ctx context.Context,
+	ps cce.PersistenceService,
+	p *cce.NodeAppTrafficPolicy,
+	replaced cce.Persistable,
+) error {
+	if p != nil {
+		if _, err := ps.Delete(ctx, p.ID, p); err != nil {
+			return err
+		}
+	}
+	if replaced == nil {
+		return nil
+	}
+
+	return ps.Create(ctx, replaced)
+}
+
+// Return the NFD tags of a node in a Json form to the remote caller
+func (g *Gorilla) swagGETNodeNFDTags(w http.ResponseWriter, r *http.Request) {
+	nodeID := mux.Vars(r)["node_id"]
+
+	// Convert persisted node NFD features to NodeNfdList
+	features, err := getNfdFeatures(r.Context(), getController(r.Context()).PersistenceService, nodeID)
+	if err != nil {
+		log.Errf("swagGETNodeNFDTags(): getNfdFeatures() failed: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "error: %v\n", err)
+		return
+	}
+	nodeNfds := swagger.NodeNfdList{}
+	for k, v := range features {
+		nodeNfdTag := swagger.NodeNfdTag{ID: k, Value: v}
+		nodeNfds.List = append(nodeNfds.List, nodeNfdTag)
+	}
+	nfdJSON, err := json.Marshal(nodeNfds)
+	if err != nil {
+		log.Errf("Error %v marshaling the NFD features into JSON for node %v", err, nodeID)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "error: %v\n", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(nfdJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// Used for GET /audit endpoint
+func (g *Gorilla) swagGETAudit(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	// entity selects the records of an entity ID and since the records at or
+	// after an RFC 3339 timestamp
+	var fs, minFs []cce.Filter
+	if entity := params.Get("entity"); entity != "" {
+		fs = append(fs, cce.Filter{Field: "entity_id", Value: entity})
+	}
+	if since := params.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			writeInvalidQuery(w, r, fmt.Errorf("since %q is not an RFC 3339 timestamp", since))
+			return
+		}
+		minFs = append(minFs, cce.Filter{Field: "timestamp", Value: cce.AuditTimestamp(t)})
+	}
+	params.Del("entity")
+	params.Del("since")
+
+	q, err := parseQuery(params)
+	if err != nil {
+		writeInvalidQuery(w, r, err)
+		return
+	}
+	q.Filters = append(q.Filters, fs...)
+	q.MinFilters = append(q.MinFilters, minFs...)
+	if q.Sort == "" {
+		q.Sort = "timestamp"
+	}
+
+	// Fetch the requested page of audit records from persistence
+	persisted, nextCursor, ok := readQueryPage(w, r, &cce.AuditRecord{}, q)
+	if !ok {
+		return
+	}
+
+	// Construct the response object
+	records := swagger.AuditRecordList{Records: []*cce.AuditRecord{}, NextCursor: nextCursor}
+	for _, e := range persisted {
+		records.Records = append(records.Records, e.(*cce.AuditRecord))
+	}
+
+	// Marshal the response object to JSON
+	recordsJSON, err := json.Marshal(records)
+	if err != nil {
+		log.Errf("Error marshaling json: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(recordsJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
 }
 
-// Used for DELETE /nodes/{node_id}/apps/{app_id}/kube_ovn/policy endpoint
-func (g *Gorilla) swagDELETENodeAppKubeOVNPolicy(w http.ResponseWriter, r *http.Request) {
+// Used for GET /webhooks endpoint
+func (g *Gorilla) swagGETWebhooks(w http.ResponseWriter, r *http.Request) {
+	// Fetch the requested page of webhooks from persistence
+	persisted, nextCursor, ok := readPage(w, r, &cce.Webhook{})
+	if !ok {
+		return
+	}
+
+	// Construct the response object
+	webhooks := swagger.WebhookList{Webhooks: []swagger.WebhookDetail{}, NextCursor: nextCursor}
+	for _, e := range persisted {
+		webhooks.Webhooks = append(webhooks.Webhooks, toWebhookDetail(e.(*cce.Webhook)))
+	}
+
+	// Marshal the response object to JSON
+	webhooksJSON, err := json.Marshal(webhooks)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(webhooksJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// Used for POST /webhooks endpoint
+func (g *Gorilla) swagPOSTWebhooks(w http.ResponseWriter, r *http.Request) {
+	g.webhooksHandler.create(w, r)
+}
+
+// Used for GET /webhooks/{webhook_id} endpoint
+func (g *Gorilla) swagGETWebhookByID(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Fetch the entity from persistence and check if it's there
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["webhook_id"], &cce.Webhook{})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if persisted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// Marshal the response object to JSON
+	webhookJSON, err := json.Marshal(toWebhookDetail(persisted.(*cce.Webhook)))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	setETag(w, rev)
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(webhookJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// Used for DELETE /webhooks/{webhook_id} endpoint
+func (g *Gorilla) swagDELETEWebhookByID(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Fetch the entity from persistence and check if it's there
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["webhook_id"], &cce.Webhook{})
+	if err != nil {
+		log.Errf("Error reading entity: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if persisted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// The delivery log of the webhook is deleted with it
+	deleteEntity(w, r, mux.Vars(r)["webhook_id"], &cce.Webhook{}, rev)
+}
+
+// Used for GET /webhooks/{webhook_id}/deliveries endpoint
+func (g *Gorilla) swagGETWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Check that the webhook exists
+	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["webhook_id"], &cce.Webhook{})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if persisted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	q, err := parseQuery(r.URL.Query())
+	if err != nil {
+		writeInvalidQuery(w, r, err)
+		return
+	}
+	q.Filters = append(q.Filters, cce.Filter{Field: "webhook_id", Value: persisted.GetID()})
+	if q.Sort == "" {
+		q.Sort = "created_at"
+	}
+
+	// Fetch the requested page of deliveries from persistence
+	deliveries, nextCursor, ok := readQueryPage(w, r, &cce.WebhookDelivery{}, q)
+	if !ok {
+		return
+	}
+
+	// Construct the response object
+	list := swagger.WebhookDeliveryList{Deliveries: []*cce.WebhookDelivery{}, NextCursor: nextCursor}
+	for _, e := range deliveries {
+		list.Deliveries = append(list.Deliveries, e.(*cce.WebhookDelivery))
+	}
+
+	// Marshal the response object to JSON
+	listJSON, err := json.Marshal(list)
+	if err != nil {
+		log.Errf("Error marshaling json: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(listJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+func toWebhookDetail(wh *cce.Webhook) swagger.WebhookDetail {
+	events := wh.Events
+	if events == nil {
+		events = []string{}
+	}
+
+	return swagger.WebhookDetail{
+		ID:     wh.ID,
+		URL:    wh.URL,
+		Events: events,
+	}
+}
+
+// Used for GET /users endpoint
+func (g *Gorilla) swagGETUsers(w http.ResponseWriter, r *http.Request) {
+	// Fetch the requested page of users from persistence
+	persisted, nextCursor, ok := readPage(w, r, &cce.User{})
+	if !ok {
+		return
+	}
+
+	// Construct the response object
+	users := swagger.UserList{Users: []swagger.UserSummary{}, NextCursor: nextCursor}
+	for _, e := range persisted {
+		users.Users = append(users.Users, toUserSummary(e.(*cce.User)))
+	}
+
+	// Marshal the response object to JSON
+	usersJSON, err := json.Marshal(users)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(usersJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// Used for POST /users endpoint
+func (g *Gorilla) swagPOSTUsers(w http.ResponseWriter, r *http.Request) {
 	// Load the controller to access the persistence and the payload
 	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	body := r.Context().Value(contextKey("body")).([]byte)
 
-	// Filter nodes_apps to get the node_app_id
-	nodeApps, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeApp{},
-		[]cce.Filter{
-			{
-				Field: "node_id",
-				Value: mux.Vars(r)["node_id"],
-			},
-			{
-				Field: "app_id",
-				Value: mux.Vars(r)["app_id"],
-			},
-		})
+	// Unmarshal the payload
+	requested := swagger.UserDetail{}
+	if err := json.Unmarshal(body, &requested); err != nil {
+		log.Errf("Error unmarshaling json: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if requested.ID != "" {
+		writeUserResponse(w, http.StatusBadRequest, "Validation failed: id cannot be specified in POST request")
+		return
+	}
+	if err := cce.ValidatePassword(requested.Password); err != nil {
+		writeUserResponse(w, http.StatusBadRequest, fmt.Sprintf("Validation failed: %v", err))
+		return
+	}
+
+	// Convert it to a persistable object
+	user := &cce.User{ID: uuid.New(), Username: requested.Username, Role: requested.Role}
+	if err := user.SetPassword(requested.Password); err != nil {
+		log.Errf("Error hashing password: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Validate the object
+	if err := user.Validate(); err != nil {
+		log.Debugf("Validation failed for %v: %v", user, err)
+		writeUserResponse(w, http.StatusBadRequest, fmt.Sprintf("Validation failed: %v", err))
+		return
+	}
+
+	// Check the username is unique and persist the user as one unit
+	exists := false
+	err := ctrl.PersistenceService.WithTx(r.Context(), func(tx cce.PersistenceService) error {
+		users, err := tx.Filter(r.Context(), &cce.User{}, []cce.Filter{{Field: "username", Value: user.Username}})
+		if err != nil {
+			return err
+		}
+		if exists = len(users) != 0; exists {
+			return nil
+		}
+		return tx.Create(r.Context(), user)
+	})
 	if err != nil {
-		log.Errf("Error filtering node_apps: %v", err)
+		log.Errf("Error creating user: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	if len(nodeApps) == 0 {
+	if exists {
+		writeUserResponse(w, http.StatusConflict, fmt.Sprintf("username %q already exists", user.Username))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if _, err = w.Write([]byte(fmt.Sprintf(`{"id":"%s"}`, user.ID))); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+// Used for GET /users/{user_id} endpoint
+func (g *Gorilla) swagGETUserByID(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Fetch the entity from persistence and check if it's there
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["user_id"], &cce.User{})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if persisted == nil {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	if len(nodeApps) > 1 {
-		log.Errf("Filter node_apps returned %d records", len(nodeApps))
+
+	// Marshal the response object to JSON
+	userJSON, err := json.Marshal(toUserSummary(persisted.(*cce.User)))
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	setETag(w, rev)
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(userJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
 
-	// Filter nodes_apps_traffic_policies to get the ID
-	nodeAppPolicies, err := ctrl.PersistenceService.Filter(
-		r.Context(),
-		&cce.NodeAppTrafficPolicy{},
-		[]cce.Filter{
-			{
-				Field: "nodes_apps_id",
-				Value: nodeApps[0].GetID(),
-			},
-		})
+// Used for PATCH /users/{user_id} endpoint
+//
+// The role and password of a user can be changed; the username cannot. A
+// changed role revokes the sessions of the user, so it takes effect when the
+// user logs in again.
+func (g *Gorilla) swagPATCHUserByID(w http.ResponseWriter, r *http.Request) { //nolint:gocyclo
+	// Load the controller to access the persistence and the payload
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	body := r.Context().Value(contextKey("body")).([]byte)
+
+	// Unmarshal the payload
+	requested := swagger.UserDetail{}
+	if err := json.Unmarshal(body, &requested); err != nil {
+		log.Errf("Error unmarshaling json: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Fetch the entity from persistence and check if it's there
+	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["user_id"], &cce.User{})
 	if err != nil {
-		log.Errf("Error reading nodes_apps_traffic_policies: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if persisted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	user := persisted.(*cce.User)
+
+	if requested.Username != "" && requested.Username != user.Username {
+		writeUserResponse(w, http.StatusBadRequest, "Validation failed: username cannot be changed")
+		return
+	}
+	if requested.Password != "" {
+		if err = cce.ValidatePassword(requested.Password); err != nil {
+			writeUserResponse(w, http.StatusBadRequest, fmt.Sprintf("Validation failed: %v", err))
+			return
+		}
+		if err = user.SetPassword(requested.Password); err != nil {
+			log.Errf("Error hashing password: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	demoted, roleChanged := false, false
+	if requested.Role != "" {
+		demoted = user.Role == cce.RoleAdmin && requested.Role != cce.RoleAdmin
+		roleChanged = requested.Role != user.Role
+		user.Role = requested.Role
+	}
+
+	// Validate the object
+	if err = user.Validate(); err != nil {
+		log.Debugf("Validation failed for %v: %v", user, err)
+		writeUserResponse(w, http.StatusBadRequest, fmt.Sprintf("Validation failed: %v", err))
+		return
+	}
+	if demoted && !checkOtherAdmins(w, r, user.ID) {
+		return
+	}
+
+	// Persist the object and revoke the tokens of the old role
+	if updateEntity(w, r, user) && roleChanged {
+		revokeSessions(w, r, user.Username)
+	}
+}
 
-	// Make gRPC call to node to delete the policy
-	if err = ctrl.KubernetesClient.DeleteNetworkPolicy(
-		r.Context(), nodeApps[0].(*cce.NodeApp).NodeID, nodeApps[0].(*cce.NodeApp).AppID,
-	); err != nil {
-		log.Errf("Error deleting policy: %v", err)
+// Used for DELETE /users/{user_id} endpoint
+//
+// The sessions of the user are revoked. The admin user is created again when
+// the controller is restarted.
+func (g *Gorilla) swagDELETEUserByID(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Fetch the entity from persistence and check if it's there
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["user_id"], &cce.User{})
+	if err != nil {
+		log.Errf("Error reading entity: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if persisted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if persisted.(*cce.User).Role == cce.RoleAdmin && !checkOtherAdmins(w, r, persisted.GetID()) {
+		return
+	}
+
+	if deleteEntity(w, r, persisted.GetID(), &cce.User{}, rev) {
+		revokeSessions(w, r, persisted.(*cce.User).Username)
+	}
+}
+
+// Used for POST /users/{user_id}/revoke endpoint
+//
+// Revokes all sessions of the user, so that none of the tokens issued to it
+// so far are accepted and it has to log in again.
+func (g *Gorilla) swagPOSTUserRevoke(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
-	// Delete the resource
-	ok, err := ctrl.PersistenceService.Delete(r.Context(), nodeAppPolicies[0].GetID(), &cce.NodeAppTrafficPolicy{})
+	// Fetch the entity from persistence and check if it's there
+	persisted, err := ctrl.PersistenceService.Read(r.Context(), mux.Vars(r)["user_id"], &cce.User{})
 	if err != nil {
-		log.Errf("Error deleting from nodes_apps_traffic_policies: %v", err)
+		log.Errf("Error reading entity: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if persisted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if revokeSessions(w, r, persisted.(*cce.User).Username) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// revokeSessions revokes the sessions of the user with the given username. If
+// false is returned the error response has already been written.
+func revokeSessions(w http.ResponseWriter, r *http.Request, username string) bool {
+	if err := getController(r.Context()).TokenService.RevokeSubject(username); err != nil {
+		log.Errf("Error revoking sessions of user '%s': %v", username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	log.Infof("User '%s' revoked the sessions of user '%s'", cce.ActorFromContext(r.Context()), username)
+
+	return true
+}
+
+// checkOtherAdmins checks that an admin other than the user with the given ID
+// exists, so the last admin cannot be deleted or demoted. If false is
+// returned the error response has already been written.
+func checkOtherAdmins(w http.ResponseWriter, r *http.Request, id string) bool {
+	admins, err := getController(r.Context()).PersistenceService.Filter(
+		r.Context(), &cce.User{}, []cce.Filter{{Field: "role", Value: cce.RoleAdmin}})
+	if err != nil {
+		log.Errf("Error filtering users: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	for _, admin := range admins {
+		if admin.GetID() != id {
+			return true
+		}
+	}
+
+	writeUserResponse(w, http.StatusConflict, "cannot remove the last admin")
+	return false
+}
+
+func writeUserResponse(w http.ResponseWriter, statusCode int, msg string) {
+	w.WriteHeader(statusCode)
+	if _, err := w.Write([]byte(msg)); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
+
+func toUserSummary(u *cce.User) swagger.UserSummary {
+	return swagger.UserSummary{
+		ID:       u.ID,
+		Username: u.Username,
+		Role:     u.Role,
+	}
+}
+
+// Used for GET /apikeys endpoint
+func (g *Gorilla) swagGETAPIKeys(w http.ResponseWriter, r *http.Request) {
+	// Fetch the requested page of API keys from persistence
+	persisted, nextCursor, ok := readPage(w, r, &cce.APIKey{})
 	if !ok {
-		log.Err("Did not delete 1 record from nodes_apps_traffic_policies")
+		return
+	}
+
+	// Construct the response object
+	keys := swagger.APIKeyList{APIKeys: []swagger.APIKeySummary{}, NextCursor: nextCursor}
+	for _, e := range persisted {
+		keys.APIKeys = append(keys.APIKeys, toAPIKeySummary(e.(*cce.APIKey)))
+	}
+
+	// Marshal the response object to JSON
+	keysJSON, err := json.Marshal(keys)
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(keysJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
+}
 
-	w.WriteHeader(http.StatusNoContent)
+// Used for POST /apikeys endpoint
+//
+// The response is the only time the key is returned.
+func (g *Gorilla) swagPOSTAPIKeys(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence and the payload
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+	body := r.Context().Value(contextKey("body")).([]byte)
+
+	// Unmarshal the payload
+	requested := swagger.APIKeySummary{}
+	if err := json.Unmarshal(body, &requested); err != nil {
+		log.Errf("Error unmarshaling json: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if requested.ID != "" || requested.CreatedBy != "" {
+		writeUserResponse(w, http.StatusBadRequest,
+			"Validation failed: id and created_by cannot be specified in POST request")
+		return
+	}
+
+	// Convert it to a persistable object and generate the key
+	apiKey := &cce.APIKey{
+		ID:        uuid.New(),
+		Name:      requested.Name,
+		Role:      requested.Role,
+		Scopes:    requested.Scopes,
+		Expiry:    requested.Expiry,
+		CreatedBy: cce.ActorFromContext(r.Context()),
+	}
+	key, err := apiKey.GenerateSecret()
+	if err != nil {
+		log.Errf("Error generating API key: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Validate the object
+	if err = apiKey.Validate(); err != nil {
+		log.Debugf("Validation failed for %v: %v", apiKey, err)
+		writeUserResponse(w, http.StatusBadRequest, fmt.Sprintf("Validation failed: %v", err))
+		return
+	}
+	if apiKey.Expired(time.Now()) {
+		writeUserResponse(w, http.StatusBadRequest, "Validation failed: expiry must be in the future")
+		return
+	}
+
+	// Persist the object
+	if err = ctrl.PersistenceService.Create(r.Context(), apiKey); err != nil {
+		log.Errf("Error creating API key: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	log.Infof("API key %s (%s) with role '%s' created by '%s'", apiKey.ID, apiKey.Name, apiKey.Role, apiKey.CreatedBy)
+
+	// Marshal the response object to JSON
+	keyJSON, err := json.Marshal(swagger.APIKeyDetail{APIKeySummary: toAPIKeySummary(apiKey), Key: key})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if _, err = w.Write(keyJSON); err != nil {
+		log.Errf("Error writing response: %v", err)
+	}
 }
 
-// Return the NFD tags of a node in a Json form to the remote caller
-func (g *Gorilla) swagGETNodeNFDTags(w http.ResponseWriter, r *http.Request) {
-	nodeID := mux.Vars(r)["node_id"]
+// Used for GET /apikeys/{apikey_id} endpoint
+func (g *Gorilla) swagGETAPIKeyByID(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
 
-	// Convert persisted node NFD features to NodeNfdList
-	features, err := getNfdFeatures(r.Context(), nodeID)
+	// Fetch the entity from persistence and check if it's there
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["apikey_id"], &cce.APIKey{})
 	if err != nil {
-		log.Errf("swagGETNodeNFDTags(): getNfdFeatures() failed: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "error: %v\n", err)
 		return
 	}
-	nodeNfds := swagger.NodeNfdList{}
-	for k, v := range features {
-		nodeNfdTag := swagger.NodeNfdTag{ID: k, Value: v}
-		nodeNfds.List = append(nodeNfds.List, nodeNfdTag)
+	if persisted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
-	nfdJSON, err := json.Marshal(nodeNfds)
+
+	// Marshal the response object to JSON
+	keyJSON, err := json.Marshal(toAPIKeySummary(persisted.(*cce.APIKey)))
 	if err != nil {
-		log.Errf("Error %v marshaling the NFD features into JSON for node %v", err, nodeID)
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "error: %v\n", err)
 		return
 	}
+	setETag(w, rev)
 	w.Header().Set("Content-Type", "application/json")
-	if _, err := w.Write(nfdJSON); err != nil {
+	if _, err = w.Write(keyJSON); err != nil {
 		log.Errf("Error writing response: %v", err)
 	}
-	fmt.Fprintf(w, "\n")
+}
+
+// Used for DELETE /apikeys/{apikey_id} endpoint
+//
+// Deleting an API key revokes it immediately.
+func (g *Gorilla) swagDELETEAPIKeyByID(w http.ResponseWriter, r *http.Request) {
+	// Load the controller to access the persistence
+	ctrl := r.Context().Value(contextKey("controller")).(*cce.Controller)
+
+	// Fetch the entity from persistence and check if it's there
+	persisted, rev, err := ctrl.PersistenceService.ReadWithRevision(
+		r.Context(), mux.Vars(r)["apikey_id"], &cce.APIKey{})
+	if err != nil {
+		log.Errf("Error reading entity: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if persisted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	log.Infof("API key %s revoked by '%s'", persisted.GetID(), cce.ActorFromContext(r.Context()))
+
+	deleteEntity(w, r, persisted.GetID(), &cce.APIKey{}, rev)
+}
+
+func toAPIKeySummary(k *cce.APIKey) swagger.APIKeySummary {
+	return swagger.APIKeySummary{
+		ID:        k.ID,
+		Name:      k.Name,
+		Role:      k.Role,
+		Scopes:    k.Scopes,
+		Expiry:    k.Expiry,
+		CreatedBy: k.CreatedBy,
+	}
 }
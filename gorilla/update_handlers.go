@@ -8,6 +8,7 @@ import (
 	"net/http"
 
 	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/grpc/node"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -18,12 +19,7 @@ func handleUpdateNodes(
 	ps cce.PersistenceService,
 	e cce.Validatable,
 ) (statusCode int, err error) {
-	ctrl := getController(ctx)
-	nodePort := ctrl.ELAPort
-	if nodePort == "" {
-		nodePort = defaultELAPort
-	}
-	nodeCC, err := connectNode(ctx, ps, &e.(*cce.NodeReq).Node, nodePort, ctrl.EdgeNodeCreds)
+	nodeCC, err := connectNode(ctx, ps, &e.(*cce.NodeReq).Node, node.ELA)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -62,11 +58,7 @@ func handleUpdateNodesApps( //nolint: gocyclo
 	e cce.Validatable,
 ) (statusCode int, err error) {
 	ctrl := getController(ctx)
-	nodePort := ctrl.EVAPort
-	if nodePort == "" {
-		nodePort = defaultEVAPort
-	}
-	nodeCC, err := connectNode(ctx, ps, &e.(*cce.NodeAppReq).NodeApp, nodePort, ctrl.EdgeNodeCreds)
+	nodeCC, err := connectNode(ctx, ps, &e.(*cce.NodeAppReq).NodeApp, node.EVA)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
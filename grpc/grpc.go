@@ -10,6 +10,7 @@ import (
 
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 
 	logger "github.com/open-ness/common/log"
@@ -43,6 +44,11 @@ func Dial(ctx context.Context, target string, conf *tls.Config, opts ...grpc.Dia
 	return &ClientConn{conn}, nil
 }
 
+// GetState wraps grpc.GetState()
+func (c *ClientConn) GetState() connectivity.State {
+	return c.conn.GetState()
+}
+
 // Close wraps grpc.Close()
 func (c *ClientConn) Close() error {
 	return c.conn.Close()
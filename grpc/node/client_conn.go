@@ -6,20 +6,53 @@ package node
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 
 	cce "github.com/open-ness/edgecontroller"
 	"github.com/open-ness/edgecontroller/grpc"
 	gclients "github.com/open-ness/edgecontroller/grpc/clients"
+	"github.com/pkg/errors"
 	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 )
 
+// ErrNoTarget is returned by Connect for a node that has no gRPC target, as
+// it has not enrolled yet.
+var ErrNoTarget = errors.New("node has no gRPC target")
+
+// Service is a gRPC server of a node.
+type Service int
+
+// Services of a node
+const (
+	// ELA serves the application policy, interface, interface policy, DNS and
+	// zone services.
+	ELA Service = iota
+	// EVA serves the application deployment and lifecycle services.
+	EVA
+)
+
+func (s Service) String() string {
+	switch s {
+	case ELA:
+		return "ELA"
+	case EVA:
+		return "EVA"
+	default:
+		return fmt.Sprintf("Service(%d)", int(s))
+	}
+}
+
 // ClientConn wraps a Node and provides a Connect() method to create wrapped gRPC clients.
 type ClientConn struct {
-	Addr string
-	Port string
-	TLS  *tls.Config
+	Addr    string
+	Service Service
+	TLS     *tls.Config
 
 	conn *grpc.ClientConn
+	// pooled is set for the connections of a Pool, which are not closed by
+	// Disconnect
+	pooled bool
 
 	AppDeploySvcCli   *gclients.ApplicationDeploymentServiceClient
 	AppLifeSvcCli     *gclients.ApplicationLifecycleServiceClient
@@ -30,37 +63,106 @@ type ClientConn struct {
 	ZoneSvcCli        *gclients.ZoneServiceClient
 }
 
-// Connect connects to a node via grpc.Dial.
-func (cc *ClientConn) Connect(ctx context.Context) error {
+// Connect connects to the Service of a node via grpc.Dial. The node is
+// dialed through the connection it opened to cce.PrefaceLis. opts are added
+// to the dial options.
+func (cc *ClientConn) Connect(ctx context.Context, opts ...ggrpc.DialOption) error {
 	var err error
 
-	if cc.Port == "42102" { // XXX use the actual variable with this!
+	switch cc.Service {
+	case EVA:
 		// OP-1742: ContextDialler not supported by Gateway
 		//nolint:staticcheck
 		cc.conn, err = grpc.Dial(ctx, cc.Addr, cc.TLS,
-			ggrpc.WithDialer(cce.PrefaceLis.DialEva))
+			append(opts, ggrpc.WithDialer(cce.PrefaceLis.DialEva))...)
 
-		// EVA
 		cc.AppDeploySvcCli = gclients.NewApplicationDeploymentServiceClient(cc.conn)
 		cc.AppLifeSvcCli = gclients.NewApplicationLifecycleServiceClient(cc.conn)
-	} else {
+	case ELA:
 		// OP-1742: ContextDialler not supported by Gateway
 		//nolint:staticcheck
 		cc.conn, err = grpc.Dial(ctx, cc.Addr, cc.TLS,
-			ggrpc.WithDialer(cce.PrefaceLis.DialEla))
+			append(opts, ggrpc.WithDialer(cce.PrefaceLis.DialEla))...)
 
-		// ELA
 		cc.AppPolicySvcCli = gclients.NewApplicationPolicyServiceClient(cc.conn)
 		cc.IfacePolicySvcCli = gclients.NewInterfacePolicyServiceClient(cc.conn)
 		cc.DNSSvcCli = gclients.NewDNSServiceClient(cc.conn)
 		cc.IfaceSvcCli = gclients.NewInterfaceServiceClient(cc.conn)
 
 		cc.ZoneSvcCli = gclients.NewZoneServiceClient(cc.conn) // XXX unimplemented?
+	default:
+		return errors.Errorf("unknown service %v", cc.Service)
 	}
 
 	return err
 }
 
+// Connect connects to a Service of a node at the gRPC target it last enrolled
+// from. The node's certificate is verified with a clone of conf whose server
+// name is the node ID.
+func Connect(
+	ctx context.Context,
+	ps cce.PersistenceService,
+	nodeID string,
+	svc Service,
+	conf *tls.Config,
+) (*ClientConn, error) {
+	target, err := readTarget(ctx, ps, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	cc := &ClientConn{Addr: target, Service: svc, TLS: serverConf(conf, nodeID)}
+	if err = cc.Connect(ctx); err != nil {
+		return nil, errors.Wrap(err, "could not connect to node")
+	}
+
+	return cc, nil
+}
+
+// readTarget returns the gRPC target a node last enrolled from.
+func readTarget(ctx context.Context, ps cce.PersistenceService, nodeID string) (string, error) {
+	targets, err := ps.Filter(ctx, &cce.NodeGRPCTarget{}, []cce.Filter{{Field: "node_id", Value: nodeID}})
+	if err != nil {
+		return "", errors.Wrap(err, "could not fetch gRPC target from DB")
+	}
+	if len(targets) == 0 {
+		return "", ErrNoTarget
+	}
+
+	return targets[0].(*cce.NodeGRPCTarget).GRPCTarget, nil
+}
+
+// serverConf returns a clone of conf whose server name is the node ID.
+func serverConf(conf *tls.Config, nodeID string) *tls.Config {
+	if conf == nil {
+		return nil
+	}
+	conf = conf.Clone()
+	conf.ServerName = nodeID
+	return conf
+}
+
+// Disconnect closes the connection of a connected ClientConn. The connections
+// of a Pool stay open to be reused and are closed by the Pool.
 func (cc *ClientConn) Disconnect() {
-	cc.conn.Close()
+	if cc.conn != nil && !cc.pooled {
+		cc.conn.Close()
+	}
+}
+
+// state returns the connectivity state of the connection, or Idle if it was
+// not connected.
+func (cc *ClientConn) state() connectivity.State {
+	if cc.conn == nil {
+		return connectivity.Idle
+	}
+	return cc.conn.GetState()
+}
+
+// close closes the connection, also of a pooled ClientConn.
+func (cc *ClientConn) close() {
+	if cc.conn != nil {
+		cc.conn.Close()
+	}
 }
@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package node
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+	"time"
+
+	logger "github.com/open-ness/common/log"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/pkg/errors"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	_ "google.golang.org/grpc/health" // registers the client side health check
+	"google.golang.org/grpc/keepalive"
+)
+
+var log = logger.DefaultLogger.WithField("pkg", "node")
+
+// DefaultIdleTimeout is the IdleTimeout of a Pool created by NewPool.
+const DefaultIdleTimeout = 10 * time.Minute
+
+// serviceConfig enables the gRPC health checking of pooled connections. The
+// health checks need a load balancer supporting them, so round_robin is used
+// for the single address of a node. Nodes not serving the health service are
+// considered healthy.
+const serviceConfig = `{
+	"loadBalancingConfig": [{"round_robin": {}}],
+	"healthCheckConfig": {"serviceName": ""}
+}`
+
+// Pool keeps one connection to each Service of a node, so that requests to a
+// node do not each dial it and pay for a TLS handshake. It is safe for
+// concurrent use.
+//
+// Pooled connections are health checked by gRPC and reconnect with an
+// exponential backoff while the node is unreachable. Before a connection is
+// reused, the gRPC target and the certificate of its node are read from the
+// DB and the connection is replaced if either changed since it was dialed,
+// e.g. because the node enrolled again from another address or its
+// credentials were renewed or revoked. Reading them from the DB also notices
+// the changes made by other controllers sharing it.
+type Pool struct {
+	// TLS are the transport credentials for connecting to nodes. The server
+	// name is overridden with the node ID.
+	TLS *tls.Config
+	// IdleTimeout is the time after which Run closes unused connections. They
+	// are kept until Close if it is 0.
+	IdleTimeout time.Duration
+	// Connect connects a ClientConn with the dial options of the pool. It is
+	// (*ClientConn).Connect by default.
+	Connect func(ctx context.Context, cc *ClientConn, opts ...ggrpc.DialOption) error
+
+	mu    sync.Mutex
+	conns map[poolKey]*pooledConn
+}
+
+type poolKey struct {
+	nodeID string
+	svc    Service
+}
+
+type pooledConn struct {
+	cc *ClientConn
+	// certificate is the certificate of the node when cc was dialed
+	certificate string
+	lastUsed    time.Time
+}
+
+// NewPool creates a Pool with the default settings.
+func NewPool(conf *tls.Config) *Pool {
+	return &Pool{
+		TLS:         conf,
+		IdleTimeout: DefaultIdleTimeout,
+		Connect: func(ctx context.Context, cc *ClientConn, opts ...ggrpc.DialOption) error {
+			return cc.Connect(ctx, opts...)
+		},
+		conns: make(map[poolKey]*pooledConn),
+	}
+}
+
+// Get returns the connection to a Service of a node, dialing it if there is
+// none. The gRPC target and certificate of the node are read with ps, which
+// is the transaction of the caller, if any. The connection stays open for
+// later calls and must not be used after the Pool is closed. Disconnect does
+// not close it.
+func (p *Pool) Get(ctx context.Context, ps cce.PersistenceService, nodeID string, svc Service) (*ClientConn, error) {
+	target, err := readTarget(ctx, ps, nodeID)
+	if err == ErrNoTarget {
+		p.Invalidate(nodeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := readCertificate(ctx, ps, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := poolKey{nodeID, svc}
+	if pc, ok := p.conns[key]; ok {
+		switch {
+		case pc.cc.Addr != target:
+			log.Infof("gRPC target of node %s changed, reconnecting to %s", nodeID, svc)
+		case pc.certificate != certificate:
+			log.Infof("Certificate of node %s changed, reconnecting to %s", nodeID, svc)
+		case pc.cc.state() == connectivity.Shutdown:
+			log.Infof("Connection to %s of node %s was shut down, reconnecting", svc, nodeID)
+		default:
+			pc.lastUsed = time.Now()
+			return pc.cc, nil
+		}
+		pc.cc.close()
+		delete(p.conns, key)
+	}
+
+	cc := &ClientConn{Addr: target, Service: svc, TLS: serverConf(p.TLS, nodeID), pooled: true}
+	if err = p.Connect(ctx, cc, dialOptions()...); err != nil {
+		return nil, errors.Wrap(err, "could not connect to node")
+	}
+	p.conns[key] = &pooledConn{cc: cc, certificate: certificate, lastUsed: time.Now()}
+
+	return cc, nil
+}
+
+// readCertificate returns the certificate of a node, or an empty string if it
+// has no credentials.
+func readCertificate(ctx context.Context, ps cce.PersistenceService, nodeID string) (string, error) {
+	creds, err := ps.Read(ctx, nodeID, &cce.Credentials{})
+	if err != nil {
+		return "", errors.Wrap(err, "could not fetch node credentials from DB")
+	}
+	if creds == nil {
+		return "", nil
+	}
+
+	return creds.(*cce.Credentials).Certificate, nil
+}
+
+// dialOptions returns the options of pooled connections. The keepalive pings
+// are not sent more often than the 5 minutes gRPC servers permit by default.
+func dialOptions() []ggrpc.DialOption {
+	return []ggrpc.DialOption{
+		ggrpc.WithDefaultServiceConfig(serviceConfig),
+		ggrpc.WithConnectParams(ggrpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  time.Second,
+				Multiplier: 1.6,
+				Jitter:     0.2,
+				MaxDelay:   30 * time.Second,
+			},
+			MinConnectTimeout: 20 * time.Second,
+		}),
+		ggrpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    5 * time.Minute,
+			Timeout: 20 * time.Second,
+		}),
+	}
+}
+
+// Invalidate closes the connections to a node, so that the next Get dials it
+// again.
+func (p *Pool) Invalidate(nodeID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, pc := range p.conns {
+		if key.nodeID == nodeID {
+			pc.cc.close()
+			delete(p.conns, key)
+		}
+	}
+}
+
+// Run closes the connections unused for IdleTimeout until ctx is done, and
+// then closes the Pool.
+func (p *Pool) Run(ctx context.Context) error {
+	defer p.Close()
+
+	if p.IdleTimeout <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(p.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		p.CloseIdle(time.Now().Add(-p.IdleTimeout))
+	}
+}
+
+// CloseIdle closes the connections last used before t.
+func (p *Pool) CloseIdle(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, pc := range p.conns {
+		if pc.lastUsed.Before(t) {
+			log.Debugf("Closing idle connection to %s of node %s", key.svc, key.nodeID)
+			pc.cc.close()
+			delete(p.conns, key)
+		}
+	}
+}
+
+// Close closes all connections.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, pc := range p.conns {
+		pc.cc.close()
+		delete(p.conns, key)
+	}
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package node_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/grpc/node"
+	"github.com/open-ness/edgecontroller/memory"
+	ggrpc "google.golang.org/grpc"
+)
+
+var _ = Describe("Pool", func() {
+	const nodeID = "9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f"
+
+	var (
+		ctx        = context.Background()
+		ps         cce.PersistenceService
+		pool       *node.Pool
+		target     *cce.NodeGRPCTarget
+		creds      *cce.Credentials
+		dialed     []*node.ClientConn
+		connectErr error
+	)
+
+	BeforeEach(func() {
+		var err error
+		ps, err = memory.NewPersistenceService("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ps.Create(ctx, &cce.Node{
+			ID:       nodeID,
+			Name:     "node123",
+			Location: "Localhost port 42101",
+			Serial:   "ABCD",
+		})).To(Succeed())
+		target = &cce.NodeGRPCTarget{ID: "a1", NodeID: nodeID, GRPCTarget: "192.0.2.1"}
+		Expect(ps.Create(ctx, target)).To(Succeed())
+		creds = &cce.Credentials{ID: nodeID, Certificate: "cert1"}
+		Expect(ps.Create(ctx, creds)).To(Succeed())
+
+		dialed = nil
+		connectErr = nil
+		pool = node.NewPool(nil)
+		pool.Connect = func(ctx context.Context, cc *node.ClientConn, opts ...ggrpc.DialOption) error {
+			if connectErr != nil {
+				return connectErr
+			}
+			dialed = append(dialed, cc)
+			return nil
+		}
+	})
+
+	AfterEach(func() {
+		pool.Close()
+	})
+
+	It("reuses the connection to each service of a node", func() {
+		ela, err := pool.Get(ctx, ps, nodeID, node.ELA)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ela.Addr).To(Equal("192.0.2.1"))
+		Expect(ela.Service).To(Equal(node.ELA))
+
+		eva, err := pool.Get(ctx, ps, nodeID, node.EVA)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(eva.Service).To(Equal(node.EVA))
+
+		ela.Disconnect()
+		Expect(pool.Get(ctx, ps, nodeID, node.ELA)).To(BeIdenticalTo(ela))
+		Expect(pool.Get(ctx, ps, nodeID, node.EVA)).To(BeIdenticalTo(eva))
+		Expect(dialed).To(HaveLen(2))
+	})
+
+	It("reconnects when the gRPC target of the node changed", func() {
+		ela, err := pool.Get(ctx, ps, nodeID, node.ELA)
+		Expect(err).ToNot(HaveOccurred())
+
+		target.GRPCTarget = "192.0.2.2"
+		Expect(ps.BulkUpdate(ctx, []cce.Persistable{target})).To(Succeed())
+
+		reconnected, err := pool.Get(ctx, ps, nodeID, node.ELA)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reconnected).ToNot(BeIdenticalTo(ela))
+		Expect(reconnected.Addr).To(Equal("192.0.2.2"))
+		Expect(dialed).To(HaveLen(2))
+	})
+
+	It("reconnects when the certificate of the node changed", func() {
+		ela, err := pool.Get(ctx, ps, nodeID, node.ELA)
+		Expect(err).ToNot(HaveOccurred())
+
+		creds.Certificate = "cert2"
+		Expect(ps.BulkUpdate(ctx, []cce.Persistable{creds})).To(Succeed())
+		renewed, err := pool.Get(ctx, ps, nodeID, node.ELA)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(renewed).ToNot(BeIdenticalTo(ela))
+
+		Expect(ps.Delete(ctx, creds.ID, &cce.Credentials{})).To(BeTrue())
+		revoked, err := pool.Get(ctx, ps, nodeID, node.ELA)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(revoked).ToNot(BeIdenticalTo(renewed))
+		Expect(dialed).To(HaveLen(3))
+	})
+
+	It("reads the node in the transaction of the caller", func() {
+		Expect(ps.WithTx(ctx, func(tx cce.PersistenceService) error {
+			target.GRPCTarget = "192.0.2.2"
+			Expect(tx.BulkUpdate(ctx, []cce.Persistable{target})).To(Succeed())
+
+			ela, err := pool.Get(ctx, tx, nodeID, node.ELA)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ela.Addr).To(Equal("192.0.2.2"))
+			return nil
+		})).To(Succeed())
+	})
+
+	It("fails for a node without a gRPC target", func() {
+		Expect(pool.Get(ctx, ps, nodeID, node.ELA)).ToNot(BeNil())
+
+		Expect(ps.Delete(ctx, target.ID, &cce.NodeGRPCTarget{})).To(BeTrue())
+		_, err := pool.Get(ctx, ps, nodeID, node.ELA)
+		Expect(err).To(Equal(node.ErrNoTarget))
+	})
+
+	It("does not pool failed connections", func() {
+		connectErr = errors.New("dial failed")
+		_, err := pool.Get(ctx, ps, nodeID, node.ELA)
+		Expect(err).To(MatchError(ContainSubstring("dial failed")))
+
+		connectErr = nil
+		Expect(pool.Get(ctx, ps, nodeID, node.ELA)).ToNot(BeNil())
+		Expect(dialed).To(HaveLen(1))
+	})
+
+	It("reconnects after the connections were invalidated", func() {
+		ela, err := pool.Get(ctx, ps, nodeID, node.ELA)
+		Expect(err).ToNot(HaveOccurred())
+
+		pool.Invalidate(nodeID)
+		Expect(pool.Get(ctx, ps, nodeID, node.ELA)).ToNot(BeIdenticalTo(ela))
+		Expect(dialed).To(HaveLen(2))
+	})
+
+	It("closes idle connections", func() {
+		ela, err := pool.Get(ctx, ps, nodeID, node.ELA)
+		Expect(err).ToNot(HaveOccurred())
+
+		pool.CloseIdle(time.Now().Add(-time.Minute))
+		Expect(pool.Get(ctx, ps, nodeID, node.ELA)).To(BeIdenticalTo(ela))
+
+		pool.CloseIdle(time.Now().Add(time.Minute))
+		Expect(pool.Get(ctx, ps, nodeID, node.ELA)).ToNot(BeIdenticalTo(ela))
+		Expect(dialed).To(HaveLen(2))
+	})
+})
+
+var _ = Describe("ClientConn", func() {
+	It("fails to connect to an unknown service", func() {
+		cc := &node.ClientConn{Addr: "192.0.2.1", Service: node.Service(7)}
+		Expect(cc.Connect(context.Background())).To(MatchError("unknown service Service(7)"))
+	})
+})
@@ -5,14 +5,13 @@ package grpc
 
 import (
 	"context"
-	"crypto/md5" //nolint:gosec
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/base64"
 	"encoding/pem"
 	"fmt"
 	"net"
+	"time"
 
 	"google.golang.org/grpc"
 
@@ -24,7 +23,9 @@ import (
 	cce "github.com/open-ness/edgecontroller"
 	authpb "github.com/open-ness/edgecontroller/pb/auth"
 	evapb "github.com/open-ness/edgecontroller/pb/eva"
+	hbpb "github.com/open-ness/edgecontroller/pb/heartbeat"
 	"github.com/open-ness/edgecontroller/uuid"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -52,53 +53,54 @@ type Server struct {
 
 // NewServer creates a new Server.
 func NewServer(controller *cce.Controller, conf *tls.Config) *Server {
-	s := &Server{
-		controller: controller,
-		grpc: grpc.NewServer(
-			grpc.Creds(credentials.NewTLS(conf)),
-			grpc.UnaryInterceptor(
-				func(
-					ctx context.Context,
-					req interface{},
-					info *grpc.UnaryServerInfo,
-					handler grpc.UnaryHandler,
-				) (resp interface{}, err error) {
-					// apply checkAuth middleware
-					if err := checkAuth(ctx,
-						info.FullMethod); err != nil {
-						return nil, err
-					}
-					return handler(ctx, req)
-				},
-			),
-			grpc.StreamInterceptor(
-				func(
-					srv interface{},
-					ss grpc.ServerStream,
-					info *grpc.StreamServerInfo,
-					handler grpc.StreamHandler,
-				) error {
-					// apply checkAuth middleware
-					if err := checkAuth(ss.Context(),
-						info.FullMethod); err != nil {
-						return err
-					}
-					return handler(srv, ss)
-				},
-			),
+	s := &Server{controller: controller}
+	s.grpc = grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(conf)),
+		grpc.UnaryInterceptor(
+			func(
+				ctx context.Context,
+				req interface{},
+				info *grpc.UnaryServerInfo,
+				handler grpc.UnaryHandler,
+			) (resp interface{}, err error) {
+				// apply checkAuth middleware
+				if err := s.checkAuth(ctx,
+					info.FullMethod); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			},
 		),
-	}
+		grpc.StreamInterceptor(
+			func(
+				srv interface{},
+				ss grpc.ServerStream,
+				info *grpc.StreamServerInfo,
+				handler grpc.StreamHandler,
+			) error {
+				// apply checkAuth middleware
+				if err := s.checkAuth(ss.Context(),
+					info.FullMethod); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			},
+		),
+	)
 
 	authpb.RegisterAuthServiceServer(s.grpc, s)
 	evapb.RegisterControllerVirtualizationAgentServer(s.grpc, s)
+	hbpb.RegisterHeartbeatServiceServer(s.grpc, s)
 
 	return s
 }
 
 // checkAuth is a middleware, applied inside the unary and stream interceptors,
 // to ensure that if the enrollment server config was used (i.e. no client cert
-// was provided) that only the enrollment endpoint is authorized.
-func checkAuth(ctx context.Context, method string) error {
+// was provided) that only the enrollment endpoint is authorized. Otherwise the
+// client certificate must not have been revoked since the connection was
+// established.
+func (s *Server) checkAuth(ctx context.Context, method string) error {
 	p, ok := peer.FromContext(ctx)
 	if !ok {
 		return fmt.Errorf("expected peer info in gRPC context")
@@ -115,6 +117,10 @@ func checkAuth(ctx context.Context, method string) error {
 		}
 		return nil
 	case SNI:
+		revocations := &cce.CertificateRevocations{PersistenceService: s.controller.PersistenceService}
+		if err := revocations.VerifyPeerCertificate(nil, tlsInfo.State.VerifiedChains); err != nil {
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
 		return nil
 	default:
 		return fmt.Errorf("unexpected server name: %s", tlsInfo.State.ServerName)
@@ -137,12 +143,173 @@ func (s *Server) Stop() {
 }
 
 // RequestCredentials requests authentication endpoint credentials.
-func (s *Server) RequestCredentials(ctx context.Context, id *authpb.Identity) ( // nolint: gocyclo
-	*authpb.Credentials,
-	error,
-) {
-	// Parse and validate CSR
-	csr := id.GetCsr()
+func (s *Server) RequestCredentials(ctx context.Context, id *authpb.Identity) (*authpb.Credentials, error) {
+	certReq, err := parseCSR(id.GetCsr())
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the Node's IP address
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "missing peer data from context")
+	}
+	nodeIP, _, err := net.SplitHostPort(p.Addr.String())
+	if nodeIP == "" || err != nil {
+		return nil, status.Errorf(codes.Internal, "bad remote address in peer data: %s: %v",
+			p.Addr.String(), err)
+	}
+
+	node, err := s.approvedNode(ctx, certReq, id.GetEnrollmentToken(), nodeIP)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := s.issueCredentials(ctx, node, certReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store the Node's address, replacing the address it enrolled from before
+	if err = s.storeGRPCTarget(ctx, node.ID, nodeIP); err != nil {
+		log.Errf("Failed to store Node address: %v", err)
+		return nil, status.Error(codes.Internal, "unable to store node address")
+	}
+	// Also let the proxy node we have a new client
+	cce.RegisterToProxy(ctx, s.controller.PersistenceService, node.ID)
+	s.controller.Notify(ctx, cce.NotificationNodeEnrolled, node)
+
+	return creds, nil
+}
+
+// approvedNode returns the node of a CSR, which is either pre-approved by its
+// serial or created by redeeming an enrollment token. The unknown nodes
+// without a token are queued for approval, if enabled.
+func (s *Server) approvedNode(
+	ctx context.Context,
+	certReq *x509.CertificateRequest,
+	token string,
+	nodeIP string,
+) (*cce.Node, error) {
+	serial := cce.NodeSerial(certReq.RawSubjectPublicKeyInfo)
+
+	if token != "" {
+		var node *cce.Node
+		err := s.controller.PersistenceService.WithTx(ctx, func(tx cce.PersistenceService) error {
+			var txErr error
+			node, txErr = (&cce.NodeEnrollments{PersistenceService: tx}).RedeemToken(ctx, token, serial)
+			return txErr
+		})
+		switch errors.Cause(err) {
+		case nil:
+			log.Infof("Node %s enrolled with serial %s by enrollment token", node.ID, serial)
+			return node, nil
+		case cce.ErrInvalidEnrollmentToken:
+			return nil, status.Error(codes.Unauthenticated, "invalid enrollment token")
+		case cce.ErrSerialInUse:
+			return nil, status.Errorf(codes.AlreadyExists, "node %s already exists", serial)
+		default:
+			log.Errf("error redeeming enrollment token: %v", err)
+			return nil, status.Error(codes.Internal, "unable to redeem enrollment token")
+		}
+	}
+
+	// Verify the Node's pre-approval by public key data
+	node, err := cce.FindNodeBySerial(
+		ctx, s.controller.PersistenceService, certReq.RawSubjectPublicKeyInfo, s.controller.AcceptLegacySerials)
+	if err != nil {
+		log.Errf("error getting node approval: %v", err)
+		return nil, status.Errorf(codes.Unauthenticated, "node %s not approved", serial)
+	}
+	if node != nil {
+		return node, nil
+	}
+	if s.controller.MaxPendingEnrollments <= 0 {
+		return nil, status.Errorf(codes.Unauthenticated, "node %s not approved", serial)
+	}
+
+	return nil, s.requestEnrollment(ctx, certReq, serial, nodeIP)
+}
+
+// requestEnrollment queues an unknown node for approval and returns the
+// status error the node is refused with.
+func (s *Server) requestEnrollment(
+	ctx context.Context,
+	certReq *x509.CertificateRequest,
+	serial string,
+	nodeIP string,
+) error {
+	enrollment := &cce.Enrollment{
+		Serial:      serial,
+		PeerIP:      nodeIP,
+		Subject:     certReq.Subject.String(),
+		RequestedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	enrollments := &cce.NodeEnrollments{
+		PersistenceService: s.controller.PersistenceService,
+		MaxPending:         s.controller.MaxPendingEnrollments,
+	}
+	created, err := enrollments.Request(ctx, enrollment)
+	switch errors.Cause(err) {
+	case nil:
+	case cce.ErrEnrollmentRejected:
+		return status.Errorf(codes.PermissionDenied, "node %s enrollment rejected", serial)
+	case cce.ErrEnrollmentQueueFull:
+		log.Warningf("Enrollment of node %s from %s not queued: %v", serial, nodeIP, err)
+		return status.Errorf(codes.Unauthenticated, "node %s not approved", serial)
+	default:
+		log.Errf("error queueing enrollment: %v", err)
+		return status.Errorf(codes.Unauthenticated, "node %s not approved", serial)
+	}
+
+	if created {
+		log.Infof("Enrollment %s of node %s from %s pending approval", enrollment.ID, serial, nodeIP)
+		s.controller.Notify(ctx, cce.NotificationEnrollmentPending, enrollment)
+	}
+
+	return status.Errorf(codes.Unauthenticated, "node %s not approved, enrollment %s pending approval",
+		serial, enrollment.ID)
+}
+
+// RenewCredentials issues a new certificate to an enrolled node, which must
+// authenticate with its current certificate. The CSR must be for the public
+// key the node was approved with. The renewed certificate supersedes the
+// current one, which is revoked.
+func (s *Server) RenewCredentials(ctx context.Context, id *authpb.Identity) (*authpb.Credentials, error) {
+	nodeID, err := getNodeID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	certReq, err := parseCSR(id.GetCsr())
+	if err != nil {
+		return nil, err
+	}
+
+	persisted, err := s.controller.PersistenceService.Read(ctx, nodeID, &cce.Node{})
+	if err != nil {
+		log.Errf("error getting node %s: %v", nodeID, err)
+		return nil, status.Error(codes.Internal, "unable to get node")
+	}
+	if persisted == nil {
+		return nil, status.Errorf(codes.Unauthenticated, "node %s not approved", nodeID)
+	}
+	node := persisted.(*cce.Node)
+
+	if err = s.checkNodeSerial(ctx, node, certReq); err != nil {
+		return nil, err
+	}
+
+	creds, err := s.issueCredentials(ctx, node, certReq)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Renewed credentials of node %s", nodeID)
+
+	return creds, nil
+}
+
+// parseCSR parses and validates a PEM-encoded CSR.
+func parseCSR(csr string) (*x509.CertificateRequest, error) {
 	if csr == "" {
 		return nil, status.Error(codes.InvalidArgument, "CSR cannot be empty")
 	}
@@ -163,24 +330,36 @@ func (s *Server) RequestCredentials(ctx context.Context, id *authpb.Identity) (
 		return nil, status.Errorf(codes.InvalidArgument, "error validating CSR: %v", err)
 	}
 
-	// Node's identity is base64-encoded (w/o padding) MD5 hash of the public key data
-	// gosec: not hashing user input/passwords
-	hash := md5.Sum(certReq.RawSubjectPublicKeyInfo) //nolint:gosec
-	serial := base64.RawURLEncoding.EncodeToString(hash[:])
+	return certReq, nil
+}
+
+// checkNodeSerial checks that the CSR is for the public key a node was
+// approved with. A legacy serial of the key is migrated if accepted.
+func (s *Server) checkNodeSerial(ctx context.Context, node *cce.Node, certReq *x509.CertificateRequest) error {
+	pubKey := certReq.RawSubjectPublicKeyInfo
+	if node.Serial == cce.NodeSerial(pubKey) {
+		return nil
+	}
+	if !s.controller.AcceptLegacySerials || node.Serial != cce.LegacyNodeSerial(pubKey) {
+		return status.Errorf(codes.PermissionDenied, "CSR public key does not match node %s", node.ID)
+	}
 
-	// Verify the Node's pre-approval by public key data
-	entities, err := s.controller.PersistenceService.Filter(ctx, &cce.Node{}, []cce.Filter{{
-		Field: "serial",
-		Value: serial,
-	}})
-	if err != nil || len(entities) == 0 {
-		if err != nil {
-			log.Errf("error getting node approval: %v", err)
-		}
-		return nil, status.Errorf(codes.Unauthenticated, "node %s not approved", serial)
+	if err := cce.MigrateNodeSerial(ctx, s.controller.PersistenceService, node, pubKey); err != nil {
+		log.Errf("error migrating serial of node %s: %v", node.ID, err)
+		return status.Error(codes.Internal, "unable to migrate node serial")
 	}
-	node := entities[0].(*cce.Node)
+	log.Infof("Migrated node %s from its legacy serial to %s", node.ID, node.Serial)
+
+	return nil
+}
 
+// issueCredentials signs the CSR of a node and stores the certificate as its
+// credentials, revoking the certificate it replaces.
+func (s *Server) issueCredentials(
+	ctx context.Context,
+	node *cce.Node,
+	certReq *x509.CertificateRequest,
+) (*authpb.Credentials, error) {
 	// Sign cert request
 	cert, err := s.controller.AuthorityService.SignCSR(
 		certReq.Raw,
@@ -219,43 +398,35 @@ func (s *Server) RequestCredentials(ctx context.Context, id *authpb.Identity) (
 		chainPEM = append(chainPEM, string(caPEM))
 	}
 
-	// Add the root CA to the Node's CA pool
+	// Add the root CA to the Node's CA pool, along with the root CAs of the
+	// Controller's TLS certificates if another CA signs the nodes'
 	caPoolPEM := chainPEM[len(chainPEM)-1:]
+	for _, caCert := range s.controller.ServerCAs {
+		if caCert.Equal(caChain[len(caChain)-1]) {
+			continue
+		}
+		caPoolPEM = append(caPoolPEM, string(pem.EncodeToMemory(
+			&pem.Block{
+				Type:  "CERTIFICATE",
+				Bytes: caCert.Raw,
+			},
+		)))
+	}
 
 	// Store Node credentials
 	creds := &cce.Credentials{
 		ID:          node.ID,
 		Certificate: string(certPEM),
 	}
-	if err = s.controller.PersistenceService.Create(ctx, creds); err != nil {
+	err = s.controller.PersistenceService.WithTx(ctx, func(tx cce.PersistenceService) error {
+		revocations := &cce.CertificateRevocations{PersistenceService: tx}
+		return revocations.ReplaceNodeCredentials(ctx, creds)
+	})
+	if err != nil {
 		log.Errf("Failed to store Node credentials: %v", err)
 		return nil, status.Error(codes.Internal, "unable to store credentials")
 	}
 
-	// Get the Node's IP address
-	p, ok := peer.FromContext(ctx)
-	if !ok {
-		return nil, status.Error(codes.Internal, "missing peer data from context")
-	}
-	nodeIP, _, err := net.SplitHostPort(p.Addr.String())
-	if nodeIP == "" || err != nil {
-		return nil, status.Errorf(codes.Internal, "bad remote address in peer data: %s: %v",
-			p.Addr.String(), err)
-	}
-
-	// Store the Node's address
-	nodeWithTarget := &cce.NodeGRPCTarget{
-		ID:         uuid.New(),
-		NodeID:     node.ID,
-		GRPCTarget: nodeIP,
-	}
-	if err := s.controller.PersistenceService.Create(ctx, nodeWithTarget); err != nil {
-		log.Errf("Failed to store Node address: %v", err)
-		return nil, status.Error(codes.Internal, "unable to store node address")
-	}
-	// Also let the proxy node we have a new client
-	cce.RegisterToProxy(ctx, s.controller.PersistenceService, node.ID)
-
 	return &authpb.Credentials{
 		Certificate: creds.Certificate,
 		CaChain:     chainPEM,
@@ -263,6 +434,29 @@ func (s *Server) RequestCredentials(ctx context.Context, id *authpb.Identity) (
 	}, nil
 }
 
+// storeGRPCTarget stores the address of a node, updating the address it had.
+func (s *Server) storeGRPCTarget(ctx context.Context, nodeID, nodeIP string) error {
+	targets, err := s.controller.PersistenceService.Filter(ctx, &cce.NodeGRPCTarget{}, []cce.Filter{{
+		Field: "node_id",
+		Value: nodeID,
+	}})
+	if err != nil {
+		return err
+	}
+
+	if len(targets) > 0 {
+		target := targets[0].(*cce.NodeGRPCTarget)
+		target.GRPCTarget = nodeIP
+		return s.controller.PersistenceService.BulkUpdate(ctx, []cce.Persistable{target})
+	}
+
+	return s.controller.PersistenceService.Create(ctx, &cce.NodeGRPCTarget{
+		ID:         uuid.New(),
+		NodeID:     nodeID,
+		GRPCTarget: nodeIP,
+	})
+}
+
 // GetContainerByIP retrieves info of deployed application with IP provided
 func (s *Server) GetContainerByIP(ctx context.Context, containerIP *evapb.ContainerIP) (*evapb.ContainerInfo, error) {
 	nodeID, err := getNodeID(ctx)
@@ -282,6 +476,46 @@ func (s *Server) GetContainerByIP(ctx context.Context, containerIP *evapb.Contai
 	return &evapb.ContainerInfo{Id: id}, nil
 }
 
+// Heartbeat records a heartbeat of the node and returns the interval of its
+// next heartbeat.
+func (s *Server) Heartbeat(ctx context.Context, req *hbpb.HeartbeatRequest) (*hbpb.HeartbeatResponse, error) {
+	if s.controller.HeartbeatService == nil {
+		return nil, status.Error(codes.Unimplemented, "heartbeats are not monitored")
+	}
+
+	nodeID, err := getNodeID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	node, err := s.controller.PersistenceService.Read(ctx, nodeID, &cce.Node{})
+	if err != nil {
+		log.Errf("Error reading node %s: %v", nodeID, err)
+		return nil, status.Error(codes.Internal, "unable to read node")
+	}
+	if node == nil {
+		return nil, status.Error(codes.NotFound, "node not found")
+	}
+
+	usage := req.GetResources()
+	interval, err := s.controller.HeartbeatService.Heartbeat(ctx, nodeID, &cce.NodeHeartbeat{
+		AgentVersion:  req.GetAgentVersion(),
+		UptimeSeconds: req.GetUptimeSeconds(),
+		Resources: cce.NodeResourceUsage{
+			CPUPercent:       usage.GetCpuPercent(),
+			MemoryUsedBytes:  usage.GetMemoryUsedBytes(),
+			MemoryTotalBytes: usage.GetMemoryTotalBytes(),
+			DiskUsedBytes:    usage.GetDiskUsedBytes(),
+			DiskTotalBytes:   usage.GetDiskTotalBytes(),
+		},
+	})
+	if err != nil {
+		log.Errf("Error recording heartbeat of node %s: %v", nodeID, err)
+		return nil, status.Error(codes.Internal, "unable to record heartbeat")
+	}
+
+	return &hbpb.HeartbeatResponse{IntervalSeconds: uint32(interval / time.Second)}, nil
+}
+
 // getNodeID extracts the node info from the client TLS certificate. A context
 // from a gRPC endpoint must be passed.
 func getNodeID(ctx context.Context) (string, error) {
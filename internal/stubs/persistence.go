@@ -45,12 +45,23 @@ func (ps *PersistenceServiceStub) Read(context.Context, string, cce.Persistable)
 	return nil, nil
 }
 
+func (ps *PersistenceServiceStub) ReadWithRevision(context.Context, string, cce.Persistable) (cce.Persistable,
+	int64, error) {
+	return nil, 0, nil
+}
+
 func (ps *PersistenceServiceStub) Filter(c context.Context, fb cce.Filterable, f []cce.Filter) ([]cce.Persistable,
 	error) {
 	ps.FilterValues = append(ps.FilterValues, f)
 	return ps.FilterRet, ps.FilterErr
 }
 
+func (ps *PersistenceServiceStub) ReadPage(c context.Context, fb cce.Filterable, q cce.Query) ([]cce.Persistable,
+	string, error) {
+	ps.FilterValues = append(ps.FilterValues, q.Filters)
+	return ps.FilterRet, "", ps.FilterErr
+}
+
 func (ps *PersistenceServiceStub) ReadAll(context.Context, cce.Persistable) ([]cce.Persistable, error) {
 	return nil, nil
 }
@@ -60,6 +71,19 @@ func (ps *PersistenceServiceStub) BulkUpdate(c context.Context, p []cce.Persista
 	return ps.BulkUpdateErr
 }
 
+func (ps *PersistenceServiceStub) UpdateIfMatch(c context.Context, p cce.Persistable, rev int64) (bool, error) {
+	ps.BulkUpdateValues = append(ps.BulkUpdateValues, []cce.Persistable{p})
+	return ps.BulkUpdateErr == nil, ps.BulkUpdateErr
+}
+
 func (ps *PersistenceServiceStub) Delete(context.Context, string, cce.Persistable) (bool, error) {
 	return false, nil
 }
+
+func (ps *PersistenceServiceStub) DeleteIfMatch(context.Context, string, cce.Persistable, int64) (bool, error) {
+	return false, nil
+}
+
+func (ps *PersistenceServiceStub) WithTx(c context.Context, fn func(tx cce.PersistenceService) error) error {
+	return fn(ps)
+}
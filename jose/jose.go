@@ -4,60 +4,260 @@
 package jose
 
 import (
-	"crypto"
 	"time"
 
+	"github.com/open-ness/edgecontroller/uuid"
 	"github.com/pkg/errors"
 	"gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
 )
 
+// Token uses
+const (
+	// UseAccess tokens authenticate API requests.
+	UseAccess = "access"
+	// UseRefresh tokens are exchanged for new access tokens.
+	UseRefresh = "refresh"
+)
+
+// Default token lifetimes
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 24 * time.Hour
+)
+
+// Revocations is a list of revoked token and session IDs.
+type Revocations interface {
+	// Revoke revokes id. It only needs to be remembered until expiry, after
+	// which the tokens it identifies have expired anyway.
+	Revoke(id string, expiry time.Time) error
+	// IsRevoked returns whether id has been revoked.
+	IsRevoked(id string) (bool, error)
+	// RevokeSubject revokes the tokens of subject issued until now, which
+	// all expire by expiry.
+	RevokeSubject(subject string, expiry time.Time) error
+	// SubjectRevokedAt returns the time until which the tokens of subject
+	// have been revoked, or the zero time if they have not.
+	SubjectRevokedAt(subject string) (time.Time, error)
+}
+
 // JWSTokenIssuer issues and validates JSON web signature tokens.
+//
+// A login issues an access token and a refresh token that share a session
+// ID. The refresh token is exchanged for new access tokens of the session
+// until it expires or the session is revoked.
+//
+// Tokens are signed with the current key of Keys and carry its ID in their
+// kid header. They are verified with the key of that ID, so tokens signed
+// with a retired key stay valid until they expire.
 type JWSTokenIssuer struct {
-	Key          crypto.PrivateKey
-	KeyAlgorithm string
+	Keys *KeyRing
+
+	// AccessTokenTTL and RefreshTokenTTL are the lifetimes of the tokens. If
+	// zero DefaultAccessTokenTTL and DefaultRefreshTokenTTL are used.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Revocations are checked by Validate and ValidateRefresh. If nil tokens
+	// cannot be revoked.
+	Revocations Revocations
+}
+
+// Claims are the claims of the tokens issued by JWSTokenIssuer.
+type Claims struct {
+	jwt.Claims
+	// Session is the ID of the session the token belongs to
+	Session string `json:"sid"`
+	// Use is either UseAccess or UseRefresh
+	Use string `json:"use"`
+	// Role is the role of the subject
+	Role string `json:"role,omitempty"`
+}
+
+// Issue starts a new session for the subject with the given role and issues
+// its access and refresh tokens signed with the authority key. The signed JWT
+// tokens are returned in the RFC 7519 compact serialization format.
+func (s *JWSTokenIssuer) Issue(subject, role string) (access, refresh string, err error) {
+	session := uuid.New()
+
+	if access, err = s.IssueAccess(subject, role, session); err != nil {
+		return "", "", err
+	}
+	if refresh, err = s.sign(subject, "", session, UseRefresh, s.refreshTokenTTL()); err != nil {
+		return "", "", err
+	}
+
+	return access, refresh, nil
 }
 
-// Issue issues a new JWT token signed with the authority key and valid for one
-// day. The signed JWT token is returned in the RFC 7519 compact serialization
-// format.
-func (s *JWSTokenIssuer) Issue() (string, error) {
+// IssueAccess issues a new access token of an existing session.
+func (s *JWSTokenIssuer) IssueAccess(subject, role, session string) (string, error) {
+	return s.sign(subject, role, session, UseAccess, s.accessTokenTTL())
+}
+
+func (s *JWSTokenIssuer) sign(subject, role, session, use string, ttl time.Duration) (string, error) {
+	key, err := s.Keys.Current()
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get token signing key")
+	}
+
 	signer, err := jose.NewSigner(
 		jose.SigningKey{
-			Key:       s.Key,
-			Algorithm: jose.SignatureAlgorithm(s.KeyAlgorithm),
+			Key:       key.Key,
+			Algorithm: KeyAlgorithm,
 		},
-		new(jose.SignerOptions).WithType("JWT"))
+		new(jose.SignerOptions).WithType("JWT").WithHeader("kid", key.ID))
 	if err != nil {
 		return "", errors.Wrap(err, "unable to create token signer")
 	}
 
-	claims := jwt.Claims{
-		Expiry: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 1 day
+	now := time.Now()
+	claims := Claims{
+		Claims: jwt.Claims{
+			ID:       uuid.New(),
+			Subject:  subject,
+			IssuedAt: jwt.NewNumericDate(now),
+			Expiry:   jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Session: session,
+		Use:     use,
+		Role:    role,
 	}
 
 	return jwt.Signed(signer).Claims(claims).CompactSerialize()
 }
 
-// Validate validates the JWT token was signed with the authority key and has
-// not yet expired. The signed JWT token is expected to be in the RFC 7519
-// compact serialization format.
-func (s *JWSTokenIssuer) Validate(t string) error {
+// Validate validates the access token was signed with the authority key, has
+// not yet expired and has not been revoked and returns its claims. The signed
+// JWT token is expected to be in the RFC 7519 compact serialization format.
+func (s *JWSTokenIssuer) Validate(t string) (*Claims, error) {
+	return s.validate(t, UseAccess)
+}
+
+// ValidateRefresh validates a refresh token like Validate validates an access
+// token.
+func (s *JWSTokenIssuer) ValidateRefresh(t string) (*Claims, error) {
+	return s.validate(t, UseRefresh)
+}
+
+func (s *JWSTokenIssuer) validate(t, use string) (*Claims, error) {
 	token, err := jwt.ParseSigned(t)
 	if err != nil {
-		return errors.Wrap(err, "unable to parse token")
+		return nil, errors.Wrap(err, "unable to parse token")
+	}
+
+	if len(token.Headers) != 1 || token.Headers[0].Algorithm != KeyAlgorithm {
+		return nil, errors.New("unexpected token signature")
+	}
+	key, err := s.Keys.Lookup(token.Headers[0].KeyID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get token verification key")
+	}
+
+	var claims Claims
+	err = token.Claims(key.Key.Public(), &claims)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to deserialize token claims")
+	}
+
+	if err = claims.Validate(jwt.Expected{Time: time.Now()}); err != nil {
+		return nil, err
+	}
+	if claims.Use != use {
+		return nil, errors.Errorf("token use %q is not %q", claims.Use, use)
+	}
+	if claims.ID == "" || claims.Session == "" {
+		return nil, errors.New("token has no ID or session")
 	}
 
-	key, ok := s.Key.(crypto.Signer)
-	if !ok {
-		return errors.Wrap(err, "invalid signing key")
+	if s.Revocations == nil {
+		return &claims, nil
+	}
+	for _, id := range []string{claims.ID, claims.Session} {
+		revoked, err := s.Revocations.IsRevoked(id)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to check token revocation")
+		}
+		if revoked {
+			return nil, errors.New("token has been revoked")
+		}
 	}
 
-	var claims jwt.Claims
-	err = token.Claims(key.Public(), &claims)
+	// Tokens issued in the second the subject's tokens were revoked are
+	// revoked too, as the issue time is only known to the second
+	revokedAt, err := s.Revocations.SubjectRevokedAt(claims.Subject)
 	if err != nil {
-		return errors.Wrap(err, "unable to deserialize token claims")
+		return nil, errors.Wrap(err, "unable to check token revocation")
+	}
+	if !revokedAt.IsZero() && (claims.IssuedAt == nil || !claims.IssuedAt.Time().After(revokedAt)) {
+		return nil, errors.New("tokens of the subject have been revoked")
+	}
+
+	return &claims, nil
+}
+
+// Revoke revokes the session of the token with the given claims, so that
+// neither its access nor its refresh tokens are valid anymore.
+func (s *JWSTokenIssuer) Revoke(claims *Claims) error {
+	if s.Revocations == nil {
+		return errors.New("token revocation is not supported")
+	}
+
+	// The session ends at the latest when a refresh token issued before this
+	// token expires
+	expiry := claims.Expiry.Time()
+	if claims.IssuedAt != nil {
+		if end := claims.IssuedAt.Time().Add(s.refreshTokenTTL()); end.After(expiry) {
+			expiry = end
+		}
+	}
+
+	if err := s.Revocations.Revoke(claims.Session, expiry); err != nil {
+		return errors.Wrap(err, "unable to revoke session")
+	}
+
+	return nil
+}
+
+// RevokeSubject revokes all sessions of the subject, so that none of the
+// tokens issued to it until now are valid anymore.
+func (s *JWSTokenIssuer) RevokeSubject(subject string) error {
+	if s.Revocations == nil {
+		return errors.New("token revocation is not supported")
+	}
+
+	ttl := s.refreshTokenTTL()
+	if s.accessTokenTTL() > ttl {
+		ttl = s.accessTokenTTL()
+	}
+	if err := s.Revocations.RevokeSubject(subject, time.Now().Add(ttl)); err != nil {
+		return errors.Wrap(err, "unable to revoke sessions")
+	}
+
+	return nil
+}
+
+// RotateKey generates a new signing key. The previous keys are kept until the
+// tokens they signed have expired.
+func (s *JWSTokenIssuer) RotateKey() (*Key, error) {
+	retain := s.refreshTokenTTL()
+	if s.accessTokenTTL() > retain {
+		retain = s.accessTokenTTL()
+	}
+
+	return s.Keys.Rotate(retain + jwt.DefaultLeeway)
+}
+
+func (s *JWSTokenIssuer) accessTokenTTL() time.Duration {
+	if s.AccessTokenTTL == 0 {
+		return DefaultAccessTokenTTL
 	}
+	return s.AccessTokenTTL
+}
 
-	return claims.Validate(jwt.Expected{Time: time.Now()})
+func (s *JWSTokenIssuer) refreshTokenTTL() time.Duration {
+	if s.RefreshTokenTTL == 0 {
+		return DefaultRefreshTokenTTL
+	}
+	return s.RefreshTokenTTL
 }
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package jose_test
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/open-ness/edgecontroller/jose"
+)
+
+// revocations is an in-memory jose.Revocations.
+type revocations struct {
+	mu       sync.Mutex
+	ids      map[string]bool
+	subjects map[string]time.Time
+}
+
+func (r *revocations) Revoke(id string, expiry time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids[id] = true
+	return nil
+}
+
+func (r *revocations) IsRevoked(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ids[id], nil
+}
+
+func (r *revocations) RevokeSubject(subject string, expiry time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subjects[subject] = time.Now().Truncate(time.Second)
+	return nil
+}
+
+func (r *revocations) SubjectRevokedAt(subject string) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.subjects[subject], nil
+}
+
+var _ = Describe("JWSTokenIssuer", func() {
+	var (
+		dir    string
+		issuer *jose.JWSTokenIssuer
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "jose")
+		Expect(err).ToNot(HaveOccurred())
+
+		keys, err := jose.LoadKeyRing(dir)
+		Expect(err).ToNot(HaveOccurred())
+		issuer = &jose.JWSTokenIssuer{
+			Keys:        keys,
+			Revocations: &revocations{ids: make(map[string]bool), subjects: make(map[string]time.Time)},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("Should issue access and refresh tokens of a session", func() {
+		access, refresh, err := issuer.Issue("jdoe", "operator")
+		Expect(err).ToNot(HaveOccurred())
+
+		claims, err := issuer.Validate(access)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(claims.Subject).To(Equal("jdoe"))
+		Expect(claims.Role).To(Equal("operator"))
+		Expect(claims.ID).ToNot(BeEmpty())
+		Expect(claims.IssuedAt).ToNot(BeNil())
+
+		refreshClaims, err := issuer.ValidateRefresh(refresh)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(refreshClaims.Session).To(Equal(claims.Session))
+
+		By("Verifying the tokens cannot be used for each other")
+		_, err = issuer.Validate(refresh)
+		Expect(err).To(HaveOccurred())
+		_, err = issuer.ValidateRefresh(access)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should not validate the tokens of a revoked session", func() {
+		access, refresh, err := issuer.Issue("jdoe", "operator")
+		Expect(err).ToNot(HaveOccurred())
+		claims, err := issuer.Validate(access)
+		Expect(err).ToNot(HaveOccurred())
+		refreshed, err := issuer.IssueAccess("jdoe", "operator", claims.Session)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(issuer.Revoke(claims)).To(Succeed())
+
+		for _, t := range []string{access, refreshed} {
+			_, err = issuer.Validate(t)
+			Expect(err).To(MatchError("token has been revoked"))
+		}
+		_, err = issuer.ValidateRefresh(refresh)
+		Expect(err).To(MatchError("token has been revoked"))
+	})
+
+	It("Should not validate the tokens of a revoked subject", func() {
+		access, refresh, err := issuer.Issue("jdoe", "operator")
+		Expect(err).ToNot(HaveOccurred())
+		other, _, err := issuer.Issue("alice", "operator")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(issuer.RevokeSubject("jdoe")).To(Succeed())
+
+		_, err = issuer.Validate(access)
+		Expect(err).To(MatchError("tokens of the subject have been revoked"))
+		_, err = issuer.ValidateRefresh(refresh)
+		Expect(err).To(MatchError("tokens of the subject have been revoked"))
+		_, err = issuer.Validate(other)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("Should validate tokens signed with a retired key", func() {
+		access, _, err := issuer.Issue("jdoe", "operator")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = issuer.RotateKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = issuer.Validate(access)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("Should not validate tokens signed by another key ring", func() {
+		otherDir, err := ioutil.TempDir("", "jose")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(otherDir)
+		keys, err := jose.LoadKeyRing(otherDir)
+		Expect(err).ToNot(HaveOccurred())
+
+		access, _, err := (&jose.JWSTokenIssuer{Keys: keys}).Issue("jdoe", "admin")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = issuer.Validate(access)
+		Expect(err).To(HaveOccurred())
+	})
+})
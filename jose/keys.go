@@ -0,0 +1,306 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-ness/edgecontroller/uuid"
+	"github.com/pkg/errors"
+)
+
+// KeyAlgorithm is the signature algorithm of the keys of a KeyRing.
+const KeyAlgorithm = "ES384"
+
+// DefaultReloadInterval is the default interval of a KeyRing reloading its
+// keys from disk to pick up the keys rotated by other controllers.
+const DefaultReloadInterval = time.Minute
+
+// PEM headers of a stored key
+const (
+	createdHeader = "Created"
+	retiredHeader = "Retired"
+)
+
+// Key is a token signing key. Only the current key of a KeyRing signs
+// tokens, retired keys only verify the tokens they signed before.
+type Key struct {
+	ID      string
+	Key     crypto.Signer
+	Created time.Time
+	// Retired is zero if the key has not been retired
+	Retired time.Time
+}
+
+// KeyRing is a set of token signing keys persisted in a directory, one PEM
+// file per key named after its ID. The newest key that has not been retired
+// is the current key. Controllers sharing the directory share the keys.
+type KeyRing struct {
+	Dir            string
+	ReloadInterval time.Duration
+
+	mu       sync.Mutex
+	keys     []*Key
+	loadedAt time.Time
+}
+
+// LoadKeyRing loads the keys persisted in dir. The directory and a first key
+// are created if they do not exist yet.
+func LoadKeyRing(dir string) (*KeyRing, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "unable to create key directory")
+	}
+
+	r := &KeyRing{Dir: dir, ReloadInterval: DefaultReloadInterval}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	if r.current() == nil {
+		if _, err := r.generate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Keys returns the keys ordered from newest to oldest.
+func (r *KeyRing) Keys() []Key {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]Key, len(r.keys))
+	for i, k := range r.keys {
+		keys[i] = *k
+	}
+	return keys
+}
+
+// Current returns the key that signs new tokens.
+func (r *KeyRing) Current() (*Key, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.loadedAt) > r.ReloadInterval {
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	k := r.current()
+	if k == nil {
+		return nil, errors.New("no current signing key")
+	}
+	return k, nil
+}
+
+// Lookup returns the key with the given ID. The keys are reloaded if the key
+// is unknown, but at most once per ReloadInterval.
+func (r *KeyRing) Lookup(id string) (*Key, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if k := r.lookup(id); k != nil {
+		return k, nil
+	}
+	if time.Since(r.loadedAt) > r.ReloadInterval {
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+		if k := r.lookup(id); k != nil {
+			return k, nil
+		}
+	}
+
+	return nil, errors.Errorf("key %q not found", id)
+}
+
+// Rotate generates a new current key and retires the previous ones. Keys
+// retired longer than retain ago are deleted, retain should thus be the
+// maximum lifetime of a token.
+func (r *KeyRing) Rotate(retain time.Duration) (*Key, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Round(0)
+	for _, k := range r.keys {
+		switch {
+		case k.Retired.IsZero():
+			k.Retired = now
+			if err := r.store(k); err != nil {
+				return nil, err
+			}
+		case now.Sub(k.Retired) > retain:
+			if err := os.Remove(r.path(k.ID)); err != nil && !os.IsNotExist(err) {
+				return nil, errors.Wrap(err, "unable to delete retired key")
+			}
+		}
+	}
+
+	k, err := r.generate()
+	if err != nil {
+		return nil, err
+	}
+	if err = r.load(); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (r *KeyRing) current() *Key {
+	for _, k := range r.keys {
+		if k.Retired.IsZero() {
+			return k
+		}
+	}
+	return nil
+}
+
+func (r *KeyRing) lookup(id string) *Key {
+	for _, k := range r.keys {
+		if k.ID == id {
+			return k
+		}
+	}
+	return nil
+}
+
+func (r *KeyRing) path(id string) string {
+	return filepath.Join(r.Dir, id+".pem")
+}
+
+// generate generates and stores a new key. It must be called with mu held.
+func (r *KeyRing) generate() (*Key, error) {
+	signer, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate key")
+	}
+
+	k := &Key{ID: uuid.New(), Key: signer, Created: time.Now().UTC().Round(0)}
+	if err = r.store(k); err != nil {
+		return nil, err
+	}
+	r.keys = append([]*Key{k}, r.keys...)
+
+	return k, nil
+}
+
+// load loads the keys from disk. It must be called with mu held.
+func (r *KeyRing) load() error {
+	files, err := ioutil.ReadDir(r.Dir)
+	if err != nil {
+		return errors.Wrap(err, "unable to read key directory")
+	}
+
+	var keys []*Key
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".pem") {
+			continue
+		}
+		k, err := loadKey(filepath.Join(r.Dir, f.Name()))
+		if os.IsNotExist(errors.Cause(err)) {
+			// Deleted by another controller
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to load key %s", f.Name())
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Created.After(keys[j].Created)
+	})
+
+	r.keys = keys
+	r.loadedAt = time.Now()
+
+	return nil
+}
+
+func loadKey(path string) (*Key, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("unable to decode key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(*ecdsa.PrivateKey)
+	if !ok || signer.Curve != elliptic.P384() {
+		return nil, errors.New("key is not a P-384 EC key")
+	}
+
+	k := &Key{
+		ID:  strings.TrimSuffix(filepath.Base(path), ".pem"),
+		Key: signer,
+	}
+	if k.Created, err = time.Parse(time.RFC3339Nano, block.Headers[createdHeader]); err != nil {
+		return nil, errors.Wrap(err, "invalid creation time")
+	}
+	if retired, ok := block.Headers[retiredHeader]; ok {
+		if k.Retired, err = time.Parse(time.RFC3339Nano, retired); err != nil {
+			return nil, errors.Wrap(err, "invalid retirement time")
+		}
+	}
+
+	return k, nil
+}
+
+// store stores a key atomically, so that other controllers never load a
+// partially written key.
+func (r *KeyRing) store(k *Key) error {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Key)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal key")
+	}
+
+	headers := map[string]string{createdHeader: k.Created.UTC().Format(time.RFC3339Nano)}
+	if !k.Retired.IsZero() {
+		headers[retiredHeader] = k.Retired.UTC().Format(time.RFC3339Nano)
+	}
+
+	tmp, err := ioutil.TempFile(r.Dir, ".key-")
+	if err != nil {
+		return errors.Wrap(err, "unable to create key file")
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// TempFile creates the file with mode 0600
+	if err = pem.Encode(tmp, &pem.Block{Type: "PRIVATE KEY", Headers: headers, Bytes: der}); err != nil {
+		return errors.Wrap(err, "unable to store key")
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap(err, "unable to store key")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), r.path(k.ID)), "unable to store key")
+}
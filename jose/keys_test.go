@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package jose_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/open-ness/edgecontroller/jose"
+)
+
+var _ = Describe("KeyRing", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "jose")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Describe("LoadKeyRing", func() {
+		It("Should generate and persist a first key", func() {
+			keys, err := jose.LoadKeyRing(filepath.Join(dir, "jwt"))
+			Expect(err).ToNot(HaveOccurred())
+			current, err := keys.Current()
+			Expect(err).ToNot(HaveOccurred())
+
+			info, err := os.Stat(filepath.Join(dir, "jwt", current.ID+".pem"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+			By("Loading the persisted key")
+			keys, err = jose.LoadKeyRing(filepath.Join(dir, "jwt"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keys.Keys()).To(HaveLen(1))
+			Expect(keys.Keys()[0].ID).To(Equal(current.ID))
+		})
+
+		It("Should return an error for an invalid key file", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "bad.pem"), []byte("bad"), 0600)).To(Succeed())
+
+			_, err := jose.LoadKeyRing(dir)
+			Expect(err).To(MatchError("unable to load key bad.pem: unable to decode key"))
+		})
+	})
+
+	Describe("Rotate", func() {
+		It("Should retire the current key and delete keys retired longer than retain", func() {
+			keys, err := jose.LoadKeyRing(dir)
+			Expect(err).ToNot(HaveOccurred())
+			first, err := keys.Current()
+			Expect(err).ToNot(HaveOccurred())
+
+			second, err := keys.Rotate(time.Hour)
+			Expect(err).ToNot(HaveOccurred())
+			current, err := keys.Current()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(current.ID).To(Equal(second.ID))
+
+			By("Verifying the first key is retired but can still be looked up")
+			retired, err := keys.Lookup(first.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retired.Retired).ToNot(BeZero())
+
+			By("Verifying the first key is deleted once retired longer than retain")
+			third, err := keys.Rotate(0)
+			Expect(err).ToNot(HaveOccurred())
+			var ids []string
+			for _, k := range keys.Keys() {
+				ids = append(ids, k.ID)
+			}
+			Expect(ids).To(Equal([]string{third.ID, second.ID}))
+			_, err = keys.Lookup(first.ID)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should be picked up by key rings sharing the directory", func() {
+			keys, err := jose.LoadKeyRing(dir)
+			Expect(err).ToNot(HaveOccurred())
+			other, err := jose.LoadKeyRing(dir)
+			Expect(err).ToNot(HaveOccurred())
+			other.ReloadInterval = 0
+
+			rotated, err := keys.Rotate(time.Hour)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = other.Lookup(rotated.ID)
+			Expect(err).ToNot(HaveOccurred())
+			current, err := other.Current()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(current.ID).To(Equal(rotated.ID))
+		})
+	})
+})
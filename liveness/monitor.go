@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+// Package liveness tracks whether nodes are online by the heartbeats they
+// send.
+package liveness
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	logger "github.com/open-ness/common/log"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/events"
+	"github.com/open-ness/edgecontroller/uuid"
+	"github.com/pkg/errors"
+)
+
+var log = logger.DefaultLogger.WithField("pkg", "liveness")
+
+// Defaults of a Monitor created by NewMonitor
+const (
+	DefaultInterval       = 30 * time.Second
+	DefaultTimeout        = 90 * time.Second
+	DefaultUsageThreshold = 90
+)
+
+// Monitor implements cce.HeartbeatService. The status of a node is:
+//
+//   - offline if its last heartbeat is older than Timeout,
+//   - degraded if it missed a heartbeat, i.e. its last one is older than twice
+//     the Interval, or it reported a CPU, memory or disk usage above
+//     UsageThreshold,
+//   - online otherwise.
+//
+// Heartbeats update the status of their node and Run updates the status of
+// nodes whose heartbeats are late. Status transitions are published as
+// updates of the cce.NodeStatus and notified to webhooks.
+type Monitor struct {
+	// PersistenceService stores the statuses. It should neither be audited
+	// nor publish events, as every heartbeat updates the status of its node.
+	PersistenceService cce.PersistenceService
+	// Hub publishes the status transitions. It may be nil.
+	Hub *cce.EventHub
+	// NotificationService notifies webhooks of status transitions. It may be
+	// nil.
+	NotificationService cce.NotificationService
+
+	// Interval is the interval in which nodes send heartbeats
+	Interval time.Duration
+	// Timeout is the time since the last heartbeat after which a node is
+	// offline
+	Timeout time.Duration
+	// UsageThreshold is the resource usage in percent above which a node is
+	// degraded
+	UsageThreshold float64
+
+	// mu serializes the updates of statuses, so that every transition is
+	// published once
+	mu sync.Mutex
+}
+
+// NewMonitor creates a Monitor with the default settings.
+func NewMonitor(ps cce.PersistenceService) *Monitor {
+	return &Monitor{
+		PersistenceService: ps,
+		Interval:           DefaultInterval,
+		Timeout:            DefaultTimeout,
+		UsageThreshold:     DefaultUsageThreshold,
+	}
+}
+
+// Heartbeat records a heartbeat of a node and returns the Interval.
+func (m *Monitor) Heartbeat(ctx context.Context, nodeID string, hb *cce.NodeHeartbeat) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := cce.ReadNodeStatus(ctx, m.PersistenceService, nodeID)
+	if err != nil {
+		return 0, errors.Wrap(err, "error reading node status")
+	}
+	previous := s.Status
+
+	now := time.Now()
+	s.LastSeen = cce.AuditTimestamp(now)
+	s.NodeHeartbeat = *hb
+	s.Status = m.status(s, now)
+	if err = m.store(ctx, s, previous); err != nil {
+		return 0, err
+	}
+
+	return m.Interval, nil
+}
+
+// Run checks the statuses every Interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := m.Check(ctx); err != nil {
+			log.Errf("Error checking node statuses: %v", err)
+		}
+	}
+}
+
+// Check updates the statuses of nodes whose heartbeats are late.
+func (m *Monitor) Check(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses, err := m.PersistenceService.ReadAll(ctx, &cce.NodeStatus{})
+	if err != nil {
+		return errors.Wrap(err, "error reading node statuses")
+	}
+
+	now := time.Now()
+	for _, e := range statuses {
+		s := e.(*cce.NodeStatus)
+		previous := s.Status
+		if s.Status = m.status(s, now); s.Status == previous {
+			continue
+		}
+		if err = m.store(ctx, s, previous); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// status returns the status of a node at now.
+func (m *Monitor) status(s *cce.NodeStatus, now time.Time) string {
+	lastSeen, err := time.Parse(cce.AuditTimeFormat, s.LastSeen)
+	if err != nil {
+		return cce.NodeStatusOffline
+	}
+
+	switch since := now.Sub(lastSeen); {
+	case since > m.Timeout:
+		return cce.NodeStatusOffline
+	case since > 2*m.Interval:
+		return cce.NodeStatusDegraded
+	}
+
+	r := s.Resources
+	if r.CPUPercent > m.UsageThreshold ||
+		percent(r.MemoryUsedBytes, r.MemoryTotalBytes) > m.UsageThreshold ||
+		percent(r.DiskUsedBytes, r.DiskTotalBytes) > m.UsageThreshold {
+		return cce.NodeStatusDegraded
+	}
+
+	return cce.NodeStatusOnline
+}
+
+func percent(used, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(used) / float64(total) * 100
+}
+
+// store stores a status. If it differs from the previous status the
+// transition is published and notified.
+func (m *Monitor) store(ctx context.Context, s *cce.NodeStatus, previous string) error {
+	changed := s.Status != previous
+	ps := m.PersistenceService
+	if changed && m.Hub != nil {
+		ps = &events.PersistenceService{PersistenceService: ps, Hub: m.Hub}
+	}
+
+	var err error
+	if s.ID == "" {
+		s.ID = uuid.New()
+		err = ps.Create(ctx, s)
+	} else {
+		err = ps.BulkUpdate(ctx, []cce.Persistable{s})
+	}
+	if err != nil {
+		return errors.Wrap(err, "error storing node status")
+	}
+
+	if changed {
+		log.Noticef("Node %s is %s, was %s", s.NodeID, s.Status, previous)
+		if m.NotificationService != nil {
+			m.NotificationService.Notify(ctx, cce.NotificationNodeStatusChanged, &cce.NodeStatusChange{
+				NodeID:         s.NodeID,
+				Status:         s.Status,
+				PreviousStatus: previous,
+				LastSeen:       s.LastSeen,
+			})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package liveness_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/liveness"
+	"github.com/open-ness/edgecontroller/memory"
+)
+
+// notifications records the notifications of a Monitor.
+type notifications []*cce.NodeStatusChange
+
+func (n *notifications) Notify(ctx context.Context, typ string, data interface{}) {
+	Expect(typ).To(Equal(cce.NotificationNodeStatusChanged))
+	*n = append(*n, data.(*cce.NodeStatusChange))
+}
+
+var _ = Describe("Monitor", func() {
+	const nodeID = "9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f"
+
+	var (
+		ctx      = context.Background()
+		ps       cce.PersistenceService
+		hub      *cce.EventHub
+		sub      *cce.EventSubscription
+		notified *notifications
+		monitor  *liveness.Monitor
+		hb       *cce.NodeHeartbeat
+	)
+
+	BeforeEach(func() {
+		var err error
+		ps, err = memory.NewPersistenceService("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ps.Create(ctx, &cce.Node{
+			ID:       nodeID,
+			Name:     "node123",
+			Location: "Localhost port 42101",
+			Serial:   "ABCD",
+		})).To(Succeed())
+
+		hub = cce.NewEventHub(16)
+		sub = hub.Subscribe(cce.EventFilter{}, false, 0)
+		notified = &notifications{}
+		monitor = liveness.NewMonitor(ps)
+		monitor.Hub = hub
+		monitor.NotificationService = notified
+
+		hb = &cce.NodeHeartbeat{
+			AgentVersion:  "1.2.3",
+			UptimeSeconds: 3600,
+			Resources: cce.NodeResourceUsage{
+				CPUPercent:       25,
+				MemoryUsedBytes:  1 << 30,
+				MemoryTotalBytes: 4 << 30,
+				DiskUsedBytes:    10 << 30,
+				DiskTotalBytes:   100 << 30,
+			},
+		}
+	})
+
+	AfterEach(func() {
+		sub.Close()
+	})
+
+	nextEvents := func() []cce.Event {
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		events, err := sub.Next(ctx)
+		if err == context.DeadlineExceeded {
+			return nil
+		}
+		Expect(err).ToNot(HaveOccurred())
+		return events
+	}
+
+	// setLastSeen sets the time of the last heartbeat of the node.
+	setLastSeen := func(ago time.Duration) {
+		s, err := cce.ReadNodeStatus(ctx, ps, nodeID)
+		Expect(err).ToNot(HaveOccurred())
+		s.LastSeen = cce.AuditTimestamp(time.Now().Add(-ago))
+		Expect(ps.BulkUpdate(ctx, []cce.Persistable{s})).To(Succeed())
+	}
+
+	Describe("Heartbeat", func() {
+		It("Should bring the node online", func() {
+			interval, err := monitor.Heartbeat(ctx, nodeID, hb)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(interval).To(Equal(liveness.DefaultInterval))
+
+			s, err := cce.ReadNodeStatus(ctx, ps, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s.Status).To(Equal(cce.NodeStatusOnline))
+			Expect(s.LastSeen).ToNot(BeEmpty())
+			Expect(s.NodeHeartbeat).To(Equal(*hb))
+
+			By("Verifying the transition was notified")
+			Expect(*notified).To(Equal(notifications{{
+				NodeID:         nodeID,
+				Status:         cce.NodeStatusOnline,
+				PreviousStatus: cce.NodeStatusOffline,
+				LastSeen:       s.LastSeen,
+			}}))
+
+			By("Verifying the transition was published")
+			events := nextEvents()
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal(cce.EventCreate))
+			Expect(events[0].Resource).To(Equal("node_statuses"))
+			Expect(events[0].NodeID).To(Equal(nodeID))
+		})
+
+		It("Should not publish heartbeats without a transition", func() {
+			_, err := monitor.Heartbeat(ctx, nodeID, hb)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nextEvents()).To(HaveLen(1))
+
+			hb.UptimeSeconds += 30
+			_, err = monitor.Heartbeat(ctx, nodeID, hb)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nextEvents()).To(BeEmpty())
+			Expect(*notified).To(HaveLen(1))
+
+			s, err := cce.ReadNodeStatus(ctx, ps, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s.UptimeSeconds).To(Equal(hb.UptimeSeconds))
+		})
+
+		It("Should degrade a node with a high resource usage", func() {
+			hb.Resources.DiskUsedBytes = 95 << 30
+
+			_, err := monitor.Heartbeat(ctx, nodeID, hb)
+			Expect(err).ToNot(HaveOccurred())
+
+			s, err := cce.ReadNodeStatus(ctx, ps, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s.Status).To(Equal(cce.NodeStatusDegraded))
+		})
+	})
+
+	Describe("Check", func() {
+		BeforeEach(func() {
+			_, err := monitor.Heartbeat(ctx, nodeID, hb)
+			Expect(err).ToNot(HaveOccurred())
+			nextEvents()
+		})
+
+		It("Should keep a node sending heartbeats in time online", func() {
+			setLastSeen(monitor.Interval)
+
+			Expect(monitor.Check(ctx)).To(Succeed())
+			s, err := cce.ReadNodeStatus(ctx, ps, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s.Status).To(Equal(cce.NodeStatusOnline))
+			Expect(nextEvents()).To(BeEmpty())
+		})
+
+		It("Should degrade a node that missed a heartbeat", func() {
+			setLastSeen(2*monitor.Interval + time.Second)
+
+			Expect(monitor.Check(ctx)).To(Succeed())
+			s, err := cce.ReadNodeStatus(ctx, ps, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s.Status).To(Equal(cce.NodeStatusDegraded))
+			Expect(nextEvents()).To(HaveLen(1))
+		})
+
+		It("Should take a node without heartbeats offline", func() {
+			setLastSeen(monitor.Timeout + time.Second)
+
+			Expect(monitor.Check(ctx)).To(Succeed())
+			s, err := cce.ReadNodeStatus(ctx, ps, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s.Status).To(Equal(cce.NodeStatusOffline))
+
+			By("Verifying the transition was notified and published")
+			Expect((*notified)[len(*notified)-1]).To(Equal(&cce.NodeStatusChange{
+				NodeID:         nodeID,
+				Status:         cce.NodeStatusOffline,
+				PreviousStatus: cce.NodeStatusOnline,
+				LastSeen:       s.LastSeen,
+			}))
+			events := nextEvents()
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal(cce.EventUpdate))
+
+			By("Bringing the node online again")
+			_, err = monitor.Heartbeat(ctx, nodeID, hb)
+			Expect(err).ToNot(HaveOccurred())
+			Expect((*notified)[len(*notified)-1].Status).To(Equal(cce.NodeStatusOnline))
+		})
+	})
+})
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"sync"
+	"time"
+)
+
+// Defaults of a LoginThrottle created by NewLoginThrottle
+const (
+	DefaultMaxUserFailures   = 5
+	DefaultMaxSourceFailures = 20
+	DefaultBaseLockout       = 5 * time.Second
+	DefaultMaxLockout        = 15 * time.Minute
+	DefaultFailureWindow     = time.Hour
+)
+
+// LoginThrottle counts the failed logins per username and per source address
+// and locks either out once it has failed too often. The first lockout lasts
+// BaseLockout and every further failure doubles it up to MaxLockout.
+//
+// Attempt counts a login as failed before its password is checked, so that
+// concurrent logins cannot exceed the maximum. A successful login resets the
+// counter of its username and takes back its attempt from the counter of its
+// source. The failures of a username or source are forgotten FailureWindow
+// after its last failure.
+type LoginThrottle struct {
+	MaxUserFailures   int
+	MaxSourceFailures int
+	BaseLockout       time.Duration
+	MaxLockout        time.Duration
+	FailureWindow     time.Duration
+
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+	prunedAt time.Time
+}
+
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// NewLoginThrottle creates a LoginThrottle with the default settings.
+func NewLoginThrottle() *LoginThrottle {
+	return &LoginThrottle{
+		MaxUserFailures:   DefaultMaxUserFailures,
+		MaxSourceFailures: DefaultMaxSourceFailures,
+		BaseLockout:       DefaultBaseLockout,
+		MaxLockout:        DefaultMaxLockout,
+		FailureWindow:     DefaultFailureWindow,
+	}
+}
+
+// Locked returns for how much longer the username or source is locked out at
+// now, or zero if neither is.
+func (t *LoginThrottle) Locked(username, source string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.locked(username, source, now)
+}
+
+// locked returns the remaining lockout. It must be called with mu held.
+func (t *LoginThrottle) locked(username, source string, now time.Time) time.Duration {
+	var remaining time.Duration
+	for _, key := range throttleKeys(username, source) {
+		if f, ok := t.failures[key]; ok && f.lockedUntil.Sub(now) > remaining {
+			remaining = f.lockedUntil.Sub(now)
+		}
+	}
+
+	return remaining
+}
+
+// Attempt reserves a login attempt of the username from the source at now. If
+// either is locked out, it returns for how much longer and reserves nothing.
+// Otherwise the attempt is recorded as failed until Succeeded is called.
+func (t *LoginThrottle) Attempt(username, source string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if d := t.locked(username, source, now); d > 0 {
+		return d
+	}
+	t.failed(username, source, now)
+
+	return 0
+}
+
+// Failed records a failed login at now and returns for how long it locks out
+// the username or source, or zero if it does not.
+func (t *LoginThrottle) Failed(username, source string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.failed(username, source, now)
+}
+
+// failed records a failed login. It must be called with mu held.
+func (t *LoginThrottle) failed(username, source string, now time.Time) time.Duration {
+	if t.failures == nil {
+		t.failures = make(map[string]*loginFailures)
+	}
+	t.prune(now)
+
+	var lockout time.Duration
+	for i, key := range throttleKeys(username, source) {
+		f, ok := t.failures[key]
+		if !ok || now.Sub(f.last) > t.FailureWindow {
+			f = &loginFailures{}
+			t.failures[key] = f
+		}
+		f.count++
+		f.last = now
+
+		max := t.MaxUserFailures
+		if i == 1 {
+			max = t.MaxSourceFailures
+		}
+		if f.count < max {
+			continue
+		}
+		d := t.lockout(f.count - max)
+		f.lockedUntil = now.Add(d)
+		if d > lockout {
+			lockout = d
+		}
+	}
+
+	return lockout
+}
+
+// Succeeded resets the failures of the username and takes back the attempt
+// reserved by Attempt from the failures of the source. The other failures of
+// the source are kept, so logging into an account of its own does not let a
+// source guess the passwords of others.
+func (t *LoginThrottle) Succeeded(username, source string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keys := throttleKeys(username, source)
+	delete(t.failures, keys[0])
+
+	f, ok := t.failures[keys[1]]
+	if !ok {
+		return
+	}
+	if f.count--; f.count < t.MaxSourceFailures {
+		// The lockout was caused by the attempt taken back
+		f.lockedUntil = time.Time{}
+	}
+	if f.count == 0 {
+		delete(t.failures, keys[1])
+	}
+}
+
+// lockout returns the lockout after n failures beyond the maximum.
+func (t *LoginThrottle) lockout(n int) time.Duration {
+	d := t.BaseLockout
+	for i := 0; i < n && d < t.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > t.MaxLockout {
+		d = t.MaxLockout
+	}
+
+	return d
+}
+
+// prune removes the forgotten failures, at most once per FailureWindow. It
+// must be called with mu held.
+func (t *LoginThrottle) prune(now time.Time) {
+	if now.Sub(t.prunedAt) < t.FailureWindow {
+		return
+	}
+	t.prunedAt = now
+
+	for key, f := range t.failures {
+		if now.Sub(f.last) > t.FailureWindow && now.After(f.lockedUntil) {
+			delete(t.failures, key)
+		}
+	}
+}
+
+// throttleKeys returns the keys of the username and the source, in this
+// order.
+func throttleKeys(username, source string) []string {
+	return []string{"user:" + username, "source:" + source}
+}
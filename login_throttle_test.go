@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+)
+
+var _ = Describe("LoginThrottle", func() {
+	var (
+		t   *cce.LoginThrottle
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		t = cce.NewLoginThrottle()
+		t.MaxUserFailures = 3
+		t.MaxSourceFailures = 5
+		t.BaseLockout = time.Second
+		t.MaxLockout = 10 * time.Second
+		t.FailureWindow = time.Minute
+		now = time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	})
+
+	fail := func(username, source string, n int) time.Duration {
+		var d time.Duration
+		for i := 0; i < n; i++ {
+			d = t.Failed(username, source, now)
+		}
+		return d
+	}
+
+	It("Should not lock out before the maximum failures of a username", func() {
+		Expect(fail("alice", "192.0.2.1", 2)).To(BeZero())
+		Expect(t.Locked("alice", "192.0.2.1", now)).To(BeZero())
+	})
+
+	It("Should lock out a username from any source", func() {
+		Expect(fail("alice", "192.0.2.1", 3)).To(Equal(time.Second))
+		Expect(t.Locked("alice", "192.0.2.2", now)).To(Equal(time.Second))
+		Expect(t.Locked("bob", "192.0.2.2", now)).To(BeZero())
+		Expect(t.Locked("alice", "192.0.2.2", now.Add(time.Second))).To(BeZero())
+	})
+
+	It("Should lock out a source for any username", func() {
+		for _, username := range []string{"a", "b", "c", "d"} {
+			Expect(t.Failed(username, "192.0.2.1", now)).To(BeZero())
+		}
+		Expect(t.Failed("e", "192.0.2.1", now)).To(Equal(time.Second))
+		Expect(t.Locked("f", "192.0.2.1", now)).To(Equal(time.Second))
+		Expect(t.Locked("f", "192.0.2.2", now)).To(BeZero())
+	})
+
+	It("Should double the lockout on every further failure up to the maximum", func() {
+		Expect(fail("alice", "192.0.2.1", 3)).To(Equal(time.Second))
+		Expect(fail("alice", "192.0.2.1", 1)).To(Equal(2 * time.Second))
+		Expect(fail("alice", "192.0.2.1", 1)).To(Equal(4 * time.Second))
+		Expect(fail("alice", "192.0.2.1", 1)).To(Equal(8 * time.Second))
+		Expect(fail("alice", "192.0.2.1", 1)).To(Equal(10 * time.Second))
+		Expect(fail("alice", "192.0.2.1", 100)).To(Equal(10 * time.Second))
+	})
+
+	It("Should reset the failures of the username on success", func() {
+		fail("alice", "192.0.2.1", 2)
+		Expect(t.Attempt("alice", "192.0.2.1", now)).To(BeZero())
+		Expect(t.Locked("alice", "192.0.2.1", now)).To(Equal(time.Second))
+
+		t.Succeeded("alice", "192.0.2.1")
+		Expect(t.Locked("alice", "192.0.2.1", now)).To(BeZero())
+		Expect(fail("alice", "192.0.2.1", 2)).To(BeZero())
+	})
+
+	It("Should keep the failures of the source on success", func() {
+		fail("mallory", "192.0.2.1", 4)
+		Expect(t.Attempt("eve", "192.0.2.1", now)).To(BeZero())
+		t.Succeeded("eve", "192.0.2.1")
+
+		Expect(t.Locked("eve", "192.0.2.1", now)).To(BeZero())
+		Expect(t.Failed("bob", "192.0.2.1", now)).To(Equal(time.Second))
+	})
+
+	It("Should count attempts before they fail", func() {
+		for i := 0; i < 3; i++ {
+			Expect(t.Attempt("alice", "192.0.2.1", now)).To(BeZero())
+		}
+		Expect(t.Attempt("alice", "192.0.2.2", now)).To(Equal(time.Second))
+		Expect(t.Attempt("bob", "192.0.2.2", now)).To(BeZero())
+	})
+
+	It("Should forget the failures after the failure window", func() {
+		fail("alice", "192.0.2.1", 2)
+		now = now.Add(2 * time.Minute)
+		Expect(fail("alice", "192.0.2.1", 2)).To(BeZero())
+		Expect(fail("alice", "192.0.2.1", 1)).To(Equal(time.Second))
+	})
+})
@@ -0,0 +1,332 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+// Package memory implements cce.PersistenceService in memory for development, CI and small single-node deployments
+// that do not warrant a MySQL server. It enforces the unique and foreign keys of the MySQL schema, so that the
+// constraint checks of the API behave the same with both backends. The data can optionally be kept in a JSON
+// snapshot file that is rewritten on every committed change.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/pkg/errors"
+)
+
+// PersistenceService implements cce.PersistenceService. Transactions run on a snapshot of the data and are serializable:
+// a transaction that read a row changed by a transaction committed after its snapshot is run again. Their functions
+// may therefore run more than once and should not have side effects other than through the transaction.
+type PersistenceService struct {
+	path string
+
+	mu     sync.RWMutex
+	tables map[string]*table
+}
+
+// NewPersistenceService creates a PersistenceService. If path is not empty, the data is loaded from the snapshot file
+// at path if it exists and every committed change is written to it.
+func NewPersistenceService(path string) (*PersistenceService, error) {
+	s := &PersistenceService{
+		path:   path,
+		tables: make(map[string]*table, len(schema)),
+	}
+	for name := range schema {
+		s.tables[name] = newTable()
+	}
+
+	if path != "" {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// snapshot is the format of the snapshot file.
+type snapshot struct {
+	Tables map[string][]snapshotRow `json:"tables"`
+}
+
+type snapshotRow struct {
+	Rev    int64           `json:"rev"`
+	Entity json.RawMessage `json:"entity"`
+}
+
+func (s *PersistenceService) load() error {
+	bytes, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "error reading snapshot")
+	}
+
+	var snap snapshot
+	if err = json.Unmarshal(bytes, &snap); err != nil {
+		return errors.Wrap(err, "error unmarshaling snapshot")
+	}
+
+	for name, rows := range snap.Tables {
+		tbl, ok := s.tables[name]
+		if !ok {
+			return errors.Errorf("snapshot contains unknown table %q", name)
+		}
+		for _, sr := range rows {
+			r, err := newRow(sr.Entity, sr.Rev)
+			if err != nil {
+				return errors.Wrapf(err, "error loading %s", name)
+			}
+			id, ok := r.col("id")
+			if !ok {
+				return errors.Errorf("snapshot contains %s without id", name)
+			}
+			tbl.put(id, r)
+		}
+	}
+
+	return nil
+}
+
+// save atomically replaces the snapshot file with tables.
+func (s *PersistenceService) save(tables map[string]*table) error {
+	snap := snapshot{Tables: make(map[string][]snapshotRow, len(tables))}
+	for name, tbl := range tables {
+		rows := make([]snapshotRow, 0, len(tbl.ids))
+		for _, id := range tbl.ids {
+			rows = append(rows, snapshotRow{Rev: tbl.rows[id].rev, Entity: tbl.rows[id].entity})
+		}
+		snap.Tables[name] = rows
+	}
+
+	bytes, err := json.Marshal(snap)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling snapshot")
+	}
+
+	if err = os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return errors.Wrap(err, "error creating snapshot directory")
+	}
+	tmp := s.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, bytes, 0600); err != nil {
+		return errors.Wrap(err, "error writing snapshot")
+	}
+	if err = os.Rename(tmp, s.path); err != nil {
+		return errors.Wrap(err, "error replacing snapshot")
+	}
+
+	return nil
+}
+
+// view runs fn with a read-only transaction.
+func (s *PersistenceService) view(fn func(t *tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return fn(&tx{committed: s.tables})
+}
+
+// maxTxAttempts is the number of times a transaction is run before it fails because of conflicts.
+const maxTxAttempts = 10
+
+// update runs fn with a transaction that is committed if fn returns nil. fn runs without holding the lock, so the
+// PersistenceService can be used while it runs. If a row read by fn was changed by another transaction in the
+// meantime, fn is run again.
+func (s *PersistenceService) update(ctx context.Context, fn func(t *tx) error) error {
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		s.mu.RLock()
+		t := newTx(s.tables)
+		s.mu.RUnlock()
+
+		if err := fn(t); err != nil {
+			return err
+		}
+		if len(t.writes) == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "error committing transaction")
+		}
+
+		committed, err := s.commit(t)
+		if err != nil {
+			return errors.Wrap(err, "error committing transaction")
+		}
+		if committed {
+			return nil
+		}
+	}
+
+	return errors.Errorf("error committing transaction: conflicts in %d attempts", maxTxAttempts)
+}
+
+// commit commits the writes of a transaction unless it conflicts with the transactions committed since its snapshot.
+func (s *PersistenceService) commit(t *tx) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.conflicts(s.tables) {
+		return false, nil
+	}
+
+	tables := t.apply(s.tables)
+	if s.path != "" {
+		if err := s.save(tables); err != nil {
+			return false, err
+		}
+	}
+	s.tables = tables
+
+	return true, nil
+}
+
+// Create persists a resource.
+func (s *PersistenceService) Create(
+	ctx context.Context,
+	e cce.Persistable,
+) error {
+	return s.update(ctx, func(t *tx) error {
+		return t.Create(ctx, e)
+	})
+}
+
+// Read retrieves a single resource of the given type by ID.
+func (s *PersistenceService) Read(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+) (e cce.Persistable, err error) {
+	err = s.view(func(t *tx) error {
+		e, err = t.Read(ctx, id, zv)
+		return err
+	})
+
+	return e, err
+}
+
+// ReadWithRevision retrieves a single resource of the given type by ID along
+// with its revision.
+func (s *PersistenceService) ReadWithRevision(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+) (e cce.Persistable, rev int64, err error) {
+	err = s.view(func(t *tx) error {
+		e, rev, err = t.ReadWithRevision(ctx, id, zv)
+		return err
+	})
+
+	return e, rev, err
+}
+
+// ReadAll retrieves all resources of the given type.
+func (s *PersistenceService) ReadAll(
+	ctx context.Context,
+	zv cce.Persistable,
+) (es []cce.Persistable, err error) {
+	err = s.view(func(t *tx) error {
+		es, err = t.ReadAll(ctx, zv)
+		return err
+	})
+
+	return es, err
+}
+
+// Filter retrieves a collection of resources of the given type using a set of
+// filters.
+func (s *PersistenceService) Filter(
+	ctx context.Context,
+	zv cce.Filterable,
+	fs []cce.Filter,
+) (es []cce.Persistable, err error) {
+	err = s.view(func(t *tx) error {
+		es, err = t.Filter(ctx, zv, fs)
+		return err
+	})
+
+	return es, err
+}
+
+// ReadPage retrieves a page of resources of the given type using a set of
+// filters, a sort order and a cursor returned with the previous page.
+func (s *PersistenceService) ReadPage(
+	ctx context.Context,
+	zv cce.Filterable,
+	q cce.Query,
+) (es []cce.Persistable, nextCursor string, err error) {
+	err = s.view(func(t *tx) error {
+		es, nextCursor, err = t.ReadPage(ctx, zv, q)
+		return err
+	})
+
+	return es, nextCursor, err
+}
+
+// BulkUpdate updates multiple resources.
+func (s *PersistenceService) BulkUpdate(
+	ctx context.Context,
+	es []cce.Persistable,
+) error {
+	return s.update(ctx, func(t *tx) error {
+		return t.BulkUpdate(ctx, es)
+	})
+}
+
+// UpdateIfMatch updates a resource if its revision matches rev.
+func (s *PersistenceService) UpdateIfMatch(
+	ctx context.Context,
+	e cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	err = s.update(ctx, func(t *tx) error {
+		ok, err = t.UpdateIfMatch(ctx, e, rev)
+		return err
+	})
+
+	return ok, err
+}
+
+// Delete deletes a resource.
+func (s *PersistenceService) Delete(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+) (ok bool, err error) {
+	err = s.update(ctx, func(t *tx) error {
+		ok, err = t.Delete(ctx, id, zv)
+		return err
+	})
+
+	return ok, err
+}
+
+// DeleteIfMatch deletes a resource if its revision matches rev.
+func (s *PersistenceService) DeleteIfMatch(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	err = s.update(ctx, func(t *tx) error {
+		ok, err = t.DeleteIfMatch(ctx, id, zv, rev)
+		return err
+	})
+
+	return ok, err
+}
+
+// WithTx runs fn in a transaction.
+func (s *PersistenceService) WithTx(
+	ctx context.Context,
+	fn func(tx cce.PersistenceService) error,
+) error {
+	return s.update(ctx, func(t *tx) error {
+		return fn(t)
+	})
+}
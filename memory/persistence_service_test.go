@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package memory_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/memory"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("PersistenceService", func() {
+	var (
+		ctx  = context.Background()
+		ps   *memory.PersistenceService
+		node *cce.Node
+		app  *cce.App
+	)
+
+	BeforeEach(func() {
+		var err error
+		ps, err = memory.NewPersistenceService("")
+		Expect(err).ToNot(HaveOccurred())
+
+		node = &cce.Node{ID: "node-1", Name: "node", Location: "here", Serial: "serial-1"}
+		app = &cce.App{ID: "app-1", Type: "container", Name: "app"}
+		Expect(ps.Create(ctx, node)).To(Succeed())
+		Expect(ps.Create(ctx, app)).To(Succeed())
+	})
+
+	Describe("Create", func() {
+		It("Should reject a duplicate ID", func() {
+			Expect(ps.Create(ctx, &cce.App{ID: "app-1"})).To(MatchError(
+				"error inserting record: duplicate entry 'app-1' for key 'id'"))
+		})
+
+		It("Should reject a duplicate unique key", func() {
+			Expect(ps.Create(ctx, &cce.NodeApp{ID: "na-1", NodeID: "node-1", AppID: "app-1"})).To(Succeed())
+			Expect(ps.Create(ctx, &cce.NodeApp{ID: "na-2", NodeID: "node-1", AppID: "app-1"})).To(MatchError(
+				"error inserting record: duplicate entry 'node-1-app-1' for key 'node_id,app_id'"))
+		})
+
+		It("Should accept unique keys whose values only differ in their separation", func() {
+			Expect(ps.Create(ctx, &cce.Node{ID: "node", Name: "node", Location: "here", Serial: "serial-2"})).
+				To(Succeed())
+			Expect(ps.Create(ctx, &cce.App{ID: "1-app-1", Type: "container", Name: "app"})).To(Succeed())
+			Expect(ps.Create(ctx, &cce.NodeApp{ID: "na-1", NodeID: "node-1", AppID: "app-1"})).To(Succeed())
+			Expect(ps.Create(ctx, &cce.NodeApp{ID: "na-2", NodeID: "node", AppID: "1-app-1"})).To(Succeed())
+		})
+
+		It("Should reject a missing foreign key", func() {
+			Expect(ps.Create(ctx, &cce.NodeApp{ID: "na-1", NodeID: "node-2", AppID: "app-1"})).To(MatchError(
+				"error inserting record: cannot add or update a child row: a foreign key constraint fails " +
+					"(nodes_apps.node_id references nodes.id)"))
+		})
+
+		It("Should report violations as a ConstraintError", func() {
+			err := ps.Create(ctx, &cce.App{ID: "app-1"})
+			Expect(errors.Cause(err)).To(BeAssignableToTypeOf(&cce.ConstraintError{}))
+			err = ps.Create(ctx, &cce.NodeApp{ID: "na-1", NodeID: "node-2", AppID: "app-1"})
+			Expect(errors.Cause(err)).To(BeAssignableToTypeOf(&cce.ConstraintError{}))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("Should reject deleting a referenced row", func() {
+			Expect(ps.Create(ctx, &cce.NodeApp{ID: "na-1", NodeID: "node-1", AppID: "app-1"})).To(Succeed())
+
+			_, err := ps.Delete(ctx, "app-1", &cce.App{})
+			Expect(err).To(MatchError(
+				"error deleting record: cannot delete or update a parent row: a foreign key constraint fails " +
+					"(nodes_apps.app_id references apps.id)"))
+
+			e, err := ps.Read(ctx, "app-1", &cce.App{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e).To(Equal(app))
+		})
+
+		It("Should cascade to rows referencing it with ON DELETE CASCADE", func() {
+			Expect(ps.Create(ctx, &cce.NodeGRPCTarget{ID: "t-1", NodeID: "node-1", GRPCTarget: "1.2.3.4:5"})).
+				To(Succeed())
+
+			Expect(ps.Delete(ctx, "node-1", &cce.Node{})).To(BeTrue())
+
+			Expect(ps.Read(ctx, "t-1", &cce.NodeGRPCTarget{})).To(BeNil())
+		})
+
+		It("Should only delete a row at the given revision", func() {
+			Expect(ps.BulkUpdate(ctx, []cce.Persistable{app})).To(Succeed())
+
+			Expect(ps.DeleteIfMatch(ctx, "app-1", &cce.App{}, 1)).To(BeFalse())
+			Expect(ps.DeleteIfMatch(ctx, "app-1", &cce.App{}, 2)).To(BeTrue())
+		})
+	})
+
+	Describe("Filter", func() {
+		It("Should return the matching rows", func() {
+			Expect(ps.Create(ctx, &cce.NodeApp{ID: "na-1", NodeID: "node-1", AppID: "app-1"})).To(Succeed())
+
+			Expect(ps.Filter(ctx, &cce.NodeApp{}, []cce.Filter{{Field: "node_id", Value: "node-1"}})).To(
+				Equal([]cce.Persistable{&cce.NodeApp{ID: "na-1", NodeID: "node-1", AppID: "app-1"}}))
+			Expect(ps.Filter(ctx, &cce.NodeApp{}, []cce.Filter{{Field: "node_id", Value: "node-2"}})).To(BeEmpty())
+		})
+
+		It("Should reject a field that is not a filter field", func() {
+			_, err := ps.Filter(ctx, &cce.NodeApp{}, []cce.Filter{{Field: "status", Value: "x"}})
+			Expect(err).To(MatchError(`disallowed filter field "status"`))
+		})
+	})
+
+	Describe("ReadPage", func() {
+		It("Should page through the sorted rows", func() {
+			Expect(ps.Create(ctx, &cce.App{ID: "app-2", Name: "b"})).To(Succeed())
+			Expect(ps.Create(ctx, &cce.App{ID: "app-3", Name: "c"})).To(Succeed())
+
+			es, cursor, err := ps.ReadPage(ctx, &cce.App{}, cce.Query{Sort: "-name", Limit: 2})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(es).To(HaveLen(2))
+			Expect(es[0].GetID()).To(Equal("app-3"))
+			Expect(es[1].GetID()).To(Equal("app-2"))
+
+			es, cursor, err = ps.ReadPage(ctx, &cce.App{}, cce.Query{Sort: "-name", Limit: 2, Cursor: cursor})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(es).To(Equal([]cce.Persistable{app}))
+			Expect(cursor).To(BeEmpty())
+		})
+	})
+
+	Describe("WithTx", func() {
+		It("Should roll back all changes if fn fails", func() {
+			errFailed := errors.New("failed")
+			Expect(ps.WithTx(ctx, func(tx cce.PersistenceService) error {
+				Expect(tx.Create(ctx, &cce.App{ID: "app-2"})).To(Succeed())
+				Expect(tx.Delete(ctx, "node-1", &cce.Node{})).To(BeTrue())
+				return errFailed
+			})).To(Equal(errFailed))
+
+			Expect(ps.Read(ctx, "app-2", &cce.App{})).To(BeNil())
+			Expect(ps.Read(ctx, "node-1", &cce.Node{})).To(Equal(node))
+		})
+
+		It("Should commit all changes if fn succeeds", func() {
+			Expect(ps.WithTx(ctx, func(tx cce.PersistenceService) error {
+				return tx.Create(ctx, &cce.App{ID: "app-2"})
+			})).To(Succeed())
+
+			Expect(ps.Read(ctx, "app-2", &cce.App{})).To(Equal(&cce.App{ID: "app-2"}))
+		})
+
+		It("Should not block the PersistenceService while fn runs", func() {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(ps.WithTx(ctx, func(tx cce.PersistenceService) error {
+					Expect(ps.Read(ctx, "app-1", &cce.App{})).To(Equal(app))
+					Expect(ps.Create(ctx, &cce.App{ID: "app-2"})).To(Succeed())
+					return tx.Create(ctx, &cce.App{ID: "app-3"})
+				})).To(Succeed())
+			}()
+			Eventually(done).Should(BeClosed())
+
+			Expect(ps.Read(ctx, "app-2", &cce.App{})).To(Equal(&cce.App{ID: "app-2"}))
+			Expect(ps.Read(ctx, "app-3", &cce.App{})).To(Equal(&cce.App{ID: "app-3"}))
+		})
+
+		It("Should run fn again if a row it read was changed meanwhile", func() {
+			attempts := 0
+			Expect(ps.WithTx(ctx, func(tx cce.PersistenceService) error {
+				attempts++
+				e, err := tx.Read(ctx, "app-1", &cce.App{})
+				Expect(err).ToNot(HaveOccurred())
+				if attempts == 1 {
+					Expect(ps.BulkUpdate(ctx, []cce.Persistable{
+						&cce.App{ID: "app-1", Type: "container", Name: "renamed"},
+					})).To(Succeed())
+				}
+				e.(*cce.App).Version = "2"
+				return tx.BulkUpdate(ctx, []cce.Persistable{e})
+			})).To(Succeed())
+			Expect(attempts).To(Equal(2))
+
+			e, rev, err := ps.ReadWithRevision(ctx, "app-1", &cce.App{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e).To(Equal(&cce.App{ID: "app-1", Type: "container", Name: "renamed", Version: "2"}))
+			Expect(rev).To(Equal(int64(3)))
+		})
+
+		It("Should keep the rows written meanwhile that fn did not read", func() {
+			attempts := 0
+			Expect(ps.WithTx(ctx, func(tx cce.PersistenceService) error {
+				attempts++
+				e, err := tx.Read(ctx, "app-1", &cce.App{})
+				Expect(err).ToNot(HaveOccurred())
+				if attempts == 1 {
+					Expect(ps.Create(ctx, &cce.App{ID: "app-2"})).To(Succeed())
+				}
+				e.(*cce.App).Version = "2"
+				return tx.BulkUpdate(ctx, []cce.Persistable{e})
+			})).To(Succeed())
+			Expect(attempts).To(Equal(1))
+
+			Expect(ps.ReadAll(ctx, &cce.App{})).To(Equal([]cce.Persistable{
+				&cce.App{ID: "app-1", Type: "container", Name: "app", Version: "2"},
+				&cce.App{ID: "app-2"},
+			}))
+		})
+	})
+
+	Describe("NewPersistenceService", func() {
+		It("Should load the snapshot saved by a previous instance", func() {
+			dir, err := ioutil.TempDir("", "memory")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "db.json")
+
+			ps, err = memory.NewPersistenceService(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ps.Create(ctx, app)).To(Succeed())
+			Expect(ps.BulkUpdate(ctx, []cce.Persistable{app})).To(Succeed())
+
+			ps, err = memory.NewPersistenceService(path)
+			Expect(err).ToNot(HaveOccurred())
+			e, rev, err := ps.ReadWithRevision(ctx, "app-1", &cce.App{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e).To(Equal(app))
+			Expect(rev).To(Equal(int64(2)))
+		})
+	})
+})
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package memory
+
+// tableSchema declares the constraints of a table. Every table also has a unique id column.
+type tableSchema struct {
+	// unique lists the unique keys of the table. A key is only enforced if none of its columns are NULL.
+	unique [][]string
+	// foreignKeys lists the columns referencing the id of another table.
+	foreignKeys []foreignKey
+}
+
+// foreignKey is a column referencing the id column of another table.
+type foreignKey struct {
+	column  string
+	table   string
+	cascade bool
+}
+
+// schema mirrors the tables and constraints created by the MySQL migrations and must be kept in sync with them.
+var schema = map[string]tableSchema{
+	// -------------
+	// Entity tables
+	// -------------
+
+	"nodes": {
+		unique: [][]string{{"serial"}},
+	},
+	"node_grpc_targets": {
+		unique: [][]string{{"node_id"}, {"grpc_target"}},
+		foreignKeys: []foreignKey{
+			{column: "node_id", table: "nodes", cascade: true},
+		},
+	},
+	"nodes_nfd_features": {
+		unique: [][]string{{"node_id", "nfd_id"}},
+		foreignKeys: []foreignKey{
+			{column: "node_id", table: "nodes", cascade: true},
+		},
+	},
+	"apps":             {},
+	"traffic_policies": {},
+	"dns_configs":      {},
+	"credentials":      {},
+
+	// -------------------
+	// Primary join tables
+	// -------------------
+
+	"dns_configs_app_aliases": {
+		unique: [][]string{{"dns_config_id", "app_id"}},
+		foreignKeys: []foreignKey{
+			{column: "dns_config_id", table: "dns_configs"},
+			{column: "app_id", table: "apps"},
+		},
+	},
+	"nodes_apps": {
+		unique: [][]string{{"node_id", "app_id"}},
+		foreignKeys: []foreignKey{
+			{column: "node_id", table: "nodes"},
+			{column: "app_id", table: "apps"},
+		},
+	},
+	"nodes_dns_configs": {
+		unique: [][]string{{"node_id"}},
+		foreignKeys: []foreignKey{
+			{column: "node_id", table: "nodes"},
+			{column: "dns_config_id", table: "dns_configs"},
+		},
+	},
+	"nodes_network_interfaces_traffic_policies": {
+		unique: [][]string{{"node_id", "network_interface_id"}},
+		foreignKeys: []foreignKey{
+			{column: "node_id", table: "nodes"},
+			{column: "traffic_policy_id", table: "traffic_policies"},
+		},
+	},
+
+	// ---------------------
+	// Secondary join tables
+	// ---------------------
+
+	"nodes_apps_traffic_policies": {
+		unique: [][]string{{"nodes_apps_id", "traffic_policy_id"}},
+		foreignKeys: []foreignKey{
+			{column: "nodes_apps_id", table: "nodes_apps"},
+			{column: "traffic_policy_id", table: "traffic_policies"},
+		},
+	},
+
+	// -----------
+	// Audit trail
+	// -----------
+
+	"audit_records": {},
+
+	// --------
+	// Webhooks
+	// --------
+
+	"webhooks": {},
+	"webhook_deliveries": {
+		foreignKeys: []foreignKey{
+			{column: "webhook_id", table: "webhooks", cascade: true},
+		},
+	},
+
+	// -----
+	// Users
+	// -----
+
+	"users": {
+		unique: [][]string{{"username"}},
+	},
+
+	// --------------
+	// Revoked tokens
+	// --------------
+
+	"revoked_tokens": {},
+
+	// --------
+	// API keys
+	// --------
+
+	"api_keys": {},
+
+	// --------------------
+	// Revoked certificates
+	// --------------------
+
+	"revoked_certificates": {
+		unique: [][]string{{"serial"}},
+	},
+
+	// -----------
+	// Enrollments
+	// -----------
+
+	"enrollment_tokens": {},
+	"enrollments": {
+		unique: [][]string{{"serial"}},
+	},
+
+	// ----------
+	// Node syncs
+	// ----------
+
+	"node_syncs": {
+		unique: [][]string{{"node_id"}},
+		foreignKeys: []foreignKey{
+			{column: "node_id", table: "nodes", cascade: true},
+		},
+	},
+
+	// -------------
+	// Node statuses
+	// -------------
+
+	"node_statuses": {
+		unique: [][]string{{"node_id"}},
+		foreignKeys: []foreignKey{
+			{column: "node_id", table: "nodes", cascade: true},
+		},
+	},
+}
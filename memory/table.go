@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// row is a persisted entity. Rows are never modified in place.
+type row struct {
+	rev    int64
+	entity []byte
+	fields map[string]json.RawMessage
+}
+
+func newRow(entity []byte, rev int64) (*row, error) {
+	r := &row{rev: rev, entity: entity}
+	if err := json.Unmarshal(entity, &r.fields); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling")
+	}
+
+	return r, nil
+}
+
+// col returns the value of a column the way MySQL generates it from entity->>'$.name'. ok is false if the value is
+// NULL.
+func (r *row) col(name string) (val string, ok bool) {
+	raw, ok := r.fields[name]
+	if !ok || string(raw) == "null" {
+		return "", false
+	}
+
+	if err := json.Unmarshal(raw, &val); err != nil {
+		// Not a string, so use the JSON representation
+		return string(raw), true
+	}
+
+	return val, true
+}
+
+// key returns the values of a unique key. ok is false if any of the values is NULL.
+func (r *row) key(cols []string) (key []string, ok bool) {
+	key = make([]string, len(cols))
+	for i, c := range cols {
+		if key[i], ok = r.col(c); !ok {
+			return nil, false
+		}
+	}
+
+	return key, true
+}
+
+// equalKeys returns whether two keys of the same columns have the same values.
+func equalKeys(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// table holds the rows of a table by ID in insertion order. As rows are never modified in place, a table is copied by
+// copying its index.
+type table struct {
+	ids  []string
+	rows map[string]*row
+}
+
+func newTable() *table {
+	return &table{rows: make(map[string]*row)}
+}
+
+func (t *table) clone() *table {
+	c := &table{
+		ids:  make([]string, len(t.ids)),
+		rows: make(map[string]*row, len(t.rows)),
+	}
+	copy(c.ids, t.ids)
+	for id, r := range t.rows {
+		c.rows[id] = r
+	}
+
+	return c
+}
+
+// put inserts or replaces the row with the given ID.
+func (t *table) put(id string, r *row) {
+	if _, ok := t.rows[id]; !ok {
+		t.ids = append(t.ids, id)
+	}
+	t.rows[id] = r
+}
+
+func (t *table) remove(id string) {
+	if _, ok := t.rows[id]; !ok {
+		return
+	}
+	delete(t.rows, id)
+	for i := range t.ids {
+		if t.ids[i] == id {
+			t.ids = append(t.ids[:i], t.ids[i+1:]...)
+			break
+		}
+	}
+}
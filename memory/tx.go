@@ -0,0 +1,619 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/pkg/errors"
+)
+
+// tx implements cce.PersistenceService within a transaction on a snapshot of the committed tables. A table is copied
+// the first time it is written to, so that the transaction sees its own writes. A writable transaction records the
+// rows and tables it read from the snapshot, so that conflicts with the transactions committed meanwhile are detected,
+// and its writes, which are replayed on the committed tables when it is committed.
+type tx struct {
+	committed map[string]*table
+	changed   map[string]*table
+
+	// reads holds the snapshot rows read by ID, nil if there was none
+	reads map[string]map[string]*row
+	// scanned holds the tables whose rows were all read
+	scanned map[string]bool
+	writes  map[string][]write
+}
+
+// write is a row put by a transaction or, if r is nil, removed.
+type write struct {
+	id string
+	r  *row
+}
+
+func newTx(committed map[string]*table) *tx {
+	return &tx{
+		committed: committed,
+		changed:   make(map[string]*table),
+		reads:     make(map[string]map[string]*row),
+		scanned:   make(map[string]bool),
+		writes:    make(map[string][]write),
+	}
+}
+
+func (t *tx) table(name string) (*table, error) {
+	if tbl, ok := t.changed[name]; ok {
+		return tbl, nil
+	}
+	if tbl, ok := t.committed[name]; ok {
+		return tbl, nil
+	}
+
+	return nil, errors.Errorf("table %q doesn't exist", name)
+}
+
+// row returns the row with the given ID, or nil if there is none.
+func (t *tx) row(name, id string) (*row, error) {
+	tbl, err := t.table(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.reads != nil {
+		if t.reads[name] == nil {
+			t.reads[name] = make(map[string]*row)
+		}
+		if _, ok := t.reads[name][id]; !ok {
+			t.reads[name][id] = t.committed[name].rows[id]
+		}
+	}
+
+	return tbl.rows[id], nil
+}
+
+// scan returns a table whose rows are all about to be read.
+func (t *tx) scan(name string) (*table, error) {
+	tbl, err := t.table(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.scanned != nil {
+		t.scanned[name] = true
+	}
+
+	return tbl, nil
+}
+
+// conflicts returns whether any of the rows read by the transaction were changed in committed since its snapshot.
+func (t *tx) conflicts(committed map[string]*table) bool {
+	for name := range t.scanned {
+		if committed[name] != t.committed[name] {
+			return true
+		}
+	}
+	for name, rows := range t.reads {
+		if committed[name] == t.committed[name] {
+			continue
+		}
+		for id, r := range rows {
+			if committed[name].rows[id] != r {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// apply returns the tables of committed with the writes of the transaction.
+func (t *tx) apply(committed map[string]*table) map[string]*table {
+	tables := make(map[string]*table, len(committed))
+	for name, tbl := range committed {
+		tables[name] = tbl
+	}
+	for name, writes := range t.writes {
+		tbl := committed[name].clone()
+		for _, w := range writes {
+			if w.r == nil {
+				tbl.remove(w.id)
+			} else {
+				tbl.put(w.id, w.r)
+			}
+		}
+		tables[name] = tbl
+	}
+
+	return tables
+}
+
+// put inserts or replaces the row with the given ID.
+func (t *tx) put(name, id string, r *row) error {
+	tbl, err := t.writableTable(name)
+	if err != nil {
+		return err
+	}
+	tbl.put(id, r)
+	t.writes[name] = append(t.writes[name], write{id: id, r: r})
+
+	return nil
+}
+
+// remove removes the row with the given ID.
+func (t *tx) remove(name, id string) error {
+	tbl, err := t.writableTable(name)
+	if err != nil {
+		return err
+	}
+	tbl.remove(id)
+	t.writes[name] = append(t.writes[name], write{id: id})
+
+	return nil
+}
+
+func (t *tx) writableTable(name string) (*table, error) {
+	if tbl, ok := t.changed[name]; ok {
+		return tbl, nil
+	}
+
+	tbl, err := t.table(name)
+	if err != nil {
+		return nil, err
+	}
+	tbl = tbl.clone()
+	t.changed[name] = tbl
+
+	return tbl, nil
+}
+
+// Create persists a resource.
+func (t *tx) Create(
+	ctx context.Context,
+	e cce.Persistable,
+) error {
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling")
+	}
+
+	if err = t.insert(e.GetTableName(), bytes); err != nil {
+		return errors.Wrap(err, "error inserting record")
+	}
+
+	return nil
+}
+
+func (t *tx) insert(name string, entity []byte) error {
+	r, err := newRow(entity, 1)
+	if err != nil {
+		return err
+	}
+	id, ok := r.col("id")
+	if !ok {
+		return errors.New("column 'id' cannot be null")
+	}
+
+	old, err := t.row(name, id)
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		return &cce.ConstraintError{Msg: fmt.Sprintf("duplicate entry '%s' for key 'id'", id)}
+	}
+	if err = t.checkConstraints(name, id, r); err != nil {
+		return err
+	}
+
+	return t.put(name, id, r)
+}
+
+// checkConstraints checks the unique and foreign keys of a row that is about to be written.
+func (t *tx) checkConstraints(name, id string, r *row) error {
+	for _, cols := range schema[name].unique {
+		key, ok := r.key(cols)
+		if !ok {
+			continue
+		}
+		tbl, err := t.scan(name)
+		if err != nil {
+			return err
+		}
+		for otherID, other := range tbl.rows {
+			if otherKey, ok := other.key(cols); ok && otherID != id && equalKeys(otherKey, key) {
+				return &cce.ConstraintError{Msg: fmt.Sprintf(
+					"duplicate entry '%s' for key '%s'", strings.Join(key, "-"), strings.Join(cols, ","))}
+			}
+		}
+	}
+
+	for _, fk := range schema[name].foreignKeys {
+		val, ok := r.col(fk.column)
+		if !ok {
+			continue
+		}
+		parent, err := t.row(fk.table, val)
+		if err != nil {
+			return err
+		}
+		if parent == nil {
+			return &cce.ConstraintError{Msg: fmt.Sprintf(
+				"cannot add or update a child row: a foreign key constraint fails (%s.%s references %s.id)",
+				name, fk.column, fk.table)}
+		}
+	}
+
+	return nil
+}
+
+// Read retrieves a single resource of the given type by ID.
+func (t *tx) Read(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+) (e cce.Persistable, err error) {
+	e, _, err = t.ReadWithRevision(ctx, id, zv)
+	return e, err
+}
+
+// ReadWithRevision retrieves a single resource of the given type by ID along
+// with its revision.
+func (t *tx) ReadWithRevision(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+) (e cce.Persistable, rev int64, err error) {
+	r, err := t.row(zv.GetTableName(), id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if r == nil {
+		return nil, 0, nil
+	}
+
+	if e, err = unmarshal(r, zv); err != nil {
+		return nil, 0, err
+	}
+
+	return e, r.rev, nil
+}
+
+// ReadAll retrieves all resources of the given type.
+func (t *tx) ReadAll(
+	ctx context.Context,
+	zv cce.Persistable,
+) (es []cce.Persistable, err error) {
+	tbl, err := t.scan(zv.GetTableName())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range tbl.ids {
+		e, err := unmarshal(tbl.rows[id], zv)
+		if err != nil {
+			return nil, err
+		}
+
+		es = append(es, e)
+	}
+
+	return es, nil
+}
+
+// Filter retrieves a collection of resources of the given type using a set of
+// filters.
+func (t *tx) Filter(
+	ctx context.Context,
+	zv cce.Filterable,
+	fs []cce.Filter,
+) (es []cce.Persistable, err error) {
+	for _, f := range fs {
+		if !isFilterField(zv, f.Field) {
+			return nil, errors.Errorf("disallowed filter field %q", f.Field)
+		}
+	}
+
+	tbl, err := t.scan(zv.GetTableName())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range tbl.ids {
+		if !matches(zv, tbl.rows[id], fs) {
+			continue
+		}
+
+		e, err := unmarshal(tbl.rows[id], zv)
+		if err != nil {
+			return nil, err
+		}
+
+		es = append(es, e)
+	}
+
+	return es, nil
+}
+
+// pageRow is a row along with the values it is ordered by.
+type pageRow struct {
+	sortVal string
+	id      string
+	r       *row
+}
+
+func (a pageRow) compare(sortVal, id string) int {
+	if c := strings.Compare(a.sortVal, sortVal); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.id, id)
+}
+
+// ReadPage retrieves a page of resources of the given type using a set of
+// filters, a sort order and a cursor returned with the previous page. Unlike
+// MySQL, values are compared byte-wise rather than by collation.
+func (t *tx) ReadPage( //nolint:gocyclo
+	ctx context.Context,
+	zv cce.Filterable,
+	q cce.Query,
+) (es []cce.Persistable, nextCursor string, err error) {
+	if err = q.Validate(); err != nil {
+		return nil, "", errors.Wrap(err, "invalid query")
+	}
+
+	sortField, desc := "id", false
+	if q.Sort != "" {
+		sortField, desc = strings.TrimPrefix(q.Sort, "-"), strings.HasPrefix(q.Sort, "-")
+	}
+
+	var cursorVal, cursorID string
+	if q.Cursor != "" {
+		if cursorVal, cursorID, err = cce.DecodeCursor(q.Cursor); err != nil {
+			return nil, "", err
+		}
+	}
+
+	tbl, err := t.scan(zv.GetTableName())
+	if err != nil {
+		return nil, "", err
+	}
+
+	var rows []pageRow
+	for _, id := range tbl.ids {
+		r := tbl.rows[id]
+		if !matches(zv, r, q.Filters) || !matchesMin(zv, r, q.MinFilters) {
+			continue
+		}
+
+		pr := pageRow{id: id, r: r}
+		pr.sortVal, _ = r.col(sortField)
+		if q.Cursor != "" && (!desc && pr.compare(cursorVal, cursorID) <= 0 ||
+			desc && pr.compare(cursorVal, cursorID) >= 0) {
+			continue
+		}
+
+		rows = append(rows, pr)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if desc {
+			return rows[i].compare(rows[j].sortVal, rows[j].id) > 0
+		}
+		return rows[i].compare(rows[j].sortVal, rows[j].id) < 0
+	})
+	if q.Limit > 0 && len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+		nextCursor = cce.EncodeCursor(rows[q.Limit-1].sortVal, rows[q.Limit-1].id)
+	}
+
+	for _, pr := range rows {
+		e, err := unmarshal(pr.r, zv)
+		if err != nil {
+			return nil, "", err
+		}
+
+		es = append(es, e)
+	}
+
+	return es, nextCursor, nil
+}
+
+// BulkUpdate updates multiple resources.
+func (t *tx) BulkUpdate(
+	ctx context.Context,
+	es []cce.Persistable,
+) error {
+	for _, e := range es {
+		if _, err := t.update(e, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateIfMatch updates a resource if its revision matches rev.
+func (t *tx) UpdateIfMatch(
+	ctx context.Context,
+	e cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	return t.update(e, rev)
+}
+
+// update updates a resource if it exists and, unless rev is zero, its revision matches rev.
+func (t *tx) update(e cce.Persistable, rev int64) (ok bool, err error) {
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return false, errors.Wrap(err, "error marshaling")
+	}
+
+	old, err := t.row(e.GetTableName(), e.GetID())
+	if err != nil {
+		return false, errors.Wrap(err, "error updating record")
+	}
+	if old == nil || rev != 0 && old.rev != rev {
+		return false, nil
+	}
+
+	r, err := newRow(bytes, old.rev+1)
+	if err != nil {
+		return false, err
+	}
+	if err = t.checkConstraints(e.GetTableName(), e.GetID(), r); err != nil {
+		return false, errors.Wrap(err, "error updating record")
+	}
+	if err = t.put(e.GetTableName(), e.GetID(), r); err != nil {
+		return false, errors.Wrap(err, "error updating record")
+	}
+
+	return true, nil
+}
+
+// Delete deletes a resource.
+func (t *tx) Delete(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+) (ok bool, err error) {
+	return t.delete(zv.GetTableName(), id, 0)
+}
+
+// DeleteIfMatch deletes a resource if its revision matches rev.
+func (t *tx) DeleteIfMatch(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	return t.delete(zv.GetTableName(), id, rev)
+}
+
+// delete deletes a row if it exists and, unless rev is zero, its revision matches rev. Rows referencing it with ON
+// DELETE CASCADE are deleted as well.
+func (t *tx) delete(name, id string, rev int64) (ok bool, err error) {
+	old, err := t.row(name, id)
+	if err != nil {
+		return false, errors.Wrap(err, "error deleting record")
+	}
+	if old == nil || rev != 0 && old.rev != rev {
+		return false, nil
+	}
+
+	// Find everything to delete before deleting anything, so that a failed
+	// delete leaves no trace like in MySQL
+	doomed := make(map[string][]string)
+	if err = t.cascade(name, id, doomed); err != nil {
+		return false, errors.Wrap(err, "error deleting record")
+	}
+
+	for n, ids := range doomed {
+		for _, id := range ids {
+			if err = t.remove(n, id); err != nil {
+				return false, errors.Wrap(err, "error deleting record")
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// cascade adds a row and the rows referencing it with ON DELETE CASCADE to doomed. It fails if a row is referenced
+// by a foreign key without ON DELETE CASCADE.
+func (t *tx) cascade(name, id string, doomed map[string][]string) error {
+	for _, doomedID := range doomed[name] {
+		if doomedID == id {
+			return nil
+		}
+	}
+	doomed[name] = append(doomed[name], id)
+
+	for child, cs := range schema {
+		for _, fk := range cs.foreignKeys {
+			if fk.table != name {
+				continue
+			}
+
+			tbl, err := t.scan(child)
+			if err != nil {
+				return err
+			}
+			for _, childID := range tbl.ids {
+				if val, ok := tbl.rows[childID].col(fk.column); !ok || val != id {
+					continue
+				}
+				if !fk.cascade {
+					return &cce.ConstraintError{Msg: fmt.Sprintf(
+						"cannot delete or update a parent row: a foreign key constraint fails "+
+							"(%s.%s references %s.id)", child, fk.column, name)}
+				}
+				if err = t.cascade(child, childID, doomed); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// WithTx runs fn in the transaction.
+func (t *tx) WithTx(
+	ctx context.Context,
+	fn func(tx cce.PersistenceService) error,
+) error {
+	return fn(t)
+}
+
+// isColumn returns whether a field is a generated column of the table.
+func isColumn(zv cce.Filterable, field string) bool {
+	return field == "id" || isFilterField(zv, field)
+}
+
+func isFilterField(zv cce.Filterable, field string) bool {
+	for _, f := range zv.FilterFields() {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches returns whether a row matches all filters. Like in MySQL, a NULL
+// column matches nothing while other missing fields match the empty string.
+func matches(zv cce.Filterable, r *row, fs []cce.Filter) bool {
+	for _, f := range fs {
+		val, ok := r.col(f.Field)
+		if !ok && isColumn(zv, f.Field) || val != f.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesMin returns whether a row is greater than or equal to all filters.
+func matchesMin(zv cce.Filterable, r *row, fs []cce.Filter) bool {
+	for _, f := range fs {
+		val, ok := r.col(f.Field)
+		if !ok && isColumn(zv, f.Field) || val < f.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func unmarshal(r *row, zv cce.Persistable) (cce.Persistable, error) {
+	e := reflect.New(reflect.ValueOf(zv).Elem().Type()).Interface().(cce.Persistable)
+	if err := json.Unmarshal(r.entity, e); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling")
+	}
+
+	return e, nil
+}
@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// schema_version has a row for each applied migration.
+const createSchemaVersionTable = `CREATE TABLE IF NOT EXISTS schema_version (
+	version INT UNSIGNED NOT NULL PRIMARY KEY,
+	description VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+const (
+	// migrationLock is the name of the lock serializing controllers migrating the same database.
+	migrationLock = "controller_ce.migrations"
+	// migrationLockTimeout is the number of seconds to wait for the migration lock.
+	migrationLockTimeout = 60
+)
+
+// LatestVersion returns the version of the last migration.
+func LatestVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// Migrate migrates the schema of db to the target version by applying the pending migrations in order or reverting
+// the applied ones in reverse order. It returns the versions before and after the migration.
+//
+// A database created by the schema.sql of a release without migrations has no schema_version table. It is recorded
+// as being at version 1.
+func Migrate(ctx context.Context, db *sql.DB, target int) (from, to int, err error) {
+	if err = checkMigrations(); err != nil {
+		return 0, 0, err
+	}
+	if target < 0 || target > LatestVersion() {
+		return 0, 0, errors.Errorf("unknown schema version %d", target)
+	}
+
+	// Use a single connection as MySQL locks are held by connections
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "error getting connection")
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err = conn.QueryRowContext(
+		ctx, "SELECT GET_LOCK(?, ?)", migrationLock, migrationLockTimeout,
+	).Scan(&locked); err != nil {
+		return 0, 0, errors.Wrap(err, "error getting migration lock")
+	}
+	if locked.Int64 != 1 {
+		return 0, 0, errors.New("timed out waiting for migration lock")
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", migrationLock)
+	}()
+
+	if from, err = currentVersion(ctx, conn); err != nil {
+		return 0, 0, err
+	}
+	if from > LatestVersion() {
+		return from, from, errors.Errorf(
+			"schema version %d is newer than the latest known version %d", from, LatestVersion())
+	}
+
+	for to = from; to < target; to++ {
+		if err = migrateUp(ctx, conn, Migrations[to]); err != nil {
+			return from, to, err
+		}
+	}
+	for ; to > target; to-- {
+		if err = migrateDown(ctx, conn, Migrations[to-1]); err != nil {
+			return from, to, err
+		}
+	}
+
+	return from, to, nil
+}
+
+// checkMigrations checks that the migrations are numbered from 1 without gaps.
+func checkMigrations() error {
+	for i, m := range Migrations {
+		if m.Version != i+1 {
+			return errors.Errorf("migration %d has version %d", i+1, m.Version)
+		}
+		if len(m.Up) == 0 || len(m.Down) == 0 {
+			return errors.Errorf("migration %d is missing up or down statements", m.Version)
+		}
+	}
+
+	return nil
+}
+
+func currentVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	if _, err := conn.ExecContext(ctx, createSchemaVersionTable); err != nil {
+		return 0, errors.Wrap(err, "error creating schema_version")
+	}
+
+	var version sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, errors.Wrap(err, "error reading schema version")
+	}
+	if version.Valid {
+		return int(version.Int64), nil
+	}
+
+	// Check for a database created before migrations were introduced
+	var legacy bool
+	if err := conn.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) > 0
+		 FROM information_schema.tables
+		 WHERE table_schema = DATABASE() AND table_name = 'nodes'`,
+	).Scan(&legacy); err != nil {
+		return 0, errors.Wrap(err, "error checking for legacy schema")
+	}
+	if !legacy {
+		return 0, nil
+	}
+
+	if _, err := conn.ExecContext(
+		ctx,
+		"INSERT INTO schema_version (version, description) VALUES (?, ?)",
+		Migrations[0].Version, Migrations[0].Description+" (legacy)",
+	); err != nil {
+		return 0, errors.Wrap(err, "error recording legacy schema version")
+	}
+
+	return Migrations[0].Version, nil
+}
+
+func migrateUp(ctx context.Context, conn *sql.Conn, m Migration) error {
+	for _, stmt := range m.Up {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrapf(err, "error applying migration %d (%s)", m.Version, m.Description)
+		}
+	}
+
+	if _, err := conn.ExecContext(
+		ctx,
+		"INSERT INTO schema_version (version, description) VALUES (?, ?)",
+		m.Version, m.Description,
+	); err != nil {
+		return errors.Wrapf(err, "error recording migration %d", m.Version)
+	}
+
+	return nil
+}
+
+func migrateDown(ctx context.Context, conn *sql.Conn, m Migration) error {
+	for _, stmt := range m.Down {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrapf(err, "error reverting migration %d (%s)", m.Version, m.Description)
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, "DELETE FROM schema_version WHERE version = ?", m.Version); err != nil {
+		return errors.Wrapf(err, "error recording revert of migration %d", m.Version)
+	}
+
+	return nil
+}
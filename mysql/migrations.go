@@ -0,0 +1,391 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package mysql
+
+// Migration is a numbered schema change. Up applies it and Down reverts it. As MySQL commits DDL statements
+// implicitly, a migration that fails halfway must be repaired manually.
+type Migration struct {
+	Version     int
+	Description string
+	Up          []string
+	Down        []string
+}
+
+// Migrations are the schema migrations in order of their versions. A migration must never be changed once released;
+// schema changes are made by appending a new one.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "initial schema",
+		Up: []string{
+			// -------------
+			// Entity tables
+			// -------------
+
+			`CREATE TABLE nodes (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				-- TODO add UNIQUE KEY on serial - will require refactoring the tests
+				serial VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.serial') STORED,
+				entity JSON
+			)`,
+
+			// the grpc target for a node may or may not exist yet, so we specify ON DELETE CASCADE to handle
+			// deletion without requiring extra logic in the code
+			`CREATE TABLE node_grpc_targets (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				node_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.node_id') STORED,
+				grpc_target VARCHAR(47) GENERATED ALWAYS AS (entity->>'$.grpc_target') STORED,
+				entity JSON,
+				FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE,
+				UNIQUE KEY (node_id),
+				UNIQUE KEY (grpc_target)
+			)`,
+
+			`CREATE TABLE nodes_nfd_features (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				node_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.node_id') STORED,
+				nfd_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.nfd_id') STORED,
+				entity JSON,
+				FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE,
+				UNIQUE KEY (node_id, nfd_id)
+			)`,
+
+			`CREATE TABLE apps (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				type VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.type') STORED,
+				entity JSON
+			)`,
+
+			`CREATE TABLE traffic_policies (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				entity JSON
+			)`,
+
+			`CREATE TABLE dns_configs (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				entity JSON
+			)`,
+
+			`CREATE TABLE credentials (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				entity JSON
+			)`,
+
+			// -------------------
+			// Primary join tables
+			// -------------------
+
+			// These tables join two entity tables.
+
+			// dns_configs x apps
+			`CREATE TABLE dns_configs_app_aliases (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				dns_config_id  VARCHAR(36) GENERATED ALWAYS AS
+					(entity->>'$.dns_config_id') STORED,
+				app_id  VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.app_id') STORED,
+				entity JSON,
+				FOREIGN KEY (dns_config_id) REFERENCES dns_configs(id),
+				FOREIGN KEY (app_id) REFERENCES apps(id),
+				UNIQUE KEY (dns_config_id, app_id)
+			)`,
+
+			// nodes x apps
+			`CREATE TABLE nodes_apps (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				node_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.node_id') STORED,
+				app_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.app_id') STORED,
+				entity JSON,
+				FOREIGN KEY (node_id) REFERENCES nodes(id),
+				FOREIGN KEY (app_id) REFERENCES apps(id),
+				UNIQUE KEY (node_id, app_id)
+			)`,
+
+			// nodes x dns_configs
+			`CREATE TABLE nodes_dns_configs (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				node_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.node_id') STORED UNIQUE KEY,
+				dns_config_id VARCHAR(36) GENERATED ALWAYS AS
+					(entity->>'$.dns_config_id') STORED,
+				entity JSON,
+				FOREIGN KEY (node_id) REFERENCES nodes(id),
+				FOREIGN KEY (dns_config_id) REFERENCES dns_configs(id)
+			)`,
+
+			// nodes (network_interfaces) x traffic_policies
+			`CREATE TABLE nodes_network_interfaces_traffic_policies (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				node_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.node_id') STORED,
+				network_interface_id VARCHAR(36) GENERATED ALWAYS AS
+					(entity->>'$.network_interface_id') STORED,
+				traffic_policy_id VARCHAR(36) GENERATED ALWAYS AS
+					(entity->>'$.traffic_policy_id') STORED,
+				entity JSON,
+				FOREIGN KEY (node_id) REFERENCES nodes(id),
+				FOREIGN KEY (traffic_policy_id) REFERENCES traffic_policies(id),
+				UNIQUE KEY (node_id, network_interface_id)
+			)`,
+
+			// ---------------------
+			// Secondary join tables
+			// ---------------------
+
+			// These tables join an entity table to a primary join table.
+
+			// nodes_apps x traffic_policies
+			`CREATE TABLE nodes_apps_traffic_policies (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				nodes_apps_id VARCHAR(36) GENERATED ALWAYS AS
+					(entity->>'$.nodes_apps_id') STORED,
+				traffic_policy_id VARCHAR(36) GENERATED ALWAYS AS
+					(entity->>'$.traffic_policy_id') STORED,
+				entity JSON,
+				FOREIGN KEY (nodes_apps_id) REFERENCES nodes_apps(id),
+				FOREIGN KEY (traffic_policy_id) REFERENCES traffic_policies(id),
+				UNIQUE KEY (nodes_apps_id, traffic_policy_id)
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE nodes_apps_traffic_policies",
+			"DROP TABLE nodes_network_interfaces_traffic_policies",
+			"DROP TABLE nodes_dns_configs",
+			"DROP TABLE nodes_apps",
+			"DROP TABLE dns_configs_app_aliases",
+			"DROP TABLE credentials",
+			"DROP TABLE dns_configs",
+			"DROP TABLE traffic_policies",
+			"DROP TABLE apps",
+			"DROP TABLE nodes_nfd_features",
+			"DROP TABLE node_grpc_targets",
+			"DROP TABLE nodes",
+		},
+	},
+	{
+		// Every table has a rev column that is incremented on each update. It is used for optimistic concurrency
+		// control (returned as the ETag of an entity and checked against If-Match).
+		Version:     2,
+		Description: "add revisions",
+		Up: []string{
+			"ALTER TABLE nodes ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+			"ALTER TABLE node_grpc_targets ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+			"ALTER TABLE nodes_nfd_features ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+			"ALTER TABLE apps ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+			"ALTER TABLE traffic_policies ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+			"ALTER TABLE dns_configs ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+			"ALTER TABLE credentials ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+			"ALTER TABLE dns_configs_app_aliases ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+			"ALTER TABLE nodes_apps ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+			"ALTER TABLE nodes_dns_configs ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+			"ALTER TABLE nodes_network_interfaces_traffic_policies ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+			"ALTER TABLE nodes_apps_traffic_policies ADD COLUMN rev INT UNSIGNED NOT NULL DEFAULT 1",
+		},
+		Down: []string{
+			"ALTER TABLE nodes_apps_traffic_policies DROP COLUMN rev",
+			"ALTER TABLE nodes_network_interfaces_traffic_policies DROP COLUMN rev",
+			"ALTER TABLE nodes_dns_configs DROP COLUMN rev",
+			"ALTER TABLE nodes_apps DROP COLUMN rev",
+			"ALTER TABLE dns_configs_app_aliases DROP COLUMN rev",
+			"ALTER TABLE credentials DROP COLUMN rev",
+			"ALTER TABLE dns_configs DROP COLUMN rev",
+			"ALTER TABLE traffic_policies DROP COLUMN rev",
+			"ALTER TABLE apps DROP COLUMN rev",
+			"ALTER TABLE nodes_nfd_features DROP COLUMN rev",
+			"ALTER TABLE node_grpc_targets DROP COLUMN rev",
+			"ALTER TABLE nodes DROP COLUMN rev",
+		},
+	},
+	{
+		// audit_records is append-only; the controller never updates or deletes its rows
+		Version:     3,
+		Description: "add audit trail",
+		Up: []string{
+			`CREATE TABLE audit_records (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				actor VARCHAR(255) GENERATED ALWAYS AS (entity->>'$.actor') STORED,
+				timestamp VARCHAR(35) GENERATED ALWAYS AS (entity->>'$.timestamp') STORED,
+				table_name VARCHAR(64) GENERATED ALWAYS AS (entity->>'$.table_name') STORED,
+				entity_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.entity_id') STORED,
+				rev INT UNSIGNED NOT NULL DEFAULT 1,
+				entity JSON,
+				KEY (entity_id, timestamp),
+				KEY (timestamp)
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE audit_records",
+		},
+	},
+	{
+		Version:     4,
+		Description: "add webhooks",
+		Up: []string{
+			`CREATE TABLE webhooks (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				url VARCHAR(2048) GENERATED ALWAYS AS (entity->>'$.url') STORED,
+				rev INT UNSIGNED NOT NULL DEFAULT 1,
+				entity JSON
+			)`,
+
+			// deliveries are the delivery log of a webhook and are deleted with it
+			`CREATE TABLE webhook_deliveries (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				webhook_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.webhook_id') STORED,
+				type VARCHAR(64) GENERATED ALWAYS AS (entity->>'$.type') STORED,
+				status VARCHAR(16) GENERATED ALWAYS AS (entity->>'$.status') STORED,
+				created_at VARCHAR(35) GENERATED ALWAYS AS (entity->>'$.created_at') STORED,
+				next_attempt_at VARCHAR(35) GENERATED ALWAYS AS (entity->>'$.next_attempt_at') STORED,
+				rev INT UNSIGNED NOT NULL DEFAULT 1,
+				entity JSON,
+				FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE,
+				KEY (webhook_id, created_at),
+				KEY (status, next_attempt_at)
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE webhook_deliveries",
+			"DROP TABLE webhooks",
+		},
+	},
+	{
+		Version:     5,
+		Description: "add users",
+		Up: []string{
+			`CREATE TABLE users (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				username VARCHAR(64) GENERATED ALWAYS AS (entity->>'$.username') STORED UNIQUE KEY,
+				role VARCHAR(16) GENERATED ALWAYS AS (entity->>'$.role') STORED,
+				rev INT UNSIGNED NOT NULL DEFAULT 1,
+				entity JSON
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE users",
+		},
+	},
+	{
+		Version:     6,
+		Description: "add revoked tokens",
+		Up: []string{
+			`CREATE TABLE revoked_tokens (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				rev INT UNSIGNED NOT NULL DEFAULT 1,
+				entity JSON
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE revoked_tokens",
+		},
+	},
+	{
+		Version:     7,
+		Description: "add API keys",
+		Up: []string{
+			`CREATE TABLE api_keys (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				name VARCHAR(64) GENERATED ALWAYS AS (entity->>'$.name') STORED,
+				role VARCHAR(16) GENERATED ALWAYS AS (entity->>'$.role') STORED,
+				created_by VARCHAR(255) GENERATED ALWAYS AS (entity->>'$.created_by') STORED,
+				rev INT UNSIGNED NOT NULL DEFAULT 1,
+				entity JSON
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE api_keys",
+		},
+	},
+	{
+		// revocations outlive the nodes whose certificates they revoke, so node_id has no foreign key
+		Version:     8,
+		Description: "add revoked certificates",
+		Up: []string{
+			`CREATE TABLE revoked_certificates (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				serial VARCHAR(40) GENERATED ALWAYS AS (entity->>'$.serial') STORED UNIQUE KEY,
+				node_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.node_id') STORED,
+				rev INT UNSIGNED NOT NULL DEFAULT 1,
+				entity JSON,
+				KEY (node_id)
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE revoked_certificates",
+		},
+	},
+	{
+		// the nodes of enrollment tokens are only created when the tokens are redeemed, so node_id has no foreign key
+		Version:     9,
+		Description: "add enrollment tokens and pending enrollments",
+		Up: []string{
+			`CREATE TABLE enrollment_tokens (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				node_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.node_id') STORED,
+				created_by VARCHAR(255) GENERATED ALWAYS AS (entity->>'$.created_by') STORED,
+				rev INT UNSIGNED NOT NULL DEFAULT 1,
+				entity JSON
+			)`,
+			`CREATE TABLE enrollments (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				serial VARCHAR(64) GENERATED ALWAYS AS (entity->>'$.serial') STORED UNIQUE KEY,
+				status VARCHAR(16) GENERATED ALWAYS AS (entity->>'$.status') STORED,
+				rev INT UNSIGNED NOT NULL DEFAULT 1,
+				entity JSON
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE enrollments",
+			"DROP TABLE enrollment_tokens",
+		},
+	},
+	{
+		// nodes are identified by SHA-256 serials, which do not fit the former column. Nodes with duplicate serials
+		// must be deleted or given distinct serials before migrating. Down keeps the column wide, as the SHA-256
+		// serials would not fit the former one.
+		Version:     10,
+		Description: "add unique key on node serials",
+		Up: []string{
+			"ALTER TABLE nodes MODIFY serial VARCHAR(64) GENERATED ALWAYS AS (entity->>'$.serial') STORED",
+			"ALTER TABLE nodes ADD UNIQUE KEY serial (serial)",
+		},
+		Down: []string{
+			"ALTER TABLE nodes DROP KEY serial",
+		},
+	},
+	{
+		// the reconciler updates the sync status of every node periodically, so it is neither audited nor published
+		Version:     11,
+		Description: "add node sync statuses",
+		Up: []string{
+			`CREATE TABLE node_syncs (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				node_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.node_id') STORED,
+				status VARCHAR(16) GENERATED ALWAYS AS (entity->>'$.status') STORED,
+				rev INT UNSIGNED NOT NULL DEFAULT 1,
+				entity JSON,
+				FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE,
+				UNIQUE KEY (node_id)
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE node_syncs",
+		},
+	},
+	{
+		// heartbeats update the status of their node, so only status transitions are published
+		Version:     12,
+		Description: "add node statuses",
+		Up: []string{
+			`CREATE TABLE node_statuses (
+				id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.id') STORED UNIQUE KEY,
+				node_id VARCHAR(36) GENERATED ALWAYS AS (entity->>'$.node_id') STORED,
+				status VARCHAR(16) GENERATED ALWAYS AS (entity->>'$.status') STORED,
+				rev INT UNSIGNED NOT NULL DEFAULT 1,
+				entity JSON,
+				FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE,
+				UNIQUE KEY (node_id)
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE node_statuses",
+		},
+	},
+}
@@ -12,7 +12,7 @@ import (
 	"sort"
 	"strings"
 
-	_ "github.com/go-sql-driver/mysql" // provides the mysql driver
+	mysqldriver "github.com/go-sql-driver/mysql" // also provides the mysql driver
 	cce "github.com/open-ness/edgecontroller"
 	"github.com/pkg/errors"
 )
@@ -23,6 +23,39 @@ type CceDB interface {
 	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 }
 
+// txBeginner is implemented by a CceDB that can start transactions, such as *sql.DB.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// txDB adapts a *sql.Tx to CceDB.
+type txDB struct {
+	*sql.Tx
+}
+
+// Ping is a no-op as the connection of a transaction is already established.
+func (txDB) Ping() error { return nil }
+
+// MySQL error numbers of unique and foreign key violations
+const (
+	errDupEntry        = 1062
+	errRowIsReferenced = 1451
+	errNoReferencedRow = 1452
+)
+
+// constraintError returns a cce.ConstraintError for an error violating a
+// unique or foreign key and err otherwise.
+func constraintError(err error) error {
+	if me, ok := err.(*mysqldriver.MySQLError); ok {
+		switch me.Number {
+		case errDupEntry, errRowIsReferenced, errNoReferencedRow:
+			return &cce.ConstraintError{Msg: me.Error()}
+		}
+	}
+
+	return err
+}
+
 // PersistenceService implements cce.PersistenceService.
 type PersistenceService struct {
 	DB CceDB
@@ -49,7 +82,7 @@ func (s *PersistenceService) Create(
 			`INSERT INTO %s (entity) VALUES (?)`, e.GetTableName()),
 		bytes)
 	if err != nil {
-		return errors.Wrap(err, "error inserting record")
+		return errors.Wrap(constraintError(err), "error inserting record")
 	}
 
 	return nil
@@ -76,6 +109,7 @@ func (s *PersistenceService) Read(
 	if err != nil {
 		return nil, errors.Wrap(err, "error running query")
 	}
+	defer rows.Close()
 
 	if !rows.Next() {
 		return nil, nil
@@ -88,6 +122,46 @@ func (s *PersistenceService) Read(
 	return e, nil
 }
 
+// ReadWithRevision retrieves a single resource of the given type by ID along
+// with its current revision.
+func (s *PersistenceService) ReadWithRevision(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+) (e cce.Persistable, rev int64, err error) {
+	// Create a timeout context for a DB operation
+	ctx, cancel := context.WithTimeout(ctx, cce.MaxDBRequestTime)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(
+		ctx,
+		// gosec: Table name is not based on user input
+		fmt.Sprintf( //nolint:gosec
+			`SELECT entity, rev
+             FROM %s
+             WHERE id = ?`, zv.GetTableName()),
+		id)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "error running query")
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, 0, nil
+	}
+
+	var bytes []byte
+	if err = rows.Scan(&bytes, &rev); err != nil {
+		return nil, 0, errors.Wrap(err, "error scanning row")
+	}
+
+	if e, err = s.unmarshal(bytes, zv); err != nil {
+		return nil, 0, err
+	}
+
+	return e, rev, nil
+}
+
 // Filter retrieves a collection of resources of the given type using a set of
 // filters.
 func (s *PersistenceService) Filter(
@@ -136,6 +210,7 @@ func (s *PersistenceService) Filter(
 	if err != nil {
 		return nil, errors.Wrap(err, "error running query")
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		e, err := s.scan(rows, zv)
@@ -149,6 +224,115 @@ func (s *PersistenceService) Filter(
 	return
 }
 
+// ReadPage retrieves a page of resources of the given type using a set of
+// filters, a sort order and a cursor returned with the previous page. Paging
+// is keyset based so that concurrent inserts do not shift the pages.
+func (s *PersistenceService) ReadPage( //nolint:gocyclo
+	ctx context.Context,
+	zv cce.Filterable,
+	q cce.Query,
+) (es []cce.Persistable, nextCursor string, err error) {
+	// Create a timeout context for a DB operation
+	ctx, cancel := context.WithTimeout(ctx, cce.MaxDBRequestTime)
+	defer cancel()
+
+	// gosec: Only fields matching cce.Query.Validate are allowed to be
+	// injected into the SQL query
+	if err = q.Validate(); err != nil {
+		return nil, "", errors.Wrap(err, "invalid query")
+	}
+
+	var (
+		fields []string
+		params []interface{}
+	)
+	for _, f := range q.Filters {
+		fields = append(fields, fmt.Sprintf("%s = ?", columnExpr(zv, f.Field)))
+		params = append(params, f.Value)
+	}
+	for _, f := range q.MinFilters {
+		fields = append(fields, fmt.Sprintf("%s >= ?", columnExpr(zv, f.Field)))
+		params = append(params, f.Value)
+	}
+
+	sortExpr, order, cmp := "id", "ASC", ">"
+	if q.Sort != "" {
+		sortExpr = columnExpr(zv, strings.TrimPrefix(q.Sort, "-"))
+		if strings.HasPrefix(q.Sort, "-") {
+			order, cmp = "DESC", "<"
+		}
+	}
+
+	if q.Cursor != "" {
+		sortVal, id, err := cce.DecodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		fields = append(fields, fmt.Sprintf(
+			"(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", sortExpr, cmp))
+		params = append(params, sortVal, sortVal, id)
+	}
+
+	// gosec: Table name is not based on user input
+	query := fmt.Sprintf("SELECT entity, %s FROM %s", sortExpr, zv.GetTableName()) //nolint:gosec
+	if len(fields) > 0 {
+		query += " WHERE " + strings.Join(fields, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s", sortExpr, order)
+	if q.Limit > 0 {
+		// Fetch one extra row to find out if there is a next page
+		query += " LIMIT ?"
+		params = append(params, q.Limit+1)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error running query")
+	}
+	defer rows.Close()
+
+	var lastSortVal string
+	for rows.Next() {
+		if q.Limit > 0 && len(es) == q.Limit {
+			nextCursor = cce.EncodeCursor(lastSortVal, es[len(es)-1].GetID())
+			break
+		}
+
+		var (
+			bytes   []byte
+			sortVal sql.NullString
+		)
+		if err = rows.Scan(&bytes, &sortVal); err != nil {
+			return nil, "", errors.Wrap(err, "error scanning row")
+		}
+
+		e, err := s.unmarshal(bytes, zv)
+		if err != nil {
+			return nil, "", err
+		}
+
+		es = append(es, e)
+		lastSortVal = sortVal.String
+	}
+
+	return es, nextCursor, nil
+}
+
+// columnExpr returns the SQL expression for a field. Filter fields and the ID
+// are generated columns, anything else is extracted from the entity.
+func columnExpr(zv cce.Filterable, field string) string {
+	if field == "id" {
+		return field
+	}
+	for _, f := range zv.FilterFields() {
+		if f == field {
+			return field
+		}
+	}
+
+	return fmt.Sprintf("COALESCE(entity->>'$.%s', '')", field)
+}
+
 // ReadAll retrieves all resources of the given type.
 func (s *PersistenceService) ReadAll(
 	ctx context.Context,
@@ -166,6 +350,7 @@ func (s *PersistenceService) ReadAll(
 	if err != nil {
 		return nil, errors.Wrap(err, "error running query")
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		e, err := s.scan(rows, zv)
@@ -188,6 +373,13 @@ func (s *PersistenceService) scan(
 		return nil, errors.Wrap(err, "error scanning row")
 	}
 
+	return s.unmarshal(bytes, zv)
+}
+
+func (s *PersistenceService) unmarshal(
+	bytes []byte,
+	zv cce.Persistable,
+) (cce.Persistable, error) {
 	e := reflect.New(reflect.ValueOf(zv).Elem().Type()).Interface().(cce.Persistable)
 	if err := json.Unmarshal(bytes, e); err != nil {
 		return nil, errors.Wrap(err, "error unmarshaling")
@@ -216,18 +408,49 @@ func (s *PersistenceService) BulkUpdate(
 			// gosec: Table name is not based on user input
 			fmt.Sprintf( //nolint:gosec
 				`UPDATE %s
-                 SET entity = ?
+                 SET entity = ?, rev = rev + 1
                  WHERE id = JSON_EXTRACT(?, "$.id")`,
 				e.GetTableName()),
 			bytes, bytes)
 		if err != nil {
-			return errors.Wrap(err, "error updating record")
+			return errors.Wrap(constraintError(err), "error updating record")
 		}
 	}
 
 	return nil
 }
 
+// UpdateIfMatch updates a resource if its revision matches rev.
+func (s *PersistenceService) UpdateIfMatch(
+	ctx context.Context,
+	e cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	// Create a timeout context for a DB operation
+	ctx, cancel := context.WithTimeout(ctx, cce.MaxDBRequestTime)
+	defer cancel()
+
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return false, errors.Wrap(err, "error marshaling")
+	}
+
+	result, err := s.DB.ExecContext(
+		ctx,
+		// gosec: Table name is not based on user input
+		fmt.Sprintf( //nolint:gosec
+			`UPDATE %s
+             SET entity = ?, rev = rev + 1
+             WHERE id = ? AND rev = ?`,
+			e.GetTableName()),
+		bytes, e.GetID(), rev)
+	if err != nil {
+		return false, errors.Wrap(constraintError(err), "error updating record")
+	}
+
+	return oneRowAffected(result)
+}
+
 // Delete deletes a resource of the given type.
 func (s *PersistenceService) Delete(
 	ctx context.Context,
@@ -247,9 +470,40 @@ func (s *PersistenceService) Delete(
              WHERE id = ?`, zv.GetTableName()),
 		id)
 	if err != nil {
-		return false, errors.Wrap(err, "error deleting record")
+		return false, errors.Wrap(constraintError(err), "error deleting record")
 	}
 
+	return oneRowAffected(result)
+}
+
+// DeleteIfMatch deletes a resource of the given type if its revision matches
+// rev.
+func (s *PersistenceService) DeleteIfMatch(
+	ctx context.Context,
+	id string,
+	zv cce.Persistable,
+	rev int64,
+) (ok bool, err error) {
+	// Create a timeout context for a DB operation
+	ctx, cancel := context.WithTimeout(ctx, cce.MaxDBRequestTime)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(
+		ctx,
+		// gosec: Table name is not based on user input
+		fmt.Sprintf( //nolint:gosec
+			`DELETE
+             FROM %s
+             WHERE id = ? AND rev = ?`, zv.GetTableName()),
+		id, rev)
+	if err != nil {
+		return false, errors.Wrap(constraintError(err), "error deleting record")
+	}
+
+	return oneRowAffected(result)
+}
+
+func oneRowAffected(result sql.Result) (bool, error) {
 	rows, err := result.RowsAffected()
 	if err != nil {
 		return false, errors.Wrap(err, "error getting rows affected")
@@ -261,3 +515,39 @@ func (s *PersistenceService) Delete(
 
 	return true, nil
 }
+
+// WithTx runs fn in a transaction. If DB cannot begin transactions, e.g. because it is already a transaction, fn is
+// run with s.
+func (s *PersistenceService) WithTx(
+	ctx context.Context,
+	fn func(tx cce.PersistenceService) error,
+) (err error) {
+	db, ok := s.DB.(txBeginner)
+	if !ok {
+		return fn(s)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "error beginning transaction")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(&PersistenceService{DB: txDB{tx}}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Wrapf(err, "error rolling back transaction (%v)", rbErr)
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing transaction")
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// NodeSerial returns the serial identifying a node by its public key, which
+// is the base64 URL-encoding (without padding) of the SHA-256 hash of the
+// DER-encoded SubjectPublicKeyInfo.
+func NodeSerial(publicKeyDER []byte) string {
+	hash := sha256.Sum256(publicKeyDER)
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// LegacyNodeSerial returns the serial nodes were identified by before
+// NodeSerial, which used the MD5 hash of the public key. It is only used to
+// find and migrate the nodes that were approved by their legacy serial.
+func LegacyNodeSerial(publicKeyDER []byte) string {
+	// gosec: not used for new identities, only to match existing ones
+	hash := md5.Sum(publicKeyDER) //nolint:gosec
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// FindNodeBySerial returns the node approved by the serial of a public key.
+// If acceptLegacy is set, a node approved by the legacy serial of the key is
+// returned as well, after its serial is migrated to the serial of the key.
+// It returns nil if no node is approved.
+func FindNodeBySerial(
+	ctx context.Context,
+	ps PersistenceService,
+	publicKeyDER []byte,
+	acceptLegacy bool,
+) (*Node, error) {
+	nodes, err := ps.Filter(ctx, &Node{}, []Filter{{Field: "serial", Value: NodeSerial(publicKeyDER)}})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) > 0 {
+		return nodes[0].(*Node), nil
+	}
+	if !acceptLegacy {
+		return nil, nil
+	}
+
+	nodes, err = ps.Filter(ctx, &Node{}, []Filter{{Field: "serial", Value: LegacyNodeSerial(publicKeyDER)}})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	node := nodes[0].(*Node)
+	if err = MigrateNodeSerial(ctx, ps, node, publicKeyDER); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// MigrateNodeSerial replaces the legacy serial of a node with the serial of
+// its public key.
+func MigrateNodeSerial(ctx context.Context, ps PersistenceService, node *Node, publicKeyDER []byte) error {
+	node.Serial = NodeSerial(publicKeyDER)
+	return ps.BulkUpdate(ctx, []Persistable{node})
+}
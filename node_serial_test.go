@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/memory"
+)
+
+var _ = Describe("Node Serials", func() {
+	var (
+		ctx = context.Background()
+		ps  cce.PersistenceService
+		key = []byte("public key")
+	)
+
+	BeforeEach(func() {
+		var err error
+		ps, err = memory.NewPersistenceService("")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	createNode := func(serial string) *cce.Node {
+		node := &cce.Node{
+			ID:       "9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f",
+			Name:     "node123",
+			Location: "Localhost port 42101",
+			Serial:   serial,
+		}
+		Expect(ps.Create(ctx, node)).To(Succeed())
+		return node
+	}
+
+	Describe("NodeSerial", func() {
+		It("Should return the base64 URL-encoded SHA-256 hash of the key", func() {
+			Expect(cce.NodeSerial(key)).To(Equal("9WmobTwsjX3aJrXb6iC9XBnus138Y_23JLrE8hwieFA"))
+		})
+	})
+
+	Describe("LegacyNodeSerial", func() {
+		It("Should return the base64 URL-encoded MD5 hash of the key", func() {
+			Expect(cce.LegacyNodeSerial(key)).To(Equal("0dj2ay6LLwv9w3m2iTEbeA"))
+		})
+	})
+
+	Describe("FindNodeBySerial", func() {
+		It("Should return the node of the serial of the key", func() {
+			node := createNode(cce.NodeSerial(key))
+			Expect(cce.FindNodeBySerial(ctx, ps, key, false)).To(Equal(node))
+		})
+
+		It("Should return nil if no node is approved", func() {
+			createNode("ABCD")
+			Expect(cce.FindNodeBySerial(ctx, ps, key, true)).To(BeNil())
+		})
+
+		It("Should not return the node of the legacy serial of the key", func() {
+			createNode(cce.LegacyNodeSerial(key))
+			Expect(cce.FindNodeBySerial(ctx, ps, key, false)).To(BeNil())
+		})
+
+		It("Should migrate the node of the legacy serial of the key if accepted", func() {
+			node := createNode(cce.LegacyNodeSerial(key))
+
+			migrated, err := cce.FindNodeBySerial(ctx, ps, key, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(migrated.ID).To(Equal(node.ID))
+			Expect(migrated.Serial).To(Equal(cce.NodeSerial(key)))
+			Expect(ps.Read(ctx, node.ID, &cce.Node{})).To(Equal(migrated))
+		})
+	})
+})
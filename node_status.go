@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Node statuses
+const (
+	// NodeStatusOnline is the status of a node sending heartbeats in time.
+	NodeStatusOnline = "online"
+	// NodeStatusDegraded is the status of a node that missed a heartbeat or
+	// reported a high resource usage.
+	NodeStatusDegraded = "degraded"
+	// NodeStatusOffline is the status of a node that has not sent a heartbeat
+	// for too long or never sent one.
+	NodeStatusOffline = "offline"
+)
+
+// HeartbeatService records the heartbeats of nodes and returns the interval
+// in which the node should send its next heartbeat.
+type HeartbeatService interface {
+	Heartbeat(ctx context.Context, nodeID string, hb *NodeHeartbeat) (interval time.Duration, err error)
+}
+
+// NodeHeartbeat is the state of a node reported by a heartbeat.
+type NodeHeartbeat struct {
+	AgentVersion  string            `json:"agent_version"`
+	UptimeSeconds uint64            `json:"uptime_seconds"`
+	Resources     NodeResourceUsage `json:"resources"`
+}
+
+// NodeResourceUsage is the resource usage of a node. CPUPercent is from 0 to
+// 100, the others are in bytes.
+type NodeResourceUsage struct {
+	CPUPercent       float64 `json:"cpu_percent"`
+	MemoryUsedBytes  uint64  `json:"memory_used_bytes"`
+	MemoryTotalBytes uint64  `json:"memory_total_bytes"`
+	DiskUsedBytes    uint64  `json:"disk_used_bytes"`
+	DiskTotalBytes   uint64  `json:"disk_total_bytes"`
+}
+
+// NodeStatus is the liveness of a node along with the state reported by its
+// last heartbeat.
+type NodeStatus struct {
+	ID       string `json:"id"`
+	NodeID   string `json:"node_id"`
+	Status   string `json:"status"`
+	LastSeen string `json:"last_seen"`
+	NodeHeartbeat
+}
+
+// GetTableName returns the name of the persistence table.
+func (*NodeStatus) GetTableName() string {
+	return "node_statuses"
+}
+
+// GetID gets the ID.
+func (s *NodeStatus) GetID() string {
+	return s.ID
+}
+
+// SetID sets the ID.
+func (s *NodeStatus) SetID(id string) {
+	s.ID = id
+}
+
+// GetNodeID gets the node ID.
+func (s *NodeStatus) GetNodeID() string {
+	return s.NodeID
+}
+
+// FilterFields returns the filterable fields for this model.
+func (*NodeStatus) FilterFields() []string {
+	return []string{
+		"node_id",
+		"status",
+	}
+}
+
+func (s *NodeStatus) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+NodeStatus[
+    ID: %s
+    NodeID: %s
+    Status: %s
+    LastSeen: %s
+    AgentVersion: %s
+    UptimeSeconds: %d
+    Resources: %+v
+]`),
+		s.ID,
+		s.NodeID,
+		s.Status,
+		s.LastSeen,
+		s.AgentVersion,
+		s.UptimeSeconds,
+		s.Resources)
+}
+
+// ReadNodeStatus returns the status of a node, or an offline status without
+// an ID if it never sent a heartbeat.
+func ReadNodeStatus(ctx context.Context, ps PersistenceService, nodeID string) (*NodeStatus, error) {
+	statuses, err := ps.Filter(ctx, &NodeStatus{}, []Filter{{Field: "node_id", Value: nodeID}})
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses) == 0 {
+		return &NodeStatus{NodeID: nodeID, Status: NodeStatusOffline}, nil
+	}
+
+	return statuses[0].(*NodeStatus), nil
+}
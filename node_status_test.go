@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/memory"
+)
+
+var _ = Describe("Node Statuses", func() {
+	const nodeID = "9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f"
+
+	var (
+		ctx = context.Background()
+		ps  cce.PersistenceService
+	)
+
+	BeforeEach(func() {
+		var err error
+		ps, err = memory.NewPersistenceService("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ps.Create(ctx, &cce.Node{
+			ID:       nodeID,
+			Name:     "node123",
+			Location: "Localhost port 42101",
+			Serial:   "ABCD",
+		})).To(Succeed())
+	})
+
+	Describe("ReadNodeStatus", func() {
+		It("Should return the status of the node", func() {
+			status := &cce.NodeStatus{
+				ID:       "5a4b3c2d-1e0f-4a9b-8c7d-6e5f4a3b2c1d",
+				NodeID:   nodeID,
+				Status:   cce.NodeStatusOnline,
+				LastSeen: "2020-03-01T12:00:00.000000000Z",
+				NodeHeartbeat: cce.NodeHeartbeat{
+					AgentVersion:  "1.2.3",
+					UptimeSeconds: 3600,
+				},
+			}
+			Expect(ps.Create(ctx, status)).To(Succeed())
+
+			Expect(cce.ReadNodeStatus(ctx, ps, nodeID)).To(Equal(status))
+		})
+
+		It("Should return offline for a node that never sent a heartbeat", func() {
+			Expect(cce.ReadNodeStatus(ctx, ps, nodeID)).To(Equal(&cce.NodeStatus{
+				NodeID: nodeID,
+				Status: cce.NodeStatusOffline,
+			}))
+		})
+	})
+})
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node sync statuses
+const (
+	// NodeSyncPending is the status of a node that was not reconciled yet.
+	NodeSyncPending = "pending"
+	// NodeSyncInSync is the status of a node whose actual state matched its
+	// desired state.
+	NodeSyncInSync = "in_sync"
+	// NodeSyncConverged is the status of a node whose drift was repaired.
+	NodeSyncConverged = "converged"
+	// NodeSyncDrifted is the status of a node with drift that could not be
+	// repaired.
+	NodeSyncDrifted = "drifted"
+	// NodeSyncUnreachable is the status of a node that could not be
+	// connected to.
+	NodeSyncUnreachable = "unreachable"
+)
+
+// Resources of a NodeDrift
+const (
+	DriftResourceApp             = "app"
+	DriftResourceInterface       = "interface"
+	DriftResourceInterfacePolicy = "interface_policy"
+	DriftResourceAppPolicy       = "app_policy"
+	DriftResourceDNS             = "dns"
+)
+
+// NodeSync is the outcome of the last reconciliation of the desired state of
+// a node in the DB with its actual state.
+type NodeSync struct {
+	ID        string      `json:"id"`
+	NodeID    string      `json:"node_id"`
+	Status    string      `json:"status"`
+	CheckedAt string      `json:"checked_at"`
+	SyncedAt  string      `json:"synced_at"`
+	Error     string      `json:"error"`
+	Drift     []NodeDrift `json:"drift"`
+}
+
+// NodeDrift is a difference between the desired and actual state of a node.
+// Repaired is set if the reconciler converged it, otherwise Error is the
+// reason it could not be.
+type NodeDrift struct {
+	Resource string `json:"resource"`
+	ID       string `json:"id"`
+	Reason   string `json:"reason"`
+	Repaired bool   `json:"repaired"`
+	Error    string `json:"error,omitempty"`
+}
+
+// GetTableName returns the name of the persistence table.
+func (*NodeSync) GetTableName() string {
+	return "node_syncs"
+}
+
+// GetID gets the ID.
+func (s *NodeSync) GetID() string {
+	return s.ID
+}
+
+// SetID sets the ID.
+func (s *NodeSync) SetID(id string) {
+	s.ID = id
+}
+
+// GetNodeID gets the node ID.
+func (s *NodeSync) GetNodeID() string {
+	return s.NodeID
+}
+
+// FilterFields returns the filterable fields for this model.
+func (*NodeSync) FilterFields() []string {
+	return []string{
+		"node_id",
+		"status",
+	}
+}
+
+func (s *NodeSync) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+NodeSync[
+    ID: %s
+    NodeID: %s
+    Status: %s
+    CheckedAt: %s
+    SyncedAt: %s
+    Error: %s
+    Drift: %v
+]`),
+		s.ID,
+		s.NodeID,
+		s.Status,
+		s.CheckedAt,
+		s.SyncedAt,
+		s.Error,
+		s.Drift)
+}
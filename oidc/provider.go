@@ -0,0 +1,353 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+// Package oidc verifies bearer tokens issued by an external OpenID Connect
+// provider.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/open-ness/common/log"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+var log = logger.DefaultLogger.WithField("pkg", "oidc")
+
+// Defaults of a Provider created by NewProvider
+const (
+	DefaultUsernameClaim      = "sub"
+	DefaultGroupsClaim        = "groups"
+	DefaultCacheTTL           = time.Hour
+	DefaultMinRefreshInterval = 10 * time.Second
+	DefaultTimeout            = 10 * time.Second
+)
+
+// DiscoveryPath is the path of the OpenID Provider configuration relative
+// to the issuer URL.
+const DiscoveryPath = "/.well-known/openid-configuration"
+
+// signatureAlgorithms are the accepted token signature algorithms. Symmetric
+// algorithms are not accepted as the keys are public.
+var signatureAlgorithms = map[string]bool{
+	string(jose.RS256): true,
+	string(jose.RS384): true,
+	string(jose.RS512): true,
+	string(jose.PS256): true,
+	string(jose.PS384): true,
+	string(jose.PS512): true,
+	string(jose.ES256): true,
+	string(jose.ES384): true,
+	string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// Provider verifies the ID and access tokens of an OpenID Connect provider.
+// The provider's JSON Web Key Set is discovered from its configuration and
+// cached for CacheTTL. It is fetched again earlier if a token is signed with
+// an unknown key, but at most once every MinRefreshInterval.
+//
+// A token must be issued by Issuer for Audience. The issuer is compared
+// verbatim, including a trailing slash. Its subject is the value of
+// the UsernameClaim and its role the highest role that RoleMapping maps any
+// of the groups in the GroupsClaim to. Tokens without a mapped group are
+// rejected.
+type Provider struct {
+	Issuer        string
+	Audience      string
+	UsernameClaim string
+	GroupsClaim   string
+	RoleMapping   map[string]string
+
+	Client             *http.Client
+	CacheTTL           time.Duration
+	MinRefreshInterval time.Duration
+
+	mu          sync.Mutex
+	keys        *jose.JSONWebKeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	// fetching is closed when the fetch in flight, if any, is done
+	fetching chan struct{}
+	fetchErr error
+}
+
+// NewProvider creates a Provider with the default settings.
+func NewProvider(issuer, audience string, roleMapping map[string]string) *Provider {
+	return &Provider{
+		Issuer:             issuer,
+		Audience:           audience,
+		UsernameClaim:      DefaultUsernameClaim,
+		GroupsClaim:        DefaultGroupsClaim,
+		RoleMapping:        roleMapping,
+		Client:             &http.Client{Timeout: DefaultTimeout},
+		CacheTTL:           DefaultCacheTTL,
+		MinRefreshInterval: DefaultMinRefreshInterval,
+	}
+}
+
+// ParseRoleMapping parses a comma-separated list of group=role pairs.
+func ParseRoleMapping(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("%q is not a group=role pair", pair)
+		}
+		group, role := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if !cce.RoleIncludes(role, cce.RoleReadOnly) {
+			return nil, fmt.Errorf("role %q of group %q must be one of %s",
+				role, group, strings.Join(cce.Roles, ", "))
+		}
+		mapping[group] = role
+	}
+	if len(mapping) == 0 {
+		return nil, errors.New("no groups are mapped to roles")
+	}
+
+	return mapping, nil
+}
+
+// Verify verifies the signature and claims of a token in the RFC 7519
+// compact serialization format and returns its subject and role.
+func (p *Provider) Verify(ctx context.Context, token string) (subject, role string, err error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return "", "", errors.Wrap(err, "unable to parse token")
+	}
+	if len(tok.Headers) != 1 {
+		return "", "", errors.New("token must have a single signature")
+	}
+	header := tok.Headers[0]
+	if !signatureAlgorithms[header.Algorithm] {
+		return "", "", errors.Errorf("signature algorithm %q is not supported", header.Algorithm)
+	}
+
+	key, err := p.key(ctx, header.KeyID)
+	if err != nil {
+		return "", "", err
+	}
+
+	var (
+		claims jwt.Claims
+		custom map[string]interface{}
+	)
+	if err = tok.Claims(key.Key, &claims, &custom); err != nil {
+		return "", "", errors.Wrap(err, "unable to verify token")
+	}
+	if claims.Expiry == nil {
+		return "", "", errors.New("token has no expiry")
+	}
+	err = claims.Validate(jwt.Expected{
+		Issuer:   p.Issuer,
+		Audience: jwt.Audience{p.Audience},
+		Time:     time.Now(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	subject, ok := custom[p.UsernameClaim].(string)
+	if !ok || subject == "" {
+		return "", "", errors.Errorf("token has no %q claim", p.UsernameClaim)
+	}
+
+	role = p.role(custom[p.GroupsClaim])
+	if role == "" {
+		return "", "", errors.Errorf("no group of %q is mapped to a role", subject)
+	}
+
+	return subject, role, nil
+}
+
+// role returns the highest role the groups are mapped to. The groups claim
+// is either a list of groups or a single group.
+func (p *Provider) role(groups interface{}) string {
+	var names []string
+	switch g := groups.(type) {
+	case string:
+		names = []string{g}
+	case []interface{}:
+		for _, name := range g {
+			if s, ok := name.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+
+	var role string
+	for _, name := range names {
+		r, ok := p.RoleMapping[name]
+		if ok && (role == "" || !cce.RoleIncludes(role, r)) {
+			role = r
+		}
+	}
+
+	return role
+}
+
+// key returns the verification key with the given ID. If the ID is empty the
+// key set must contain a single key. If the key set cannot be fetched again
+// the cached one is used.
+func (p *Provider) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	p.mu.Lock()
+	keys := p.keys
+	stale := keys == nil || time.Since(p.fetchedAt) > p.CacheTTL
+	p.mu.Unlock()
+
+	refreshed := false
+	if stale {
+		// Without cached keys wait for a fetch in flight
+		fetched, ok, err := p.refresh(ctx, keys == nil)
+		switch {
+		case !ok:
+		case err != nil && keys == nil:
+			return nil, err
+		case err != nil:
+			log.Warningf("Using cached keys of provider %s: %v", p.Issuer, err)
+		default:
+			keys = fetched
+		}
+		refreshed = ok
+	}
+	if keys == nil {
+		return nil, errors.New("provider keys are not available")
+	}
+
+	key, found := findKey(keys, kid)
+	if !found && !refreshed {
+		// The provider may have rotated its keys
+		fetched, ok, err := p.refresh(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			key, found = findKey(fetched, kid)
+		}
+	}
+	if !found {
+		return nil, errors.Errorf("verification key %q not found", kid)
+	}
+
+	return key, nil
+}
+
+func findKey(keys *jose.JSONWebKeySet, kid string) (*jose.JSONWebKey, bool) {
+	if kid == "" {
+		if len(keys.Keys) == 1 {
+			return &keys.Keys[0], true
+		}
+		return nil, false
+	}
+
+	for i := range keys.Keys {
+		if keys.Keys[i].KeyID == kid && keys.Keys[i].Use != "enc" {
+			return &keys.Keys[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// refresh fetches the key set again, unless the last attempt was within
+// MinRefreshInterval, and returns it. If another call is fetching it, it
+// waits for that fetch if wait is set and otherwise returns. ok is false if
+// no key set was fetched. The key set is fetched without holding mu, so that
+// tokens are verified with the cached keys meanwhile.
+func (p *Provider) refresh(ctx context.Context, wait bool) (keys *jose.JSONWebKeySet, ok bool, err error) {
+	p.mu.Lock()
+	if fetching := p.fetching; fetching != nil {
+		p.mu.Unlock()
+		if !wait {
+			return nil, false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-fetching:
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.keys, true, p.fetchErr
+	}
+
+	now := time.Now()
+	if now.Sub(p.attemptedAt) <= p.MinRefreshInterval {
+		p.mu.Unlock()
+		return nil, false, nil
+	}
+	p.attemptedAt = now
+	fetching := make(chan struct{})
+	p.fetching = fetching
+	p.mu.Unlock()
+
+	keys, err = p.fetch(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		p.keys = keys
+		p.fetchedAt = now
+	}
+	p.fetchErr = err
+	p.fetching = nil
+	close(fetching)
+
+	return keys, true, err
+}
+
+// fetch discovers and fetches the key set.
+func (p *Provider) fetch(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	var config struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+DiscoveryPath, &config); err != nil {
+		return nil, errors.Wrap(err, "unable to discover provider configuration")
+	}
+	if config.Issuer != p.Issuer {
+		return nil, errors.Errorf("provider configuration issuer %q does not match %q", config.Issuer, p.Issuer)
+	}
+	if config.JWKSURI == "" {
+		return nil, errors.New("provider configuration has no jwks_uri")
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := p.getJSON(ctx, config.JWKSURI, &keys); err != nil {
+		return nil, errors.Wrap(err, "unable to fetch provider keys")
+	}
+	log.Debugf("Fetched %d keys of provider %s", len(keys.Keys), p.Issuer)
+
+	return &keys, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("GET %s responded with %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,302 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/oidc"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// idp is a stand-in OpenID Connect provider serving its configuration and
+// key set and signing tokens with its current key.
+type idp struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	key          *rsa.PrivateKey
+	kid          string
+	jwksRequests int
+	// issuer is the issuer of the configuration, the server URL if empty
+	issuer string
+	// release blocks key set requests until it is closed, if set
+	release chan struct{}
+}
+
+func newIDP() *idp {
+	p := &idp{}
+	p.rotate("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(oidc.DiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		issuer := p.issuer
+		p.mu.Unlock()
+		if issuer == "" {
+			issuer = p.URL
+		}
+		Expect(json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   issuer,
+			"jwks_uri": p.URL + "/keys",
+		})).To(Succeed())
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.jwksRequests++
+		release := p.release
+		p.mu.Unlock()
+		if release != nil {
+			<-release
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		Expect(json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: p.key.Public(), KeyID: p.kid, Algorithm: string(jose.RS256), Use: "sig"},
+		}})).To(Succeed())
+	})
+	p.Server = httptest.NewServer(mux)
+
+	return p
+}
+
+func (p *idp) rotate(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.key, p.kid = key, kid
+}
+
+func (p *idp) requests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.jwksRequests
+}
+
+func (p *idp) sign(alg jose.SignatureAlgorithm, key interface{}, claims ...interface{}) string {
+	p.mu.Lock()
+	kid := p.kid
+	if key == nil {
+		key = p.key
+	}
+	p.mu.Unlock()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: alg, Key: key},
+		new(jose.SignerOptions).WithType("JWT").WithHeader("kid", kid))
+	Expect(err).ToNot(HaveOccurred())
+
+	builder := jwt.Signed(signer)
+	for _, c := range claims {
+		builder = builder.Claims(c)
+	}
+	token, err := builder.CompactSerialize()
+	Expect(err).ToNot(HaveOccurred())
+	return token
+}
+
+var _ = Describe("Provider", func() {
+	var (
+		ctx      = context.Background()
+		server   *idp
+		provider *oidc.Provider
+	)
+
+	BeforeEach(func() {
+		server = newIDP()
+		provider = oidc.NewProvider(server.URL, "controller", map[string]string{
+			"viewers":   cce.RoleReadOnly,
+			"operators": cce.RoleOperator,
+			"admins":    cce.RoleAdmin,
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	claims := func() jwt.Claims {
+		return jwt.Claims{
+			Issuer:   server.URL,
+			Subject:  "jdoe",
+			Audience: jwt.Audience{"controller"},
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+			Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+	}
+
+	groups := func(gs ...string) map[string]interface{} {
+		return map[string]interface{}{"groups": gs}
+	}
+
+	Describe("Verify", func() {
+		It("Should return the subject and highest role of a valid token", func() {
+			token := server.sign(jose.RS256, nil, claims(), groups("viewers", "operators", "unmapped"))
+
+			subject, role, err := provider.Verify(ctx, token)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subject).To(Equal("jdoe"))
+			Expect(role).To(Equal(cce.RoleOperator))
+		})
+
+		It("Should use the configured claims", func() {
+			provider.UsernameClaim = "email"
+			provider.GroupsClaim = "roles"
+			token := server.sign(jose.RS256, nil, claims(),
+				map[string]interface{}{"email": "jdoe@example.com", "roles": "admins"})
+
+			subject, role, err := provider.Verify(ctx, token)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subject).To(Equal("jdoe@example.com"))
+			Expect(role).To(Equal(cce.RoleAdmin))
+		})
+
+		It("Should cache the key set", func() {
+			for i := 0; i < 3; i++ {
+				_, _, err := provider.Verify(ctx, server.sign(jose.RS256, nil, claims(), groups("viewers")))
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(server.requests()).To(Equal(1))
+		})
+
+		It("Should fetch the key set again when the provider rotates its key", func() {
+			provider.MinRefreshInterval = 0
+			_, _, err := provider.Verify(ctx, server.sign(jose.RS256, nil, claims(), groups("viewers")))
+			Expect(err).ToNot(HaveOccurred())
+
+			server.rotate("key-2")
+			_, _, err = provider.Verify(ctx, server.sign(jose.RS256, nil, claims(), groups("viewers")))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server.requests()).To(Equal(2))
+		})
+
+		It("Should not fetch the key set again within the minimum refresh interval", func() {
+			_, _, err := provider.Verify(ctx, server.sign(jose.RS256, nil, claims(), groups("viewers")))
+			Expect(err).ToNot(HaveOccurred())
+
+			server.rotate("key-2")
+			_, _, err = provider.Verify(ctx, server.sign(jose.RS256, nil, claims(), groups("viewers")))
+			Expect(err).To(MatchError(`verification key "key-2" not found`))
+			Expect(server.requests()).To(Equal(1))
+		})
+
+		It("Should verify the tokens of an issuer with a trailing slash", func() {
+			server.issuer = server.URL + "/"
+			provider = oidc.NewProvider(server.URL+"/", "controller", provider.RoleMapping)
+			c := claims()
+			c.Issuer = server.URL + "/"
+
+			subject, _, err := provider.Verify(ctx, server.sign(jose.RS256, nil, c, groups("viewers")))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subject).To(Equal("jdoe"))
+		})
+
+		It("Should verify tokens with the cached keys while fetching the key set", func() {
+			_, _, err := provider.Verify(ctx, server.sign(jose.RS256, nil, claims(), groups("viewers")))
+			Expect(err).ToNot(HaveOccurred())
+
+			release := make(chan struct{})
+			server.mu.Lock()
+			server.release = release
+			server.mu.Unlock()
+			provider.CacheTTL = 0
+			provider.MinRefreshInterval = 0
+
+			refreshed := make(chan error, 1)
+			go func() {
+				_, _, err := provider.Verify(ctx, server.sign(jose.RS256, nil, claims(), groups("viewers")))
+				refreshed <- err
+			}()
+			Eventually(server.requests).Should(Equal(2))
+
+			_, _, err = provider.Verify(ctx, server.sign(jose.RS256, nil, claims(), groups("viewers")))
+			Expect(err).ToNot(HaveOccurred())
+
+			close(release)
+			Eventually(refreshed).Should(Receive(BeNil()))
+			Expect(server.requests()).To(Equal(2))
+		})
+
+		It("Should return an error if the discovered issuer does not match", func() {
+			provider = oidc.NewProvider(server.URL+"/other", "controller", provider.RoleMapping)
+
+			_, _, err := provider.Verify(ctx, server.sign(jose.RS256, nil, claims(), groups("viewers")))
+			Expect(err).To(HaveOccurred())
+		})
+
+		DescribeTable("Invalid tokens",
+			func(modify func(c *jwt.Claims), gs []string, expectedErr interface{}) {
+				c := claims()
+				modify(&c)
+				_, _, err := provider.Verify(ctx, server.sign(jose.RS256, nil, c, groups(gs...)))
+				Expect(err).To(MatchError(expectedErr))
+			},
+			Entry("Wrong issuer",
+				func(c *jwt.Claims) { c.Issuer = "https://example.com" }, []string{"admins"},
+				jwt.ErrInvalidIssuer),
+			Entry("Wrong audience",
+				func(c *jwt.Claims) { c.Audience = jwt.Audience{"other"} }, []string{"admins"},
+				jwt.ErrInvalidAudience),
+			Entry("Expired",
+				func(c *jwt.Claims) { c.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour)) }, []string{"admins"},
+				jwt.ErrExpired),
+			Entry("No expiry",
+				func(c *jwt.Claims) { c.Expiry = nil }, []string{"admins"},
+				"token has no expiry"),
+			Entry("No subject",
+				func(c *jwt.Claims) { c.Subject = "" }, []string{"admins"},
+				`token has no "sub" claim`),
+			Entry("No mapped group",
+				func(c *jwt.Claims) {}, []string{"guests"},
+				`no group of "jdoe" is mapped to a role`),
+		)
+
+		It("Should return an error for a token signed with another key", func() {
+			other, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = provider.Verify(ctx, server.sign(jose.RS256, other, claims(), groups("admins")))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should return an error for a token signed with a symmetric algorithm", func() {
+			_, _, err := provider.Verify(ctx, server.sign(jose.HS256, []byte("secret"), claims(), groups("admins")))
+			Expect(err).To(MatchError(`signature algorithm "HS256" is not supported`))
+		})
+	})
+
+	Describe("ParseRoleMapping", func() {
+		It("Should parse group=role pairs", func() {
+			Expect(oidc.ParseRoleMapping("ops=operator, sre=admin,")).To(Equal(map[string]string{
+				"ops": cce.RoleOperator,
+				"sre": cce.RoleAdmin,
+			}))
+		})
+
+		DescribeTable("Invalid mappings",
+			func(mapping, expectedErr string) {
+				_, err := oidc.ParseRoleMapping(mapping)
+				Expect(err).To(MatchError(expectedErr))
+			},
+			Entry("Empty", "", "no groups are mapped to roles"),
+			Entry("No role", "ops", `"ops" is not a group=role pair`),
+			Entry("Unknown role", "ops=root", `role "root" of group "ops" must be one of read-only, operator, admin`),
+		)
+	})
+})
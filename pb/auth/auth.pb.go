@@ -34,7 +34,9 @@ const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 // see the RFC here: https://tools.ietf.org/html/rfc7468
 type Identity struct {
 	// A PEM-encoded certificate signing request (CSR)
-	Csr                  string   `protobuf:"bytes,1,opt,name=csr,proto3" json:"csr,omitempty"`
+	Csr string `protobuf:"bytes,1,opt,name=csr,proto3" json:"csr,omitempty"`
+	// A one-time token enrolling a node that was not pre-approved by serial
+	EnrollmentToken      string   `protobuf:"bytes,2,opt,name=enrollment_token,json=enrollmentToken,proto3" json:"enrollment_token,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -72,6 +74,13 @@ func (m *Identity) GetCsr() string {
 	return ""
 }
 
+func (m *Identity) GetEnrollmentToken() string {
+	if m != nil {
+		return m.EnrollmentToken
+	}
+	return ""
+}
+
 // Credentials defines a response for a request to obtain authentication
 // credentials. These credentials may be used to further communicate with
 // endpoint(s) that are protected by a form of authentication.
@@ -156,39 +165,43 @@ func init() {
 func init() { proto.RegisterFile("auth.proto", fileDescriptor_8bbd6f3875b0e874) }
 
 var fileDescriptor_8bbd6f3875b0e874 = []byte{
-	// 497 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x92, 0x41, 0x6f, 0xd3, 0x30,
-	0x14, 0xc7, 0x95, 0x96, 0x6d, 0x9d, 0x0b, 0xd3, 0x64, 0x09, 0x56, 0xa2, 0x1e, 0xac, 0xc0, 0x61,
-	0x2a, 0x34, 0x6e, 0xcb, 0x4e, 0xe5, 0x42, 0x56, 0xf5, 0x50, 0x34, 0xa1, 0xaa, 0x15, 0x17, 0x2e,
-	0x93, 0xeb, 0xbc, 0x25, 0x86, 0xd4, 0x36, 0xb6, 0xc3, 0x04, 0x07, 0x0e, 0x7c, 0x83, 0x8d, 0x0f,
-	0xc1, 0x07, 0xe2, 0xcc, 0x8d, 0x03, 0x17, 0xbe, 0x03, 0x72, 0x08, 0xa2, 0x30, 0x6d, 0xa7, 0x38,
-	0xef, 0xf7, 0xcb, 0x7b, 0x2f, 0x7f, 0x19, 0x21, 0x56, 0xba, 0x3c, 0xd6, 0x46, 0x39, 0x85, 0xef,
-	0x28, 0x0d, 0x52, 0x82, 0xb5, 0xb1, 0x2f, 0x86, 0xdd, 0x4c, 0xa9, 0xac, 0x00, 0xca, 0xb4, 0xa0,
-	0x4c, 0x4a, 0xe5, 0x98, 0x13, 0x4a, 0xda, 0xdf, 0x72, 0xf8, 0xb8, 0x7a, 0xf0, 0x7e, 0x06, 0xb2,
-	0x6f, 0xcf, 0x59, 0x96, 0x81, 0xa1, 0x4a, 0x57, 0xc6, 0x55, 0x3b, 0xea, 0xa2, 0xd6, 0x2c, 0x05,
-	0xe9, 0x84, 0x7b, 0x8f, 0xf7, 0x51, 0x93, 0x5b, 0xd3, 0x09, 0x48, 0x70, 0xb8, 0xbb, 0xf0, 0xc7,
-	0xc8, 0xa2, 0xf6, 0xc4, 0x40, 0xc5, 0x59, 0x61, 0xf1, 0x1e, 0x6a, 0x88, 0xb4, 0xe6, 0x0d, 0x91,
-	0x62, 0x82, 0xda, 0x1c, 0x8c, 0x13, 0x67, 0x82, 0x33, 0x07, 0x9d, 0x46, 0x05, 0x36, 0x4b, 0xf8,
-	0x3e, 0x6a, 0x71, 0x76, 0xca, 0x73, 0x26, 0x64, 0xa7, 0x49, 0x9a, 0x87, 0xbb, 0x8b, 0x1d, 0xce,
-	0x26, 0xfe, 0x15, 0x1f, 0xa0, 0x1d, 0xce, 0x4e, 0xb5, 0x52, 0x45, 0xe7, 0x56, 0x45, 0xb6, 0x39,
-	0x9b, 0x2b, 0x55, 0x8c, 0x7e, 0x06, 0xa8, 0x9d, 0x94, 0x2e, 0x5f, 0x82, 0x79, 0x27, 0x38, 0xe0,
-	0x6f, 0x01, 0xc2, 0x0b, 0x78, 0x5b, 0x82, 0x75, 0x9b, 0xcb, 0x1c, 0xc4, 0xff, 0xa4, 0x12, 0xff,
-	0xf9, 0x8d, 0x30, 0xfc, 0x0f, 0x6c, 0x7c, 0x14, 0x5d, 0x04, 0x97, 0xc9, 0xc7, 0x30, 0xaa, 0xdb,
-	0x11, 0xcf, 0x3d, 0xe2, 0x55, 0x26, 0x84, 0xff, 0x35, 0x9f, 0x3f, 0x42, 0xcd, 0xd1, 0x60, 0x88,
-	0x1f, 0xa2, 0x28, 0xb9, 0x56, 0xf2, 0x67, 0xe6, 0x20, 0xf5, 0xf2, 0xd1, 0xe0, 0xc8, 0xcb, 0x75,
-	0x67, 0x48, 0x89, 0xa8, 0xf7, 0x21, 0x52, 0x39, 0xf2, 0x46, 0xaa, 0x73, 0x49, 0xcf, 0x54, 0x29,
-	0xd3, 0x4f, 0x5f, 0xbf, 0x7f, 0x6e, 0xa0, 0x68, 0x8b, 0xfa, 0xe1, 0xe3, 0xa0, 0x77, 0x7c, 0xd1,
-	0xb8, 0x4c, 0x7e, 0x04, 0xf8, 0x4b, 0x80, 0x5a, 0x7e, 0x14, 0x49, 0xe6, 0xb3, 0xe8, 0x18, 0xa1,
-	0xe5, 0x9a, 0x19, 0x47, 0xa6, 0x69, 0x06, 0xb8, 0x9b, 0x09, 0x97, 0x97, 0xab, 0x98, 0xab, 0x35,
-	0xb5, 0xbe, 0x0c, 0x69, 0x06, 0x6b, 0xe0, 0x55, 0x8b, 0xf0, 0x9e, 0x2d, 0xb5, 0x56, 0xc6, 0x3d,
-	0xab, 0x50, 0xdf, 0x33, 0x6f, 0xf6, 0xe6, 0x08, 0x27, 0x9a, 0xf1, 0x1c, 0xc8, 0x28, 0x1e, 0x90,
-	0x13, 0xc1, 0x41, 0x5a, 0xc0, 0xe3, 0xdc, 0x39, 0x6d, 0xc7, 0x94, 0x5e, 0xd7, 0xd3, 0xf2, 0x1c,
-	0xd6, 0x8c, 0xae, 0x0a, 0xb5, 0xa2, 0x6b, 0x66, 0x1d, 0x18, 0x7a, 0x32, 0x9b, 0x4c, 0x5f, 0x2c,
-	0xa7, 0xa3, 0xad, 0x61, 0x3c, 0x88, 0x07, 0xbd, 0x20, 0x18, 0xed, 0x33, 0xad, 0x8b, 0x3a, 0x11,
-	0xfa, 0xda, 0x2a, 0x39, 0xbe, 0x52, 0x59, 0xdc, 0xf5, 0xa1, 0x0c, 0xf1, 0x1e, 0xba, 0xfd, 0x52,
-	0xfa, 0x45, 0x95, 0x11, 0x1f, 0x20, 0x7d, 0xf5, 0xe0, 0xe6, 0xc1, 0x4f, 0xbd, 0xba, 0xda, 0xae,
-	0x6e, 0xe7, 0x93, 0x5f, 0x01, 0x00, 0x00, 0xff, 0xff, 0xb0, 0x0c, 0x90, 0xc7, 0x06, 0x03, 0x00,
-	0x00,
+	// 573 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x93, 0xcf, 0x6e, 0xd4, 0x3c,
+	0x14, 0xc5, 0x95, 0x99, 0xaf, 0xff, 0x3c, 0x1f, 0x65, 0x64, 0x09, 0x3a, 0x44, 0x5d, 0x58, 0x81,
+	0x45, 0x29, 0x34, 0x9e, 0x4e, 0xbb, 0x1a, 0x36, 0xa4, 0x55, 0x85, 0x8a, 0x2a, 0x54, 0x4d, 0x61,
+	0xc3, 0xa6, 0xf2, 0x38, 0xb7, 0x89, 0x69, 0xc6, 0x36, 0xb6, 0x87, 0x52, 0x16, 0x2c, 0x78, 0x01,
+	0xd4, 0xf2, 0x10, 0x88, 0xe7, 0x61, 0xcd, 0x0a, 0x16, 0xec, 0x79, 0x01, 0x64, 0x13, 0xd4, 0x81,
+	0xaa, 0x15, 0x82, 0x55, 0x9c, 0x73, 0x4e, 0x7e, 0xbe, 0xe7, 0x4a, 0x41, 0x88, 0x8d, 0x5d, 0x99,
+	0x6a, 0xa3, 0x9c, 0xc2, 0x57, 0x94, 0x06, 0x29, 0xc1, 0xda, 0xd4, 0x8b, 0xf1, 0x62, 0xa1, 0x54,
+	0x51, 0x01, 0x65, 0x5a, 0x50, 0x26, 0xa5, 0x72, 0xcc, 0x09, 0x25, 0xed, 0x8f, 0x70, 0x7c, 0x37,
+	0x3c, 0xf8, 0x4a, 0x01, 0x72, 0xc5, 0x1e, 0xb1, 0xa2, 0x00, 0x43, 0x95, 0x0e, 0x89, 0xf3, 0xe9,
+	0xe4, 0x01, 0x9a, 0xdd, 0xce, 0x41, 0x3a, 0xe1, 0x8e, 0x71, 0x1b, 0x35, 0xb9, 0x35, 0x9d, 0x88,
+	0x44, 0x4b, 0x73, 0x03, 0x7f, 0xc4, 0xb7, 0x51, 0x1b, 0xa4, 0x51, 0x55, 0x35, 0x02, 0xe9, 0xf6,
+	0x9d, 0x3a, 0x04, 0xd9, 0x69, 0x04, 0xfb, 0xea, 0x99, 0xfe, 0xd8, 0xcb, 0x89, 0x45, 0xad, 0x4d,
+	0x03, 0x01, 0xc5, 0x2a, 0x8b, 0xe7, 0x51, 0x43, 0xe4, 0x35, 0xaa, 0x21, 0x72, 0x4c, 0x50, 0x8b,
+	0x83, 0x71, 0xe2, 0x40, 0x70, 0xe6, 0xa0, 0x86, 0x4c, 0x4a, 0xf8, 0x06, 0x9a, 0xe5, 0x6c, 0x9f,
+	0x97, 0x4c, 0xc8, 0x4e, 0x93, 0x34, 0x97, 0xe6, 0x06, 0x33, 0x9c, 0x6d, 0xfa, 0x57, 0xbc, 0x80,
+	0x66, 0x38, 0xdb, 0xd7, 0x4a, 0x55, 0x9d, 0xff, 0x82, 0x33, 0xcd, 0xd9, 0xae, 0x52, 0x55, 0xef,
+	0x73, 0x13, 0xb5, 0xb2, 0xb1, 0x2b, 0xf7, 0xc0, 0xbc, 0x10, 0x1c, 0xf0, 0xa7, 0x08, 0xe1, 0x01,
+	0x3c, 0x1f, 0x83, 0x75, 0x93, 0xc3, 0x2c, 0xa4, 0xbf, 0x2c, 0x30, 0xfd, 0xd9, 0x38, 0x8e, 0x7f,
+	0x33, 0x26, 0x3e, 0x4a, 0x4e, 0xa2, 0xd3, 0xec, 0x75, 0x9c, 0xd4, 0x38, 0xe2, 0x7d, 0x6f, 0xf1,
+	0xb0, 0x3e, 0xc2, 0xcf, 0x92, 0x0f, 0xef, 0xa0, 0x66, 0xaf, 0xbb, 0x8a, 0x6f, 0xa1, 0x24, 0xbb,
+	0x30, 0xe4, 0xcf, 0xcc, 0x41, 0xee, 0xc3, 0xeb, 0xdd, 0x75, 0x1f, 0xae, 0xc9, 0x90, 0x13, 0x51,
+	0xcf, 0x43, 0xa4, 0x72, 0xe4, 0x50, 0xaa, 0x23, 0x49, 0x0f, 0xd4, 0x58, 0xe6, 0x6f, 0x3e, 0x7e,
+	0x79, 0xd7, 0x40, 0xfd, 0x68, 0x39, 0x99, 0xa2, 0xfe, 0x7e, 0xfc, 0x2d, 0x42, 0xed, 0x01, 0x48,
+	0x38, 0xfa, 0xe7, 0x76, 0x1f, 0xa2, 0xd3, 0xec, 0x6d, 0x14, 0xaf, 0x05, 0xda, 0x25, 0xe5, 0xc8,
+	0x10, 0x0e, 0x94, 0x01, 0xe2, 0x4a, 0x38, 0x26, 0xf0, 0x52, 0x0b, 0x03, 0x7f, 0xd6, 0xd7, 0x78,
+	0xf0, 0xdf, 0xf4, 0x6d, 0xfb, 0xbe, 0xad, 0xd0, 0x97, 0x06, 0xc8, 0xc6, 0x49, 0xe3, 0x34, 0xfb,
+	0x1a, 0xe1, 0xf7, 0x11, 0x9a, 0xf5, 0x17, 0x92, 0x6c, 0x77, 0x3b, 0xd9, 0x40, 0x68, 0x6f, 0xc4,
+	0x8c, 0x23, 0x5b, 0x79, 0x01, 0x78, 0xb1, 0x10, 0xae, 0x1c, 0x0f, 0x53, 0xae, 0x46, 0xd4, 0x7a,
+	0x19, 0xf2, 0x02, 0x46, 0xc0, 0x03, 0x25, 0xbe, 0x6e, 0xc7, 0x5a, 0x2b, 0xe3, 0xee, 0x07, 0x6b,
+	0xc5, 0x7b, 0x3e, 0xb9, 0xbc, 0x8b, 0x70, 0xa6, 0x19, 0x2f, 0x81, 0xf4, 0xd2, 0x2e, 0xd9, 0x11,
+	0x1c, 0xa4, 0x05, 0xdc, 0x2f, 0x9d, 0xd3, 0xb6, 0x4f, 0xe9, 0x45, 0x4c, 0xcb, 0x4b, 0x18, 0x31,
+	0x3a, 0xac, 0xd4, 0x90, 0x8e, 0x98, 0x75, 0x60, 0xe8, 0xce, 0xf6, 0xe6, 0xd6, 0xa3, 0xbd, 0xad,
+	0xde, 0xd4, 0x6a, 0xda, 0x4d, 0xbb, 0xcb, 0x51, 0xd4, 0x6b, 0x33, 0xad, 0xab, 0x7a, 0x2f, 0xf4,
+	0x99, 0x55, 0xb2, 0x7f, 0x4e, 0x19, 0x5c, 0xf3, 0xab, 0x59, 0xc5, 0xf3, 0xe8, 0xff, 0x27, 0xd2,
+	0x0f, 0xaa, 0x8c, 0x78, 0x05, 0xf9, 0xd3, 0x9b, 0x97, 0x5f, 0x7c, 0xcf, 0x47, 0x87, 0xd3, 0xe1,
+	0xf7, 0x5d, 0xfb, 0x3e, 0x00, 0x3a, 0x57, 0x59, 0x0d, 0x27, 0x04, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -204,6 +217,7 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type AuthServiceClient interface {
 	RequestCredentials(ctx context.Context, in *Identity, opts ...grpc.CallOption) (*Credentials, error)
+	RenewCredentials(ctx context.Context, in *Identity, opts ...grpc.CallOption) (*Credentials, error)
 }
 
 type authServiceClient struct {
@@ -223,9 +237,19 @@ func (c *authServiceClient) RequestCredentials(ctx context.Context, in *Identity
 	return out, nil
 }
 
+func (c *authServiceClient) RenewCredentials(ctx context.Context, in *Identity, opts ...grpc.CallOption) (*Credentials, error) {
+	out := new(Credentials)
+	err := c.cc.Invoke(ctx, "/openness.auth.AuthService/RenewCredentials", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuthServiceServer is the server API for AuthService service.
 type AuthServiceServer interface {
 	RequestCredentials(context.Context, *Identity) (*Credentials, error)
+	RenewCredentials(context.Context, *Identity) (*Credentials, error)
 }
 
 func RegisterAuthServiceServer(s *grpc.Server, srv AuthServiceServer) {
@@ -250,6 +274,24 @@ func _AuthService_RequestCredentials_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AuthService_RenewCredentials_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Identity)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).RenewCredentials(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/openness.auth.AuthService/RenewCredentials",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).RenewCredentials(ctx, req.(*Identity))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _AuthService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "openness.auth.AuthService",
 	HandlerType: (*AuthServiceServer)(nil),
@@ -258,6 +300,10 @@ var _AuthService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RequestCredentials",
 			Handler:    _AuthService_RequestCredentials_Handler,
 		},
+		{
+			MethodName: "RenewCredentials",
+			Handler:    _AuthService_RenewCredentials_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "auth.proto",
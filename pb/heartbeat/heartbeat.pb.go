@@ -0,0 +1,309 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2020 Intel Corporation
+
+package heartbeat
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// HeartbeatRequest is the state of the node sending a heartbeat.
+type HeartbeatRequest struct {
+	// The version of the node's agent
+	AgentVersion string `protobuf:"bytes,1,opt,name=agent_version,json=agentVersion,proto3" json:"agent_version,omitempty"`
+	// The number of seconds the node has been up
+	UptimeSeconds uint64 `protobuf:"varint,2,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	// The resource usage of the node
+	Resources            *ResourceUsage `protobuf:"bytes,3,opt,name=resources,proto3" json:"resources,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *HeartbeatRequest) Reset()         { *m = HeartbeatRequest{} }
+func (m *HeartbeatRequest) String() string { return proto.CompactTextString(m) }
+func (*HeartbeatRequest) ProtoMessage()    {}
+func (*HeartbeatRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3c667767fb9826a9, []int{0}
+}
+
+func (m *HeartbeatRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HeartbeatRequest.Unmarshal(m, b)
+}
+func (m *HeartbeatRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HeartbeatRequest.Marshal(b, m, deterministic)
+}
+func (m *HeartbeatRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HeartbeatRequest.Merge(m, src)
+}
+func (m *HeartbeatRequest) XXX_Size() int {
+	return xxx_messageInfo_HeartbeatRequest.Size(m)
+}
+func (m *HeartbeatRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_HeartbeatRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HeartbeatRequest proto.InternalMessageInfo
+
+func (m *HeartbeatRequest) GetAgentVersion() string {
+	if m != nil {
+		return m.AgentVersion
+	}
+	return ""
+}
+
+func (m *HeartbeatRequest) GetUptimeSeconds() uint64 {
+	if m != nil {
+		return m.UptimeSeconds
+	}
+	return 0
+}
+
+func (m *HeartbeatRequest) GetResources() *ResourceUsage {
+	if m != nil {
+		return m.Resources
+	}
+	return nil
+}
+
+// ResourceUsage is the resource usage of a node.
+type ResourceUsage struct {
+	// The CPU usage in percent, from 0 to 100
+	CpuPercent float64 `protobuf:"fixed64,1,opt,name=cpu_percent,json=cpuPercent,proto3" json:"cpu_percent,omitempty"`
+	// The used and total memory in bytes
+	MemoryUsedBytes  uint64 `protobuf:"varint,2,opt,name=memory_used_bytes,json=memoryUsedBytes,proto3" json:"memory_used_bytes,omitempty"`
+	MemoryTotalBytes uint64 `protobuf:"varint,3,opt,name=memory_total_bytes,json=memoryTotalBytes,proto3" json:"memory_total_bytes,omitempty"`
+	// The used and total disk space in bytes
+	DiskUsedBytes        uint64   `protobuf:"varint,4,opt,name=disk_used_bytes,json=diskUsedBytes,proto3" json:"disk_used_bytes,omitempty"`
+	DiskTotalBytes       uint64   `protobuf:"varint,5,opt,name=disk_total_bytes,json=diskTotalBytes,proto3" json:"disk_total_bytes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResourceUsage) Reset()         { *m = ResourceUsage{} }
+func (m *ResourceUsage) String() string { return proto.CompactTextString(m) }
+func (*ResourceUsage) ProtoMessage()    {}
+func (*ResourceUsage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3c667767fb9826a9, []int{1}
+}
+
+func (m *ResourceUsage) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResourceUsage.Unmarshal(m, b)
+}
+func (m *ResourceUsage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResourceUsage.Marshal(b, m, deterministic)
+}
+func (m *ResourceUsage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResourceUsage.Merge(m, src)
+}
+func (m *ResourceUsage) XXX_Size() int {
+	return xxx_messageInfo_ResourceUsage.Size(m)
+}
+func (m *ResourceUsage) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResourceUsage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResourceUsage proto.InternalMessageInfo
+
+func (m *ResourceUsage) GetCpuPercent() float64 {
+	if m != nil {
+		return m.CpuPercent
+	}
+	return 0
+}
+
+func (m *ResourceUsage) GetMemoryUsedBytes() uint64 {
+	if m != nil {
+		return m.MemoryUsedBytes
+	}
+	return 0
+}
+
+func (m *ResourceUsage) GetMemoryTotalBytes() uint64 {
+	if m != nil {
+		return m.MemoryTotalBytes
+	}
+	return 0
+}
+
+func (m *ResourceUsage) GetDiskUsedBytes() uint64 {
+	if m != nil {
+		return m.DiskUsedBytes
+	}
+	return 0
+}
+
+func (m *ResourceUsage) GetDiskTotalBytes() uint64 {
+	if m != nil {
+		return m.DiskTotalBytes
+	}
+	return 0
+}
+
+// HeartbeatResponse acknowledges a heartbeat.
+type HeartbeatResponse struct {
+	// The number of seconds in which the node should send its next heartbeat
+	IntervalSeconds      uint32   `protobuf:"varint,1,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HeartbeatResponse) Reset()         { *m = HeartbeatResponse{} }
+func (m *HeartbeatResponse) String() string { return proto.CompactTextString(m) }
+func (*HeartbeatResponse) ProtoMessage()    {}
+func (*HeartbeatResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3c667767fb9826a9, []int{2}
+}
+
+func (m *HeartbeatResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HeartbeatResponse.Unmarshal(m, b)
+}
+func (m *HeartbeatResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HeartbeatResponse.Marshal(b, m, deterministic)
+}
+func (m *HeartbeatResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HeartbeatResponse.Merge(m, src)
+}
+func (m *HeartbeatResponse) XXX_Size() int {
+	return xxx_messageInfo_HeartbeatResponse.Size(m)
+}
+func (m *HeartbeatResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_HeartbeatResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HeartbeatResponse proto.InternalMessageInfo
+
+func (m *HeartbeatResponse) GetIntervalSeconds() uint32 {
+	if m != nil {
+		return m.IntervalSeconds
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*HeartbeatRequest)(nil), "openness.heartbeat.HeartbeatRequest")
+	proto.RegisterType((*ResourceUsage)(nil), "openness.heartbeat.ResourceUsage")
+	proto.RegisterType((*HeartbeatResponse)(nil), "openness.heartbeat.HeartbeatResponse")
+}
+
+func init() { proto.RegisterFile("heartbeat.proto", fileDescriptor_3c667767fb9826a9) }
+
+var fileDescriptor_3c667767fb9826a9 = []byte{
+	// 364 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x92, 0xcd, 0x4e, 0x83, 0x40,
+	0x14, 0x85, 0x83, 0xad, 0x26, 0x9d, 0x4a, 0xa1, 0xb3, 0x6a, 0xdc, 0x58, 0xab, 0x35, 0x68, 0x14,
+	0x4c, 0xdd, 0x6b, 0xd2, 0x95, 0x4b, 0x43, 0xad, 0x31, 0x6e, 0x08, 0x3f, 0x37, 0x94, 0x08, 0x33,
+	0xe3, 0xcc, 0xd0, 0xa4, 0x4f, 0xe3, 0x8b, 0xf9, 0x30, 0x66, 0x18, 0x4a, 0xf1, 0x27, 0x71, 0xc9,
+	0x77, 0xce, 0x3d, 0x39, 0x73, 0x2f, 0xc8, 0x5a, 0x41, 0xc8, 0x65, 0x04, 0xa1, 0x74, 0x19, 0xa7,
+	0x92, 0x62, 0x4c, 0x19, 0x10, 0x02, 0x42, 0xb8, 0x8d, 0x32, 0xf9, 0x30, 0x90, 0xfd, 0xb0, 0xfd,
+	0xf2, 0xe1, 0xbd, 0x04, 0x21, 0xf1, 0x29, 0x32, 0xc3, 0x14, 0x88, 0x0c, 0xd6, 0xc0, 0x45, 0x46,
+	0xc9, 0xc8, 0x18, 0x1b, 0x4e, 0xcf, 0x3f, 0xac, 0xe0, 0xb3, 0x66, 0x78, 0x8a, 0x06, 0x25, 0x93,
+	0x59, 0x01, 0x81, 0x80, 0x98, 0x92, 0x44, 0x8c, 0xf6, 0xc6, 0x86, 0xd3, 0xf5, 0x4d, 0x4d, 0x17,
+	0x1a, 0xe2, 0x7b, 0xd4, 0xe3, 0x20, 0x68, 0xc9, 0x63, 0x10, 0xa3, 0xce, 0xd8, 0x70, 0xfa, 0xb3,
+	0x13, 0xf7, 0x77, 0x11, 0xd7, 0xaf, 0x4d, 0x4b, 0x11, 0xa6, 0xe0, 0xef, 0x66, 0x26, 0x9f, 0x06,
+	0x32, 0xbf, 0x89, 0xf8, 0x18, 0xf5, 0x63, 0x56, 0x06, 0x0c, 0x78, 0x0c, 0x44, 0x56, 0xe5, 0x0c,
+	0x1f, 0xc5, 0xac, 0x7c, 0xd4, 0x04, 0x5f, 0xa2, 0x61, 0x01, 0x05, 0xe5, 0x9b, 0xa0, 0x14, 0x90,
+	0x04, 0xd1, 0x46, 0xc2, 0xb6, 0x9d, 0xa5, 0x85, 0xa5, 0x80, 0x64, 0xae, 0x30, 0xbe, 0x42, 0xb8,
+	0xf6, 0x4a, 0x2a, 0xc3, 0xbc, 0x36, 0x77, 0x2a, 0xb3, 0xad, 0x95, 0x27, 0x25, 0x68, 0xf7, 0x39,
+	0xb2, 0x92, 0x4c, 0xbc, 0xb5, 0x73, 0xbb, 0xfa, 0xd5, 0x0a, 0xef, 0x52, 0x1d, 0x64, 0x57, 0xbe,
+	0x76, 0xe6, 0x7e, 0x65, 0x1c, 0x28, 0xbe, 0x4b, 0x9c, 0xdc, 0xa1, 0x61, 0x6b, 0xff, 0x82, 0x51,
+	0x22, 0x00, 0x5f, 0x20, 0x3b, 0x23, 0x12, 0xf8, 0x3a, 0xcc, 0x9b, 0xed, 0xaa, 0x67, 0x9a, 0xbe,
+	0xb5, 0xe5, 0xf5, 0x7e, 0x67, 0x79, 0xeb, 0x7e, 0x0b, 0xe0, 0xeb, 0x2c, 0x06, 0xfc, 0x82, 0x7a,
+	0x0d, 0xc3, 0x67, 0x7f, 0x6d, 0xfb, 0xe7, 0xc9, 0x8f, 0xa6, 0xff, 0xb8, 0x74, 0xb1, 0xf9, 0xec,
+	0xf5, 0x26, 0xcd, 0xe4, 0xaa, 0x8c, 0xdc, 0x98, 0x16, 0x9e, 0x1a, 0xb9, 0x56, 0x33, 0x1e, 0x24,
+	0xa9, 0xea, 0x23, 0x39, 0xcd, 0x73, 0xe0, 0x1e, 0x8b, 0xbc, 0x26, 0x25, 0x3a, 0xa8, 0xfe, 0xbe,
+	0xdb, 0xaf, 0x01, 0x00, 0xd9, 0xab, 0x1a, 0x8d, 0x90, 0x02, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// HeartbeatServiceClient is the client API for HeartbeatService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type HeartbeatServiceClient interface {
+	// Heartbeat reports that the node is alive along with its state. The node
+	// should send its next heartbeat within the interval of the response.
+	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
+}
+
+type heartbeatServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewHeartbeatServiceClient(cc *grpc.ClientConn) HeartbeatServiceClient {
+	return &heartbeatServiceClient{cc}
+}
+
+func (c *heartbeatServiceClient) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error) {
+	out := new(HeartbeatResponse)
+	err := c.cc.Invoke(ctx, "/openness.heartbeat.HeartbeatService/Heartbeat", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HeartbeatServiceServer is the server API for HeartbeatService service.
+type HeartbeatServiceServer interface {
+	// Heartbeat reports that the node is alive along with its state. The node
+	// should send its next heartbeat within the interval of the response.
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+}
+
+func RegisterHeartbeatServiceServer(s *grpc.Server, srv HeartbeatServiceServer) {
+	s.RegisterService(&_HeartbeatService_serviceDesc, srv)
+}
+
+func _HeartbeatService_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeartbeatServiceServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/openness.heartbeat.HeartbeatService/Heartbeat",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeartbeatServiceServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _HeartbeatService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "openness.heartbeat.HeartbeatService",
+	HandlerType: (*HeartbeatServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Heartbeat",
+			Handler:    _HeartbeatService_Heartbeat_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "heartbeat.proto",
+}
@@ -10,4 +10,7 @@
 //nolint:lll
 //go:generate protoc -I../../schema/pb -I../../../grpc-ecosystem/grpc-gateway -I../../../grpc-ecosystem/grpc-gateway/third_party/googleapis --go_out=plugins=grpc,paths=source_relative,Mela.proto=github.com/open-ness/edgecontroller/pb/ela:eva ../../schema/pb/eva.proto
 
+//nolint:lll
+//go:generate protoc -Iheartbeat --go_out=plugins=grpc,paths=source_relative:heartbeat heartbeat/heartbeat.proto
+
 package pb
@@ -12,7 +12,6 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"math/big"
-	rdm "math/rand"
 	"os"
 	"path/filepath"
 	"time"
@@ -20,23 +19,48 @@ import (
 	"github.com/pkg/errors"
 )
 
-// RootCA manages digital certificates.
+// RootCA manages digital certificates. It is either a self-signed root CA or,
+// when the root is kept offline, an intermediate CA issued by the root. Chain
+// then holds the issuers of the intermediate, ending with the root, and
+// Retired the intermediates it replaced, whose certificates are still
+// trusted until they expire.
+//
+// NodeCertValidity is the validity of the certificates signed by SignCSR and
+// TLSCertValidity the validity of the TLS certificates it creates. A zero
+// validity makes certificates valid until the CA expires, and no certificate
+// is valid for longer.
 type RootCA struct {
-	Cert *x509.Certificate
-	Key  crypto.PrivateKey
+	Cert    *x509.Certificate
+	Key     crypto.PrivateKey
+	Chain   []*x509.Certificate
+	Retired []*x509.Certificate
+
+	NodeCertValidity time.Duration
+	TLSCertValidity  time.Duration
 }
 
+// Files of a CA directory
+const (
+	keyFile     = "key.pem"
+	certFile    = "cert.pem"
+	chainFile   = "chain.pem"
+	retiredFile = "retired.pem"
+)
+
 // InitRootCA creates a RootCA by loading the CA certificate and key from the
 // certificates directory. If they do not exist or the certificate was not
 // signed with the key, a new certificate and key will generated.
+//
+// If the directory holds the chain of an intermediate CA, the intermediate CA
+// is loaded with LoadIntermediateCA instead and nothing is generated.
 func InitRootCA(certsDir string) (*RootCA, error) {
 	var (
 		err error
 
-		keyFile string
+		keyPath string
 		key     crypto.PrivateKey
 
-		certFile string
+		certPath string
 		cert     *x509.Certificate
 		certDER  []byte
 	)
@@ -45,32 +69,36 @@ func InitRootCA(certsDir string) (*RootCA, error) {
 		return nil, errors.Wrap(err, "unable to create CA directory")
 	}
 
-	keyFile = filepath.Join(certsDir, "key.pem")
+	if _, err = os.Stat(filepath.Join(certsDir, chainFile)); err == nil {
+		return LoadIntermediateCA(certsDir)
+	}
 
-	if key, err = LoadKey(keyFile); err != nil {
+	keyPath = filepath.Join(certsDir, keyFile)
+
+	if key, err = LoadKey(keyPath); err != nil {
 		if key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader); err != nil {
 			return nil, errors.Wrap(err, "unable to generate CA key")
 		}
 
-		if err = StoreKey(key, keyFile); err != nil {
+		if err = StoreKey(key, keyPath); err != nil {
 			return nil, errors.Wrap(err, "unable to store CA key")
 		}
 
-		log.Debugf("Generated and stored CA key at: %s", keyFile)
+		log.Debugf("Generated and stored CA key at: %s", keyPath)
 	}
 
-	certFile = filepath.Join(certsDir, "cert.pem")
+	certPath = filepath.Join(certsDir, certFile)
 
-	if cert, err = LoadCertificate(certFile); err != nil {
-		if cert, err = generateRootCA(key); err != nil {
+	if cert, err = LoadCertificate(certPath); err != nil {
+		if cert, err = generateRootCA(key, 3*365*24*time.Hour, 0); err != nil {
 			return nil, errors.Wrap(err, "unable to generate root CA")
 		}
 
-		if err = StoreCertificate(certFile, cert); err != nil {
+		if err = StoreCertificate(certPath, cert); err != nil {
 			return nil, errors.Wrap(err, "unable to store CA certificate")
 		}
 
-		log.Debugf("Generated and stored CA certificate at: %s", certFile)
+		log.Debugf("Generated and stored CA certificate at: %s", certPath)
 	}
 
 	if certDER, err = x509.MarshalPKIXPublicKey(key.(crypto.Signer).Public()); err != nil {
@@ -79,7 +107,7 @@ func InitRootCA(certsDir string) (*RootCA, error) {
 
 	// Verify the certificate was signed with the private key
 	if !bytes.Equal(cert.RawSubjectPublicKeyInfo, certDER) {
-		if err = os.Remove(certFile); err != nil {
+		if err = os.Remove(certPath); err != nil {
 			return nil, errors.Wrap(err, "unable to remove invalid cert")
 		}
 
@@ -92,11 +120,26 @@ func InitRootCA(certsDir string) (*RootCA, error) {
 	}, nil
 }
 
-// CAChain returns the root CA certificate wrapped in a slice to satisfy the
-// interface. Since the root CA is the issuing CA and there are no intermediate
-// CAs, we only need to return the root CA certificate.
+// CAChain returns the chain of the issuing CA, starting with the issuing CA
+// and ending with the root CA. A self-signed root CA is its own chain.
 func (ca *RootCA) CAChain() ([]*x509.Certificate, error) {
-	return []*x509.Certificate{ca.Cert}, nil
+	return append([]*x509.Certificate{ca.Cert}, ca.Chain...), nil
+}
+
+// CertPool returns a pool of the CA chain and the retired intermediate CAs,
+// to verify the certificates issued by any of them. Nodes only present their
+// own certificate, so the intermediates must be trusted directly.
+func (ca *RootCA) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	for _, cert := range ca.Chain {
+		pool.AddCert(cert)
+	}
+	for _, cert := range ca.Retired {
+		pool.AddCert(cert)
+	}
+
+	return pool
 }
 
 // SignCSR signs a ASN.1 DER encoded certificate signing request.
@@ -106,16 +149,18 @@ func (ca *RootCA) SignCSR(der []byte, template *x509.Certificate) (*x509.Certifi
 		return nil, errors.Wrap(err, "unable to parse CSR")
 	}
 
-	// Pick random serial number
-	source := rdm.NewSource(time.Now().UnixNano())
-	serial := big.NewInt(int64(rdm.New(source).Uint64()))
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
 
 	// Sign certificate request
 	tmpl := &x509.Certificate{
 		SerialNumber: serial,
 		Subject:      template.Subject,
-		NotBefore:    time.Now(),
-		NotAfter:     ca.Cert.NotAfter, // Valid until CA expires
+		NotBefore:    now,
+		NotAfter:     ca.notAfter(now, ca.NodeCertValidity),
 	}
 	certDER, err := x509.CreateCertificate(
 		rand.Reader,
@@ -151,9 +196,11 @@ func (ca *RootCA) newTLSCert(
 		return nil, errors.Errorf("invalid private key type: %T", key)
 	}
 
-	// Pick random serial number
-	source := rdm.NewSource(time.Now().UnixNano())
-	serial := big.NewInt(int64(rdm.New(source).Uint64()))
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
 
 	// Generate certificate
 	template := &x509.Certificate{
@@ -161,8 +208,8 @@ func (ca *RootCA) newTLSCert(
 		Subject:      pkix.Name{CommonName: sni},
 		KeyUsage:     x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:  extKeyUsage,
-		NotBefore:    time.Now(),
-		NotAfter:     ca.Cert.NotAfter, // Valid until CA expires
+		NotBefore:    now,
+		NotAfter:     ca.notAfter(now, ca.TLSCertValidity),
 	}
 	certDER, err := x509.CreateCertificate(
 		rand.Reader,
@@ -178,13 +225,23 @@ func (ca *RootCA) newTLSCert(
 	return x509.ParseCertificate(certDER)
 }
 
-// generateRootCA creates a root CA from the private key valid for 3 years.
-func generateRootCA(key crypto.PrivateKey) (*x509.Certificate, error) {
+// notAfter returns the end of the validity of a certificate issued at now,
+// which is at the latest when the CA expires.
+func (ca *RootCA) notAfter(now time.Time, validity time.Duration) time.Time {
+	if validity <= 0 || now.Add(validity).After(ca.Cert.NotAfter) {
+		return ca.Cert.NotAfter
+	}
+	return now.Add(validity)
+}
+
+// generateRootCA creates a root CA from the private key valid for validity. A
+// root CA with a maxPathLen of 0 signs certificates directly, one of 1 signs
+// intermediate CAs.
+func generateRootCA(key crypto.PrivateKey, validity time.Duration, maxPathLen int) (*x509.Certificate, error) {
 	var (
 		err      error
 		k        crypto.Signer
 		ok       bool
-		source   rdm.Source
 		serial   *big.Int
 		template *x509.Certificate
 		der      []byte
@@ -194,9 +251,9 @@ func generateRootCA(key crypto.PrivateKey) (*x509.Certificate, error) {
 		return nil, errors.Wrap(err, "unable to parse key")
 	}
 
-	source = rdm.NewSource(time.Now().UnixNano())
-
-	serial = big.NewInt(int64(rdm.New(source).Uint64()))
+	if serial, err = newSerial(); err != nil {
+		return nil, err
+	}
 
 	template = &x509.Certificate{
 		SerialNumber: serial,
@@ -204,12 +261,12 @@ func generateRootCA(key crypto.PrivateKey) (*x509.Certificate, error) {
 			Organization: []string{"Controller Authority"},
 		},
 		NotBefore:             time.Now().Add(-15 * time.Second),
-		NotAfter:              time.Now().Add(3 * 365 * 24 * time.Hour),
+		NotAfter:              time.Now().Add(validity),
 		IsCA:                  true,
-		KeyUsage:              x509.KeyUsageCertSign,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
-		MaxPathLen:            0,
-		MaxPathLenZero:        true,
+		MaxPathLen:            maxPathLen,
+		MaxPathLenZero:        maxPathLen == 0,
 		BasicConstraintsValid: true,
 	}
 
@@ -219,3 +276,17 @@ func generateRootCA(key crypto.PrivateKey) (*x509.Certificate, error) {
 
 	return x509.ParseCertificate(der)
 }
+
+// serialLimit bounds the serial numbers to 127 bits, so that they are always
+// positive and fit the 20 octets allowed by RFC 5280.
+var serialLimit = new(big.Int).Lsh(big.NewInt(1), 127)
+
+// newSerial returns a random positive certificate serial number.
+func newSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, serialLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate serial number")
+	}
+
+	return serial.Add(serial, big.NewInt(1)), nil
+}
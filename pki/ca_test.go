@@ -13,6 +13,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -131,4 +132,41 @@ var _ = Describe("Controller CA", func() {
 			})
 		})
 	})
+
+	Describe("SignCSR", func() {
+		var (
+			rootCA *pki.RootCA
+			csrDER []byte
+		)
+
+		BeforeEach(func() {
+			rootCA, err = pki.InitRootCA(tmpDir)
+			Expect(err).ToNot(HaveOccurred())
+
+			key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			csrDER, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should sign certificates valid until the CA expires by default", func() {
+			cert, err := rootCA.SignCSR(csrDER, &x509.Certificate{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cert.NotAfter).To(BeTemporally("==", rootCA.Cert.NotAfter))
+		})
+
+		It("Should sign certificates valid for the node certificate validity", func() {
+			rootCA.NodeCertValidity = 24 * time.Hour
+			cert, err := rootCA.SignCSR(csrDER, &x509.Certificate{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cert.NotAfter).To(BeTemporally("~", time.Now().Add(24*time.Hour), time.Minute))
+		})
+
+		It("Should not sign certificates valid for longer than the CA", func() {
+			rootCA.NodeCertValidity = 10 * 365 * 24 * time.Hour
+			cert, err := rootCA.SignCSR(csrDER, &x509.Certificate{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cert.NotAfter).To(BeTemporally("==", rootCA.Cert.NotAfter))
+		})
+	})
 })
@@ -62,3 +62,38 @@ func LoadCertificate(path string) (*x509.Certificate, error) {
 
 	return x509.ParseCertificate(block.Bytes)
 }
+
+// LoadCertificates loads a certificate chain from disk.
+func LoadCertificates(path string) ([]*x509.Certificate, error) {
+	var (
+		err   error
+		bytes []byte
+		block *pem.Block
+		cert  *x509.Certificate
+		certs []*x509.Certificate
+	)
+
+	if bytes, err = ioutil.ReadFile(filepath.Clean(path)); err != nil {
+		return nil, errors.Wrap(err, "unable to read certificate file")
+	}
+
+	for {
+		if block, bytes = pem.Decode(bytes); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, errors.Wrap(err, "unable to parse certificate")
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("unable to decode certificate")
+	}
+
+	return certs, nil
+}
@@ -102,6 +102,23 @@ var _ = Describe("Certificate Persistence", func() {
 			Expect(storedCert).To(Equal(cert))
 		})
 	})
+
+	Describe("LoadCertificates", func() {
+		It("Should load a certificate chain from disk", func() {
+			By("Generating another certificate")
+			cert2, err := generateCert(key)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("Storing the certificate chain on disk")
+			err = pki.StoreCertificate(certFile, cert, cert2)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("Verifying stored certificates")
+			storedCerts, err := pki.LoadCertificates(certFile)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(storedCerts).To(Equal([]*x509.Certificate{cert, cert2}))
+		})
+	})
 })
 
 func generateCert(key crypto.PrivateKey) (*x509.Certificate, error) {
@@ -0,0 +1,302 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Default validities of the offline root CA and of the intermediate CAs it
+// signs
+const (
+	DefaultRootCAValidity         = 10 * 365 * 24 * time.Hour
+	DefaultIntermediateCAValidity = 3 * 365 * 24 * time.Hour
+)
+
+// Files of the next intermediate CA of a CA directory, until its certificate
+// is installed
+const (
+	nextKeyFile = "next-key.pem"
+	nextCSRFile = "next-csr.pem"
+)
+
+// CreateRootCA generates the key and self-signed certificate of a root CA that
+// signs intermediate CAs and stores them in dir. The root CA is meant to be
+// kept offline, it fails if dir already holds a CA.
+func CreateRootCA(dir string, validity time.Duration) (*RootCA, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "unable to create CA directory")
+	}
+	if _, err := os.Stat(filepath.Join(dir, keyFile)); err == nil {
+		return nil, errors.Errorf("a CA already exists in %s", dir)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate CA key")
+	}
+	cert, err := generateRootCA(key, validity, 1)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate root CA")
+	}
+
+	if err = StoreKey(key, filepath.Join(dir, keyFile)); err != nil {
+		return nil, errors.Wrap(err, "unable to store CA key")
+	}
+	if err = StoreCertificate(filepath.Join(dir, certFile), cert); err != nil {
+		return nil, errors.Wrap(err, "unable to store CA certificate")
+	}
+
+	return &RootCA{Cert: cert, Key: key}, nil
+}
+
+// CreateIntermediateCSR generates the key of the next intermediate CA of the
+// CA directory dir and returns the PEM-encoded CSR to be signed by the root
+// CA. The key and CSR are stored in dir until the signed certificate is
+// installed with InstallIntermediateCA, so that the key never leaves dir.
+func CreateIntermediateCSR(dir string) ([]byte, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "unable to create CA directory")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate CA key")
+	}
+	der, err := x509.CreateCertificateRequest(
+		rand.Reader,
+		&x509.CertificateRequest{
+			Subject: pkix.Name{
+				Organization: []string{"Controller Authority"},
+				CommonName:   "Controller Issuing CA",
+			},
+		},
+		key,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create CSR")
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+
+	if err = StoreKey(key, filepath.Join(dir, nextKeyFile)); err != nil {
+		return nil, errors.Wrap(err, "unable to store CA key")
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, nextCSRFile), csrPEM, 0600); err != nil {
+		return nil, errors.Wrap(err, "unable to store CSR")
+	}
+
+	return csrPEM, nil
+}
+
+// SignIntermediateCSR signs an ASN.1 DER encoded CSR of an intermediate CA
+// valid for validity, but at most until the CA expires. The intermediate CA
+// can only sign end-entity certificates.
+func (ca *RootCA) SignIntermediateCSR(der []byte, validity time.Duration) (*x509.Certificate, error) {
+	if !ca.Cert.IsCA || ca.Cert.MaxPathLenZero {
+		return nil, errors.New("CA cannot sign intermediate CAs")
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse CSR")
+	}
+	if err = csr.CheckSignature(); err != nil {
+		return nil, errors.Wrap(err, "invalid CSR signature")
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               csr.Subject,
+		NotBefore:             now.Add(-15 * time.Second),
+		NotAfter:              ca.notAfter(now, validity),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		MaxPathLen:            0,
+		MaxPathLenZero:        true,
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Cert, csr.PublicKey, ca.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to sign certificate")
+	}
+
+	return x509.ParseCertificate(certDER)
+}
+
+// InstallIntermediateCA installs the certificate of the next intermediate CA
+// of the CA directory dir, followed by its chain up to the root CA, as the
+// issuing CA. The intermediate CA it replaces is retired: the certificates it
+// issued are trusted until it expires, but it does not issue any more.
+func InstallIntermediateCA(dir string, certs []*x509.Certificate) error {
+	if len(certs) < 2 {
+		return errors.New("intermediate CA certificate must be followed by its chain")
+	}
+
+	key, err := LoadKey(filepath.Join(dir, nextKeyFile))
+	if err != nil {
+		return errors.Wrap(err, "unable to load next CA key")
+	}
+	if err = verifyIntermediateCA(key, certs[0], certs[1:], time.Now()); err != nil {
+		return err
+	}
+
+	retired, err := retire(dir, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(retired) == 0 {
+		err = os.Remove(filepath.Join(dir, retiredFile))
+	} else {
+		err = StoreCertificate(filepath.Join(dir, retiredFile), retired...)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "unable to store retired CA certificates")
+	}
+
+	if err = StoreCertificate(filepath.Join(dir, chainFile), certs[1:]...); err != nil {
+		return errors.Wrap(err, "unable to store CA chain")
+	}
+	if err = StoreCertificate(filepath.Join(dir, certFile), certs[0]); err != nil {
+		return errors.Wrap(err, "unable to store CA certificate")
+	}
+	if err = os.Rename(filepath.Join(dir, nextKeyFile), filepath.Join(dir, keyFile)); err != nil {
+		return errors.Wrap(err, "unable to install CA key")
+	}
+	if err = os.Remove(filepath.Join(dir, nextCSRFile)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "unable to remove CSR")
+	}
+
+	return nil
+}
+
+// LoadIntermediateCA loads an intermediate CA and its chain from the CA
+// directory dir. Unlike InitRootCA, it never generates a CA, so that the
+// Controller cannot run with an online root CA.
+func LoadIntermediateCA(dir string) (*RootCA, error) {
+	key, err := LoadKey(filepath.Join(dir, keyFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load CA key")
+	}
+	cert, err := LoadCertificate(filepath.Join(dir, certFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load CA certificate")
+	}
+	chain, err := LoadCertificates(filepath.Join(dir, chainFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load CA chain")
+	}
+	if err = verifyIntermediateCA(key, cert, chain, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var retired []*x509.Certificate
+	if _, err = os.Stat(filepath.Join(dir, retiredFile)); err == nil {
+		if retired, err = LoadCertificates(filepath.Join(dir, retiredFile)); err != nil {
+			return nil, errors.Wrap(err, "unable to load retired CA certificates")
+		}
+	}
+
+	return &RootCA{
+		Cert:    cert,
+		Key:     key,
+		Chain:   chain,
+		Retired: unexpired(retired, time.Now()),
+	}, nil
+}
+
+// verifyIntermediateCA verifies that cert is the certificate of key, an
+// intermediate CA that can sign certificates, and that chain, which ends with
+// the root CA, issued it.
+func verifyIntermediateCA(
+	key crypto.PrivateKey,
+	cert *x509.Certificate,
+	chain []*x509.Certificate,
+	now time.Time,
+) error {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return errors.Errorf("invalid private key type: %T", key)
+	}
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal public key")
+	}
+	if !bytes.Equal(cert.RawSubjectPublicKeyInfo, der) {
+		return errors.New("intermediate CA certificate does not match the CA key")
+	}
+	if !cert.IsCA || cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.New("intermediate CA certificate cannot sign certificates")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(chain[len(chain)-1])
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[:len(chain)-1] {
+		intermediates.AddCert(c)
+	}
+	if _, err = cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return errors.Wrap(err, "unable to verify intermediate CA chain")
+	}
+
+	return nil
+}
+
+// retire returns the unexpired retired certificates of the CA directory dir
+// with its current issuing CA.
+func retire(dir string, now time.Time) ([]*x509.Certificate, error) {
+	var (
+		err     error
+		cert    *x509.Certificate
+		retired []*x509.Certificate
+	)
+
+	if _, err = os.Stat(filepath.Join(dir, retiredFile)); err == nil {
+		if retired, err = LoadCertificates(filepath.Join(dir, retiredFile)); err != nil {
+			return nil, errors.Wrap(err, "unable to load retired CA certificates")
+		}
+	}
+	if _, err = os.Stat(filepath.Join(dir, certFile)); err == nil {
+		if cert, err = LoadCertificate(filepath.Join(dir, certFile)); err != nil {
+			return nil, errors.Wrap(err, "unable to load CA certificate")
+		}
+		retired = append(retired, cert)
+	}
+
+	return unexpired(retired, now), nil
+}
+
+func unexpired(certs []*x509.Certificate, now time.Time) []*x509.Certificate {
+	var valid []*x509.Certificate
+	for _, cert := range certs {
+		if now.Before(cert.NotAfter) {
+			valid = append(valid, cert)
+		}
+	}
+
+	return valid
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package pki_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/open-ness/edgecontroller/pki"
+)
+
+var _ = Describe("Intermediate CA", func() {
+	var (
+		tmpDir  string
+		rootDir string
+		caDir   string
+		root    *pki.RootCA
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "intermediate_test")
+		Expect(err).ToNot(HaveOccurred())
+		rootDir = filepath.Join(tmpDir, "root")
+		caDir = filepath.Join(tmpDir, "ca")
+
+		By("Creating an offline root CA")
+		root, err = pki.CreateRootCA(rootDir, pki.DefaultRootCAValidity)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	// signIntermediate creates the CSR of the next intermediate CA of caDir
+	// and signs it with the root CA.
+	signIntermediate := func() []*x509.Certificate {
+		csrPEM, err := pki.CreateIntermediateCSR(caDir)
+		Expect(err).ToNot(HaveOccurred())
+		block, _ := pem.Decode(csrPEM)
+		Expect(block).ToNot(BeNil())
+
+		cert, err := root.SignIntermediateCSR(block.Bytes, pki.DefaultIntermediateCAValidity)
+		Expect(err).ToNot(HaveOccurred())
+		return []*x509.Certificate{cert, root.Cert}
+	}
+
+	// signNodeCert signs a node certificate with the issuing CA.
+	signNodeCert := func(ca *pki.RootCA) *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+		Expect(err).ToNot(HaveOccurred())
+		cert, err := ca.SignCSR(csrDER, &x509.Certificate{})
+		Expect(err).ToNot(HaveOccurred())
+		return cert
+	}
+
+	verifies := func(cert *x509.Certificate, pool *x509.CertPool) error {
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:     pool,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		return err
+	}
+
+	It("Should not overwrite an existing root CA", func() {
+		_, err := pki.CreateRootCA(rootDir, pki.DefaultRootCAValidity)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should issue certificates with an installed intermediate CA", func() {
+		By("Installing an intermediate CA")
+		certs := signIntermediate()
+		Expect(pki.InstallIntermediateCA(caDir, certs)).To(Succeed())
+
+		By("Initializing the CA")
+		ca, err := pki.InitRootCA(caDir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ca.Cert).To(Equal(certs[0]))
+		Expect(ca.CAChain()).To(Equal(certs))
+
+		By("Verifying the root key is not in the CA directory")
+		keyPEM, err := ioutil.ReadFile(filepath.Join(caDir, "key.pem"))
+		Expect(err).ToNot(HaveOccurred())
+		rootKeyPEM, err := ioutil.ReadFile(filepath.Join(rootDir, "key.pem"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keyPEM).ToNot(Equal(rootKeyPEM))
+
+		By("Verifying issued certificates chain to the root CA")
+		cert := signNodeCert(ca)
+		roots := x509.NewCertPool()
+		roots.AddCert(root.Cert)
+		_, err = cert.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: ca.CertPool(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verifies(cert, ca.CertPool())).To(Succeed())
+	})
+
+	It("Should trust the certificates of a rotated intermediate CA", func() {
+		By("Installing an intermediate CA")
+		Expect(pki.InstallIntermediateCA(caDir, signIntermediate())).To(Succeed())
+		ca1, err := pki.LoadIntermediateCA(caDir)
+		Expect(err).ToNot(HaveOccurred())
+		cert1 := signNodeCert(ca1)
+
+		By("Rotating the intermediate CA")
+		certs := signIntermediate()
+		Expect(pki.InstallIntermediateCA(caDir, certs)).To(Succeed())
+		ca2, err := pki.LoadIntermediateCA(caDir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ca2.Cert).To(Equal(certs[0]))
+		Expect(ca2.Retired).To(ConsistOf(ca1.Cert))
+
+		By("Verifying the certificates of both intermediate CAs are trusted")
+		Expect(verifies(cert1, ca2.CertPool())).To(Succeed())
+		Expect(verifies(signNodeCert(ca2), ca2.CertPool())).To(Succeed())
+	})
+
+	It("Should not install an intermediate CA for another key", func() {
+		certs := signIntermediate()
+
+		By("Creating the CSR of another intermediate CA")
+		_, err := pki.CreateIntermediateCSR(caDir)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(pki.InstallIntermediateCA(caDir, certs)).To(MatchError(
+			"intermediate CA certificate does not match the CA key"))
+	})
+
+	It("Should not install an intermediate CA without its chain", func() {
+		certs := signIntermediate()
+		Expect(pki.InstallIntermediateCA(caDir, certs[:1])).To(HaveOccurred())
+	})
+
+	It("Should not load a self-signed root CA as intermediate CA", func() {
+		_, err := pki.InitRootCA(caDir)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = pki.LoadIntermediateCA(caDir)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should not sign intermediate CAs with a root CA that signs certificates", func() {
+		ca, err := pki.InitRootCA(caDir)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = ca.SignIntermediateCSR(nil, time.Hour)
+		Expect(err).To(MatchError("CA cannot sign intermediate CAs"))
+	})
+})
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ocsp"
+)
+
+// CreateCRL signs a DER encoded certificate revocation list of the revoked
+// certificates issued by the CA, valid from now until nextUpdate.
+func (ca *RootCA) CreateCRL(revoked []pkix.RevokedCertificate, now, nextUpdate time.Time) ([]byte, error) {
+	signer, ok := ca.Key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("invalid private key type: %T", ca.Key)
+	}
+
+	crl, err := ca.Cert.CreateCRL(rand.Reader, signer, revoked, now, nextUpdate)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create CRL")
+	}
+
+	return crl, nil
+}
+
+// CreateOCSPResponse signs a DER encoded OCSP response with the status of a
+// certificate issued by the CA. The CA signs the response itself, so that no
+// delegated responder certificate is needed.
+func (ca *RootCA) CreateOCSPResponse(template ocsp.Response) ([]byte, error) {
+	signer, ok := ca.Key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("invalid private key type: %T", ca.Key)
+	}
+
+	resp, err := ocsp.CreateResponse(ca.Cert, ca.Cert, template, signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create OCSP response")
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package pki_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/open-ness/edgecontroller/pki"
+	"golang.org/x/crypto/ocsp"
+)
+
+var _ = Describe("Revocation", func() {
+	var (
+		tmpDir string
+		rootCA *pki.RootCA
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "revocation_test")
+		Expect(err).ToNot(HaveOccurred())
+		rootCA, err = pki.InitRootCA(tmpDir)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	newNodeCert := func() *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		cert, err := rootCA.NewTLSClientCert(key, "node")
+		Expect(err).ToNot(HaveOccurred())
+		return cert
+	}
+
+	Describe("SignCSR", func() {
+		It("Should assign positive serial numbers", func() {
+			for i := 0; i < 32; i++ {
+				Expect(newNodeCert().SerialNumber.Sign()).To(Equal(1))
+			}
+		})
+	})
+
+	Describe("CreateCRL", func() {
+		It("Should create a CRL signed by the CA", func() {
+			now := time.Now().UTC().Truncate(time.Second)
+			der, err := rootCA.CreateCRL([]pkix.RevokedCertificate{
+				{SerialNumber: big.NewInt(42), RevocationTime: now},
+			}, now, now.Add(time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+
+			crl, err := x509.ParseCRL(der)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rootCA.Cert.CheckCRLSignature(crl)).To(Succeed())
+			Expect(crl.TBSCertList.RevokedCertificates).To(HaveLen(1))
+			Expect(crl.TBSCertList.RevokedCertificates[0].SerialNumber).To(Equal(big.NewInt(42)))
+			Expect(crl.TBSCertList.NextUpdate).To(BeTemporally("==", now.Add(time.Hour)))
+		})
+	})
+
+	Describe("CreateOCSPResponse", func() {
+		It("Should create an OCSP response signed by the CA", func() {
+			cert := newNodeCert()
+			now := time.Now().UTC().Truncate(time.Second)
+			der, err := rootCA.CreateOCSPResponse(ocsp.Response{
+				Status:           ocsp.Revoked,
+				SerialNumber:     cert.SerialNumber,
+				RevokedAt:        now,
+				RevocationReason: ocsp.KeyCompromise,
+				ThisUpdate:       now,
+				NextUpdate:       now.Add(time.Hour),
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			resp, err := ocsp.ParseResponseForCert(der, cert, rootCA.Cert)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Status).To(Equal(ocsp.Revoked))
+			Expect(resp.RevocationReason).To(Equal(ocsp.KeyCompromise))
+		})
+	})
+})
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Query selects a page of a collection in PersistenceService.ReadPage.
+type Query struct {
+	// Filters restrict the page to entities whose field equals the value. Fields that are not returned by
+	// FilterFields are matched against the top-level properties of the persisted entity.
+	Filters []Filter
+	// MinFilters restrict the page to entities whose field is greater than or equal to the value, compared as strings.
+	MinFilters []Filter
+	// Sort is the field to order by. A leading "-" sorts in descending order. The ID is always used as a
+	// tiebreaker so that paging is stable.
+	Sort string
+	// Limit is the maximum number of entities in the page. Zero means no limit.
+	Limit int
+	// Cursor is the opaque value returned with the previous page.
+	Cursor string
+}
+
+var queryFieldRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ErrInvalidCursor is returned by PersistenceService.ReadPage when the cursor was not returned with a previous page.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Validate validates the query.
+func (q *Query) Validate() error {
+	for _, fs := range [][]Filter{q.Filters, q.MinFilters} {
+		for _, f := range fs {
+			if !queryFieldRegexp.MatchString(f.Field) {
+				return fmt.Errorf("filter field %q is invalid", f.Field)
+			}
+		}
+	}
+	if q.Sort != "" && !queryFieldRegexp.MatchString(strings.TrimPrefix(q.Sort, "-")) {
+		return fmt.Errorf("sort field %q is invalid", q.Sort)
+	}
+	if q.Limit < 0 || q.Limit > MaxPageLimit {
+		return fmt.Errorf("limit must be in [0..%d]", MaxPageLimit)
+	}
+
+	return nil
+}
+
+// EncodeCursor encodes the sort value and ID of the last entity of a page as an opaque cursor.
+func EncodeCursor(sortVal, id string) string {
+	bytes, _ := json.Marshal([]string{sortVal, id})
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+// DecodeCursor decodes a cursor returned by EncodeCursor. It returns ErrInvalidCursor if the cursor is malformed.
+func DecodeCursor(cursor string) (sortVal, id string, err error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", ErrInvalidCursor
+	}
+
+	var vals []string
+	if err = json.Unmarshal(bytes, &vals); err != nil || len(vals) != 2 {
+		return "", "", ErrInvalidCursor
+	}
+
+	return vals[0], vals[1], nil
+}
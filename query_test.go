@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+)
+
+var _ = Describe("Query", func() {
+	var (
+		q *cce.Query
+	)
+
+	BeforeEach(func() {
+		q = &cce.Query{
+			Filters: []cce.Filter{
+				{
+					Field: "location",
+					Value: "test-location",
+				},
+			},
+			Sort:   "-name",
+			Limit:  10,
+			Cursor: "WyJhIiwiYiJd",
+		}
+	})
+
+	Describe("Validate", func() {
+		It("Should not return an error for a valid query", func() {
+			Expect(q.Validate()).To(Succeed())
+		})
+
+		It("Should not return an error for an empty query", func() {
+			Expect((&cce.Query{}).Validate()).To(Succeed())
+		})
+
+		It("Should return an error if a filter field is invalid", func() {
+			q.Filters[0].Field = "name') OR ('1'='1"
+			Expect(q.Validate()).To(MatchError(
+				`filter field "name') OR ('1'='1" is invalid`))
+		})
+
+		It("Should return an error if a min filter field is invalid", func() {
+			q.MinFilters = []cce.Filter{{Field: "Name", Value: "a"}}
+			Expect(q.Validate()).To(MatchError(`filter field "Name" is invalid`))
+		})
+
+		It("Should return an error if the sort field is invalid", func() {
+			q.Sort = "--name"
+			Expect(q.Validate()).To(MatchError(`sort field "--name" is invalid`))
+		})
+
+		It("Should return an error if the limit is negative", func() {
+			q.Limit = -1
+			Expect(q.Validate()).To(MatchError("limit must be in [0..1000]"))
+		})
+
+		It("Should return an error if the limit is too large", func() {
+			q.Limit = cce.MaxPageLimit + 1
+			Expect(q.Validate()).To(MatchError("limit must be in [0..1000]"))
+		})
+	})
+	Describe("DecodeCursor", func() {
+		It("Should decode a cursor returned by EncodeCursor", func() {
+			sortVal, id, err := cce.DecodeCursor(cce.EncodeCursor("a", "b"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sortVal).To(Equal("a"))
+			Expect(id).To(Equal("b"))
+		})
+
+		It("Should return an error if the cursor is malformed", func() {
+			_, _, err := cce.DecodeCursor("not a cursor")
+			Expect(err).To(Equal(cce.ErrInvalidCursor))
+		})
+	})
+})
@@ -0,0 +1,442 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+// Package reconcile converges the actual state of the nodes with their
+// desired state in the controller DB.
+package reconcile
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	logger "github.com/open-ness/common/log"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/grpc/node"
+	"github.com/open-ness/edgecontroller/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var log = logger.DefaultLogger.WithField("pkg", "reconcile")
+
+// Defaults of a Reconciler created by NewReconciler
+const (
+	DefaultInterval    = 5 * time.Minute
+	DefaultTimeout     = time.Minute
+	DefaultConcurrency = 8
+)
+
+// Reconciler periodically compares the desired state of every node with its
+// actual state and converges them:
+//
+//   - The apps of the node are deployed unless the EVA returns their status.
+//     Apps are only reconciled in native orchestration mode.
+//   - The interfaces of the interface policies must exist on the node. Missing
+//     interfaces cannot be repaired.
+//   - The interface policies, app policies and DNS config cannot be read from
+//     the node, so they are applied again on every pass. They are reported as
+//     drift if applying them fails.
+//
+// The outcome is stored as the cce.NodeSync of the node.
+type Reconciler struct {
+	Controller *cce.Controller
+	// PersistenceService reads the desired state and stores the sync status.
+	// It should not be audited, as every pass updates the status of every
+	// node.
+	PersistenceService cce.PersistenceService
+
+	// Interval is the interval in which all nodes are reconciled
+	Interval time.Duration
+	// Timeout bounds the reconciliation of a node
+	Timeout time.Duration
+	// Concurrency is the number of nodes reconciled at once
+	Concurrency int
+
+	// Dial connects to a service of a node. It is node.Connect with the
+	// PersistenceService and the Controller's EdgeNodeCreds by default, and
+	// should be the Get of the node.Pool shared with the HTTP API.
+	Dial func(ctx context.Context, nodeID string, svc node.Service) (*node.ClientConn, error)
+}
+
+// NewReconciler creates a Reconciler with the default settings.
+func NewReconciler(ctrl *cce.Controller, ps cce.PersistenceService) *Reconciler {
+	r := &Reconciler{
+		Controller:         ctrl,
+		PersistenceService: ps,
+		Interval:           DefaultInterval,
+		Timeout:            DefaultTimeout,
+		Concurrency:        DefaultConcurrency,
+	}
+	r.Dial = func(ctx context.Context, nodeID string, svc node.Service) (*node.ClientConn, error) {
+		return node.Connect(ctx, r.PersistenceService, nodeID, svc, r.Controller.EdgeNodeCreds)
+	}
+
+	return r
+}
+
+// Run reconciles all nodes every Interval until ctx is done.
+func (r *Reconciler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := r.ReconcileAll(ctx); err != nil {
+			log.Errf("Error reconciling nodes: %v", err)
+		}
+	}
+}
+
+// ReconcileAll reconciles all nodes, Concurrency at a time.
+func (r *Reconciler) ReconcileAll(ctx context.Context) error {
+	nodes, err := r.PersistenceService.ReadAll(ctx, &cce.Node{})
+	if err != nil {
+		return errors.Wrap(err, "error reading nodes")
+	}
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, r.Concurrency)
+	)
+	for _, e := range nodes {
+		nodeID := e.GetID()
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if _, err := r.ReconcileNode(ctx, nodeID); err != nil {
+				log.Errf("Error reconciling node %s: %v", nodeID, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// ReconcileNode reconciles a node and stores and returns its sync status. An
+// error is only returned if the status could not be determined or stored.
+func (r *Reconciler) ReconcileNode(ctx context.Context, nodeID string) (*cce.NodeSync, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	existing, err := r.PersistenceService.Filter(ctx, &cce.NodeSync{}, []cce.Filter{{
+		Field: "node_id",
+		Value: nodeID,
+	}})
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading node sync")
+	}
+	ns := &cce.NodeSync{ID: uuid.New(), NodeID: nodeID}
+	if len(existing) > 0 {
+		ns = existing[0].(*cce.NodeSync)
+	}
+	desired, err := r.readDesired(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	ns.CheckedAt = cce.AuditTimestamp(time.Now())
+	ns.Error = ""
+	ns.Drift = nil
+
+	if err = r.reconcile(ctx, ns, desired); err != nil {
+		ns.Status = cce.NodeSyncUnreachable
+		ns.Error = err.Error()
+		log.Noticef("Node %s unreachable: %v", nodeID, err)
+	} else {
+		ns.Status = syncStatus(ns.Drift)
+		if ns.Status != cce.NodeSyncDrifted {
+			ns.SyncedAt = ns.CheckedAt
+		}
+	}
+	if ns.Status == cce.NodeSyncConverged || ns.Status == cce.NodeSyncDrifted {
+		log.Infof("Node %s %s: %v", nodeID, ns.Status, ns.Drift)
+	}
+
+	if len(existing) > 0 {
+		err = r.PersistenceService.BulkUpdate(ctx, []cce.Persistable{ns})
+	} else {
+		err = r.PersistenceService.Create(ctx, ns)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error storing node sync")
+	}
+
+	return ns, nil
+}
+
+// syncStatus returns the status of a node with drift.
+func syncStatus(drift []cce.NodeDrift) string {
+	if len(drift) == 0 {
+		return cce.NodeSyncInSync
+	}
+	for _, d := range drift {
+		if !d.Repaired {
+			return cce.NodeSyncDrifted
+		}
+	}
+
+	return cce.NodeSyncConverged
+}
+
+// reconcile connects to the node and converges it with its desired state,
+// recording the drift in ns. An error is returned if the node cannot be
+// connected to.
+func (r *Reconciler) reconcile(ctx context.Context, ns *cce.NodeSync, desired *desiredState) error {
+	ela, err := r.Dial(ctx, ns.NodeID, node.ELA)
+	if err != nil {
+		return err
+	}
+	defer ela.Disconnect()
+
+	if r.Controller.OrchestrationMode == cce.OrchestrationModeNative {
+		var eva *node.ClientConn
+		if eva, err = r.Dial(ctx, ns.NodeID, node.EVA); err != nil {
+			return err
+		}
+		defer eva.Disconnect()
+
+		if err = r.reconcileApps(ctx, ns, eva, desired.apps); err != nil {
+			return err
+		}
+	}
+
+	nis, err := ela.IfaceSvcCli.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	interfaces := make(map[string]bool)
+	for _, ni := range nis {
+		interfaces[ni.ID] = true
+	}
+
+	for _, p := range desired.interfacePolicies {
+		if !interfaces[p.id] {
+			ns.Drift = append(ns.Drift, cce.NodeDrift{
+				Resource: cce.DriftResourceInterface,
+				ID:       p.id,
+				Reason:   "not found on node",
+			})
+			continue
+		}
+		apply(ns, cce.DriftResourceInterfacePolicy, p.id, func() error {
+			return ela.IfacePolicySvcCli.Set(ctx, p.id, p.policy)
+		})
+	}
+	for _, p := range desired.appPolicies {
+		apply(ns, cce.DriftResourceAppPolicy, p.id, func() error {
+			return ela.AppPolicySvcCli.Set(ctx, p.id, p.policy)
+		})
+	}
+	if desired.dns != nil {
+		apply(ns, cce.DriftResourceDNS, desired.dns.ID, func() error {
+			for _, record := range desired.dns.ARecords {
+				if err := ela.DNSSvcCli.SetA(ctx, record); err != nil {
+					return err
+				}
+			}
+			if len(desired.dns.Forwarders) == 0 {
+				return nil
+			}
+			return ela.DNSSvcCli.SetForwarders(ctx, desired.dns.Forwarders)
+		})
+	}
+
+	return nil
+}
+
+// reconcileApps deploys the apps of a node whose status the EVA does not
+// know. An error is only returned if the EVA cannot be reached.
+func (r *Reconciler) reconcileApps(
+	ctx context.Context,
+	ns *cce.NodeSync,
+	eva *node.ClientConn,
+	apps []*cce.App,
+) error {
+	for _, app := range apps {
+		_, err := eva.AppLifeSvcCli.GetStatus(ctx, app.ID)
+		switch status.Code(errors.Cause(err)) {
+		case codes.OK:
+			continue
+		case codes.NotFound:
+		case codes.Unavailable, codes.DeadlineExceeded:
+			return err
+		default:
+			ns.Drift = append(ns.Drift, cce.NodeDrift{
+				Resource: cce.DriftResourceApp,
+				ID:       app.ID,
+				Reason:   "status unknown",
+				Error:    err.Error(),
+			})
+			continue
+		}
+
+		drift := cce.NodeDrift{
+			Resource: cce.DriftResourceApp,
+			ID:       app.ID,
+			Reason:   "not deployed",
+		}
+		if err = eva.AppDeploySvcCli.Deploy(ctx, app); err != nil {
+			drift.Error = err.Error()
+		} else {
+			drift.Repaired = true
+		}
+		ns.Drift = append(ns.Drift, drift)
+	}
+
+	return nil
+}
+
+// apply applies a write-only resource to the node. It is reported as drift
+// if applying it fails.
+func apply(ns *cce.NodeSync, resource, id string, set func() error) {
+	if err := set(); err != nil {
+		ns.Drift = append(ns.Drift, cce.NodeDrift{
+			Resource: resource,
+			ID:       id,
+			Reason:   "apply failed",
+			Error:    err.Error(),
+		})
+	}
+}
+
+// policy is a traffic policy of an interface or app.
+type policy struct {
+	id     string
+	policy *cce.TrafficPolicy
+}
+
+// desiredState is the state of a node in the DB.
+type desiredState struct {
+	apps              []*cce.App
+	interfacePolicies []policy
+	appPolicies       []policy
+	dns               *cce.DNSConfig
+}
+
+// readDesired reads the desired state of a node from the DB.
+func (r *Reconciler) readDesired(ctx context.Context, nodeID string) (*desiredState, error) {
+	ps := r.PersistenceService
+	desired := &desiredState{}
+	byNode := []cce.Filter{{Field: "node_id", Value: nodeID}}
+
+	nodeApps, err := ps.Filter(ctx, &cce.NodeApp{}, byNode)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading node apps")
+	}
+	for _, e := range nodeApps {
+		nodeApp := e.(*cce.NodeApp)
+		var app cce.Persistable
+		if app, err = ps.Read(ctx, nodeApp.AppID, &cce.App{}); err != nil {
+			return nil, errors.Wrap(err, "error reading app")
+		}
+		if app != nil {
+			desired.apps = append(desired.apps, app.(*cce.App))
+		}
+
+		var nodeAppPolicies []cce.Persistable
+		if nodeAppPolicies, err = ps.Filter(ctx, &cce.NodeAppTrafficPolicy{}, []cce.Filter{{
+			Field: "nodes_apps_id",
+			Value: nodeApp.ID,
+		}}); err != nil {
+			return nil, errors.Wrap(err, "error reading app policies")
+		}
+		for _, p := range nodeAppPolicies {
+			var tp *cce.TrafficPolicy
+			if tp, err = r.readPolicy(ctx, p.(*cce.NodeAppTrafficPolicy).TrafficPolicyID); err != nil {
+				return nil, err
+			}
+			desired.appPolicies = append(desired.appPolicies, policy{id: nodeApp.AppID, policy: tp})
+		}
+	}
+
+	nodeInterfacePolicies, err := ps.Filter(ctx, &cce.NodeInterfaceTrafficPolicy{}, byNode)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading interface policies")
+	}
+	for _, e := range nodeInterfacePolicies {
+		p := e.(*cce.NodeInterfaceTrafficPolicy)
+		var tp *cce.TrafficPolicy
+		if tp, err = r.readPolicy(ctx, p.TrafficPolicyID); err != nil {
+			return nil, err
+		}
+		desired.interfacePolicies = append(desired.interfacePolicies, policy{id: p.NetworkInterfaceID, policy: tp})
+	}
+	sort.Slice(desired.interfacePolicies, func(i, j int) bool {
+		return desired.interfacePolicies[i].id < desired.interfacePolicies[j].id
+	})
+
+	if desired.dns, err = r.readDNS(ctx, byNode); err != nil {
+		return nil, err
+	}
+
+	return desired, nil
+}
+
+// readPolicy reads a traffic policy. An empty policy is returned if it does
+// not exist, like the handlers set it.
+func (r *Reconciler) readPolicy(ctx context.Context, id string) (*cce.TrafficPolicy, error) {
+	tp, err := r.PersistenceService.Read(ctx, id, &cce.TrafficPolicy{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading traffic policy")
+	}
+	if tp == nil {
+		return &cce.TrafficPolicy{}, nil
+	}
+
+	return tp.(*cce.TrafficPolicy), nil
+}
+
+// readDNS reads the DNS config of a node with the A records of its app
+// aliases, or nil if it has none.
+func (r *Reconciler) readDNS(ctx context.Context, byNode []cce.Filter) (*cce.DNSConfig, error) {
+	ps := r.PersistenceService
+	nodeDNS, err := ps.Filter(ctx, &cce.NodeDNSConfig{}, byNode)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading node DNS config")
+	}
+	if len(nodeDNS) == 0 {
+		return nil, nil
+	}
+
+	e, err := ps.Read(ctx, nodeDNS[0].(*cce.NodeDNSConfig).DNSConfigID, &cce.DNSConfig{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading DNS config")
+	}
+	if e == nil {
+		return nil, nil
+	}
+	persisted := e.(*cce.DNSConfig)
+
+	aliases, err := ps.Filter(ctx, &cce.DNSConfigAppAlias{}, []cce.Filter{{
+		Field: "dns_config_id",
+		Value: persisted.ID,
+	}})
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading DNS aliases")
+	}
+
+	// Apply the A records of the aliases first, like the handlers do
+	dns := &cce.DNSConfig{ID: persisted.ID, Name: persisted.Name, Forwarders: persisted.Forwarders}
+	for _, alias := range aliases {
+		dns.ARecords = append(dns.ARecords, &cce.DNSARecord{
+			Name:        alias.(*cce.DNSConfigAppAlias).AppID,
+			Description: alias.(*cce.DNSConfigAppAlias).Description,
+			IPs:         []string{alias.(*cce.DNSConfigAppAlias).AppID},
+		})
+	}
+	dns.ARecords = append(dns.ARecords, persisted.ARecords...)
+
+	return dns, nil
+}
@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package reconcile_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	gclients "github.com/open-ness/edgecontroller/grpc/clients"
+	"github.com/open-ness/edgecontroller/grpc/node"
+	"github.com/open-ness/edgecontroller/memory"
+	ctrlgmock "github.com/open-ness/edgecontroller/mock/controller/grpc"
+	nodegmock "github.com/open-ness/edgecontroller/mock/node/grpc"
+	"github.com/open-ness/edgecontroller/reconcile"
+)
+
+var _ = Describe("Reconciler", func() {
+	const nodeID = "9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f"
+
+	var (
+		ctx        = context.Background()
+		ps         cce.PersistenceService
+		mockNode   *nodegmock.MockNode
+		reconciler *reconcile.Reconciler
+		dialErr    error
+	)
+
+	BeforeEach(func() {
+		var err error
+		ps, err = memory.NewPersistenceService("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ps.Create(ctx, &cce.Node{
+			ID:       nodeID,
+			Name:     "node123",
+			Location: "Localhost port 42101",
+			Serial:   "ABCD",
+		})).To(Succeed())
+
+		mockNode = nodegmock.NewMockNode()
+		dialErr = nil
+		reconciler = reconcile.NewReconciler(&cce.Controller{
+			OrchestrationMode: cce.OrchestrationModeNative,
+		}, ps)
+		reconciler.Dial = func(ctx context.Context, nodeID string, svc node.Service) (*node.ClientConn, error) {
+			if dialErr != nil {
+				return nil, dialErr
+			}
+			return &node.ClientConn{
+				Service: svc,
+				AppDeploySvcCli: &gclients.ApplicationDeploymentServiceClient{
+					PBCli: &ctrlgmock.MockPBApplicationDeploymentServiceClient{MockNode: mockNode},
+				},
+				AppLifeSvcCli: &gclients.ApplicationLifecycleServiceClient{
+					PBCli: &ctrlgmock.MockPBApplicationLifecycleServiceClient{MockNode: mockNode},
+				},
+				AppPolicySvcCli: &gclients.ApplicationPolicyServiceClient{
+					PBCli: &ctrlgmock.MockPBApplicationPolicyServiceClient{MockNode: mockNode},
+				},
+				IfacePolicySvcCli: &gclients.InterfacePolicyServiceClient{
+					PBCli: &ctrlgmock.MockPBInterfacePolicyServiceClient{MockNode: mockNode},
+				},
+				IfaceSvcCli: &gclients.InterfaceServiceClient{
+					PBCli: &ctrlgmock.MockPBInterfaceServiceClient{MockNode: mockNode},
+				},
+				DNSSvcCli: &gclients.DNSServiceClient{
+					PBCli: &ctrlgmock.MockPBDNSServiceClient{MockNode: mockNode},
+				},
+			}, nil
+		}
+	})
+
+	createApp := func() *cce.App {
+		app := &cce.App{
+			ID:          "3e4b7a1c-5d2f-4e8a-9b6c-1a2d3e4f5a6b",
+			Type:        "container",
+			Name:        "app123",
+			Version:     "1.0",
+			Vendor:      "vendor",
+			Description: "description",
+			Cores:       4,
+			Memory:      1024,
+			Source:      "http://www.test.com/my_file.tar.gz",
+		}
+		Expect(ps.Create(ctx, app)).To(Succeed())
+		Expect(ps.Create(ctx, &cce.NodeApp{
+			ID:     "7c6b5a4d-3e2f-4a1b-8c9d-0e1f2a3b4c5d",
+			NodeID: nodeID,
+			AppID:  app.ID,
+		})).To(Succeed())
+		return app
+	}
+
+	createInterfacePolicy := func(interfaceID string) {
+		Expect(ps.Create(ctx, &cce.TrafficPolicy{
+			ID:   "5a4b3c2d-1e0f-4a9b-8c7d-6e5f4a3b2c1d",
+			Name: "policy123",
+		})).To(Succeed())
+		Expect(ps.Create(ctx, &cce.NodeInterfaceTrafficPolicy{
+			ID:                 "1d2c3b4a-5f6e-4d7c-8b9a-0f1e2d3c4b5a",
+			NodeID:             nodeID,
+			NetworkInterfaceID: interfaceID,
+			TrafficPolicyID:    "5a4b3c2d-1e0f-4a9b-8c7d-6e5f4a3b2c1d",
+		})).To(Succeed())
+	}
+
+	Describe("ReconcileNode", func() {
+		It("Should report a node matching its desired state as in sync", func() {
+			ns, err := reconciler.ReconcileNode(ctx, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ns.Status).To(Equal(cce.NodeSyncInSync))
+			Expect(ns.Drift).To(BeEmpty())
+			Expect(ns.SyncedAt).To(Equal(ns.CheckedAt))
+		})
+
+		It("Should deploy an app missing on the node", func() {
+			app := createApp()
+
+			ns, err := reconciler.ReconcileNode(ctx, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ns.Status).To(Equal(cce.NodeSyncConverged))
+			Expect(ns.Drift).To(Equal([]cce.NodeDrift{{
+				Resource: cce.DriftResourceApp,
+				ID:       app.ID,
+				Reason:   "not deployed",
+				Repaired: true,
+			}}))
+
+			By("Reconciling the node again")
+			ns, err = reconciler.ReconcileNode(ctx, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ns.Status).To(Equal(cce.NodeSyncInSync))
+		})
+
+		It("Should not reconcile apps in Kubernetes mode", func() {
+			reconciler.Controller.OrchestrationMode = cce.OrchestrationModeKubernetes
+			createApp()
+
+			ns, err := reconciler.ReconcileNode(ctx, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ns.Status).To(Equal(cce.NodeSyncInSync))
+		})
+
+		It("Should apply the interface policies", func() {
+			createInterfacePolicy("if0")
+
+			ns, err := reconciler.ReconcileNode(ctx, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ns.Status).To(Equal(cce.NodeSyncInSync))
+		})
+
+		It("Should report an interface missing on the node as drift", func() {
+			createInterfacePolicy("if9")
+
+			ns, err := reconciler.ReconcileNode(ctx, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ns.Status).To(Equal(cce.NodeSyncDrifted))
+			Expect(ns.SyncedAt).To(BeEmpty())
+			Expect(ns.Drift).To(Equal([]cce.NodeDrift{{
+				Resource: cce.DriftResourceInterface,
+				ID:       "if9",
+				Reason:   "not found on node",
+			}}))
+		})
+
+		It("Should report a node that cannot be connected to as unreachable", func() {
+			dialErr = errors.New("connection refused")
+
+			ns, err := reconciler.ReconcileNode(ctx, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ns.Status).To(Equal(cce.NodeSyncUnreachable))
+			Expect(ns.Error).To(Equal("connection refused"))
+		})
+
+		It("Should store the sync status of the node", func() {
+			_, err := reconciler.ReconcileNode(ctx, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+			dialErr = errors.New("connection refused")
+			ns, err := reconciler.ReconcileNode(ctx, nodeID)
+			Expect(err).ToNot(HaveOccurred())
+
+			syncs, err := ps.Filter(ctx, &cce.NodeSync{}, []cce.Filter{{Field: "node_id", Value: nodeID}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(syncs).To(Equal([]cce.Persistable{ns}))
+			Expect(ns.SyncedAt).ToNot(BeEmpty())
+		})
+	})
+
+	Describe("ReconcileAll", func() {
+		It("Should reconcile every node", func() {
+			Expect(reconciler.ReconcileAll(ctx)).To(Succeed())
+
+			syncs, err := ps.ReadAll(ctx, &cce.NodeSync{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(syncs).To(HaveLen(1))
+			Expect(syncs[0].(*cce.NodeSync).Status).To(Equal(cce.NodeSyncInSync))
+		})
+	})
+})
@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-ness/edgecontroller/uuid"
+	"golang.org/x/crypto/ocsp"
+)
+
+// Certificate revocation reasons, see RFC 5280 section 5.3.1
+const (
+	RevocationReasonUnspecified          = "unspecified"
+	RevocationReasonKeyCompromise        = "key_compromise"
+	RevocationReasonSuperseded           = "superseded"
+	RevocationReasonCessationOfOperation = "cessation_of_operation"
+)
+
+// oidReasonCode is the OID of the reason code CRL entry extension.
+var oidReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+
+// revocationReasonCodes maps the revocation reasons to their CRL reason codes.
+var revocationReasonCodes = map[string]int{
+	RevocationReasonUnspecified:          ocsp.Unspecified,
+	RevocationReasonKeyCompromise:        ocsp.KeyCompromise,
+	RevocationReasonSuperseded:           ocsp.Superseded,
+	RevocationReasonCessationOfOperation: ocsp.CessationOfOperation,
+}
+
+// RevokedCertificate is a revoked node certificate. The serial is the
+// lower-case hexadecimal serial number of the certificate.
+type RevokedCertificate struct {
+	ID        string `json:"id"`
+	Serial    string `json:"serial"`
+	NodeID    string `json:"node_id"`
+	Reason    string `json:"reason"`
+	RevokedAt string `json:"revoked_at"`
+	NotAfter  string `json:"not_after"`
+}
+
+// GetTableName returns the name of the persistence table.
+func (*RevokedCertificate) GetTableName() string {
+	return "revoked_certificates"
+}
+
+// GetID gets the ID.
+func (c *RevokedCertificate) GetID() string {
+	return c.ID
+}
+
+// SetID sets the ID.
+func (c *RevokedCertificate) SetID(id string) {
+	c.ID = id
+}
+
+// GetNodeID gets the node ID.
+func (c *RevokedCertificate) GetNodeID() string {
+	return c.NodeID
+}
+
+// FilterFields returns the filterable fields for this model.
+func (*RevokedCertificate) FilterFields() []string {
+	return []string{
+		"serial",
+		"node_id",
+	}
+}
+
+// Validate validates the model.
+func (c *RevokedCertificate) Validate() error {
+	if !uuid.IsValid(c.ID) {
+		return errors.New("id not a valid uuid")
+	}
+	if _, ok := new(big.Int).SetString(c.Serial, 16); !ok || c.Serial != strings.ToLower(c.Serial) {
+		return errors.New("serial not a lower-case hexadecimal number")
+	}
+	if err := ValidateRevocationReason(c.Reason); err != nil {
+		return err
+	}
+	if _, err := time.Parse(time.RFC3339, c.RevokedAt); err != nil {
+		return errors.New("revoked_at not a valid RFC 3339 timestamp")
+	}
+	if _, err := time.Parse(time.RFC3339, c.NotAfter); err != nil {
+		return errors.New("not_after not a valid RFC 3339 timestamp")
+	}
+
+	return nil
+}
+
+// ReasonCode returns the CRL reason code of the revocation.
+func (c *RevokedCertificate) ReasonCode() int {
+	return revocationReasonCodes[c.Reason]
+}
+
+func (c *RevokedCertificate) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+RevokedCertificate[
+    ID: %s
+    Serial: %s
+    NodeID: %s
+    Reason: %s
+    RevokedAt: %s
+    NotAfter: %s
+]`),
+		c.ID,
+		c.Serial,
+		c.NodeID,
+		c.Reason,
+		c.RevokedAt,
+		c.NotAfter)
+}
+
+// RevocationReasons returns the valid revocation reasons in sorted order.
+func RevocationReasons() []string {
+	reasons := make([]string, 0, len(revocationReasonCodes))
+	for reason := range revocationReasonCodes {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	return reasons
+}
+
+// ValidateRevocationReason validates a revocation reason.
+func ValidateRevocationReason(reason string) error {
+	if _, ok := revocationReasonCodes[reason]; !ok {
+		return fmt.Errorf("reason must be one of %s", strings.Join(RevocationReasons(), ", "))
+	}
+	return nil
+}
+
+// CertificateRevocations is the persisted list of revoked node certificates.
+// It is checked when nodes connect and published as a CRL and by an OCSP
+// responder.
+type CertificateRevocations struct {
+	PersistenceService PersistenceService
+}
+
+// Revoke revokes a certificate of a node. Revoking a revoked certificate
+// returns its existing revocation.
+func (r *CertificateRevocations) Revoke(
+	ctx context.Context,
+	cert *x509.Certificate,
+	nodeID, reason string,
+) (*RevokedCertificate, error) {
+	revoked, err := r.Lookup(ctx, cert.SerialNumber)
+	if err != nil || revoked != nil {
+		return revoked, err
+	}
+
+	revoked = &RevokedCertificate{
+		ID:        uuid.New(),
+		Serial:    cert.SerialNumber.Text(16),
+		NodeID:    nodeID,
+		Reason:    reason,
+		RevokedAt: time.Now().UTC().Format(time.RFC3339),
+		NotAfter:  cert.NotAfter.UTC().Format(time.RFC3339),
+	}
+	if err = revoked.Validate(); err != nil {
+		return nil, err
+	}
+	if err = r.PersistenceService.Create(ctx, revoked); err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+// RevokeNodeCredentials revokes the certificate issued to a node when it
+// enrolled and deletes its credentials, so that the node can enroll again if
+// it is still approved. It returns nil if the node has no credentials.
+func (r *CertificateRevocations) RevokeNodeCredentials(
+	ctx context.Context,
+	nodeID, reason string,
+) (*RevokedCertificate, error) {
+	persisted, err := r.PersistenceService.Read(ctx, nodeID, &Credentials{})
+	if err != nil || persisted == nil {
+		return nil, err
+	}
+
+	cert, err := parseCredentialsCertificate(persisted.(*Credentials))
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := r.Revoke(ctx, cert, nodeID, reason)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = r.PersistenceService.Delete(ctx, nodeID, &Credentials{}); err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+// ReplaceNodeCredentials stores the credentials of a node, whose ID is the
+// node ID. The certificate of the credentials they replace is revoked as
+// superseded, so that a node only ever has one valid certificate.
+func (r *CertificateRevocations) ReplaceNodeCredentials(ctx context.Context, creds *Credentials) error {
+	persisted, err := r.PersistenceService.Read(ctx, creds.ID, &Credentials{})
+	if err != nil {
+		return err
+	}
+	if persisted == nil {
+		return r.PersistenceService.Create(ctx, creds)
+	}
+
+	cert, err := parseCredentialsCertificate(persisted.(*Credentials))
+	if err != nil {
+		return err
+	}
+	if _, err = r.Revoke(ctx, cert, creds.ID, RevocationReasonSuperseded); err != nil {
+		return err
+	}
+
+	return r.PersistenceService.BulkUpdate(ctx, []Persistable{creds})
+}
+
+func parseCredentialsCertificate(creds *Credentials) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(creds.Certificate))
+	if block == nil {
+		return nil, errors.New("credentials certificate not PEM-encoded")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// Lookup returns the revocation of the certificate with the given serial
+// number, or nil if it has not been revoked.
+func (r *CertificateRevocations) Lookup(ctx context.Context, serial *big.Int) (*RevokedCertificate, error) {
+	ps, err := r.PersistenceService.Filter(
+		ctx,
+		&RevokedCertificate{},
+		[]Filter{{Field: "serial", Value: serial.Text(16)}},
+	)
+	if err != nil || len(ps) == 0 {
+		return nil, err
+	}
+
+	return ps[0].(*RevokedCertificate), nil
+}
+
+// RevocationList returns the entries of the CRL: the revoked certificates
+// that have not expired yet.
+func (r *CertificateRevocations) RevocationList(ctx context.Context, now time.Time) ([]pkix.RevokedCertificate, error) {
+	ps, err := r.PersistenceService.ReadAll(ctx, &RevokedCertificate{})
+	if err != nil {
+		return nil, err
+	}
+
+	list := []pkix.RevokedCertificate{}
+	for _, p := range ps {
+		c := p.(*RevokedCertificate)
+		notAfter, perr := time.Parse(time.RFC3339, c.NotAfter)
+		if perr == nil && notAfter.Before(now) {
+			continue
+		}
+		serial, _ := new(big.Int).SetString(c.Serial, 16)
+		revokedAt, _ := time.Parse(time.RFC3339, c.RevokedAt)
+		entry := pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: revokedAt}
+
+		// The reason code is omitted if unspecified, see RFC 5280 section 5.3.1
+		if code := c.ReasonCode(); code != ocsp.Unspecified {
+			value, merr := asn1.Marshal(asn1.Enumerated(code))
+			if merr != nil {
+				return nil, merr
+			}
+			entry.Extensions = []pkix.Extension{{Id: oidReasonCode, Value: value}}
+		}
+		list = append(list, entry)
+	}
+
+	return list, nil
+}
+
+// VerifyPeerCertificate rejects TLS peers whose verified chains contain a
+// revoked certificate. It is meant to be set as the VerifyPeerCertificate
+// function of a tls.Config requiring verified client certificates.
+func (r *CertificateRevocations) VerifyPeerCertificate(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+	for _, chain := range verifiedChains {
+		for _, cert := range chain {
+			revoked, err := r.Lookup(context.Background(), cert.SerialNumber)
+			if err != nil {
+				return fmt.Errorf("unable to check revocation of certificate %x: %v", cert.SerialNumber, err)
+			}
+			if revoked != nil {
+				return fmt.Errorf("certificate %s of node %s has been revoked", revoked.Serial, revoked.NodeID)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/memory"
+)
+
+var _ = Describe("Entities: RevokedCertificate", func() {
+	var (
+		c *cce.RevokedCertificate
+	)
+
+	BeforeEach(func() {
+		c = &cce.RevokedCertificate{
+			ID:        "0f1c3c1e-4b3a-4d8e-9d65-3d0b5f1b2a6e",
+			Serial:    "5c3b8e1f2a",
+			NodeID:    "9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f",
+			Reason:    cce.RevocationReasonKeyCompromise,
+			RevokedAt: "2020-03-01T12:00:00Z",
+			NotAfter:  "2023-03-01T12:00:00Z",
+		}
+	})
+
+	Describe("GetTableName", func() {
+		It(`Should return "revoked_certificates"`, func() {
+			Expect(c.GetTableName()).To(Equal("revoked_certificates"))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("Should not return an error for a valid revoked certificate", func() {
+			Expect(c.Validate()).To(Succeed())
+		})
+
+		It("Should return an error for an invalid ID", func() {
+			c.ID = "123"
+			Expect(c.Validate()).To(MatchError("id not a valid uuid"))
+		})
+
+		It("Should return an error for an invalid serial", func() {
+			c.Serial = "5C3B"
+			Expect(c.Validate()).To(MatchError("serial not a lower-case hexadecimal number"))
+		})
+
+		It("Should return an error for an unknown reason", func() {
+			c.Reason = "bored"
+			Expect(c.Validate()).To(MatchError(
+				"reason must be one of cessation_of_operation, key_compromise, superseded, unspecified"))
+		})
+
+		It("Should return an error for an invalid revocation time", func() {
+			c.RevokedAt = "yesterday"
+			Expect(c.Validate()).To(MatchError("revoked_at not a valid RFC 3339 timestamp"))
+		})
+	})
+
+	Describe("CertificateRevocations", func() {
+		var (
+			ctx         = context.Background()
+			ps          cce.PersistenceService
+			revocations *cce.CertificateRevocations
+			cert        *x509.Certificate
+		)
+
+		BeforeEach(func() {
+			var err error
+			ps, err = memory.NewPersistenceService("")
+			Expect(err).ToNot(HaveOccurred())
+			revocations = &cce.CertificateRevocations{PersistenceService: ps}
+			cert = newCertificate(big.NewInt(0x5c3b8e1f2a), time.Now().Add(time.Hour))
+		})
+
+		It("Should revoke a certificate once", func() {
+			revoked, err := revocations.Revoke(ctx, cert, c.NodeID, cce.RevocationReasonKeyCompromise)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revoked.Serial).To(Equal("5c3b8e1f2a"))
+			Expect(revoked.NodeID).To(Equal(c.NodeID))
+
+			again, err := revocations.Revoke(ctx, cert, c.NodeID, cce.RevocationReasonUnspecified)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(again).To(Equal(revoked))
+
+			Expect(revocations.Lookup(ctx, cert.SerialNumber)).To(Equal(revoked))
+			Expect(revocations.Lookup(ctx, big.NewInt(1))).To(BeNil())
+		})
+
+		It("Should revoke the certificate of node credentials and delete them", func() {
+			Expect(ps.Create(ctx, &cce.Credentials{
+				ID:          c.NodeID,
+				Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+			})).To(Succeed())
+
+			revoked, err := revocations.RevokeNodeCredentials(ctx, c.NodeID, cce.RevocationReasonSuperseded)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revoked.Serial).To(Equal("5c3b8e1f2a"))
+			Expect(ps.Read(ctx, c.NodeID, &cce.Credentials{})).To(BeNil())
+
+			By("Verifying nothing is revoked without credentials")
+			Expect(revocations.RevokeNodeCredentials(ctx, c.NodeID, cce.RevocationReasonSuperseded)).To(BeNil())
+		})
+
+		It("Should replace node credentials and revoke the superseded certificate", func() {
+			encode := func(cert *x509.Certificate) string {
+				return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+			}
+
+			By("Storing the first credentials of the node")
+			Expect(revocations.ReplaceNodeCredentials(ctx, &cce.Credentials{
+				ID:          c.NodeID,
+				Certificate: encode(cert),
+			})).To(Succeed())
+			Expect(revocations.Lookup(ctx, cert.SerialNumber)).To(BeNil())
+
+			By("Replacing the credentials")
+			renewed := newCertificate(big.NewInt(3), time.Now().Add(time.Hour))
+			Expect(revocations.ReplaceNodeCredentials(ctx, &cce.Credentials{
+				ID:          c.NodeID,
+				Certificate: encode(renewed),
+			})).To(Succeed())
+
+			persisted, err := ps.Read(ctx, c.NodeID, &cce.Credentials{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(persisted.(*cce.Credentials).Certificate).To(Equal(encode(renewed)))
+
+			revoked, err := revocations.Lookup(ctx, cert.SerialNumber)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revoked.Reason).To(Equal(cce.RevocationReasonSuperseded))
+			Expect(revocations.Lookup(ctx, renewed.SerialNumber)).To(BeNil())
+		})
+
+		It("Should list the revoked certificates that have not expired", func() {
+			_, err := revocations.Revoke(ctx, cert, c.NodeID, cce.RevocationReasonKeyCompromise)
+			Expect(err).ToNot(HaveOccurred())
+			expired := newCertificate(big.NewInt(2), time.Now().Add(-time.Hour))
+			_, err = revocations.Revoke(ctx, expired, c.NodeID, cce.RevocationReasonUnspecified)
+			Expect(err).ToNot(HaveOccurred())
+
+			list, err := revocations.RevocationList(ctx, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(list).To(HaveLen(1))
+			Expect(list[0].SerialNumber).To(Equal(cert.SerialNumber))
+			Expect(list[0].Extensions).To(ConsistOf(pkix.Extension{
+				Id:    []int{2, 5, 29, 21},
+				Value: []byte{0x0a, 0x01, 0x01},
+			}))
+		})
+
+		It("Should reject peers with a revoked certificate", func() {
+			chains := [][]*x509.Certificate{{cert}}
+			Expect(revocations.VerifyPeerCertificate(nil, chains)).To(Succeed())
+
+			_, err := revocations.Revoke(ctx, cert, c.NodeID, cce.RevocationReasonKeyCompromise)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revocations.VerifyPeerCertificate(nil, chains)).To(MatchError(
+				"certificate 5c3b8e1f2a of node 9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f has been revoked"))
+		})
+	})
+})
+
+func newCertificate(serial *big.Int, notAfter time.Time) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "node"},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	Expect(err).ToNot(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+	return cert
+}
@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/open-ness/edgecontroller/uuid"
+)
+
+// RevokedToken is a revoked authentication token or session, or the tokens of
+// a subject issued until RevokedAt. It is kept until Expiry, after which the
+// tokens it identifies have expired anyway.
+type RevokedToken struct {
+	ID        string `json:"id"`
+	Expiry    string `json:"expiry"`
+	RevokedAt string `json:"revoked_at,omitempty"`
+}
+
+// GetTableName returns the name of the persistence table.
+func (*RevokedToken) GetTableName() string {
+	return "revoked_tokens"
+}
+
+// GetID gets the ID.
+func (t *RevokedToken) GetID() string {
+	return t.ID
+}
+
+// SetID sets the ID.
+func (t *RevokedToken) SetID(id string) {
+	t.ID = id
+}
+
+// Validate validates the model.
+func (t *RevokedToken) Validate() error {
+	if !uuid.IsValid(t.ID) {
+		return errors.New("id not a valid uuid")
+	}
+	if _, err := time.Parse(time.RFC3339, t.Expiry); err != nil {
+		return errors.New("expiry not a valid RFC 3339 timestamp")
+	}
+	if t.RevokedAt != "" {
+		if _, err := time.Parse(time.RFC3339, t.RevokedAt); err != nil {
+			return errors.New("revoked_at not a valid RFC 3339 timestamp")
+		}
+	}
+
+	return nil
+}
+
+func (t *RevokedToken) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+RevokedToken[
+    ID: %s
+    Expiry: %s
+    RevokedAt: %s
+]`),
+		t.ID,
+		t.Expiry,
+		t.RevokedAt)
+}
+
+// TokenRevocations is the persisted list of revoked token and session IDs
+// checked by the token service. Expired entries are removed whenever a token
+// is revoked.
+type TokenRevocations struct {
+	PersistenceService PersistenceService
+}
+
+// Revoke revokes id until expiry.
+func (r *TokenRevocations) Revoke(id string, expiry time.Time) error {
+	return r.store(&RevokedToken{ID: id, Expiry: expiry.UTC().Format(time.RFC3339)})
+}
+
+// RevokeSubject revokes the tokens of subject issued until now, which all
+// expire by expiry. Revoking them again moves the revocation to now.
+func (r *TokenRevocations) RevokeSubject(subject string, expiry time.Time) error {
+	return r.store(&RevokedToken{
+		ID:        subjectRevocationID(subject),
+		Expiry:    expiry.UTC().Format(time.RFC3339),
+		RevokedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// store stores a revocation, replacing a subject's previous revocation, and
+// removes the expired ones.
+func (r *TokenRevocations) store(t *RevokedToken) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	return r.PersistenceService.WithTx(ctx, func(tx PersistenceService) error {
+		es, err := tx.ReadAll(ctx, &RevokedToken{})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, e := range es {
+			persisted := e.(*RevokedToken)
+			if persisted.ID == t.ID {
+				if t.RevokedAt == "" {
+					return nil
+				}
+				return tx.BulkUpdate(ctx, []Persistable{t})
+			}
+			if exp, perr := time.Parse(time.RFC3339, persisted.Expiry); perr == nil && exp.Before(now) {
+				if _, err = tx.Delete(ctx, persisted.ID, &RevokedToken{}); err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Create(ctx, t)
+	})
+}
+
+// IsRevoked returns whether id has been revoked.
+func (r *TokenRevocations) IsRevoked(id string) (bool, error) {
+	e, err := r.PersistenceService.Read(context.Background(), id, &RevokedToken{})
+	if err != nil {
+		return false, err
+	}
+
+	return e != nil, nil
+}
+
+// SubjectRevokedAt returns the time until which the tokens of subject have
+// been revoked, or the zero time if they have not.
+func (r *TokenRevocations) SubjectRevokedAt(subject string) (time.Time, error) {
+	e, err := r.PersistenceService.Read(context.Background(), subjectRevocationID(subject), &RevokedToken{})
+	if err != nil || e == nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339, e.(*RevokedToken).RevokedAt)
+}
+
+// subjectRevocationID returns the ID of the revocation of the tokens of
+// subject. It is derived from subject, so it is not stored with it.
+func subjectRevocationID(subject string) string {
+	return uuid.FromName("subject:" + subject)
+}
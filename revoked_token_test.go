@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/memory"
+)
+
+var _ = Describe("Entities: RevokedToken", func() {
+	var (
+		t *cce.RevokedToken
+	)
+
+	BeforeEach(func() {
+		t = &cce.RevokedToken{
+			ID:     "0f1c3c1e-4b3a-4d8e-9d65-3d0b5f1b2a6e",
+			Expiry: "2020-03-01T12:00:00Z",
+		}
+	})
+
+	Describe("GetTableName", func() {
+		It(`Should return "revoked_tokens"`, func() {
+			Expect(t.GetTableName()).To(Equal("revoked_tokens"))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("Should not return an error for a valid revoked token", func() {
+			Expect(t.Validate()).To(Succeed())
+		})
+
+		It("Should return an error for an invalid ID", func() {
+			t.ID = "123"
+			Expect(t.Validate()).To(MatchError("id not a valid uuid"))
+		})
+
+		It("Should return an error for an invalid expiry", func() {
+			t.Expiry = "tomorrow"
+			Expect(t.Validate()).To(MatchError("expiry not a valid RFC 3339 timestamp"))
+		})
+
+		It("Should return an error for an invalid revocation time", func() {
+			t.RevokedAt = "today"
+			Expect(t.Validate()).To(MatchError("revoked_at not a valid RFC 3339 timestamp"))
+		})
+	})
+
+	Describe("TokenRevocations", func() {
+		var (
+			ps          cce.PersistenceService
+			revocations *cce.TokenRevocations
+		)
+
+		BeforeEach(func() {
+			var err error
+			ps, err = memory.NewPersistenceService("")
+			Expect(err).ToNot(HaveOccurred())
+			revocations = &cce.TokenRevocations{PersistenceService: ps}
+		})
+
+		It("Should report revoked IDs", func() {
+			Expect(revocations.Revoke(t.ID, time.Now().Add(time.Hour))).To(Succeed())
+			Expect(revocations.Revoke(t.ID, time.Now().Add(time.Hour))).To(Succeed())
+
+			Expect(revocations.IsRevoked(t.ID)).To(BeTrue())
+			Expect(revocations.IsRevoked("9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f")).To(BeFalse())
+		})
+
+		It("Should remove expired IDs when revoking", func() {
+			Expect(ps.Create(context.Background(), t)).To(Succeed())
+
+			Expect(revocations.Revoke("9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f", time.Now().Add(time.Hour))).
+				To(Succeed())
+
+			Expect(revocations.IsRevoked(t.ID)).To(BeFalse())
+			Expect(revocations.IsRevoked("9f8a6e3c-2d4b-4f1a-8c7e-5b6d4a3c2e1f")).To(BeTrue())
+		})
+
+		It("Should report when the tokens of a subject were revoked", func() {
+			Expect(revocations.SubjectRevokedAt("jdoe")).To(BeZero())
+
+			Expect(revocations.RevokeSubject("jdoe", time.Now().Add(time.Hour))).To(Succeed())
+			revokedAt, err := revocations.SubjectRevokedAt("jdoe")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revokedAt).To(BeTemporally("~", time.Now(), 2*time.Second))
+
+			Expect(revocations.RevokeSubject("jdoe", time.Now().Add(time.Hour))).To(Succeed())
+			Expect(revocations.SubjectRevokedAt("alice")).To(BeZero())
+		})
+	})
+})
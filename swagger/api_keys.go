@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package swagger
+
+// APIKeySummary is a summary representation of the API key. The key itself is
+// only returned when it is created.
+type APIKeySummary struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Role      string   `json:"role"`
+	Scopes    []string `json:"scopes,omitempty"`
+	Expiry    string   `json:"expiry,omitempty"`
+	CreatedBy string   `json:"created_by,omitempty"`
+}
+
+// APIKeyDetail is a detailed representation of the API key. The key is only
+// set in the response to its creation.
+type APIKeyDetail struct {
+	APIKeySummary
+	Key string `json:"key,omitempty"`
+}
+
+// APIKeyList is a list representation of API keys.
+type APIKeyList struct {
+	APIKeys    []APIKeySummary `json:"api_keys"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
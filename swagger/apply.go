@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package swagger
+
+// Manifest is the desired state of the controller resources, applied with
+// POST /apply. Resources are matched with the existing ones by ID or, if the
+// ID is empty, by name (nodes by serial). Applying is additive: resources
+// that are not in the manifest are left untouched, unless POST /apply is
+// called with prune=true. Then the DNS settings, apps and policies of the
+// manifest's nodes that are not in the manifest are deleted.
+type Manifest struct {
+	Apps     []AppDetail    `json:"apps,omitempty"`
+	Policies []PolicyDetail `json:"policies,omitempty"`
+	Nodes    []ManifestNode `json:"nodes,omitempty"`
+}
+
+// ManifestNode is a node in a manifest along with its DNS settings, apps and
+// interface policies. Apps and policies are referenced by ID or name; the
+// values of DNS alias records are app references as well.
+type ManifestNode struct {
+	NodeSummary
+	DNS        *DNSDetail          `json:"dns,omitempty"`
+	Apps       []ManifestNodeApp   `json:"apps,omitempty"`
+	Interfaces []ManifestInterface `json:"interfaces,omitempty"`
+}
+
+// ManifestNodeApp is an app deployed to a node with an optional traffic
+// policy.
+type ManifestNodeApp struct {
+	App    string `json:"app"`
+	Policy string `json:"policy,omitempty"`
+}
+
+// ManifestInterface is the traffic policy of a node's network interface.
+type ManifestInterface struct {
+	ID     string `json:"id"`
+	Policy string `json:"policy"`
+}
+
+// ApplyStep is a step of the plan to apply a manifest. Its action is
+// "create", "update", "delete" or "none" if the resource is up to date.
+type ApplyStep struct {
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+	Name     string `json:"name"`
+	ID       string `json:"id,omitempty"`
+	Applied  bool   `json:"applied,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ApplyPlan is the plan to apply a manifest in the order of its steps.
+type ApplyPlan struct {
+	DryRun bool        `json:"dry_run"`
+	Steps  []ApplyStep `json:"steps"`
+}
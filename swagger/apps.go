@@ -29,5 +29,6 @@ type AppDetail struct {
 
 // AppList is a list representation of apps.
 type AppList struct {
-	Apps []AppSummary `json:"apps"`
+	Apps       []AppSummary `json:"apps"`
+	NextCursor string       `json:"next_cursor,omitempty"`
 }
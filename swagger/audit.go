@@ -0,0 +1,14 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package swagger
+
+import (
+	cce "github.com/open-ness/edgecontroller"
+)
+
+// AuditRecordList is a list representation of audit records.
+type AuditRecordList struct {
+	Records    []*cce.AuditRecord `json:"records"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package swagger
+
+// EnrollmentTokenSummary is a summary representation of the enrollment token.
+// The token itself is only returned when it is created.
+type EnrollmentTokenSummary struct {
+	ID string `json:"id"`
+	// NodeID is the ID of the node created when the token is redeemed
+	NodeID   string `json:"node_id"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+	// Expiry defaults to 24 hours after the token is created
+	Expiry    string `json:"expiry,omitempty"`
+	CreatedBy string `json:"created_by,omitempty"`
+}
+
+// EnrollmentTokenDetail is a detailed representation of the enrollment token.
+// The token is only set in the response to its creation.
+type EnrollmentTokenDetail struct {
+	EnrollmentTokenSummary
+	Token string `json:"token,omitempty"`
+}
+
+// EnrollmentTokenList is a list representation of enrollment tokens.
+type EnrollmentTokenList struct {
+	EnrollmentTokens []EnrollmentTokenSummary `json:"enrollment_tokens"`
+	NextCursor       string                   `json:"next_cursor,omitempty"`
+}
+
+// Enrollment is an unknown node's request to enroll.
+type Enrollment struct {
+	ID          string `json:"id"`
+	Serial      string `json:"serial"`
+	PeerIP      string `json:"peer_ip"`
+	Subject     string `json:"subject"`
+	Status      string `json:"status"`
+	RequestedAt string `json:"requested_at"`
+}
+
+// EnrollmentList is a list representation of enrollments.
+type EnrollmentList struct {
+	Enrollments []Enrollment `json:"enrollments"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
+}
+
+// EnrollmentApproval is the body of POST /enrollments/{enrollment_id}/approve.
+type EnrollmentApproval struct {
+	Name     string `json:"name"`
+	Location string `json:"location"`
+}
@@ -0,0 +1,27 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package swagger
+
+import (
+	"encoding/json"
+)
+
+// ExportVersion is the version of the archive format produced by GET /export.
+const ExportVersion = 1
+
+// Export is an archive of the controller configuration. Tables maps the name
+// of each exported table to its entities.
+type Export struct {
+	Version    int                          `json:"version"`
+	ExportedAt string                       `json:"exported_at"`
+	Tables     map[string][]json.RawMessage `json:"tables"`
+}
+
+// ImportResult is the number of entities of each table that have been
+// created, updated or skipped by POST /import.
+type ImportResult struct {
+	Created map[string]int `json:"created"`
+	Updated map[string]int `json:"updated"`
+	Skipped map[string]int `json:"skipped"`
+}
@@ -17,5 +17,6 @@ type NodeAppDetail struct {
 
 // NodeAppList is a list representation of node apps.
 type NodeAppList struct {
-	NodeApps []NodeAppSummary `json:"apps"`
+	NodeApps   []NodeAppSummary `json:"apps"`
+	NextCursor string           `json:"next_cursor,omitempty"`
 }
@@ -3,12 +3,16 @@
 
 package swagger
 
-// NodeSummary is a summary representation of the node.
+// NodeSummary is a summary representation of the node. Status is one of
+// online, degraded or offline and LastSeen is the time of the node's last
+// heartbeat. Both are read-only.
 type NodeSummary struct {
 	ID       string `json:"id"`
 	Name     string `json:"name"`
 	Location string `json:"location"`
 	Serial   string `json:"serial"`
+	Status   string `json:"status,omitempty"`
+	LastSeen string `json:"last_seen,omitempty"`
 }
 
 // NodeDetail is a detailed representation of the node.
@@ -18,5 +22,28 @@ type NodeDetail struct {
 
 // NodeList is a list representation of nodes.
 type NodeList struct {
-	Nodes []NodeSummary `json:"nodes"`
+	Nodes      []NodeSummary `json:"nodes"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// NodeSync is the outcome of the last reconciliation of the node's desired
+// state with its actual state. Status is one of pending, in_sync, converged,
+// drifted or unreachable.
+type NodeSync struct {
+	NodeID    string      `json:"node_id"`
+	Status    string      `json:"status"`
+	CheckedAt string      `json:"checked_at,omitempty"`
+	SyncedAt  string      `json:"synced_at,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Drift     []NodeDrift `json:"drift"`
+}
+
+// NodeDrift is a difference between the desired and actual state of the node.
+// Resource is one of app, interface, interface_policy, app_policy or dns.
+type NodeDrift struct {
+	Resource string `json:"resource"`
+	ID       string `json:"id"`
+	Reason   string `json:"reason"`
+	Repaired bool   `json:"repaired"`
+	Error    string `json:"error,omitempty"`
 }
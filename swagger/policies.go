@@ -28,5 +28,6 @@ type PolicyKubeOVNDetail struct {
 
 // PolicyList is a list representation of traffic policies.
 type PolicyList struct {
-	Policies []PolicySummary `json:"policies"`
+	Policies   []PolicySummary `json:"policies"`
+	NextCursor string          `json:"next_cursor,omitempty"`
 }
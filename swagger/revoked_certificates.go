@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package swagger
+
+// RevokedCertificate is a revoked node certificate.
+type RevokedCertificate struct {
+	// Serial is the lower-case hexadecimal serial number of the certificate
+	Serial    string `json:"serial"`
+	NodeID    string `json:"node_id"`
+	Reason    string `json:"reason"`
+	RevokedAt string `json:"revoked_at"`
+}
+
+// RevocationRequest is the optional body of POST /nodes/{node_id}/revoke.
+type RevocationRequest struct {
+	// Reason defaults to "unspecified"
+	Reason string `json:"reason,omitempty"`
+}
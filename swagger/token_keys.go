@@ -0,0 +1,18 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package swagger
+
+// TokenKey is a token signing key. The private key is never returned.
+type TokenKey struct {
+	ID      string `json:"id"`
+	Created string `json:"created"`
+	// Retired is empty for the current key, which signs new tokens
+	Retired string `json:"retired,omitempty"`
+}
+
+// TokenKeyList is a list representation of the token signing keys from
+// newest to oldest.
+type TokenKeyList struct {
+	Keys []TokenKey `json:"keys"`
+}
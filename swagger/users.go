@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package swagger
+
+// UserSummary is a summary representation of the user. The password is never
+// returned.
+type UserSummary struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// UserDetail is a detailed representation of the user. The password is only
+// set in requests.
+type UserDetail struct {
+	UserSummary
+	Password string `json:"password,omitempty"`
+}
+
+// UserList is a list representation of users.
+type UserList struct {
+	Users      []UserSummary `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,29 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package swagger
+
+import (
+	cce "github.com/open-ness/edgecontroller"
+)
+
+// WebhookDetail is a detailed representation of the webhook. The secret is
+// never returned.
+type WebhookDetail struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// WebhookList is a list representation of webhooks.
+type WebhookList struct {
+	Webhooks   []WebhookDetail `json:"webhooks"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// WebhookDeliveryList is a list representation of the deliveries to a
+// webhook.
+type WebhookDeliveryList struct {
+	Deliveries []*cce.WebhookDelivery `json:"deliveries"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+
+	cce "github.com/open-ness/edgecontroller"
+)
+
+// AuthEventAppName is the APP-NAME of the syslog messages of authentication
+// events.
+const AuthEventAppName = "cce"
+
+// authEventSDID is the ID of the structured data element of authentication
+// events. 343 is the private enterprise number of Intel.
+const authEventSDID = "auth@343"
+
+// syslogTimestamp is the RFC 5424 timestamp format, which allows at most six
+// digits of fractional seconds.
+const syslogTimestamp = "2006-01-02T15:04:05.000000Z07:00"
+
+// Syslog priorities of the authpriv facility
+const (
+	priorityAuthWarning = 10*8 + 4
+	priorityAuthNotice  = 10*8 + 5
+	priorityAuthInfo    = 10*8 + 6
+)
+
+// SyncWriter serializes the writes to W, so that the lines written
+// concurrently by WriteToByLine and an AuthEventLogger do not interleave.
+type SyncWriter struct {
+	W io.Writer
+
+	mu sync.Mutex
+}
+
+func (w *SyncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.W.Write(p)
+}
+
+// AuthEventLogger writes authentication events as RFC 5424 syslog messages
+// of the authpriv facility, one per line. The event fields are structured
+// data, so that a SIEM can parse them:
+//
+//	<85>1 2020-03-01T12:00:00.000000Z controller cce - auth [auth@343 action="login" outcome="failure"
+//	user="admin" src="192.0.2.1" reason="invalid username or password"] Login of user admin failed
+type AuthEventLogger struct {
+	W        io.Writer
+	Hostname string
+}
+
+// LogAuthEvent writes the syslog message of an event.
+func (l *AuthEventLogger) LogAuthEvent(e *cce.AuthEvent) {
+	hostname := l.Hostname
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	var (
+		priority = priorityAuthInfo
+		verb     = "succeeded"
+	)
+	switch e.Outcome {
+	case cce.AuthOutcomeFailure:
+		priority, verb = priorityAuthNotice, "failed"
+	case cce.AuthOutcomeLockedOut:
+		priority, verb = priorityAuthWarning, "was locked out"
+	}
+
+	sd := fmt.Sprintf(`[%s action="%s" outcome="%s" user="%s" src="%s"`,
+		authEventSDID, sdEscape(e.Action), sdEscape(e.Outcome), sdEscape(e.Username), sdEscape(e.Source))
+	if e.Reason != "" {
+		sd += fmt.Sprintf(` reason="%s"`, sdEscape(e.Reason))
+	}
+	sd += "]"
+
+	msg := fmt.Sprintf("<%d>1 %s %s %s - auth %s %s of user %s %s\n",
+		priority,
+		e.Time.UTC().Format(syslogTimestamp),
+		hostname,
+		AuthEventAppName,
+		sd,
+		strings.Title(e.Action),
+		printable(e.Username),
+		verb)
+
+	if _, err := l.W.Write([]byte(msg)); err != nil {
+		log.Errf("error writing auth event to telemetry file: %v", err)
+	}
+}
+
+// sdEscape escapes a structured data parameter value as required by RFC 5424.
+func sdEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(printable(s))
+}
+
+// printable replaces the control characters of s with spaces, so that user
+// input cannot break lines.
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package telemetry_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/telemetry"
+)
+
+var _ = Describe("AuthEventLogger", func() {
+	var (
+		buf    *Buffer
+		logger *telemetry.AuthEventLogger
+		event  *cce.AuthEvent
+	)
+
+	BeforeEach(func() {
+		buf = NewBuffer()
+		logger = &telemetry.AuthEventLogger{
+			W:        &telemetry.SyncWriter{W: buf},
+			Hostname: "controller",
+		}
+		event = &cce.AuthEvent{
+			Time:     time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC),
+			Action:   cce.AuthActionLogin,
+			Outcome:  cce.AuthOutcomeSuccess,
+			Username: "admin",
+			Source:   "192.0.2.1",
+		}
+	})
+
+	It("Should write a successful login as an info message", func() {
+		logger.LogAuthEvent(event)
+		Expect(string(buf.Contents())).To(Equal(
+			`<86>1 2020-03-01T12:00:00.000000Z controller cce - auth ` +
+				`[auth@343 action="login" outcome="success" user="admin" src="192.0.2.1"] ` +
+				"Login of user admin succeeded\n"))
+	})
+
+	It("Should write a failed login with its reason as a notice message", func() {
+		event.Outcome = cce.AuthOutcomeFailure
+		event.Reason = "invalid username or password"
+		logger.LogAuthEvent(event)
+		Expect(string(buf.Contents())).To(Equal(
+			`<85>1 2020-03-01T12:00:00.000000Z controller cce - auth ` +
+				`[auth@343 action="login" outcome="failure" user="admin" src="192.0.2.1" ` +
+				`reason="invalid username or password"] Login of user admin failed` + "\n"))
+	})
+
+	It("Should write a lockout as a warning message", func() {
+		event.Outcome = cce.AuthOutcomeLockedOut
+		logger.LogAuthEvent(event)
+		Expect(buf).To(Say(`^<84>1 .* Login of user admin was locked out\n$`))
+	})
+
+	It("Should escape the user input", func() {
+		logger.Hostname = ""
+		event.Username = "a\"]\\b\nc"
+		logger.LogAuthEvent(event)
+		Expect(string(buf.Contents())).To(ContainSubstring(` - cce - auth `))
+		Expect(string(buf.Contents())).To(ContainSubstring(`user="a\"\]\\b c"`))
+		Expect(string(buf.Contents())).To(HaveSuffix("Login of user a\"]\\b c succeeded\n"))
+	})
+})
@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/open-ness/edgecontroller/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User roles
+const (
+	// RoleAdmin can do everything, including managing users, webhooks and
+	// the controller configuration as a whole.
+	RoleAdmin = "admin"
+	// RoleOperator can read and change nodes, apps, policies and DNS
+	// settings.
+	RoleOperator = "operator"
+	// RoleReadOnly can read what operators can change.
+	RoleReadOnly = "read-only"
+)
+
+// Roles are the user roles in order of increasing permissions.
+var Roles = []string{
+	RoleReadOnly,
+	RoleOperator,
+	RoleAdmin,
+}
+
+// RoleIncludes returns whether role has the permissions of required. Unknown
+// roles have no permissions.
+func RoleIncludes(role, required string) bool {
+	rank, requiredRank := roleRank(role), roleRank(required)
+	return rank >= 0 && requiredRank >= 0 && rank >= requiredRank
+}
+
+func roleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// MinPasswordLength is the minimum length of a password set through the API.
+const MinPasswordLength = 8
+
+// MaxUsernameLength is the maximum length of a username.
+const MaxUsernameLength = 64
+
+// ValidatePassword validates a new password.
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	// bcrypt only uses the first 72 bytes
+	if len(password) > 72 {
+		return errors.New("password must be at most 72 bytes")
+	}
+
+	return nil
+}
+
+// User is an account of the controller API. Only the bcrypt hash of the
+// password is stored.
+type User struct {
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role"`
+}
+
+// GetTableName returns the name of the persistence table.
+func (*User) GetTableName() string {
+	return "users"
+}
+
+// GetID gets the ID.
+func (u *User) GetID() string {
+	return u.ID
+}
+
+// SetID sets the ID.
+func (u *User) SetID(id string) {
+	u.ID = id
+}
+
+// FilterFields returns the filterable fields for this model.
+func (*User) FilterFields() []string {
+	return []string{
+		"username",
+		"role",
+	}
+}
+
+// Validate validates the model.
+func (u *User) Validate() error {
+	if !uuid.IsValid(u.ID) {
+		return errors.New("id not a valid uuid")
+	}
+	if u.Username == "" {
+		return errors.New("username cannot be empty")
+	}
+	if len(u.Username) > MaxUsernameLength || strings.ContainsAny(u.Username, " \t\r\n") {
+		return fmt.Errorf("username must be at most %d characters without whitespace", MaxUsernameLength)
+	}
+	if roleRank(u.Role) < 0 {
+		return fmt.Errorf("role must be one of %s", strings.Join(Roles, ", "))
+	}
+	if u.PasswordHash == "" {
+		return errors.New("password cannot be empty")
+	}
+
+	return nil
+}
+
+// SetPassword sets the password hash to the bcrypt hash of password.
+func (u *User) SetPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = string(hash)
+
+	return nil
+}
+
+// CheckPassword returns whether password matches the password hash.
+func (u *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// Redacted returns a copy of the user without the password hash.
+func (u *User) Redacted() Persistable {
+	redacted := *u
+	redacted.PasswordHash = ""
+	return &redacted
+}
+
+func (u *User) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+User[
+    ID: %s
+    Username: %s
+    Role: %s
+]`),
+		u.ID,
+		u.Username,
+		u.Role)
+}
+
+// EnsureAdminUser creates or updates the admin user with the given
+// credentials, so the admin password passed to the controller always works.
+func EnsureAdminUser(ctx context.Context, ps PersistenceService, creds *AuthCreds) error {
+	return ps.WithTx(ctx, func(tx PersistenceService) error {
+		es, err := tx.Filter(ctx, &User{}, []Filter{{Field: "username", Value: creds.Username}})
+		if err != nil {
+			return err
+		}
+
+		if len(es) == 0 {
+			u := &User{ID: uuid.New(), Username: creds.Username, Role: RoleAdmin}
+			if err = u.SetPassword(creds.Password); err != nil {
+				return err
+			}
+			return tx.Create(ctx, u)
+		}
+
+		u := es[0].(*User)
+		if u.Role == RoleAdmin && u.CheckPassword(creds.Password) {
+			return nil
+		}
+		u.Role = RoleAdmin
+		if err = u.SetPassword(creds.Password); err != nil {
+			return err
+		}
+		return tx.BulkUpdate(ctx, []Persistable{u})
+	})
+}
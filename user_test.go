@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce_test
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/memory"
+)
+
+var _ = Describe("Entities: User", func() {
+	var (
+		u *cce.User
+	)
+
+	BeforeEach(func() {
+		u = &cce.User{
+			ID:       "7e2a4d7e-95ae-4b6b-8e0b-31d7d7f0e1c4",
+			Username: "jdoe",
+			Role:     cce.RoleOperator,
+		}
+		Expect(u.SetPassword("correct horse")).To(Succeed())
+	})
+
+	Describe("GetTableName", func() {
+		It(`Should return "users"`, func() {
+			Expect(u.GetTableName()).To(Equal("users"))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("Should not return an error for a valid user", func() {
+			Expect(u.Validate()).To(Succeed())
+		})
+
+		It("Should return an error for an invalid ID", func() {
+			u.ID = "123"
+			Expect(u.Validate()).To(MatchError("id not a valid uuid"))
+		})
+
+		It("Should return an error for an empty username", func() {
+			u.Username = ""
+			Expect(u.Validate()).To(MatchError("username cannot be empty"))
+		})
+
+		It("Should return an error for a username with whitespace", func() {
+			u.Username = "j doe"
+			Expect(u.Validate()).To(MatchError("username must be at most 64 characters without whitespace"))
+		})
+
+		It("Should return an error for an unknown role", func() {
+			u.Role = "root"
+			Expect(u.Validate()).To(MatchError("role must be one of read-only, operator, admin"))
+		})
+
+		It("Should return an error for a missing password", func() {
+			u.PasswordHash = ""
+			Expect(u.Validate()).To(MatchError("password cannot be empty"))
+		})
+	})
+
+	Describe("CheckPassword", func() {
+		It("Should accept the password", func() {
+			Expect(u.PasswordHash).ToNot(ContainSubstring("correct horse"))
+			Expect(u.CheckPassword("correct horse")).To(BeTrue())
+		})
+
+		It("Should reject another password", func() {
+			Expect(u.CheckPassword("battery staple")).To(BeFalse())
+		})
+	})
+
+	Describe("ValidatePassword", func() {
+		It("Should reject short passwords", func() {
+			Expect(cce.ValidatePassword("short")).To(MatchError("password must be at least 8 characters"))
+		})
+
+		It("Should reject passwords longer than bcrypt supports", func() {
+			Expect(cce.ValidatePassword(strings.Repeat("x", 73))).To(MatchError("password must be at most 72 bytes"))
+		})
+	})
+
+	Describe("Redacted", func() {
+		It("Should return a copy without the password hash", func() {
+			redacted := u.Redacted().(*cce.User)
+			Expect(redacted.PasswordHash).To(BeEmpty())
+			Expect(redacted.Username).To(Equal(u.Username))
+			Expect(u.PasswordHash).ToNot(BeEmpty())
+		})
+	})
+
+	Describe("RoleIncludes", func() {
+		It("Should order the roles", func() {
+			Expect(cce.RoleIncludes(cce.RoleAdmin, cce.RoleOperator)).To(BeTrue())
+			Expect(cce.RoleIncludes(cce.RoleOperator, cce.RoleOperator)).To(BeTrue())
+			Expect(cce.RoleIncludes(cce.RoleReadOnly, cce.RoleOperator)).To(BeFalse())
+		})
+
+		It("Should not give unknown roles any permissions", func() {
+			Expect(cce.RoleIncludes("", cce.RoleReadOnly)).To(BeFalse())
+			Expect(cce.RoleIncludes(cce.RoleAdmin, "")).To(BeFalse())
+		})
+	})
+
+	Describe("EnsureAdminUser", func() {
+		var (
+			ctx   = context.Background()
+			ps    cce.PersistenceService
+			creds = &cce.AuthCreds{Username: "admin", Password: "first password"}
+		)
+
+		BeforeEach(func() {
+			var err error
+			ps, err = memory.NewPersistenceService("")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		admin := func() *cce.User {
+			es, err := ps.Filter(ctx, &cce.User{}, []cce.Filter{{Field: "username", Value: "admin"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(es).To(HaveLen(1))
+			return es[0].(*cce.User)
+		}
+
+		It("Should create the admin user", func() {
+			Expect(cce.EnsureAdminUser(ctx, ps, creds)).To(Succeed())
+			Expect(admin().Role).To(Equal(cce.RoleAdmin))
+			Expect(admin().CheckPassword("first password")).To(BeTrue())
+		})
+
+		It("Should reset the password and role of the admin user", func() {
+			Expect(cce.EnsureAdminUser(ctx, ps, creds)).To(Succeed())
+			u := admin()
+			u.Role = cce.RoleReadOnly
+			Expect(ps.BulkUpdate(ctx, []cce.Persistable{u})).To(Succeed())
+
+			Expect(cce.EnsureAdminUser(ctx, ps, &cce.AuthCreds{Username: "admin", Password: "second password"})).
+				To(Succeed())
+			Expect(admin().Role).To(Equal(cce.RoleAdmin))
+			Expect(admin().CheckPassword("second password")).To(BeTrue())
+		})
+	})
+})
@@ -10,6 +10,11 @@ func New() string {
 	return uuid.NewV4().String()
 }
 
+// FromName returns the V5 UUID of name, which is the same for the same name.
+func FromName(name string) string {
+	return uuid.NewV5(uuid.NamespaceOID, name).String()
+}
+
 // IsValid returns true if id is a valid V4 UUID.
 func IsValid(id string) bool {
 	return uuid.FromStringOrNil(id) != uuid.Nil
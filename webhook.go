@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/open-ness/edgecontroller/uuid"
+)
+
+// Notification types delivered to webhooks
+const (
+	// NotificationNodeEnrolled is sent with the Node when a node has been issued credentials.
+	NotificationNodeEnrolled = "node.enrolled"
+	// NotificationEnrollmentPending is sent with the Enrollment when an unknown node is queued for approval.
+	NotificationEnrollmentPending = "node.enrollment_pending"
+	// NotificationAppDeployFailed is sent with an AppDeployFailure when deploying an app to a node fails.
+	NotificationAppDeployFailed = "node_app.deploy_failed"
+	// NotificationAppLifecycle is sent with the NodeAppReq when a lifecycle command has been sent to a node app.
+	NotificationAppLifecycle = "node_app.lifecycle"
+	// NotificationNodeStatusChanged is sent with a NodeStatusChange when a node goes online, degraded or offline.
+	NotificationNodeStatusChanged = "node.status_changed"
+)
+
+// NotificationTypes are the notification types a webhook can subscribe to.
+var NotificationTypes = []string{
+	NotificationNodeEnrolled,
+	NotificationEnrollmentPending,
+	NotificationAppDeployFailed,
+	NotificationAppLifecycle,
+	NotificationNodeStatusChanged,
+}
+
+// AppDeployFailure is the data of a NotificationAppDeployFailed notification.
+type AppDeployFailure struct {
+	NodeID string `json:"node_id"`
+	AppID  string `json:"app_id"`
+	Error  string `json:"error"`
+}
+
+// NodeStatusChange is the data of a NotificationNodeStatusChanged notification.
+type NodeStatusChange struct {
+	NodeID         string `json:"node_id"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status"`
+	LastSeen       string `json:"last_seen"`
+}
+
+// MinWebhookSecretLength is the minimum length of a webhook secret.
+const MinWebhookSecretLength = 16
+
+// NotificationService notifies external systems of events. Notify must not block on the delivery of the notification.
+type NotificationService interface {
+	Notify(ctx context.Context, typ string, data interface{})
+}
+
+// Webhook is an HTTP endpoint receiving notifications. Every notification is POSTed as JSON, signed with Secret. If
+// Events is empty the webhook receives notifications of all types.
+type Webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events,omitempty"`
+}
+
+// GetTableName returns the name of the persistence table.
+func (*Webhook) GetTableName() string {
+	return "webhooks"
+}
+
+// GetID gets the ID.
+func (wh *Webhook) GetID() string {
+	return wh.ID
+}
+
+// SetID sets the ID.
+func (wh *Webhook) SetID(id string) {
+	wh.ID = id
+}
+
+// FilterFields returns the filterable fields for this model.
+func (*Webhook) FilterFields() []string {
+	return []string{
+		"url",
+	}
+}
+
+// Validate validates the model.
+func (wh *Webhook) Validate() error {
+	if !uuid.IsValid(wh.ID) {
+		return errors.New("id not a valid uuid")
+	}
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if len(wh.Secret) < MinWebhookSecretLength {
+		return fmt.Errorf("secret must be at least %d characters", MinWebhookSecretLength)
+	}
+	for _, e := range wh.Events {
+		if !isNotificationType(e) {
+			return fmt.Errorf("event %q is invalid", e)
+		}
+	}
+
+	return nil
+}
+
+// Subscribed returns whether the webhook receives notifications of type typ.
+func (wh *Webhook) Subscribed(typ string) bool {
+	if len(wh.Events) == 0 {
+		return true
+	}
+	for _, e := range wh.Events {
+		if e == typ {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redacted returns a copy of the webhook without its secret.
+func (wh *Webhook) Redacted() Persistable {
+	redacted := *wh
+	redacted.Secret = ""
+	return &redacted
+}
+
+func (wh *Webhook) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+Webhook[
+    ID: %s
+    URL: %s
+    Events: %v
+]`),
+		wh.ID,
+		wh.URL,
+		wh.Events)
+}
+
+func isNotificationType(typ string) bool {
+	for _, t := range NotificationTypes {
+		if t == typ {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is the delivery of a notification to a webhook. A pending delivery is attempted at NextAttemptAt;
+// it has failed once the maximum number of attempts has been made. The timestamps are formatted with
+// AuditTimestamp.
+type WebhookDelivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	CreatedAt     string          `json:"created_at"`
+	NextAttemptAt string          `json:"next_attempt_at,omitempty"`
+	// ResponseCode and Error describe the outcome of the last attempt
+	ResponseCode int    `json:"response_code,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// GetTableName returns the name of the persistence table.
+func (*WebhookDelivery) GetTableName() string {
+	return "webhook_deliveries"
+}
+
+// GetID gets the ID.
+func (d *WebhookDelivery) GetID() string {
+	return d.ID
+}
+
+// SetID sets the ID.
+func (d *WebhookDelivery) SetID(id string) {
+	d.ID = id
+}
+
+// FilterFields returns the filterable fields for this model.
+func (*WebhookDelivery) FilterFields() []string {
+	return []string{
+		"webhook_id",
+		"type",
+		"status",
+		"created_at",
+		"next_attempt_at",
+	}
+}
+
+func (d *WebhookDelivery) String() string {
+	return fmt.Sprintf(strings.TrimSpace(`
+WebhookDelivery[
+    ID: %s
+    WebhookID: %s
+    Type: %s
+    Status: %s
+    Attempts: %d
+]`),
+		d.ID,
+		d.WebhookID,
+		d.Type,
+		d.Status,
+		d.Attempts)
+}
+
+// Notify notifies the controller's NotificationService of an event if it is set.
+func (c *Controller) Notify(ctx context.Context, typ string, data interface{}) {
+	if c.NotificationService != nil {
+		c.NotificationService.Notify(ctx, typ, data)
+	}
+}
@@ -0,0 +1,316 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+// Package webhook delivers notifications to the webhooks registered with the controller.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	logger "github.com/open-ness/common/log"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/uuid"
+	"github.com/pkg/errors"
+)
+
+var log = logger.DefaultLogger.WithField("pkg", "webhook")
+
+// Headers sent with every delivery
+const (
+	// EventHeader is the notification type.
+	EventHeader = "X-Controller-Event"
+	// DeliveryHeader is the ID of the cce.WebhookDelivery.
+	DeliveryHeader = "X-Controller-Delivery"
+	// TimestampHeader is the time of the attempt in seconds since the Unix epoch.
+	TimestampHeader = "X-Controller-Timestamp"
+	// SignatureHeader is "sha256=" followed by the Sign of the timestamp and body.
+	SignatureHeader = "X-Controller-Signature"
+)
+
+// Defaults of a Dispatcher created by NewDispatcher
+const (
+	DefaultMaxAttempts  = 8
+	DefaultMinBackoff   = 10 * time.Second
+	DefaultMaxBackoff   = time.Hour
+	DefaultPollInterval = 5 * time.Second
+	DefaultTimeout      = 10 * time.Second
+
+	// storeTimeout limits how long Notify waits for the deliveries to be
+	// stored
+	storeTimeout = 10 * time.Second
+	// batchSize is the maximum number of deliveries attempted at once
+	batchSize = 100
+)
+
+// Notification is the JSON payload POSTed to a webhook. The ID is the same
+// for the deliveries of a notification to different webhooks and can be used
+// by receivers to discard duplicates.
+type Notification struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Timestamp string          `json:"timestamp"`
+	Actor     string          `json:"actor"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of timestamp, "." and body keyed
+// with secret.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = io.WriteString(mac, timestamp+".")
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a delivery in constant time. Receivers
+// should also reject deliveries with old timestamps to prevent replays.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte("sha256="+Sign(secret, timestamp, body)))
+}
+
+// Dispatcher implements cce.NotificationService. Notify stores a
+// cce.WebhookDelivery for every subscribed webhook, which Run delivers, so a
+// slow webhook or a restart does not lose notifications.
+// Failed deliveries are retried with exponential backoff, so deliveries are
+// at least once and may be out of order.
+type Dispatcher struct {
+	// PersistenceService stores the deliveries. It should not be audited, as
+	// every delivery attempt updates a delivery.
+	PersistenceService cce.PersistenceService
+	Client             *http.Client
+
+	// MaxAttempts is the number of attempts before a delivery fails
+	MaxAttempts int
+	// MinBackoff is the delay after the first failed attempt. It doubles on
+	// every further attempt up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// PollInterval is the interval in which retries are checked for
+	PollInterval time.Duration
+
+	// stored wakes Run after Notify stored deliveries
+	stored chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher with the default settings.
+func NewDispatcher(ps cce.PersistenceService) *Dispatcher {
+	return &Dispatcher{
+		PersistenceService: ps,
+		Client:             &http.Client{Timeout: DefaultTimeout},
+		MaxAttempts:        DefaultMaxAttempts,
+		MinBackoff:         DefaultMinBackoff,
+		MaxBackoff:         DefaultMaxBackoff,
+		PollInterval:       DefaultPollInterval,
+		stored:             make(chan struct{}, 1),
+	}
+}
+
+// Notify stores a notification of type typ with data marshaled as JSON. It
+// does not wait for the deliveries, but stores them in a transaction of its
+// own that does not end with the caller's ctx.
+func (d *Dispatcher) Notify(ctx context.Context, typ string, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Errf("Error marshaling %s notification: %v", typ, err)
+		return
+	}
+
+	n := &Notification{
+		ID:        uuid.New(),
+		Type:      typ,
+		Timestamp: cce.AuditTimestamp(time.Now()),
+		Actor:     cce.ActorFromContext(ctx),
+		Data:      raw,
+	}
+
+	storeCtx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err = d.store(storeCtx, n); err != nil {
+		log.Errf("Error storing %s notification %s: %v", typ, n.ID, err)
+		return
+	}
+
+	select {
+	case d.stored <- struct{}{}:
+	default:
+	}
+}
+
+// Run attempts the due deliveries on start, every PollInterval and after
+// notifications were stored until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.deliverDue(ctx); err != nil {
+			log.Errf("Error delivering notifications: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-d.stored:
+		case <-ticker.C:
+		}
+	}
+}
+
+// store stores a pending delivery of n for every subscribed webhook.
+func (d *Dispatcher) store(ctx context.Context, n *Notification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling notification")
+	}
+
+	return d.PersistenceService.WithTx(ctx, func(tx cce.PersistenceService) error {
+		webhooks, err := tx.ReadAll(ctx, &cce.Webhook{})
+		if err != nil {
+			return errors.Wrap(err, "error reading webhooks")
+		}
+
+		for _, e := range webhooks {
+			if !e.(*cce.Webhook).Subscribed(n.Type) {
+				continue
+			}
+			if err = tx.Create(ctx, &cce.WebhookDelivery{
+				ID:            uuid.New(),
+				WebhookID:     e.GetID(),
+				Type:          n.Type,
+				Payload:       payload,
+				Status:        cce.DeliveryPending,
+				CreatedAt:     n.Timestamp,
+				NextAttemptAt: n.Timestamp,
+			}); err != nil {
+				return errors.Wrap(err, "error storing delivery")
+			}
+		}
+
+		return nil
+	})
+}
+
+// deliverDue attempts the pending deliveries whose next attempt is due.
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	es, _, err := d.PersistenceService.ReadPage(ctx, &cce.WebhookDelivery{}, cce.Query{
+		Filters: []cce.Filter{{Field: "status", Value: cce.DeliveryPending}},
+		Sort:    "next_attempt_at",
+		Limit:   batchSize,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error reading pending deliveries")
+	}
+
+	now := cce.AuditTimestamp(time.Now())
+	var wg sync.WaitGroup
+	for _, e := range es {
+		delivery := e.(*cce.WebhookDelivery)
+		if delivery.NextAttemptAt > now {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.deliver(ctx, delivery); err != nil {
+				log.Errf("Error delivering %s: %v", delivery.ID, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// deliver makes an attempt to deliver a pending delivery and records its
+// outcome.
+func (d *Dispatcher) deliver(ctx context.Context, delivery *cce.WebhookDelivery) error {
+	e, err := d.PersistenceService.Read(ctx, delivery.WebhookID, &cce.Webhook{})
+	if err != nil {
+		return errors.Wrap(err, "error reading webhook")
+	}
+	if e == nil {
+		// The webhook was deleted along with its deliveries
+		return nil
+	}
+	wh := e.(*cce.Webhook)
+
+	delivery.Attempts++
+	delivery.ResponseCode, err = d.post(ctx, wh, delivery)
+
+	switch {
+	case err == nil:
+		delivery.Status = cce.DeliverySucceeded
+		delivery.NextAttemptAt = ""
+		delivery.Error = ""
+	case delivery.Attempts >= d.MaxAttempts:
+		log.Infof("Delivery %s of %s notification to webhook %s failed after %d attempts: %v",
+			delivery.ID, delivery.Type, wh.ID, delivery.Attempts, err)
+		delivery.Status = cce.DeliveryFailed
+		delivery.NextAttemptAt = ""
+		delivery.Error = err.Error()
+	default:
+		delivery.NextAttemptAt = cce.AuditTimestamp(time.Now().Add(d.backoff(delivery.Attempts)))
+		delivery.Error = err.Error()
+	}
+
+	if err = d.PersistenceService.BulkUpdate(ctx, []cce.Persistable{delivery}); err != nil {
+		return errors.Wrap(err, "error updating delivery")
+	}
+
+	return nil
+}
+
+// post POSTs the payload of a delivery to a webhook and returns the response
+// status code. An error is returned unless the status code is 2xx.
+func (d *Dispatcher) post(ctx context.Context, wh *cce.Webhook, delivery *cce.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, errors.Wrap(err, "error creating request")
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Type)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(wh.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, errors.Wrap(err, "error sending request")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %q", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.MinBackoff
+	for i := 1; i < attempts && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+
+	return delay
+}
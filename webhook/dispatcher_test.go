@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+	"github.com/open-ness/edgecontroller/memory"
+	"github.com/open-ness/edgecontroller/webhook"
+)
+
+const secret = "0123456789abcdef"
+
+// receiver records the deliveries to an httptest.Server and responds with
+// the queued status codes, then 200 OK. If block is set, requests are only
+// answered once it is closed.
+type receiver struct {
+	block    chan struct{}
+	mu       sync.Mutex
+	statuses []int
+	headers  []http.Header
+	bodies   [][]byte
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	Expect(err).ToNot(HaveOccurred())
+	if rcv.block != nil {
+		<-rcv.block
+	}
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.headers = append(rcv.headers, r.Header)
+	rcv.bodies = append(rcv.bodies, body)
+	if len(rcv.statuses) > 0 {
+		w.WriteHeader(rcv.statuses[0])
+		rcv.statuses = rcv.statuses[1:]
+	}
+}
+
+func (rcv *receiver) count() int {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return len(rcv.bodies)
+}
+
+var _ = Describe("Dispatcher", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		done   chan struct{}
+		ps     *memory.PersistenceService
+		d      *webhook.Dispatcher
+		rcv    *receiver
+		srv    *httptest.Server
+	)
+
+	deliveries := func() []*cce.WebhookDelivery {
+		es, _, err := ps.ReadPage(ctx, &cce.WebhookDelivery{}, cce.Query{Sort: "created_at"})
+		Expect(err).ToNot(HaveOccurred())
+
+		var ds []*cce.WebhookDelivery
+		for _, e := range es {
+			ds = append(ds, e.(*cce.WebhookDelivery))
+		}
+		return ds
+	}
+
+	status := func() string {
+		ds := deliveries()
+		if len(ds) != 1 {
+			return ""
+		}
+		return ds[0].Status
+	}
+
+	BeforeEach(func() {
+		var err error
+		ps, err = memory.NewPersistenceService("")
+		Expect(err).ToNot(HaveOccurred())
+
+		rcv = &receiver{}
+		srv = httptest.NewServer(rcv)
+
+		Expect(ps.Create(context.Background(), &cce.Webhook{
+			ID:     "webhook-1",
+			URL:    srv.URL,
+			Secret: secret,
+			Events: []string{cce.NotificationAppDeployFailed},
+		})).To(Succeed())
+		Expect(ps.Create(context.Background(), &cce.Webhook{
+			ID:     "webhook-2",
+			URL:    srv.URL,
+			Secret: secret,
+			Events: []string{cce.NotificationNodeEnrolled},
+		})).To(Succeed())
+
+		d = webhook.NewDispatcher(ps)
+		d.MaxAttempts = 3
+		d.MinBackoff = 10 * time.Millisecond
+		d.PollInterval = 5 * time.Millisecond
+
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			Expect(d.Run(ctx)).To(Succeed())
+		}()
+	})
+
+	AfterEach(func() {
+		cancel()
+		<-done
+		srv.Close()
+	})
+
+	It("Should deliver a signed notification to the subscribed webhooks", func() {
+		d.Notify(cce.WithActor(context.Background(), "admin"), cce.NotificationAppDeployFailed,
+			&cce.AppDeployFailure{NodeID: "node-1", AppID: "app-1", Error: "failed"})
+
+		Eventually(status).Should(Equal(cce.DeliverySucceeded))
+		Expect(rcv.count()).To(Equal(1))
+
+		ds := deliveries()
+		Expect(ds[0].WebhookID).To(Equal("webhook-1"))
+		Expect(ds[0].Attempts).To(Equal(1))
+		Expect(ds[0].ResponseCode).To(Equal(http.StatusOK))
+
+		h, body := rcv.headers[0], rcv.bodies[0]
+		Expect(h.Get(webhook.EventHeader)).To(Equal(cce.NotificationAppDeployFailed))
+		Expect(h.Get(webhook.DeliveryHeader)).To(Equal(ds[0].ID))
+		Expect(webhook.Verify(secret, h.Get(webhook.TimestampHeader), body,
+			h.Get(webhook.SignatureHeader))).To(BeTrue())
+		Expect(webhook.Verify("another secret!!", h.Get(webhook.TimestampHeader), body,
+			h.Get(webhook.SignatureHeader))).To(BeFalse())
+
+		var n webhook.Notification
+		Expect(json.Unmarshal(body, &n)).To(Succeed())
+		Expect(n.Type).To(Equal(cce.NotificationAppDeployFailed))
+		Expect(n.Actor).To(Equal("admin"))
+		Expect(n.Data).To(MatchJSON(`{"node_id":"node-1","app_id":"app-1","error":"failed"}`))
+	})
+
+	It("Should retry a failed delivery", func() {
+		rcv.statuses = []int{http.StatusInternalServerError}
+		d.Notify(context.Background(), cce.NotificationNodeEnrolled, &cce.Node{ID: "node-1"})
+
+		Eventually(status).Should(Equal(cce.DeliverySucceeded))
+		Expect(rcv.count()).To(Equal(2))
+		Expect(deliveries()[0].Attempts).To(Equal(2))
+		Expect(deliveries()[0].Error).To(BeEmpty())
+	})
+
+	It("Should give up after the maximum number of attempts", func() {
+		rcv.statuses = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+		d.Notify(context.Background(), cce.NotificationNodeEnrolled, &cce.Node{ID: "node-1"})
+
+		Eventually(status).Should(Equal(cce.DeliveryFailed))
+		Expect(rcv.count()).To(Equal(3))
+
+		ds := deliveries()
+		Expect(ds[0].Attempts).To(Equal(3))
+		Expect(ds[0].ResponseCode).To(Equal(http.StatusBadGateway))
+		Expect(ds[0].Error).To(ContainSubstring("502 Bad Gateway"))
+		Expect(ds[0].NextAttemptAt).To(BeEmpty())
+	})
+
+	It("Should store notifications while a webhook is slow to respond", func() {
+		rcv.block = make(chan struct{})
+		for i := 0; i < 300; i++ {
+			d.Notify(context.Background(), cce.NotificationNodeEnrolled, &cce.Node{ID: "node-1"})
+		}
+		Expect(deliveries()).To(HaveLen(300))
+
+		close(rcv.block)
+		Eventually(rcv.count).Should(Equal(300))
+	})
+
+	It("Should deliver notifications stored before a restart", func() {
+		cancel()
+		<-done
+		d.Notify(context.Background(), cce.NotificationNodeEnrolled, &cce.Node{ID: "node-1"})
+		Expect(status()).To(Equal(cce.DeliveryPending))
+
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			Expect(webhook.NewDispatcher(ps).Run(ctx)).To(Succeed())
+		}()
+
+		Eventually(status).Should(Equal(cce.DeliverySucceeded))
+		Expect(rcv.count()).To(Equal(1))
+	})
+
+	It("Should not deliver notifications nobody subscribed to", func() {
+		d.Notify(context.Background(), cce.NotificationAppLifecycle, &cce.NodeAppReq{Cmd: "start"})
+
+		Consistently(deliveries, 50*time.Millisecond).Should(BeEmpty())
+		Expect(rcv.count()).To(Equal(0))
+	})
+})
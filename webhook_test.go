@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package cce_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	cce "github.com/open-ness/edgecontroller"
+)
+
+var _ = Describe("Entities: Webhook", func() {
+	var (
+		wh *cce.Webhook
+	)
+
+	BeforeEach(func() {
+		wh = &cce.Webhook{
+			ID:     "c1c3a5a4-6b58-4993-8d45-bde6239d4baa",
+			URL:    "https://noc.example.com/hooks/controller",
+			Secret: "0123456789abcdef",
+			Events: []string{cce.NotificationAppDeployFailed},
+		}
+	})
+
+	Describe("GetTableName", func() {
+		It(`Should return "webhooks"`, func() {
+			Expect(wh.GetTableName()).To(Equal("webhooks"))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("Should not return an error for a valid webhook", func() {
+			Expect(wh.Validate()).To(Succeed())
+		})
+
+		It("Should return an error for an invalid ID", func() {
+			wh.ID = "123"
+			Expect(wh.Validate()).To(MatchError("id not a valid uuid"))
+		})
+
+		It("Should return an error for a relative URL", func() {
+			wh.URL = "/hooks/controller"
+			Expect(wh.Validate()).To(MatchError("url must be an absolute http or https URL"))
+		})
+
+		It("Should return an error for a non-HTTP URL", func() {
+			wh.URL = "ftp://noc.example.com/hooks"
+			Expect(wh.Validate()).To(MatchError("url must be an absolute http or https URL"))
+		})
+
+		It("Should return an error for a short secret", func() {
+			wh.Secret = "secret"
+			Expect(wh.Validate()).To(MatchError("secret must be at least 16 characters"))
+		})
+
+		It("Should return an error for an unknown event", func() {
+			wh.Events = append(wh.Events, "node.exploded")
+			Expect(wh.Validate()).To(MatchError(`event "node.exploded" is invalid`))
+		})
+	})
+
+	Describe("Subscribed", func() {
+		It("Should return true for a subscribed event", func() {
+			Expect(wh.Subscribed(cce.NotificationAppDeployFailed)).To(BeTrue())
+		})
+
+		It("Should return false for another event", func() {
+			Expect(wh.Subscribed(cce.NotificationNodeEnrolled)).To(BeFalse())
+		})
+
+		It("Should return true for any event if there are no events", func() {
+			wh.Events = nil
+			Expect(wh.Subscribed(cce.NotificationNodeEnrolled)).To(BeTrue())
+		})
+	})
+
+	Describe("Redacted", func() {
+		It("Should return a copy without the secret", func() {
+			redacted := wh.Redacted().(*cce.Webhook)
+			Expect(redacted.Secret).To(BeEmpty())
+			Expect(redacted.URL).To(Equal(wh.URL))
+			Expect(wh.Secret).To(Equal("0123456789abcdef"))
+		})
+	})
+})